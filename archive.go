@@ -0,0 +1,67 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// archiveInputs are the three input files looked for inside an -archive zip, by the
+// prefix their base name must start with. An entry named exactly like the loose
+// file (payroll_data.csv, ...) wins over other prefix matches.
+var archiveInputs = []struct {
+	kind, exact, prefix string
+}{
+	{"payroll", "payroll_data.csv", "payroll"},
+	{"time", "time_data.csv", "time"},
+	{"benefits", "benefits.csv", "benefit"},
+}
+
+// findArchiveInputs locates the payroll, time, and benefits entries in an archive and
+// returns their entry names keyed by kind. Base names are matched case-insensitively
+// and must end in .csv, .tsv, .txt, or .xlsx; directories and macOS resource forks are
+// ignored. A kind with no match, or with several equally good ones, is an error.
+func findArchiveInputs(archive *zip.Reader) (map[string]string, error) {
+	var names []string
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	found := make(map[string]string)
+	for _, input := range archiveInputs {
+		var exact, prefixed []string
+		for _, name := range names {
+			base := strings.ToLower(path.Base(name))
+			switch path.Ext(base) {
+			case ".csv", ".tsv", ".txt", ".xlsx":
+			default:
+				continue
+			}
+			if base == input.exact {
+				exact = append(exact, name)
+			} else if strings.HasPrefix(base, input.prefix) {
+				prefixed = append(prefixed, name)
+			}
+		}
+		matches := exact
+		if len(matches) == 0 {
+			matches = prefixed
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("archive has no %s file (want a name starting with %q); entries: %s",
+				input.kind, input.prefix, strings.Join(names, ", "))
+		case 1:
+			found[input.kind] = matches[0]
+		default:
+			return nil, fmt.Errorf("archive has several %s files: %s", input.kind, strings.Join(matches, ", "))
+		}
+	}
+	return found, nil
+}
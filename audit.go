@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// AuditEntry is one run's record in the -audit-log: what it read, under which
+// config, and what it wrote, each by SHA-256. Hash covers every other field,
+// PrevHash included, so each entry seals the one before it and altering or
+// removing any entry breaks the chain from there on.
+type AuditEntry struct {
+	Seq        int               `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Inputs     map[string]string `json:"inputs"`     // file name -> SHA-256
+	ConfigHash string            `json:"configHash"` // SHA-256 of the effective tax config as JSON
+	Outputs    map[string]string `json:"outputs"`    // file name -> SHA-256
+	Records    int               `json:"records"`
+	PrevHash   string            `json:"prevHash"`
+	Hash       string            `json:"hash"`
+}
+
+// computeHash hashes the entry's JSON with Hash left empty. encoding/json writes
+// struct fields in order and map keys sorted, so the encoding is canonical.
+func (e AuditEntry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hashFile returns the SHA-256 of a file's contents in hex; a URL is fetched.
+func hashFile(name string) (string, error) {
+	file, err := openInput(name, ReaderOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("cannot hash %s: %v", name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFiles hashes each named file.
+func hashFiles(names []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(names))
+	for _, name := range names {
+		sum, err := hashFile(name)
+		if err != nil {
+			return nil, err
+		}
+		hashes[name] = sum
+	}
+	return hashes, nil
+}
+
+// appendAuditEntry completes entry (sequence number, link to the last entry,
+// hash) and appends it to the log at path as one JSON line, creating the log if
+// needed. The existing chain is verified first, so a run never extends a log that
+// has already been tampered with.
+func appendAuditEntry(path string, entry AuditEntry) error {
+	entries, err := readAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if n, err := verifyAuditChain(entries); err != nil {
+		return fmt.Errorf("audit log %s is broken at entry %d, not appending: %v", path, n+1, err)
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+	} else {
+		entry.Seq = 1
+	}
+	if entry.Hash, err = entry.computeHash(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %v", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot append to audit log: %v", err)
+	}
+	return file.Close()
+}
+
+// readAuditLog reads every entry of the log at path.
+func readAuditLog(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e AuditEntry
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&e); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read audit log: %v", err)
+	}
+	return entries, nil
+}
+
+// verifyAuditChain checks that every entry's hash matches its contents, that it
+// links to the entry before it, and that sequence numbers run on without a gap.
+// On failure it also returns the index of the first bad entry.
+func verifyAuditChain(entries []AuditEntry) (int, error) {
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != i+1 {
+			return i, fmt.Errorf("sequence number %d, want %d", e.Seq, i+1)
+		}
+		if e.PrevHash != prev {
+			return i, fmt.Errorf("previous hash %.12s does not match entry %d's %.12s", e.PrevHash, i, prev)
+		}
+		sum, err := e.computeHash()
+		if err != nil {
+			return i, err
+		}
+		if sum != e.Hash {
+			return i, fmt.Errorf("contents do not match hash %.12s", e.Hash)
+		}
+		prev = e.Hash
+	}
+	return len(entries), nil
+}
+
+// configHash is the SHA-256 of cfg as JSON.
+func configHash(cfg TaxConfig) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
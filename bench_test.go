@@ -0,0 +1,108 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchEmployees is the size of the benchmark dataset, each employee with 4 periods.
+const benchEmployees = 1000
+
+// benchInputs generates the benchmark dataset and returns it as an in-memory zip
+// archive, so the readers time parsing rather than the disk.
+func benchInputs(b *testing.B) ReaderOptions {
+	b.Helper()
+	dir := b.TempDir()
+	if err := generateData(dir, benchEmployees, 4, 1); err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range []string{"payroll_data.csv", "time_data.csv", "benefits.csv"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		b.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ReaderOptions{Archive: r}
+}
+
+// benchRead reads the three inputs the way a run does.
+func benchRead(b *testing.B, opts ReaderOptions) (map[string]PayrollRecord, map[string]TimeRecord, map[string]BenefitsRecord) {
+	payrollMap, err := readPayrollRecords("payroll_data.csv", opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	timeMap, err := readTimeRecords("time_data.csv", opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benefitsMap, err := readBenefitsRecords("benefits.csv", opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return payrollMap, timeMap, benefitsMap
+}
+
+func BenchmarkRead(b *testing.B) {
+	opts := benchInputs(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchRead(b, opts)
+	}
+}
+
+func BenchmarkCompute(b *testing.B) {
+	payrollMap, timeMap, benefitsMap := benchRead(b, benchInputs(b))
+	cfg, opts := defaultTaxConfig(), defaultComputeOptions()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeRegister(payrollMap, timeMap, benefitsMap, cfg, opts)
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	payrollMap, timeMap, benefitsMap := benchRead(b, benchInputs(b))
+	registers := computeRegister(payrollMap, timeMap, benefitsMap, defaultTaxConfig(), defaultComputeOptions()).Registers
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := writeRegisterCSV(io.Discard, registers, defaultWriterOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEndToEnd(b *testing.B) {
+	inputs := benchInputs(b)
+	cfg, opts := defaultTaxConfig(), defaultComputeOptions()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		payrollMap, timeMap, benefitsMap := benchRead(b, inputs)
+		result := computeRegister(payrollMap, timeMap, benefitsMap, cfg, opts)
+		if _, err := writeRegisterCSV(io.Discard, result.Registers, defaultWriterOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// uncachedFlags are flags whose effects a cached register cannot reproduce (extra
+// reports, per-period files, live displays). Setting any of them bypasses the cache.
+var uncachedFlags = map[string]bool{
+	"fx-summary": true, "employer-cost": true, "paystubs-dir": true, "remittance": true, "export": true,
+	"rate-changes": true, "benefit-changes": true, "period-gaps": true, "top-n": true, "net-ratio-stats": true, "expected-net": true,
+	"expected-net-file": true, "split-by-period": true, "shards": true, "tui": true, "metrics-addr": true,
+	"preview": true, "statement": true, "ss-wage-base": true, "w2-preview": true, "audit-log": true,
+	"explain-taxes": true, "preview-diff": true, "deposit-out": true, "nacha-out": true,
+	"projection": true, "warnings-file": true, "retro-pay": true,
+}
+
+// cacheableRun reports whether the flags set on the command line allow the register
+// to be served from the cache, and if not, which flag prevents it.
+func cacheableRun() (bool, string) {
+	blocker := ""
+	flag.Visit(func(f *flag.Flag) {
+		if blocker == "" && uncachedFlags[f.Name] {
+			blocker = f.Name
+		}
+	})
+	return blocker == "", blocker
+}
+
+// runCacheKey hashes everything a register depends on: this binary (by size and
+// modification time), the register schema version, every flag set on the command
+// line except where output and the run report go and the cache flags themselves,
+// and the contents of the input files. Missing files hash as absent rather than failing, so the normal
+// read reports them.
+func runCacheKey(files []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "schema %d\n", registerSchemaVersion)
+	if self, err := os.Executable(); err == nil {
+		if info, err := os.Stat(self); err == nil {
+			fmt.Fprintf(h, "binary %d %d\n", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "out", "cache-dir", "no-cache", "report":
+			return
+		}
+		fmt.Fprintf(h, "flag %s=%q\n", f.Name, f.Value.String())
+	})
+	for _, name := range files {
+		fmt.Fprintf(h, "file %q\n", name)
+		file, err := openInput(name, ReaderOptions{})
+		if err != nil {
+			fmt.Fprintf(h, "absent\n")
+			continue
+		}
+		_, err = io.Copy(h, file)
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("cannot hash %s: %v", name, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies src to dst, where dst may be "-" for stdout.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := createOutput(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// restoreFromCache copies a cached register (and its .meta.json sidecar, if one was
+// cached) to output. It reports false, with no error, when there is no entry for key.
+func restoreFromCache(dir, key, output string) (bool, error) {
+	cached := filepath.Join(dir, key+".out")
+	if _, err := os.Stat(cached); err != nil {
+		return false, nil
+	}
+	if err := copyFile(cached, output); err != nil {
+		return false, fmt.Errorf("cannot restore cached register: %v", err)
+	}
+	if meta := filepath.Join(dir, key+".meta.json"); output != stdoutName {
+		if _, err := os.Stat(meta); err == nil {
+			if err := copyFile(meta, metaFilename(output)); err != nil {
+				return false, fmt.Errorf("cannot restore cached sidecar: %v", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// saveToCache stores a just-written register, and its sidecar when the output format
+// writes one, under key. Files are written to a temporary name and renamed, so a
+// concurrent run never sees half an entry. A register written to stdout cannot be
+// cached and is skipped.
+func saveToCache(dir, key, output string, sidecar bool) error {
+	if output == stdoutName {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create cache directory: %v", err)
+	}
+	store := func(src, name string) error {
+		tmp, err := os.CreateTemp(dir, name+".tmp*")
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		if err := copyFile(src, tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		return os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if sidecar {
+		if err := store(metaFilename(output), key+".meta.json"); err != nil {
+			return fmt.Errorf("cannot cache sidecar: %v", err)
+		}
+	}
+	// The register goes last: its presence is what marks the entry complete.
+	if err := store(output, key+".out"); err != nil {
+		return fmt.Errorf("cannot cache register: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ColumnMapping renames source headers to the column names the readers expect, per
+// dataset (-column-map). It is loaded from JSON such as
+//
+//	{"payroll": {"EmpID": "Employee ID", "Rate": "Hourly Rate"},
+//	 "time": {"employee_id": "Employee ID", "period": "Pay Period"}}
+//
+// Datasets are named as in error messages: payroll, time, benefits, daily time, ...
+// Source names match after normalizeHeader, like every other header lookup.
+type ColumnMapping map[string]map[string]string
+
+// requiredColumns are the columns each main input reads by position, in order.
+var requiredColumns = map[string][]string{
+	"payroll":  {"Employee ID", "Employee Name", "Job Title", "Pay Period", "Hourly Rate"},
+	"time":     {"Employee ID", "Pay Period", "Regular Hours", "Overtime Hours"},
+	"benefits": {"Employee ID", "Pay Period", "Health Insurance", "Retirement", "Other Benefits"},
+}
+
+// loadColumnMapping reads a -column-map file.
+func loadColumnMapping(filename string) (ColumnMapping, error) {
+	var mapping ColumnMapping
+	data, err := readInput(filename, ReaderOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read column map: %w", err)
+	}
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("cannot parse column map %s: %v", filename, err)
+	}
+	for dataset, fields := range mapping {
+		seen := make(map[string]string)
+		for _, source := range sortedKeys(fields) {
+			logical := normalizeHeader(fields[source])
+			if logical == "" {
+				return nil, fmt.Errorf("column map %s: %s column %q maps to an empty name", filename, dataset, source)
+			}
+			if prev, dup := seen[logical]; dup {
+				return nil, fmt.Errorf("column map %s: %s columns %q and %q both map to %q", filename, dataset, prev, source, fields[source])
+			}
+			seen[logical] = source
+		}
+	}
+	return mapping, nil
+}
+
+// arrange applies the mapping for dataset to a header row. It returns the renamed
+// header and the order to take each row's cells in: the dataset's required columns
+// first, in the order the reader expects them, then every other column as it came.
+// A required column that neither the header nor the mapping provides is an error.
+// A nil order means the file is used as it is.
+func (m ColumnMapping) arrange(dataset string, header []string) ([]string, []int, error) {
+	if _, ok := m[dataset]; !ok {
+		return header, nil, nil
+	}
+	renamed := m.rename(dataset, header)
+
+	position := make(map[string]int, len(renamed))
+	for i, name := range renamed {
+		if _, dup := position[normalizeHeader(name)]; !dup {
+			position[normalizeHeader(name)] = i
+		}
+	}
+	var order []int
+	used := make(map[int]bool)
+	var missing []string
+	for _, name := range requiredColumns[dataset] {
+		i, ok := position[normalizeHeader(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		order = append(order, i)
+		used[i] = true
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("no column maps to required field(s) %s", strings.Join(missing, ", "))
+	}
+	for i := range renamed {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+	return pick(renamed, order), order, nil
+}
+
+// rename applies the mapping for dataset to a header row's names, leaving the
+// columns where they are.
+func (m ColumnMapping) rename(dataset string, header []string) []string {
+	rename := make(map[string]string, len(m[dataset]))
+	for source, logical := range m[dataset] {
+		rename[normalizeHeader(source)] = logical
+	}
+	renamed := make([]string, len(header))
+	for i, name := range header {
+		renamed[i] = name
+		if logical, ok := rename[normalizeHeader(name)]; ok {
+			renamed[i] = logical
+		}
+	}
+	return renamed
+}
+
+// Name orders (-name-order) for an Employee Name joined from separate columns.
+const (
+	nameFirstLast = "first-last" // "Jordan Brown"
+	nameLastFirst = "last-first" // "Brown, Jordan"
+)
+
+// nameColumns are the positions of a source's separate First Name and Last Name
+// columns, for files that have no Employee Name column.
+type nameColumns struct {
+	first, last int
+	order       string
+}
+
+// findNameColumns locates First Name and Last Name in a header already renamed
+// by the mapping. It returns nil when the header has an Employee Name column, or
+// not both of the others: those files are read as they are.
+func findNameColumns(header []string, order string) *nameColumns {
+	names := &nameColumns{first: -1, last: -1, order: order}
+	for i, name := range header {
+		switch normalizeHeader(name) {
+		case normalizeHeader("Employee Name"):
+			return nil
+		case normalizeHeader("First Name"):
+			names.first = i
+		case normalizeHeader("Last Name"):
+			names.last = i
+		}
+	}
+	if names.first < 0 || names.last < 0 {
+		return nil
+	}
+	return names
+}
+
+// join builds a row's Employee Name in the configured order. A blank part is
+// left out along with its separator.
+func (n *nameColumns) join(row []string) string {
+	cell := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	first, last := cell(n.first), cell(n.last)
+	switch {
+	case first == "":
+		return last
+	case last == "":
+		return first
+	case n.order == nameLastFirst:
+		return last + ", " + first
+	}
+	return first + " " + last
+}
+
+// pick returns row's cells in order; cells past the end of a short row are blank.
+func pick(row []string, order []int) []string {
+	out := make([]string, len(order))
+	for j, i := range order {
+		if i < len(row) {
+			out[j] = row[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// combinedBenefitsColumns are the optional benefits columns a combined file may
+// carry. Named benefits cannot be told apart from the payroll and time columns in
+// a combined file, so only these are read besides the required ones.
+var combinedBenefitsColumns = []string{"Employer Contribution", "Imputed Income"}
+
+// readCombinedRecords reads one wide file that already joins payroll, time and
+// benefits (-combined): every row holds all three records for one employee-period,
+// found by header name, so the file needs a header with each reader's required
+// columns (see requiredColumns) in any order. -column-map renames its columns
+// under the dataset name "combined". Each row then goes through the same parsing
+// as the separate files, so the records come out exactly as if read from them.
+func readCombinedRecords(filename string, opts ReaderOptions) (map[string]PayrollRecord, map[string]TimeRecord, map[string]BenefitsRecord, error) {
+	if opts.NoHeader {
+		return nil, nil, nil, fmt.Errorf("a combined file must have a header row")
+	}
+	payrollMap := make(map[string]PayrollRecord)
+	timeMap := make(map[string]TimeRecord)
+	benefitsMap := make(map[string]BenefitsRecord)
+	datasets := []struct {
+		name  string
+		rows  func(cols columnMap, row []string, line int) error
+		order []int
+		cols  columnMap
+	}{
+		{name: "payroll", rows: payrollRows(payrollMap, opts)},
+		{name: "time", rows: timeRows(timeMap, opts)},
+		{name: "benefits", rows: benefitsRows(benefitsMap, opts)},
+	}
+	var header []string
+	err := readCSV(filename, "combined", opts, func(cols columnMap, row []string, line int) error {
+		if header == nil {
+			// First data row: lay out each dataset's view of the header, the
+			// required columns first as its reader expects.
+			header = cols.names
+			var missing []string
+			for i := range datasets {
+				d := &datasets[i]
+				names, order, err := ColumnMapping{d.name: {}}.arrange(d.name, header)
+				if err != nil {
+					for _, name := range requiredColumns[d.name] {
+						if _, ok := cols.lookup(name); !ok {
+							missing = append(missing, d.name+" "+name)
+						}
+					}
+					continue
+				}
+				if d.name == "benefits" {
+					// Keep only the benefits columns; anything else would be read
+					// as a named benefit.
+					n := len(requiredColumns[d.name])
+					for j := n; j < len(order); j++ {
+						for _, optional := range combinedBenefitsColumns {
+							if normalizeHeader(names[j]) == normalizeHeader(optional) {
+								names[n], order[n] = names[j], order[j]
+								n++
+							}
+						}
+					}
+					names, order = names[:n], order[:n]
+				}
+				if d.cols, err = newColumnMap(names); err != nil {
+					return err
+				}
+				if err := opts.checkHeader(d.name, d.cols); err != nil {
+					return err
+				}
+				d.order = order
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("combined header lacks required column(s): %s", strings.Join(missing, ", "))
+			}
+		}
+		for _, d := range datasets {
+			if err := d.rows(d.cols, pick(row, d.order), line); err != nil {
+				return fmt.Errorf("%s: %v", d.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payrollMap, timeMap, benefitsMap, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+)
+
+// RegisterDelta compares one employee-period's register line under two configs.
+// Base or New is nil when only one config produced the line (the other skipped it).
+type RegisterDelta struct {
+	EmployeeID   string
+	EmployeeName string
+	PayPeriod    string
+	Currency     string
+	Base, New    *PayRegister
+}
+
+// registerDeltaColumns are the per-line amounts the comparison reports, in order.
+var registerDeltaColumns = []struct {
+	name  string
+	value func(PayRegister) Money
+}{
+	{"Gross Wages", func(r PayRegister) Money { return r.GrossWages }},
+	{"Federal Tax", func(r PayRegister) Money { return r.FederalTax }},
+	{"State Tax", func(r PayRegister) Money { return r.StateTax }},
+	{"Local Tax", func(r PayRegister) Money { return r.LocalTax }},
+	{"Social Security", func(r PayRegister) Money { return r.SocialSecurity }},
+	{"Medicare", func(r PayRegister) Money { return r.Medicare }},
+	{"Total Deductions", func(r PayRegister) Money { return r.TotalDeductions }},
+	{"Net Pay", func(r PayRegister) Money { return r.NetPay }},
+}
+
+// compareRegisters pairs the lines of two runs over the same inputs by employee and
+// period, in employee-then-period order.
+func compareRegisters(base, candidate []PayRegister) []RegisterDelta {
+	deltas := make(map[string]*RegisterDelta)
+	add := func(reg PayRegister, isBase bool) {
+		key := makeKey(reg.EmployeeID, reg.PayPeriod)
+		d, ok := deltas[key]
+		if !ok {
+			d = &RegisterDelta{EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, PayPeriod: reg.PayPeriod, Currency: reg.Currency}
+			deltas[key] = d
+		}
+		if isBase {
+			d.Base = &reg
+		} else {
+			d.New = &reg
+		}
+	}
+	for _, reg := range base {
+		add(reg, true)
+	}
+	for _, reg := range candidate {
+		add(reg, false)
+	}
+	out := make([]RegisterDelta, 0, len(deltas))
+	for _, key := range sortedKeys(deltas) {
+		out = append(out, *deltas[key])
+	}
+	return out
+}
+
+// writeRegisterComparison writes one row per employee-period with each amount's
+// change (new minus base), then base and new net pay, followed by a TOTAL row per
+// currency. Lines only one config produced are marked in the Status column and
+// count as zero on the missing side.
+func writeRegisterComparison(deltas []RegisterDelta, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create comparison file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Pay Period", "Currency", "Status"}
+	for _, c := range registerDeltaColumns {
+		header = append(header, c.name+" Change")
+	}
+	header = append(header, "Base Net Pay", "New Net Pay")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write comparison header: %v", err)
+	}
+
+	type totals struct {
+		changes  []Money
+		base, nw Money
+		lines    int
+	}
+	byCurrency := make(map[string]*totals)
+	for _, d := range deltas {
+		var base, nw PayRegister
+		status := "both"
+		switch {
+		case d.Base == nil:
+			nw, status = *d.New, "new only"
+		case d.New == nil:
+			base, status = *d.Base, "base only"
+		default:
+			base, nw = *d.Base, *d.New
+		}
+		t := byCurrency[d.Currency]
+		if t == nil {
+			t = &totals{changes: make([]Money, len(registerDeltaColumns))}
+			byCurrency[d.Currency] = t
+		}
+		t.lines++
+		t.base += base.NetPay
+		t.nw += nw.NetPay
+
+		money := opts.formatter(d.Currency)
+		label := opts.currencyLabel(PayRegister{Currency: d.Currency})
+		row := []string{csvText(d.EmployeeID), csvText(d.EmployeeName), csvText(d.PayPeriod), label, status}
+		for i, c := range registerDeltaColumns {
+			change := c.value(nw) - c.value(base)
+			t.changes[i] += change
+			row = append(row, money(change))
+		}
+		row = append(row, money(base.NetPay), money(nw.NetPay))
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write comparison row: %v", err)
+		}
+	}
+	for _, code := range sortedKeys(byCurrency) {
+		t := byCurrency[code]
+		money := opts.formatter(code)
+		row := []string{"TOTAL", "", "", opts.currencyLabel(PayRegister{Currency: code}), strconv.Itoa(t.lines) + " line(s)"}
+		for _, change := range t.changes {
+			row = append(row, money(change))
+		}
+		row = append(row, money(t.base), money(t.nw))
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write comparison total: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write comparison file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// configError lists every problem found in one config file, one per line.
+type configError struct {
+	source   string
+	problems []string
+}
+
+func (e configError) Error() string {
+	return fmt.Sprintf("%s has %d problem(s):\n  %s", e.source, len(e.problems), strings.Join(e.problems, "\n  "))
+}
+
+// checkConfigRate reports a rate outside [0, 1]. A rate above 1 is almost always
+// a percentage typed as a whole number, so the message says how to write it.
+func checkConfigRate(path string, rate float64, add func(path, format string, args ...any)) {
+	switch {
+	case rate < 0:
+		add(path, "must not be negative, got %g", rate)
+	case rate > 1:
+		add(path, "is %g, above 1; rates are fractions, so write %g%% as %.10g", rate, rate, rate/100)
+	}
+}
+
+// unknownConfigKeys lists the keys in a config file that no TaxConfig field takes
+// (a misspelling, usually), with their paths and the closest known key. JSON
+// decoding would otherwise drop them silently. Keys match fields
+// case-insensitively, as decoding does.
+func unknownConfigKeys(data []byte) []string {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil // the decode proper reports it
+	}
+	var problems []string
+	var walk func(path string, v any, t reflect.Type)
+	walk = func(path string, v any, t reflect.Type) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+			return
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return
+			}
+			fields := make(map[string]reflect.Type)
+			var names []string
+			for i := 0; i < t.NumField(); i++ {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				if name == "" || name == "-" {
+					continue
+				}
+				fields[strings.ToLower(name)] = t.Field(i).Type
+				names = append(names, name)
+			}
+			for _, key := range sortedKeys(obj) {
+				fieldPath := strings.TrimPrefix(path+"."+key, ".")
+				ft, ok := fields[strings.ToLower(key)]
+				if !ok {
+					msg := fieldPath + ": unknown field"
+					if guess := closestName(key, names); guess != "" {
+						msg += fmt.Sprintf(" (did you mean %q?)", guess)
+					}
+					problems = append(problems, msg)
+					continue
+				}
+				walk(fieldPath, obj[key], ft)
+			}
+		case reflect.Map:
+			if obj, ok := v.(map[string]any); ok {
+				for _, key := range sortedKeys(obj) {
+					walk(path+"."+key, obj[key], t.Elem())
+				}
+			}
+		case reflect.Slice:
+			if list, ok := v.([]any); ok {
+				for i, item := range list {
+					walk(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+				}
+			}
+		}
+	}
+	walk("", raw, reflect.TypeFor[TaxConfig]())
+	return problems
+}
+
+// closestName is the name within two edits of key, ignoring case, or "" if none is.
+func closestName(key string, names []string) string {
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// configComments documents each top-level TaxConfig key in the -init-config
+// template, one comment line per entry.
+var configComments = map[string][]string{
+	"federalRate":        {"Flat income tax rates, as fractions of the taxable base (0.12 = 12%)."},
+	"socialSecurityRate": {"Employee FICA rates."},
+	"periodsPerYear":     {"Pay periods per year: 52 weekly, 26 biweekly, 24 semimonthly, 12 monthly."},
+	"localTaxRates": {"Local income tax rate per Work Locality code, e.g. {\"NYC\": 0.03876, \"PHILADELPHIA\": 0.0375};",
+		"localities not listed levy none."},
+	"benefitEligibility": {"Benefit categories (\"health\", \"retirement\", \"other\") each Employee Type may",
+		"receive, e.g. {\"PART-TIME\": [\"retirement\", \"other\"]}; types not listed, and a blank",
+		"type, are eligible for everything."},
+	"preTaxBenefits": {"Benefit categories deducted before income taxes, e.g. {\"retirement\": true}."},
+	"imputedBenefits": {"Benefits columns holding employer-paid benefits taxed as imputed income",
+		"instead of deducted from pay."},
+	"overtimeExemptTitles": {"Job titles exempt from overtime pay, matched case-insensitively."},
+	"exemptOvertimePay":    {"What exempt employees' overtime hours earn: \"regular\" (blank) or \"none\"."},
+	"defaultBenefits": {"Fallback benefits per job title for employees without a benefits record, e.g.",
+		"{\"Cashier\": {\"healthInsurance\": 50.00, \"retirement\": 0.00, \"otherBenefits\": 0.00, \"employerContribution\": 100.00}}."},
+	"reportingCurrency":          {"Currency the -fx-summary report converts to, and each currency's rate into it."},
+	"employerSocialSecurityRate": {"Employer FICA share, paid on top of gross."},
+	"socialSecurityWageBase":     {"The year's Social Security wage base; 0 means none."},
+	"taxableBases": {"Base of each tax: \"gross\", \"gross-minus-pretax\", or \"custom\" with an",
+		"\"exclude\" list of the benefit categories that reduce it."},
+}
+
+// configTemplateTail documents the settings the defaults leave out entirely.
+var configTemplateTail = []string{
+	"Employer 401k match on the Retirement column, by tiers of gross, with an optional annual cap:",
+	`"retirementMatch": {"tiers": [{"matchPercent": 100, "upToPercent": 3}, {"matchPercent": 50, "upToPercent": 5}], "annualCap": 0.00},`,
+	"Statutory contributions replacing Social Security and Medicare, e.g. Canada's CPP and EI:",
+	`"contributions": [{"name": "CPP", "rate": 0.0595, "employerRate": 0.0595, "threshold": 134.62, "wageBase": 68500.00}],`,
+	"Client-specific deductions after the standard ones: a flat amount, or a percent of net above a floor:",
+	`"deductions": [{"label": "Union Dues", "amount": 25.00}, {"label": "Loan", "percent": 0.10, "floor": 500.00}],`,
+	"The employer and its bank, for -nacha-out:",
+	`"ach": {"companyName": "ACME PAYROLL", "companyId": "1234567890", "originRouting": "021000021"},`,
+	"Per-tax-year tables, each listing only what changed that year:",
+	`"years": {"2025": {"socialSecurityWageBase": 176100.00}}`,
+}
+
+// configTemplate renders the default config as commented JSON for -init-config:
+// every setting present with its default value, and a comment saying what it
+// does. loadTaxConfig reads it back unchanged.
+func configTemplate() ([]byte, error) {
+	cfg := defaultTaxConfig()
+	cfg.LocalTaxRates = map[string]float64{}
+	cfg.BenefitEligibility = map[string][]string{}
+	cfg.PreTaxBenefits = map[string]bool{}
+	cfg.ImputedBenefits = []string{}
+	cfg.OvertimeExemptTitles = []string{}
+	cfg.DefaultBenefits = map[string]DefaultBenefits{}
+	cfg.FXRates = map[string]float64{}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteString("// Payroll tax config, written by -init-config with every setting at its default.\n")
+	out.WriteString("// Edit what you need and pass the file to -config; settings removed keep their defaults.\n")
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, `  "`) {
+			key, _, _ := strings.Cut(line[3:], `"`)
+			for _, c := range configComments[key] {
+				out.WriteString("  // " + c + "\n")
+			}
+		}
+		if i == len(lines)-1 {
+			for _, c := range configTemplateTail {
+				out.WriteString("  // " + c + "\n")
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes(), nil
+}
+
+// writeConfigTemplate writes the -init-config template to filename, or stdout
+// for "-". An existing file is left alone rather than overwritten.
+func writeConfigTemplate(filename string) error {
+	data, err := configTemplate()
+	if err != nil {
+		return fmt.Errorf("cannot render config template: %v", err)
+	}
+	if filename == stdoutName {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists; remove it or choose another path", filename)
+	} else if err != nil {
+		return fmt.Errorf("cannot create %s: %v", filename, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write %s: %v", filename, err)
+	}
+	return file.Close()
+}
+
+// stripJSONComments blanks out // comments running to the end of a line, outside
+// strings, so config files may carry comments. Blanking rather than deleting
+// keeps the byte offsets in JSON syntax errors pointing at the right place.
+func stripJSONComments(data []byte) []byte {
+	out := bytes.Clone(data)
+	inString, escaped := false, false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
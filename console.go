@@ -0,0 +1,27 @@
+package main
+
+import "log"
+
+// Console verbosity levels (-quiet, -verbose). Errors are always logged.
+const (
+	verbosityQuiet   = iota // errors only
+	verbosityNormal         // plus progress lines, warnings and notices
+	verbosityVerbose        // plus per-record detail, such as each unmatched record skipped
+)
+
+// verbosity is the run's console verbosity.
+var verbosity = verbosityNormal
+
+// logf logs a warning or notice unless -quiet is set.
+func logf(format string, args ...any) {
+	if verbosity >= verbosityNormal {
+		log.Printf(format, args...)
+	}
+}
+
+// verbosef logs per-record detail when -verbose is set.
+func verbosef(format string, args ...any) {
+	if verbosity >= verbosityVerbose {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Contribution is a statutory payroll contribution such as Canada's CPP and EI or
+// the UK's National Insurance. A config listing any replaces US Social Security
+// and Medicare with them:
+//
+//	"contributions": [
+//	  {"name": "CPP", "rate": 0.0595, "employerRate": 0.0595, "threshold": 134.62, "wageBase": 68500},
+//	  {"name": "EI", "rate": 0.0166, "employerRate": 0.02324, "wageBase": 63200}
+//	]
+//
+// A contribution is levied on the part of its taxable base (gross unless Base
+// says otherwise) above Threshold, the per-period exemption, and, when WageBase is
+// set, only until the employee's earnings it was levied on reach WageBase in the
+// calendar year. Employees exempt from FICA are exempt from these too.
+type Contribution struct {
+	Name         string      `json:"name"`
+	Rate         float64     `json:"rate"`
+	EmployerRate float64     `json:"employerRate"`
+	Threshold    Money       `json:"threshold"`
+	WageBase     Money       `json:"wageBase"`
+	Base         TaxableBase `json:"base"`
+}
+
+// ContributionAmount is one Contribution on one register line: the earnings it
+// was levied on and the employee's and employer's shares.
+type ContributionAmount struct {
+	Name     string `json:"name"`
+	Earnings Money  `json:"earnings"`
+	Employee Money  `json:"employee"`
+	Employer Money  `json:"employer"`
+}
+
+// contributionColumn is the register column holding a contribution's employee share.
+func contributionColumn(name string) string {
+	return name + " Contribution"
+}
+
+// checkContributions validates a config's contributions, passing each problem to
+// add with its field path.
+func checkContributions(contributions []Contribution, add func(path, format string, args ...any)) {
+	seen := make(map[string]bool)
+	for i, c := range contributions {
+		path := fmt.Sprintf("contributions[%d]", i)
+		name := strings.TrimSpace(c.Name)
+		switch {
+		case name == "":
+			add(path+".name", "is required")
+		case seen[strings.ToLower(name)]:
+			add(path+".name", "%q is listed twice", name)
+		}
+		seen[strings.ToLower(name)] = true
+		checkConfigRate(path+".rate", c.Rate, add)
+		checkConfigRate(path+".employerRate", c.EmployerRate, add)
+		if c.Threshold < 0 {
+			add(path+".threshold", "must not be negative, got %s", c.Threshold)
+		}
+		if c.WageBase < 0 {
+			add(path+".wageBase", "must not be negative (0 means none), got %s", c.WageBase)
+		}
+		switch c.Base.Kind {
+		case "", baseGross, baseGrossMinusPretax, baseCustom:
+		default:
+			add(path+".base.kind", "unknown kind %q (want gross, gross-minus-pretax, or custom)", c.Base.Kind)
+		}
+		for _, category := range c.Base.Exclude {
+			if !slices.Contains([]string{"health", "retirement", "other"}, category) {
+				add(path+".base.exclude", "unknown benefit category %q", category)
+			}
+		}
+	}
+}
+
+// contributionsCapped reports whether any year's contributions have a wage base,
+// which needs each employee's periods computed in order.
+func (cfg TaxConfig) contributionsCapped() bool {
+	if slices.ContainsFunc(cfg.Contributions, func(c Contribution) bool { return c.WageBase > 0 }) {
+		return true
+	}
+	for _, yearCfg := range cfg.Years {
+		if yearCfg.contributionsCapped() {
+			return true
+		}
+	}
+	return false
+}
+
+// contribute computes one contribution on a line's taxable base, before any wage
+// base. A negative base (a correction) is refunded in full.
+func (c Contribution) contribute(base Money, acc *float64) ContributionAmount {
+	earnings := base
+	if base >= 0 {
+		earnings = max(base-c.Threshold, 0)
+	}
+	return ContributionAmount{
+		Name:     c.Name,
+		Earnings: earnings,
+		Employee: roundedMul(earnings, c.Rate, acc),
+		Employer: earnings.MulRate(c.EmployerRate),
+	}
+}
+
+// contributionTotal is the employees' share of all of a line's contributions.
+func contributionTotal(amounts []ContributionAmount) Money {
+	var total Money
+	for _, a := range amounts {
+		total += a.Employee
+	}
+	return total
+}
+
+// capContributions holds each of a register line's contributions to what is left
+// of its wage base for the employee's year, counting the line's earnings toward
+// it in ytd (keyed employee|year|currency|name), and adjusts the line's totals
+// to match. Lines must arrive in each employee's chronological order.
+func capContributions(reg *PayRegister, cfg TaxConfig, ytd map[string]Money) {
+	start, err := parsePeriod(reg.PayPeriod)
+	if err != nil {
+		return
+	}
+	for i := range reg.Contributions {
+		a := &reg.Contributions[i]
+		c := cfg.Contributions[i]
+		if c.WageBase <= 0 || a.Earnings <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%s|%s", reg.EmployeeID, start.Year(), reg.Currency, c.Name)
+		if room := max(c.WageBase-ytd[key], 0); a.Earnings > room {
+			refund := a.Employee - room.MulRate(c.Rate)
+			a.Earnings, a.Employee, a.Employer = room, room.MulRate(c.Rate), room.MulRate(c.EmployerRate)
+			reg.TotalDeductions -= refund
+			reg.NetPay += refund
+			for j := range reg.TaxDetails {
+				if reg.TaxDetails[j].Tax == c.Name {
+					reg.TaxDetails[j].Base, reg.TaxDetails[j].Amount, reg.TaxDetails[j].Method = room, a.Employee, "rate to wage base"
+				}
+			}
+		}
+		ytd[key] += a.Earnings
+	}
+	reg.TotalEmployerCost = computeEmployerCost(*reg)
+	reg.EffectiveTaxRate = effectiveTaxRate(*reg)
+}
+
+// socialSecurityCapped reports whether any year sets a Social Security wage base,
+// which needs each employee's periods computed in order.
+func (cfg TaxConfig) socialSecurityCapped() bool {
+	if cfg.SocialSecurityWageBase > 0 {
+		return true
+	}
+	for _, yearCfg := range cfg.Years {
+		if yearCfg.socialSecurityCapped() {
+			return true
+		}
+	}
+	return false
+}
+
+// capSocialSecurity holds a register line's Social Security, employee and
+// employer, to what is left of the year's wage base, counting the line's wages
+// toward it in ytd (keyed by ytdKey, seeded from -ytd-seed), and adjusts the
+// line's totals to match. Lines must arrive in each employee's chronological
+// order.
+func capSocialSecurity(reg *PayRegister, cfg TaxConfig, ytd map[string]Money) {
+	start, err := parsePeriod(reg.PayPeriod)
+	if err != nil || cfg.SocialSecurityWageBase <= 0 || reg.SocialSecurityWages <= 0 {
+		return
+	}
+	key := ytdKey(reg.EmployeeID, start.Year(), reg.Currency)
+	if room := max(cfg.SocialSecurityWageBase-ytd[key], 0); reg.SocialSecurityWages > room {
+		refund := reg.SocialSecurity - room.MulRate(cfg.SocialSecurityRate)
+		reg.SocialSecurityWages, reg.SocialSecurity = room, room.MulRate(cfg.SocialSecurityRate)
+		reg.EmployerSocialSecurity = room.MulRate(cfg.EmployerSocialSecurityRate)
+		reg.TotalDeductions -= refund
+		reg.NetPay += refund
+		for j := range reg.TaxDetails {
+			if reg.TaxDetails[j].Tax == "socialSecurity" {
+				reg.TaxDetails[j].Base, reg.TaxDetails[j].Amount, reg.TaxDetails[j].Method = room, reg.SocialSecurity, "rate to wage base"
+			}
+		}
+		reg.TotalEmployerCost = computeEmployerCost(*reg)
+		reg.EffectiveTaxRate = effectiveTaxRate(*reg)
+	}
+	ytd[key] += reg.SocialSecurityWages
+}
+
+// contributionNames lists the contributions on any of the registers, in the order
+// they first appear, for the register's contribution columns.
+func contributionNames(registers []PayRegister) []string {
+	var names []string
+	for _, reg := range registers {
+		for _, a := range reg.Contributions {
+			if !slices.Contains(names, a.Name) {
+				names = append(names, a.Name)
+			}
+		}
+	}
+	return names
+}
+
+// contributionAmount is the employee share of the named contribution on a line,
+// zero when the line has none.
+func contributionAmount(reg PayRegister, name string) Money {
+	for _, a := range reg.Contributions {
+		if a.Name == name {
+			return a.Employee
+		}
+	}
+	return 0
+}
+
+// addContributions adds b's contributions into a's by name, for totals.
+func addContributions(a, b []ContributionAmount) []ContributionAmount {
+	for _, add := range b {
+		i := slices.IndexFunc(a, func(x ContributionAmount) bool { return x.Name == add.Name })
+		if i < 0 {
+			a = append(a, ContributionAmount{Name: add.Name})
+			i = len(a) - 1
+		}
+		a[i].Earnings += add.Earnings
+		a[i].Employee += add.Employee
+		a[i].Employer += add.Employer
+	}
+	return a
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeductionRule computes one client-specific deduction for a register line. Rules run
+// after the standard taxes and benefits, in order, and each sees the net pay left by
+// the rules before it. A rule returning zero contributes nothing.
+//
+// Taxes, benefits and contributions are not rules: they feed the tax detail,
+// employer cost, arrears and wage bases, so computeRow levies them itself. Amounts
+// are Money, not float64, so a rule's deduction is exact to the cent.
+type DeductionRule interface {
+	Apply(reg *PayRegister) (amount Money, label string)
+}
+
+// Deduction is one applied DeductionRule result, kept on the register for itemization.
+type Deduction struct {
+	Label  string `json:"label"`
+	Amount Money  `json:"amount"`
+}
+
+// FlatDeduction takes a fixed amount every period (e.g. a union due).
+type FlatDeduction struct {
+	Label  string
+	Amount Money
+}
+
+func (d FlatDeduction) Apply(reg *PayRegister) (Money, string) {
+	return d.Amount, d.Label
+}
+
+// PercentOfNetAboveFloor takes a percentage of whatever net pay exceeds Floor, the
+// usual shape of a loan repayment that must leave the employee a minimum check.
+type PercentOfNetAboveFloor struct {
+	Label   string
+	Percent float64
+	Floor   Money
+}
+
+func (d PercentOfNetAboveFloor) Apply(reg *PayRegister) (Money, string) {
+	if reg.NetPay <= d.Floor {
+		return 0, d.Label
+	}
+	return (reg.NetPay - d.Floor).MulRate(d.Percent), d.Label
+}
+
+// DeductionConfig is one client-specific deduction in a config's "deductions"
+// list: a flat Amount every period, or Percent of the net pay above Floor.
+//
+//	"deductions": [
+//	  {"label": "Union Dues", "amount": 25.00},
+//	  {"label": "Loan", "percent": 0.10, "floor": 500.00}
+//	]
+type DeductionConfig struct {
+	Label   string  `json:"label"`
+	Amount  Money   `json:"amount"`
+	Percent float64 `json:"percent"`
+	Floor   Money   `json:"floor"`
+}
+
+// rule returns the DeductionRule d configures.
+func (d DeductionConfig) rule() DeductionRule {
+	if d.Percent > 0 {
+		return PercentOfNetAboveFloor{Label: d.Label, Percent: d.Percent, Floor: d.Floor}
+	}
+	return FlatDeduction{Label: d.Label, Amount: d.Amount}
+}
+
+// deductionRules returns the config's deductions as rules, in the order listed.
+func (cfg TaxConfig) deductionRules() []DeductionRule {
+	var rules []DeductionRule
+	for _, d := range cfg.Deductions {
+		rules = append(rules, d.rule())
+	}
+	return rules
+}
+
+// checkDeductions validates a config's deductions, passing each problem to add
+// with its field path.
+func checkDeductions(deductions []DeductionConfig, add func(path, format string, args ...any)) {
+	for i, d := range deductions {
+		path := fmt.Sprintf("deductions[%d]", i)
+		if strings.TrimSpace(d.Label) == "" {
+			add(path+".label", "is required")
+		}
+		switch {
+		case d.Amount != 0 && d.Percent != 0:
+			add(path, "sets both amount and percent; a deduction is one or the other")
+		case d.Amount < 0:
+			add(path+".amount", "must not be negative, got %s", d.Amount)
+		}
+		checkConfigRate(path+".percent", d.Percent, add)
+		switch {
+		case d.Floor < 0:
+			add(path+".floor", "must not be negative, got %s", d.Floor)
+		case d.Floor != 0 && d.Percent == 0:
+			add(path+".floor", "applies only to a percent deduction")
+		}
+	}
+}
+
+// applyDeductionRules runs rules against reg, folding each amount into the
+// custom-deduction total, total deductions, and net pay.
+func applyDeductionRules(reg *PayRegister, rules []DeductionRule) {
+	for _, rule := range rules {
+		amount, label := rule.Apply(reg)
+		if amount == 0 {
+			continue
+		}
+		reg.Deductions = append(reg.Deductions, Deduction{Label: label, Amount: amount})
+		reg.CustomDeductions += amount
+		reg.TotalDeductions += amount
+		reg.NetPay -= amount
+	}
+}
+
+// applyArrears limits this period's benefits and custom deductions, plus owed arrears
+// brought forward, to what net pay can bear above floor. Current deductions are
+// collected before arrears; whatever cannot be collected is left in
+// ArrearsOutstanding for the employee's next period. Taxes are never deferred.
+func applyArrears(reg *PayRegister, owed, floor Money) {
+	current := reg.TotalBenefits + reg.CustomDeductions
+	available := reg.NetPay + current - floor
+	if available < 0 {
+		available = 0
+	}
+	due := current + owed
+	withheld := min(due, available)
+	reg.ArrearsOutstanding = due - withheld
+	reg.ArrearsCollected = max(0, withheld-current)
+	reg.TotalDeductions += withheld - current
+	reg.NetPay -= withheld - current
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigDeductions(t *testing.T) {
+	row := func(cfg TaxConfig) PayRegister {
+		t.Helper()
+		reg, _, err := computeRow(PayrollRecord{EmployeeID: "012", PayPeriod: "2024-06", HourlyRate: 2000},
+			TimeRecord{EmployeeID: "012", PayPeriod: "2024-06", RegularHours: 80}, BenefitsRecord{}, cfg, defaultComputeOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reg
+	}
+	base := row(defaultTaxConfig())
+	cfg := defaultTaxConfig()
+	cfg.Deductions = []DeductionConfig{{Label: "Union Dues", Amount: 2500}, {Label: "Loan", Percent: 0.10, Floor: 50000}}
+	reg := row(cfg)
+
+	// The loan sees the net pay the union due left.
+	loan := (base.NetPay - 2500 - 50000).MulRate(0.10)
+	want := []Deduction{{"Union Dues", 2500}, {"Loan", loan}}
+	if len(reg.Deductions) != len(want) {
+		t.Fatalf("got deductions %v, want %v", reg.Deductions, want)
+	}
+	for i, d := range reg.Deductions {
+		if d != want[i] {
+			t.Errorf("deduction %d: got %v, want %v", i, d, want[i])
+		}
+	}
+	if reg.CustomDeductions != 2500+loan || reg.NetPay != base.NetPay-2500-loan {
+		t.Errorf("got custom deductions %s and net %s, want %s and %s", reg.CustomDeductions, reg.NetPay, 2500+loan, base.NetPay-2500-loan)
+	}
+
+	for _, tc := range []struct {
+		name string
+		d    DeductionConfig
+		want string
+	}{
+		{"no label", DeductionConfig{Amount: 100}, "deductions[0].label: is required"},
+		{"both kinds", DeductionConfig{Label: "X", Amount: 100, Percent: 0.1}, "sets both amount and percent"},
+		{"percent as a whole number", DeductionConfig{Label: "X", Percent: 10}, "deductions[0].percent: is 10, above 1"},
+		{"floor on a flat amount", DeductionConfig{Label: "X", Amount: 100, Floor: 100}, "applies only to a percent deduction"},
+	} {
+		cfg := defaultTaxConfig()
+		cfg.Deductions = []DeductionConfig{tc.d}
+		problems := strings.Join(cfg.problems(), "\n")
+		if !strings.Contains(problems, tc.want) {
+			t.Errorf("%s: got problems %q, want one mentioning %q", tc.name, problems, tc.want)
+		}
+	}
+}
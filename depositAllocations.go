@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DepositAllocation is one of an employee's direct-deposit accounts: a fixed
+// Amount, a Percent of the net left after the fixed amounts, or, with neither,
+// the account taking whatever remains.
+type DepositAllocation struct {
+	Account string
+	Amount  *Money
+	Percent *float64
+}
+
+// method names how the allocation asks for its share, for the report.
+func (a DepositAllocation) method() string {
+	switch {
+	case a.Amount != nil:
+		return "amount"
+	case a.Percent != nil:
+		return "percent"
+	}
+	return "remainder"
+}
+
+// DepositSplit is the part of one register line's net pay deposited to one
+// account. Flag says why it differs from what was asked for, if it does.
+type DepositSplit struct {
+	Account   string
+	Method    string
+	Requested string
+	Amount    Money
+	Flag      string
+}
+
+// readDepositAllocations reads the -deposit-allocations CSV: Employee ID, Account,
+// and one of Amount or Percent (0-100) per row, located by header, one row per
+// account in the order they are paid. A row with neither takes the remainder;
+// without one, the employee's last account does.
+func readDepositAllocations(filename string, opts ReaderOptions) (map[string][]DepositAllocation, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a deposit allocations file must have a header row")
+	}
+	allocations := make(map[string][]DepositAllocation)
+	err := readCSV(filename, "deposit allocations", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		a := DepositAllocation{Account: strings.TrimSpace(cols.value(row, "Account"))}
+		if a.Account == "" {
+			return fmt.Errorf("missing Account in row %d", line)
+		}
+		if v := strings.TrimSpace(cols.value(row, "Amount")); v != "" {
+			amount, err := parseMoney(v)
+			if err != nil || amount < 0 {
+				return fmt.Errorf("error parsing Amount in row %d: want a non-negative amount, got %q", line, v)
+			}
+			a.Amount = &amount
+		}
+		if v := strings.TrimSuffix(strings.TrimSpace(cols.value(row, "Percent")), "%"); v != "" {
+			percent, err := strconv.ParseFloat(v, 64)
+			if err != nil || percent < 0 || percent > 100 {
+				return fmt.Errorf("error parsing Percent in row %d: want a percentage between 0 and 100, got %q", line, v)
+			}
+			a.Percent = &percent
+		}
+		if a.Amount != nil && a.Percent != nil {
+			return fmt.Errorf("row %d sets both an amount and a percent", line)
+		}
+		var percents float64
+		for _, prev := range allocations[id] {
+			switch {
+			case strings.EqualFold(prev.Account, a.Account):
+				return fmt.Errorf("employee %s lists account %s twice (row %d)", id, a.Account, line)
+			case prev.method() == "remainder" && a.method() == "remainder":
+				return fmt.Errorf("employee %s has more than one remainder account (row %d)", id, line)
+			case prev.Percent != nil:
+				percents += *prev.Percent
+			}
+		}
+		if a.Percent != nil && percents+*a.Percent > 100 {
+			return fmt.Errorf("employee %s's percentages add up to more than 100 (row %d)", id, line)
+		}
+		allocations[id] = append(allocations[id], a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// allocateNetPay divides net among an employee's accounts: fixed amounts first, in
+// order, then percentages of what they leave, with the rest (unallocated percent
+// and rounding) going to the remainder account, or the last one listed. A fixed
+// amount that would take more than is left is cut to what is left and flagged,
+// as is every allocation when net is not positive.
+func allocateNetPay(net Money, allocations []DepositAllocation) []DepositSplit {
+	splits := make([]DepositSplit, len(allocations))
+	remainder := len(allocations) - 1
+	for i, a := range allocations {
+		splits[i] = DepositSplit{Account: a.Account, Method: a.method()}
+		switch {
+		case a.Amount != nil:
+			splits[i].Requested = a.Amount.String()
+		case a.Percent != nil:
+			splits[i].Requested = strconv.FormatFloat(*a.Percent, 'f', -1, 64) + "%"
+		default:
+			remainder = i
+		}
+	}
+	if net <= 0 {
+		for i := range splits {
+			splits[i].Flag = "net pay is not positive"
+		}
+		return splits
+	}
+
+	left := net
+	for i, a := range allocations {
+		if a.Amount == nil {
+			continue
+		}
+		splits[i].Amount = min(*a.Amount, left)
+		if splits[i].Amount < *a.Amount {
+			splits[i].Flag = "clamped to remaining net pay"
+		}
+		left -= splits[i].Amount
+	}
+	base := left
+	for i, a := range allocations {
+		if a.Percent != nil && i != remainder {
+			splits[i].Amount = base.MulRate(*a.Percent / 100)
+			left -= splits[i].Amount
+		}
+	}
+	splits[remainder].Amount += left
+	return splits
+}
+
+// DepositLine is one register line's deposit to one account.
+type DepositLine struct {
+	Register PayRegister
+	DepositSplit
+}
+
+// depositLines splits each register line's net pay with allocateNetPay. Employees
+// without allocations get a single line with no account and their whole net pay,
+// flagged.
+func depositLines(registers []PayRegister, allocations map[string][]DepositAllocation) []DepositLine {
+	var lines []DepositLine
+	for _, reg := range registers {
+		splits := []DepositSplit{{Amount: reg.NetPay, Flag: "no deposit allocation on file"}}
+		if accounts, ok := allocations[reg.EmployeeID]; ok {
+			splits = allocateNetPay(reg.NetPay, accounts)
+		}
+		for _, s := range splits {
+			lines = append(lines, DepositLine{reg, s})
+		}
+	}
+	return lines
+}
+
+// writeDepositAllocations writes the depositLines, one row per register line and
+// deposit account.
+func writeDepositAllocations(lines []DepositLine, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create deposit allocations file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write([]string{"Employee ID", "Employee Name", "Pay Period", "Currency", "Net Pay", "Account", "Method", "Requested", "Deposit", "Flag"}); err != nil {
+		return fmt.Errorf("cannot write deposit allocations header: %v", err)
+	}
+	for _, l := range lines {
+		reg := l.Register
+		money := opts.formatter(reg.Currency)
+		row := []string{csvText(reg.EmployeeID), csvText(reg.EmployeeName), csvText(reg.PayPeriod), opts.currencyLabel(reg),
+			money(reg.NetPay), csvText(l.Account), l.Method, l.Requested, money(l.Amount), l.Flag}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write deposit allocations row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write deposit allocations file: %v", err)
+	}
+	return nil
+}
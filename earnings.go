@@ -0,0 +1,225 @@
+package main
+
+// EmployeePeriod is what an EarningsComponent sees of a register line: the
+// employee's payroll and time records (hours after overtime rules), the hours
+// paid at each rate, how earnings are paid, and the gross so far from the
+// components before it.
+type EmployeePeriod struct {
+	Payroll PayrollRecord
+	Time    TimeRecord
+	// Shifts are the line's hours by the rate they are paid at: one for the
+	// period, one per job, or the parts either side of a mid-period raise. Under
+	// OvertimeAmounts they carry no overtime hours.
+	Shifts []PayShift
+	// EarningsRounding is the -earnings-rounding policy.
+	EarningsRounding string
+	// OvertimeAmounts is set under -overtime-mode amount: overtime is the time
+	// file's Overtime Pay rather than hours at 1.5x.
+	OvertimeAmounts bool
+	Gross           Money
+	// tally collects the built-in components' overtime split, piece earnings and
+	// rounding for the register.
+	tally *earningsTally
+}
+
+// PayShift is hours paid at one rate.
+type PayShift struct {
+	Rate                          Money
+	Regular, Overtime, DoubleTime int
+}
+
+// earningsTally is what the built-in components report besides their amounts.
+type earningsTally struct {
+	overtimeStraight, overtimePremium, piece Money
+	rounding                                 float64
+}
+
+// EarningsComponent computes one kind of earnings for a register line.
+// Components run in order, each adding to gross before taxes; a component
+// returning zero contributes nothing. The default set, builtinEarnings, pays
+// regular hours, overtime and double time, piece work, overtime amounts and
+// adjustments; client-specific components are appended to it. Their amounts
+// are itemized in Earnings and totaled in Custom Earnings.
+type EarningsComponent interface {
+	Compute(ctx EmployeePeriod) (amount Money, label string)
+}
+
+// builtinComponent marks the components the register always has columns for,
+// which are therefore not itemized as custom earnings.
+type builtinComponent interface {
+	builtin()
+}
+
+// builtinEarnings returns the default EarningsComponents, the earnings every
+// line has always had. A nil ComputeOptions.EarningsComponents means these.
+func builtinEarnings() []EarningsComponent {
+	return []EarningsComponent{RegularEarnings{}, OvertimeEarnings{}, PieceWorkEarnings{}, OvertimePayEarnings{}, AdjustmentEarnings{}}
+}
+
+// RegularEarnings pays each shift's regular hours at its rate. Piece-rate lines
+// are paid by PieceWorkEarnings instead.
+type RegularEarnings struct{}
+
+func (RegularEarnings) builtin() {}
+
+func (RegularEarnings) Compute(ctx EmployeePeriod) (Money, string) {
+	if ctx.Payroll.PieceRate != 0 {
+		return 0, "Regular"
+	}
+	var pay Money
+	for _, s := range ctx.Shifts {
+		pay += s.Rate.MulHours(s.Regular)
+	}
+	return pay, "Regular"
+}
+
+// OvertimeEarnings pays each shift's overtime at 1.5 and double time at 2 times
+// its rate, split into the straight-time part and the premium on top. Each
+// shift's overtime is rounded to the cent, or under roundEarningsGross summed
+// exact and rounded once. Piece-rate lines are paid by PieceWorkEarnings instead.
+type OvertimeEarnings struct{}
+
+func (OvertimeEarnings) builtin() {}
+
+func (OvertimeEarnings) Compute(ctx EmployeePeriod) (Money, string) {
+	if ctx.Payroll.PieceRate != 0 {
+		return 0, "Overtime"
+	}
+	var pay Money
+	var exact float64
+	for _, s := range ctx.Shifts {
+		shiftPay := s.Rate.MulHours(2 * s.DoubleTime)
+		if ctx.EarningsRounding == roundEarningsGross {
+			exact += float64(s.Rate) * 1.5 * float64(s.Overtime)
+		} else {
+			shiftPay += roundedMul(s.Rate, 1.5*float64(s.Overtime), &ctx.tally.rounding)
+		}
+		straight := s.Rate.MulHours(s.Overtime + s.DoubleTime)
+		ctx.tally.overtimeStraight += straight
+		ctx.tally.overtimePremium += shiftPay - straight
+		pay += shiftPay
+	}
+	if exact != 0 {
+		rounded := roundedMul(1, exact, &ctx.tally.rounding)
+		ctx.tally.overtimePremium += rounded
+		pay += rounded
+	}
+	return pay, "Overtime"
+}
+
+// PieceWorkEarnings pays a piece-rate line: its units at the piece rate plus its
+// hours, overtime by the regular-rate method (see pieceGross).
+type PieceWorkEarnings struct{}
+
+func (PieceWorkEarnings) builtin() {}
+
+func (PieceWorkEarnings) Compute(ctx EmployeePeriod) (Money, string) {
+	if ctx.Payroll.PieceRate == 0 || len(ctx.Shifts) != 1 {
+		return 0, "Piece Work"
+	}
+	s := ctx.Shifts[0]
+	piece := ctx.Payroll.PieceRate.MulHours(ctx.Time.Units)
+	pay := pieceGross(piece, s.Rate, s.Regular, s.Overtime, s.DoubleTime, &ctx.tally.rounding)
+	ctx.tally.piece += piece
+	ctx.tally.overtimeStraight += s.Rate.MulHours(s.Overtime + s.DoubleTime)
+	ctx.tally.overtimePremium += pay - piece - s.Rate.MulHours(s.Regular+s.Overtime+s.DoubleTime)
+	return pay, "Piece Work"
+}
+
+// OvertimePayEarnings pays the time file's Overtime Pay under -overtime-mode
+// amount. The amount is the overtime's whole pay; the part above its hours at
+// the straight rate is its premium.
+type OvertimePayEarnings struct{}
+
+func (OvertimePayEarnings) builtin() {}
+
+func (OvertimePayEarnings) Compute(ctx EmployeePeriod) (Money, string) {
+	if !ctx.OvertimeAmounts {
+		return 0, "Overtime Pay"
+	}
+	straight := ctx.Payroll.HourlyRate.MulHours(ctx.Time.OvertimeHours)
+	ctx.tally.overtimeStraight += straight
+	ctx.tally.overtimePremium += ctx.Time.OvertimePay - straight
+	return ctx.Time.OvertimePay, "Overtime Pay"
+}
+
+// AdjustmentEarnings folds the time file's Adjustment into gross. A negative
+// adjustment may drive gross below zero; that is allowed for correction rows.
+type AdjustmentEarnings struct{}
+
+func (AdjustmentEarnings) builtin() {}
+
+func (AdjustmentEarnings) Compute(ctx EmployeePeriod) (Money, string) {
+	return ctx.Time.Adjustment, "Adjustment"
+}
+
+// Earning is one EarningsComponent result, kept on the register for itemization.
+type Earning struct {
+	Label  string `json:"label"`
+	Amount Money  `json:"amount"`
+}
+
+// FlatEarning pays a fixed amount every period (e.g. an on-call stipend).
+type FlatEarning struct {
+	Label  string
+	Amount Money
+}
+
+func (e FlatEarning) Compute(ctx EmployeePeriod) (Money, string) {
+	return e.Amount, e.Label
+}
+
+// HourlyEarning pays Rate for every hour worked, overtime and double time
+// included, the usual shape of hazard or shift pay.
+type HourlyEarning struct {
+	Label string
+	Rate  Money
+}
+
+func (e HourlyEarning) Compute(ctx EmployeePeriod) (Money, string) {
+	return e.Rate.MulHours(ctx.Time.RegularHours + ctx.Time.OvertimeHours + ctx.Time.DoubleTimeHours), e.Label
+}
+
+// applyEarningsComponents runs components for a line, returning the gross they
+// come to, the part from custom (not built-in) components, each non-zero custom
+// amount, and the built-in components' tally. A nil components means
+// builtinEarnings.
+func applyEarningsComponents(ctx EmployeePeriod, components []EarningsComponent) (gross, custom Money, earnings []Earning, tally earningsTally) {
+	if components == nil {
+		components = builtinEarnings()
+	}
+	ctx.tally = &tally
+	for _, component := range components {
+		amount, label := component.Compute(ctx)
+		if amount == 0 {
+			continue
+		}
+		if _, ok := component.(builtinComponent); !ok {
+			earnings = append(earnings, Earning{Label: label, Amount: amount})
+			custom += amount
+		}
+		ctx.Gross += amount
+	}
+	return ctx.Gross, custom, earnings, tally
+}
+
+// hasCustomComponents reports whether components go beyond the built-in ones.
+func hasCustomComponents(components []EarningsComponent) bool {
+	for _, component := range components {
+		if _, ok := component.(builtinComponent); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCustomEarnings reports whether any line has component earnings, for the
+// register's Custom Earnings column.
+func hasCustomEarnings(registers []PayRegister) bool {
+	for _, reg := range registers {
+		if reg.CustomEarnings != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestEarningsComponents(t *testing.T) {
+	// Components add to gross after the built-in earnings: 10 hours at 20.00 plus
+	// 2.00 hazard pay an hour is 220.00, a zero stipend is left off, and the line
+	// still verifies.
+	opts := defaultComputeOptions()
+	opts.EarningsComponents = append(opts.EarningsComponents, HourlyEarning{Label: "Hazard Pay", Rate: 200}, FlatEarning{Label: "On-Call"})
+	reg, _, err := computeRow(PayrollRecord{EmployeeID: "010", PayPeriod: "2024-06", HourlyRate: 2000},
+		TimeRecord{EmployeeID: "010", PayPeriod: "2024-06", RegularHours: 10}, BenefitsRecord{}, defaultTaxConfig(), opts)
+	if err != nil || reg.GrossWages != 22000 || reg.CustomEarnings != 2000 || len(reg.Earnings) != 1 || len(checkRegisterConsistency(reg, false, 0)) != 0 {
+		t.Errorf("got gross %s, custom %s, earnings %v (%v), want 220.00 with 20.00 of Hazard Pay", reg.GrossWages, reg.CustomEarnings, reg.Earnings, err)
+	}
+}
+
+// grossShare pays a fraction of the gross so far, to show what a component sees.
+type grossShare struct{ rate float64 }
+
+func (e grossShare) Compute(ctx EmployeePeriod) (Money, string) {
+	return ctx.Gross.MulRate(e.rate), "Share"
+}
+
+func TestApplyEarningsComponentsInOrder(t *testing.T) {
+	// Each component sees the gross from the ones before it: 10 hours at 10.00, a
+	// 50.00 stipend, then 10% of the 150.00 is 15.00, not 10.00.
+	ctx := EmployeePeriod{Time: TimeRecord{RegularHours: 10}, Shifts: []PayShift{{Rate: 1000, Regular: 10}}}
+	gross, custom, earnings, _ := applyEarningsComponents(ctx,
+		append(builtinEarnings(), FlatEarning{Label: "Stipend", Amount: 5000}, grossShare{0.10}))
+	if gross != 16500 || custom != 6500 || len(earnings) != 2 || earnings[1].Amount != 1500 {
+		t.Errorf("got gross %s with %s custom from %+v, want 165.00 with 65.00 and a share of 15.00", gross, custom, earnings)
+	}
+}
+
+func TestBuiltinEarnings(t *testing.T) {
+	// 10 regular and 2 overtime hours at 20.00 plus a 5.00 adjustment is 265.00,
+	// from the default components or a nil slice alike; none of it is custom.
+	payroll := PayrollRecord{EmployeeID: "013", PayPeriod: "2024-06", HourlyRate: 2000}
+	timeRec := TimeRecord{EmployeeID: "013", PayPeriod: "2024-06", RegularHours: 10, OvertimeHours: 2, Adjustment: 500}
+	for name, components := range map[string][]EarningsComponent{"default": defaultComputeOptions().EarningsComponents, "nil": nil} {
+		opts := defaultComputeOptions()
+		opts.EarningsComponents = components
+		reg, _, err := computeRow(payroll, timeRec, BenefitsRecord{}, defaultTaxConfig(), opts)
+		if err != nil || reg.GrossWages != 26500 || reg.OvertimeStraight != 4000 || reg.OvertimePremium != 2000 || reg.CustomEarnings != 0 || len(reg.Earnings) != 0 {
+			t.Errorf("%s: got gross %s (overtime %s + %s, custom %s) (%v), want 265.00 with no custom earnings",
+				name, reg.GrossWages, reg.OvertimeStraight, reg.OvertimePremium, reg.CustomEarnings, err)
+		}
+	}
+
+	// A client paying overtime its own way replaces the built-in component.
+	opts := defaultComputeOptions()
+	opts.EarningsComponents = []EarningsComponent{RegularEarnings{}, HourlyEarning{Label: "Overtime At 25.00", Rate: 2500}, AdjustmentEarnings{}}
+	timeRec.RegularHours = 0
+	reg, _, err := computeRow(payroll, timeRec, BenefitsRecord{}, defaultTaxConfig(), opts)
+	if err != nil || reg.GrossWages != 5500 || reg.CustomEarnings != 5000 {
+		t.Errorf("replaced overtime: got gross %s, custom %s (%v), want 55.00 with 50.00 custom", reg.GrossWages, reg.CustomEarnings, err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// normalizeEmployeeID trims id and, when width > 0 and id is all digits, zero-pads it
+// to width so "123" and "00123" join as the same employee. Longer IDs and IDs with
+// letters are left as they are.
+func normalizeEmployeeID(id string, width int) string {
+	id = strings.TrimSpace(id)
+	if width > 0 && id != "" && isDigits(id) && len(id) < width {
+		return strings.Repeat("0", width-len(id)) + id
+	}
+	return id
+}
+
+// employeeID applies the reader's -normalize-ids and -anonymize settings to a raw
+// Employee ID cell. Normalizing first means "123" and "00123" still pseudonymize alike.
+func (opts ReaderOptions) employeeID(raw string) string {
+	id := raw
+	if opts.IDWidth > 0 {
+		id = normalizeEmployeeID(raw, opts.IDWidth)
+	}
+	if opts.AnonymizeSalt != nil {
+		id = pseudonymousID(id, opts.AnonymizeSalt)
+	}
+	return id
+}
+
+// period returns a Pay Period cell as it is joined and reported: normalized to
+// PeriodLayout when set (-normalize-period), else as read.
+func (opts ReaderOptions) period(raw string) (string, error) {
+	if opts.PeriodLayout == "" {
+		return raw, nil
+	}
+	return normalizePeriod(raw, opts.PeriodLayout)
+}
+
+// pseudonymousID replaces an employee ID with a salted hash, so the same ID maps to
+// the same pseudonym in every input file (joins still work) but cannot be reversed
+// by hashing every short numeric ID.
+func pseudonymousID(id string, salt []byte) string {
+	h := hmac.New(sha256.New, salt)
+	h.Write([]byte(strings.TrimSpace(id)))
+	return "X" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// pseudonymousName is the display name used for an anonymized employee ID.
+func pseudonymousName(anonymizedID string) string {
+	return "Employee " + anonymizedID
+}
+
+// checkEmployeeIDs warns about numeric employee IDs that differ only in leading zeros
+// ("123" vs "00123"), within or across the input files. The join treats them as two
+// different people, which is almost never what was meant.
+func checkEmployeeIDs(files map[string][]string) []Warning {
+	spellings := make(map[string]map[string][]string) // canonical -> spelling -> files
+	for _, file := range sortedKeys(files) {
+		for _, id := range files[file] {
+			id = strings.TrimSpace(id)
+			if id == "" || !isDigits(id) {
+				continue
+			}
+			canonical := strings.TrimLeft(id, "0")
+			if canonical == "" {
+				canonical = "0"
+			}
+			if spellings[canonical] == nil {
+				spellings[canonical] = make(map[string][]string)
+			}
+			if seen := spellings[canonical][id]; len(seen) == 0 || seen[len(seen)-1] != file {
+				spellings[canonical][id] = append(seen, file)
+			}
+		}
+	}
+
+	var warnings []Warning
+	for _, canonical := range sortedKeys(spellings) {
+		forms := spellings[canonical]
+		if len(forms) < 2 {
+			continue
+		}
+		var parts []string
+		for _, id := range sortedKeys(forms) {
+			parts = append(parts, fmt.Sprintf("%q in %s", id, strings.Join(forms[id], ", ")))
+		}
+		sort.Strings(parts)
+		warnings = append(warnings, Warning{
+			Category:   "employee-id",
+			EmployeeID: canonical,
+			Message:    "same employee spelled differently: " + strings.Join(parts, "; ") + " (see -normalize-ids)",
+		})
+	}
+	return warnings
+}
+
+// employeeIDs lists the Employee IDs of a record map, one per record.
+func employeeIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		id, _, _ := strings.Cut(key, "|")
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// employeeList collects the repeatable -employee flag.
+type employeeList []string
+
+func (l *employeeList) String() string { return strings.Join(*l, ",") }
+
+func (l *employeeList) Set(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("empty employee ID")
+	}
+	*l = append(*l, strings.TrimSpace(id))
+	return nil
+}
+
+// readEmployeesFile reads the -employees-file roster: one Employee ID per line.
+// Blank lines and lines starting with # are skipped.
+func readEmployeesFile(filename string, opts ReaderOptions) ([]string, error) {
+	data, err := readInput(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids = append(ids, line)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s lists no employees", filename)
+	}
+	return ids, nil
+}
+
+// employeeSet keys the employees a run is restricted to (-employee,
+// -employees-file) the way the readers key them, so a roster entry matches
+// whatever -normalize-ids, -anonymize and -fold-key-case do to the inputs.
+func employeeSet(ids []string, opts ReaderOptions) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[makeKey(opts.employeeID(id), "")] = true
+	}
+	return set
+}
+
+// includes reports whether the run computes employeeID: always, unless it is
+// restricted to a roster.
+func (opts ComputeOptions) includes(employeeID string) bool {
+	return opts.Employees == nil || opts.Employees[makeKey(employeeID, "")]
+}
+
+// missingEmployees lists the roster's IDs, as given, that none of the inputs'
+// employee IDs match.
+func missingEmployees(roster []string, opts ReaderOptions, inputIDs ...[]string) []string {
+	present := make(map[string]bool)
+	for _, ids := range inputIDs {
+		for _, id := range ids {
+			present[makeKey(id, "")] = true
+		}
+	}
+	var missing []string
+	for _, id := range roster {
+		if !present[makeKey(opts.employeeID(id), "")] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
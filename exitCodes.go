@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// Exit codes, so a scheduler can tell failure classes apart without parsing logs:
+//
+//	0  success
+//	1  any other failure (writing output, -generate, metrics endpoint, ...)
+//	2  usage: an unknown flag or an invalid flag value (the flag package also uses 2)
+//	3  an input file (data, config, spec, archive entry) does not exist
+//	4  an input file exists but cannot be parsed
+//	5  validation: a check configured to fail the run, -strict, or a config that
+//	   cannot serve the data (such as a missing FX rate)
+//	6  mismatch: -verify found inconsistencies, -expected-net did not reconcile, or
+//	   -round-trip-check read back something other than what was written
+const (
+	exitFailure       = 1
+	exitUsage         = 2
+	exitInputNotFound = 3
+	exitParseError    = 4
+	exitValidation    = 5
+	exitMismatch      = 6
+)
+
+// exitCodesHelp is appended to -h output.
+const exitCodesHelp = `
+Exit codes:
+  0  success
+  1  other failure (writing output, -generate, ...)
+  2  invalid flags or flag values
+  3  input file not found
+  4  input file could not be parsed
+  5  validation failure (-strict, a check set to error, unusable config)
+  6  -verify, -expected-net or -round-trip-check mismatch
+`
+
+// fatalf logs like log.Fatalf but exits with code, writing the -report first.
+func fatalf(code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	activeReport.finish(code, msg)
+	activeWarnings.finish()
+	os.Exit(code)
+}
+
+// inputExitCode classifies an error from reading an input file: missing files are
+// exitInputNotFound and everything else is treated as a parse error.
+func inputExitCode(err error) int {
+	if errors.Is(err, fs.ErrNotExist) {
+		return exitInputNotFound
+	}
+	return exitParseError
+}
+
+// usageWithExitCodes is flag.Usage plus the exit code table.
+func usageWithExitCodes() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprint(out, exitCodesHelp)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TaxDetail is how one tax on a register line was computed, recorded by computeRow
+// as it computes the tax so the -explain-taxes report cannot drift from it.
+type TaxDetail struct {
+	Tax   string // federal, state, local, socialSecurity, or medicare
+	Basis string // the taxable base's kind, as baseKind describes it
+	Base  Money
+	// Rate is the rate applied; zero when the tax was not computed by rate.
+	Rate float64
+	// Method is "rate" (the config's, or the locality's), "rate to wage base",
+	// "override rate", "override amount", "exempt", "withholding floor", or
+	// "disabled".
+	Method string
+	Amount Money
+}
+
+// baseKind describes the base a tax is levied on (see levyKind).
+func (cfg TaxConfig) baseKind(tax string) string {
+	return levyKind(cfg.TaxableBases[tax])
+}
+
+// levyKind describes a taxable base: its kind, gross for a zero one, and for a
+// custom base the benefit categories it excludes.
+func levyKind(base TaxableBase) string {
+	switch base.Kind {
+	case "":
+		return baseGross
+	case baseCustom:
+		return fmt.Sprintf("%s (excluding %s)", baseCustom, strings.Join(base.Exclude, ", "))
+	}
+	return base.Kind
+}
+
+// writeTaxExplanations writes the -explain-taxes report: one row per tax of every
+// register line, with the base it was levied on, the rate, and the amount.
+func writeTaxExplanations(registers []PayRegister, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create tax explanation file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	header := []string{"Employee ID", "Pay Period", "Tax", "Taxable Base", "Base Amount", "Rate", "Method", "Amount", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write tax explanation header: %v", err)
+	}
+	for _, reg := range registers {
+		money := opts.formatter(reg.Currency)
+		for _, t := range reg.TaxDetails {
+			row := []string{csvText(reg.EmployeeID), csvText(reg.PayPeriod), t.Tax, t.Basis, money(t.Base),
+				strconv.FormatFloat(t.Rate, 'f', -1, 64), t.Method, money(t.Amount), reg.Currency}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("cannot write tax explanation row: %v", err)
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write tax explanation file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RegisterExporter writes a computed register in another system's import layout,
+// such as a payroll service's pay-data file.
+type RegisterExporter interface {
+	Export(registers []PayRegister, w io.Writer) error
+}
+
+// ExportMapping is a config-driven RegisterExporter for the one-line-per-code CSV
+// layout payroll services import: each register line becomes one output row per
+// earning, tax, or deduction code it has. Columns lays out every row; Codes lists
+// which register column each code takes its hours or amount from.
+type ExportMapping struct {
+	Columns []ExportColumn `json:"columns"`
+	Codes   []ExportCode   `json:"codes"`
+	// SkipZero leaves out codes whose hours and amount are both zero.
+	SkipZero bool `json:"skipZero,omitempty"`
+	// Amounts is the number format for amounts: decimal2 (default) or cents.
+	Amounts string `json:"amounts,omitempty"`
+}
+
+// ExportColumn is one output column. It holds exactly one of: a register column
+// (Column, matched like -columns), a constant (Value), or a part of the code line
+// (Field: "code", "kind", "hours", or "amount").
+type ExportColumn struct {
+	Header string `json:"header"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Field  string `json:"field,omitempty"`
+}
+
+// ExportCode is one earning, tax, or deduction code and the register columns its
+// hours and amount come from; either may be left out.
+type ExportCode struct {
+	Code   string `json:"code"`
+	Kind   string `json:"kind"` // earning, tax, or deduction
+	Hours  string `json:"hours,omitempty"`
+	Amount string `json:"amount,omitempty"`
+}
+
+// builtinExportMappings are the mappings -export-mapping accepts by name. "paydata"
+// is the common ADP/Paychex pay-data shape: company and batch, the employee's file
+// number, then one code per line with its hours or amount.
+var builtinExportMappings = map[string]ExportMapping{
+	"paydata": {
+		Columns: []ExportColumn{
+			{Header: "Co Code", Value: ""},
+			{Header: "Batch ID", Value: ""},
+			{Header: "File #", Column: "Employee ID"},
+			{Header: "Employee Name", Column: "Employee Name"},
+			{Header: "Pay Period", Column: "Pay Period"},
+			{Header: "Code Type", Field: "kind"},
+			{Header: "Code", Field: "code"},
+			{Header: "Hours", Field: "hours"},
+			{Header: "Amount", Field: "amount"},
+		},
+		Codes: []ExportCode{
+			{Code: "REG", Kind: "earning", Hours: "Regular Hours"},
+			{Code: "OT", Kind: "earning", Hours: "Overtime Hours"},
+			{Code: "DT", Kind: "earning", Hours: "Double Time Hours"},
+			{Code: "PCE", Kind: "earning", Hours: "Units", Amount: "Piece Earnings"},
+			{Code: "ADJ", Kind: "earning", Amount: "Adjustment"},
+			{Code: "FIT", Kind: "tax", Amount: "Federal Tax"},
+			{Code: "SIT", Kind: "tax", Amount: "State Tax"},
+			{Code: "LIT", Kind: "tax", Amount: "Local Tax"},
+			{Code: "SS", Kind: "tax", Amount: "Social Security"},
+			{Code: "MED", Kind: "tax", Amount: "Medicare"},
+			{Code: "MEDINS", Kind: "deduction", Amount: "Health Insurance"},
+			{Code: "401K", Kind: "deduction", Amount: "Retirement"},
+			{Code: "OTHBEN", Kind: "deduction", Amount: "Other Benefits"},
+			{Code: "MISC", Kind: "deduction", Amount: "Custom Deductions"},
+		},
+		SkipZero: true,
+	},
+}
+
+// loadExportMapping returns the built-in mapping called name, or else reads name as
+// a JSON mapping file, and checks it.
+func loadExportMapping(name string) (ExportMapping, error) {
+	mapping, ok := builtinExportMappings[name]
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return mapping, fmt.Errorf("cannot read export mapping: %w", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return mapping, fmt.Errorf("cannot parse export mapping %s: %v", name, err)
+		}
+	}
+	if err := mapping.check(); err != nil {
+		return mapping, fmt.Errorf("export mapping %s: %v", name, err)
+	}
+	return mapping, nil
+}
+
+// check validates the mapping: every column holds one thing, every register
+// column named exists, and every code has a kind and something to export.
+func (m ExportMapping) check() error {
+	if len(m.Columns) == 0 || len(m.Codes) == 0 {
+		return fmt.Errorf("needs columns and codes")
+	}
+	switch m.Amounts {
+	case "", numberDecimal2, numberCents:
+	default:
+		return fmt.Errorf("amounts must be decimal2 or cents, got %q", m.Amounts)
+	}
+	var names []string
+	for _, c := range m.Columns {
+		set := 0
+		for _, s := range []string{c.Column, c.Value, c.Field} {
+			if s != "" {
+				set++
+			}
+		}
+		if set > 1 {
+			return fmt.Errorf("column %q sets more than one of column, value, and field", c.Header)
+		}
+		switch c.Field {
+		case "", "code", "kind", "hours", "amount":
+		default:
+			return fmt.Errorf("column %q has unknown field %q (want code, kind, hours, or amount)", c.Header, c.Field)
+		}
+		if c.Column != "" {
+			names = append(names, c.Column)
+		}
+	}
+	for _, code := range m.Codes {
+		switch code.Kind {
+		case "earning", "tax", "deduction":
+		default:
+			return fmt.Errorf("code %q has kind %q (want earning, tax, or deduction)", code.Code, code.Kind)
+		}
+		if code.Code == "" || (code.Hours == "" && code.Amount == "") {
+			return fmt.Errorf("every code needs a code and an hours or amount column")
+		}
+		for _, s := range []string{code.Hours, code.Amount} {
+			if s != "" {
+				names = append(names, s)
+			}
+		}
+	}
+	_, err := selectColumns(names)
+	return err
+}
+
+// Export writes a header row, then one row per register line and code, in register
+// then code order. TOTAL rows are never exported.
+func (m ExportMapping) Export(registers []PayRegister, w io.Writer) error {
+	index, err := newColumnMap(registerHeader)
+	if err != nil {
+		return err
+	}
+	opts := defaultWriterOptions()
+	opts.NumberFormat = m.Amounts
+	writer := csv.NewWriter(w)
+	header := make([]string, len(m.Columns))
+	for i, c := range m.Columns {
+		header[i] = c.Header
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write export header: %v", err)
+	}
+	for _, reg := range registers {
+		row := registerRow(reg, opts, reg.OtherBenefits)
+		value := func(column string) string {
+			if column == "" {
+				return ""
+			}
+			i, _ := index.lookup(column)
+			return row[i]
+		}
+		for _, code := range m.Codes {
+			hours, amount := value(code.Hours), value(code.Amount)
+			if m.SkipZero && isZeroCell(hours) && isZeroCell(amount) {
+				continue
+			}
+			record := make([]string, len(m.Columns))
+			for i, c := range m.Columns {
+				switch {
+				case c.Column != "":
+					record[i] = value(c.Column)
+				case c.Field == "code":
+					record[i] = code.Code
+				case c.Field == "kind":
+					record[i] = code.Kind
+				case c.Field == "hours":
+					record[i] = hours
+				case c.Field == "amount":
+					record[i] = amount
+				default:
+					record[i] = c.Value
+				}
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("cannot write export row: %v", err)
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// isZeroCell reports whether a rendered register cell is blank or zero.
+func isZeroCell(cell string) bool {
+	m, err := parseRegisterAmount(cell)
+	return err == nil && m == 0
+}
+
+// writeExport writes registers through exporter to filename.
+func writeExport(registers []PayRegister, filename string, exporter RegisterExporter) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create export file: %v", err)
+	}
+	defer closeOutput(file, &err)
+	if err := exporter.Export(registers, file); err != nil {
+		return fmt.Errorf("cannot write export file: %v", err)
+	}
+	return nil
+}
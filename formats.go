@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+)
+
+// formatExtensions are the register output formats -format accepts, with the file
+// extension each gets when one run writes several of them.
+var formatExtensions = map[string]string{
+	"csv":    ".csv",
+	"ndjson": ".ndjson",
+	"json":   ".json",
+	"html":   ".html",
+	"fixed":  ".txt",
+	"long":   ".csv",
+}
+
+// parseFormats splits a -format list ("csv,json,html"), rejecting unknown and
+// repeated formats.
+func parseFormats(list string) ([]string, error) {
+	var formats []string
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if _, ok := formatExtensions[f]; !ok {
+			return nil, fmt.Errorf("unknown format %q (want csv, ndjson, json, html, fixed, or long)", f)
+		}
+		for _, seen := range formats {
+			if seen == f {
+				return nil, fmt.Errorf("format %q listed twice", f)
+			}
+		}
+		formats = append(formats, f)
+	}
+	return formats, nil
+}
+
+// formatFilename gives an output path the extension of format:
+// payroll_register.csv -> payroll_register.html.
+func formatFilename(filename, format string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + formatExtensions[format]
+}
+
+// formatOutputs lists the files writing format to filename creates: filename
+// itself, except for long's two tables.
+func formatOutputs(filename, format string) []string {
+	if format == "long" {
+		earnings, deductions := longFilenames(filename)
+		return []string{earnings, deductions}
+	}
+	return []string{filename}
+}
+
+// writeRegisterJSON writes the register as one indented JSON array of the same
+// objects -format ndjson writes a line each, plus the .meta.json sidecar unless
+// filename is "-".
+func writeRegisterJSON(registers []PayRegister, filename string, cfg TaxConfig) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	if registers == nil {
+		registers = []PayRegister{}
+	}
+	buffered := bufio.NewWriter(file)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(registers); err != nil {
+		return fmt.Errorf("cannot write output file: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("cannot write output file: %v", err)
+	}
+
+	if filename == stdoutName {
+		return nil
+	}
+	return writeRegisterMeta(filename, len(registers), cfg, nil)
+}
+
+// writeRegisterHTML writes the register as a standalone HTML page holding one
+// table, with the CSV register's columns (or the -columns subset) and amounts
+// formatted the same way. Amount columns are right-aligned.
+func writeRegisterHTML(registers []PayRegister, filename string, opts WriterOptions) (err error) {
+	columns, err := registerColumns(registers, opts)
+	if err != nil {
+		return err
+	}
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	buffered := bufio.NewWriter(file)
+	buffered.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Pay Register</title>\n")
+	buffered.WriteString("<style>table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:2px 6px}td.n{text-align:right}</style>\n")
+	buffered.WriteString("</head>\n<body>\n<table>\n<thead><tr>")
+	for _, c := range columns {
+		buffered.WriteString("<th>" + html.EscapeString(registerHeader[c]) + "</th>")
+	}
+	buffered.WriteString("</tr></thead>\n<tbody>\n")
+	for _, reg := range registers {
+		row := registerRow(reg, opts, reg.OtherBenefits)
+		buffered.WriteString("<tr>")
+		for _, c := range columns {
+			if registerTextColumns[registerHeader[c]] {
+				buffered.WriteString("<td>")
+			} else {
+				buffered.WriteString(`<td class="n">`)
+			}
+			buffered.WriteString(html.EscapeString(row[c]) + "</td>")
+		}
+		buffered.WriteString("</tr>\n")
+	}
+	buffered.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("cannot write output file: %v", err)
+	}
+	return nil
+}
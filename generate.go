@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// generateJobTitles and generateNames are the pools synthetic employees draw from.
+var (
+	generateJobTitles = []string{"Engineer", "Clerk", "Manager", "Analyst", "Technician", "Nurse", "Driver", "Cashier"}
+	generateFirst     = []string{"Alex", "Jordan", "Sam", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	generateLast      = []string{"Smith", "Garcia", "Chen", "Johnson", "Okafor", "Novak", "Patel", "Brown", "Kim", "Silva"}
+)
+
+// generateData writes payroll_data.csv, time_data.csv, and benefits.csv into dir for
+// employees synthetic employees over periods consecutive monthly pay periods. The
+// same seed always produces byte-identical files, so load tests can be reproduced
+// without sharing real payroll data.
+func generateData(dir string, employees, periods int, seed int64) error {
+	if employees <= 0 || periods <= 0 {
+		return fmt.Errorf("employee and period counts must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create output directory: %v", err)
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	open := func(name string, header []string) (*os.File, *csv.Writer, error) {
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot create %s: %v", name, err)
+		}
+		writer := csv.NewWriter(file)
+		if err := writer.Write(header); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("cannot write %s header: %v", name, err)
+		}
+		return file, writer, nil
+	}
+	payrollFile, payroll, err := open("payroll_data.csv", []string{"Employee ID", "Employee Name", "Job Title", "Pay Period", "Hourly Rate"})
+	if err != nil {
+		return err
+	}
+	defer payrollFile.Close()
+	timeFile, timeW, err := open("time_data.csv", []string{"Employee ID", "Pay Period", "Regular Hours", "Overtime Hours"})
+	if err != nil {
+		return err
+	}
+	defer timeFile.Close()
+	benefitsFile, benefits, err := open("benefits.csv", []string{"Employee ID", "Pay Period", "Health Insurance", "Retirement", "Other Benefits"})
+	if err != nil {
+		return err
+	}
+	defer benefitsFile.Close()
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	width := len(fmt.Sprint(employees))
+	if width < 3 {
+		width = 3
+	}
+	for e := 1; e <= employees; e++ {
+		id := fmt.Sprintf("%0*d", width, e)
+		name := generateFirst[rng.Intn(len(generateFirst))] + " " + generateLast[rng.Intn(len(generateLast))]
+		title := generateJobTitles[rng.Intn(len(generateJobTitles))]
+		// Hourly rates between 15.00 and 85.00, with an occasional raise between periods.
+		rate := Money(1500 + rng.Intn(7001))
+		health := Money(2000 + rng.Intn(10001))
+		for p := 0; p < periods; p++ {
+			period := start.AddDate(0, p, 0).Format("2006-01")
+			if p > 0 && rng.Intn(10) == 0 {
+				rate += rate.MulRate(0.03)
+			}
+			regular := 60 + rng.Intn(21)
+			overtime := 0
+			if rng.Intn(4) == 0 {
+				overtime = 1 + rng.Intn(15)
+			}
+			retirement := rate.MulHours(regular).MulRate(float64(rng.Intn(7)) / 100)
+			other := Money(rng.Intn(5001))
+			rows := []struct {
+				w   *csv.Writer
+				row []string
+			}{
+				{payroll, []string{id, name, title, period, rate.String()}},
+				{timeW, []string{id, period, fmt.Sprint(regular), fmt.Sprint(overtime)}},
+				{benefits, []string{id, period, health.String(), retirement.String(), other.String()}},
+			}
+			for _, r := range rows {
+				if err := r.w.Write(r.row); err != nil {
+					return fmt.Errorf("cannot write generated row: %v", err)
+				}
+			}
+		}
+	}
+
+	for _, w := range []*csv.Writer{payroll, timeW, benefits} {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("cannot write generated data: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// grossUp returns the gross pay that nets targetNet under cfg's rates, for a
+// bonus paid on its own: no hours, no benefits, no local tax. See grossUpRow.
+func grossUp(targetNet Money, cfg TaxConfig) (Money, error) {
+	reg, err := grossUpRow(targetNet, PayrollRecord{EmployeeID: "GROSS-UP"}, cfg, defaultComputeOptions())
+	if err != nil {
+		return 0, err
+	}
+	return reg.GrossWages, nil
+}
+
+// grossUpRow solves for the smallest gross that nets at least targetNet when paid
+// to payroll's employee as an adjustment line on its own, and returns that line.
+// Net can step over a cent as gross rises a cent at a time, so the line may net a
+// cent more than asked.
+//
+// With flat rates (no brackets, contributions, withholding floor, deduction
+// rules or earnings components) net is a straight line in gross, rate overrides
+// and fixed amounts included, so two lines give its slope and offset and the
+// gross follows algebraically, up to the cent rounding that the last step walks
+// out. Otherwise net is only piecewise straight (brackets, contribution
+// thresholds, the floor), and the gross is found by bisection, which assumes
+// net rises with gross. Wage bases are not applied: that needs the employee's
+// year to date.
+func grossUpRow(targetNet Money, payroll PayrollRecord, cfg TaxConfig, opts ComputeOptions) (PayRegister, error) {
+	if targetNet <= 0 {
+		return PayRegister{}, fmt.Errorf("target net pay must be positive, got %s", targetNet)
+	}
+	line := func(gross Money) (PayRegister, error) {
+		timeRec := TimeRecord{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Adjustment: gross}
+		benefitsRec := BenefitsRecord{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod}
+		reg, _, err := computeRow(payroll, timeRec, benefitsRec, cfg, opts)
+		return reg, err
+	}
+	nets := func(gross Money) (bool, error) {
+		reg, err := line(gross)
+		return reg.NetPay >= targetNet, err
+	}
+
+	var gross Money
+	if len(cfg.Contributions) == 0 && len(cfg.FederalBrackets) == 0 && len(cfg.StateBrackets) == 0 && opts.WithholdingFloor == 0 && len(cfg.Deductions) == 0 && len(opts.DeductionRules) == 0 && !hasCustomComponents(opts.EarningsComponents) {
+		low, err := line(targetNet)
+		if err != nil {
+			return PayRegister{}, err
+		}
+		high, err := line(2 * targetNet)
+		if err != nil {
+			return PayRegister{}, err
+		}
+		slope := float64(high.NetPay-low.NetPay) / float64(targetNet)
+		if slope <= 0 {
+			return PayRegister{}, fmt.Errorf("taxes take all of any gross; no gross nets %s", targetNet)
+		}
+		offset := float64(low.NetPay) - slope*float64(targetNet)
+		gross = Money(math.Round((float64(targetNet) - offset) / slope))
+		for {
+			ok, err := nets(gross)
+			if err != nil {
+				return PayRegister{}, err
+			}
+			if ok {
+				break
+			}
+			gross++
+		}
+		for gross > 0 {
+			ok, err := nets(gross - 1)
+			if err != nil {
+				return PayRegister{}, err
+			}
+			if !ok {
+				break
+			}
+			gross--
+		}
+	} else {
+		// Net never exceeds gross, so the answer is at least the target; double an
+		// upper bound until it nets enough, then halve the gap.
+		low, high := targetNet-1, targetNet
+		for {
+			ok, err := nets(high)
+			if err != nil {
+				return PayRegister{}, err
+			}
+			if ok {
+				break
+			}
+			if high > math.MaxInt64/4 {
+				return PayRegister{}, fmt.Errorf("no gross nets %s", targetNet)
+			}
+			low, high = high, 2*high
+		}
+		for high-low > 1 {
+			mid := low + (high-low)/2
+			ok, err := nets(mid)
+			if err != nil {
+				return PayRegister{}, err
+			}
+			if ok {
+				high = mid
+			} else {
+				low = mid
+			}
+		}
+		gross = high
+	}
+	return line(gross)
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestGrossUp(t *testing.T) {
+	// 1000.00 net at the default 24.65% needs 1327.14, since 1327.13 nets 999.99.
+	gross, err := grossUp(100000, defaultTaxConfig())
+	if err != nil || gross != 132714 {
+		t.Errorf("gross-up of 1000.00: got %s (%v), want 1327.14", gross, err)
+	}
+}
+
+func TestGrossUpRowIsSmallest(t *testing.T) {
+	brackets := defaultTaxConfig()
+	brackets.FederalBrackets = []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}, {From: 1160000, Rate: 0.12}}
+	for name, cfg := range map[string]TaxConfig{"flat rates": defaultTaxConfig(), "brackets": brackets} {
+		payroll := PayrollRecord{EmployeeID: "001", PayPeriod: "2024-06"}
+		reg, err := grossUpRow(250000, payroll, cfg, defaultComputeOptions())
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		less, _, err := computeRow(payroll, TimeRecord{EmployeeID: "001", PayPeriod: "2024-06", Adjustment: reg.GrossWages - 1},
+			BenefitsRecord{EmployeeID: "001", PayPeriod: "2024-06"}, cfg, defaultComputeOptions())
+		if err != nil || reg.NetPay < 250000 || less.NetPay >= 250000 {
+			t.Errorf("%s: gross %s nets %s and a cent less nets %s (%v), want the smallest gross netting 2500.00", name, reg.GrossWages, reg.NetPay, less.NetPay, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IncrementalManifest is the sidecar an -incremental run writes next to its
+// register: Run hashes what every line depends on (the binary, the flags, the
+// config and the other side files), and Employees hashes each employee's joined
+// input records, keyed by makeKey(id, ""). The next run recomputes only the
+// employees whose hash changed and carries the rest over from the register.
+type IncrementalManifest struct {
+	Run       string            `json:"run"`
+	Employees map[string]string `json:"employees"`
+}
+
+// manifestFilename returns the -incremental sidecar path for a register file,
+// e.g. out.csv -> out.inputs.json.
+func manifestFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".inputs.json"
+}
+
+// employeeInputHashes hashes each employee's payroll, time and benefits records,
+// every period and orphans included, so any change to what computeRegister would
+// read for them changes their hash. A whole employee is the unit because
+// arrears, wage bases and net rounding carry from one period to the next.
+func employeeInputHashes(payrollMap map[string]PayrollRecord, timeMap map[string]TimeRecord, benefitsMap map[string]BenefitsRecord) (map[string]string, error) {
+	keys := make(map[string]bool)
+	for key := range payrollMap {
+		keys[key] = true
+	}
+	for key := range timeMap {
+		keys[key] = true
+	}
+	for key := range benefitsMap {
+		keys[key] = true
+	}
+	hashers := make(map[string]hash.Hash)
+	for _, key := range sortedKeys(keys) {
+		id, _, _ := strings.Cut(key, "|")
+		employee := id + "|"
+		h := hashers[employee]
+		if h == nil {
+			h = sha256.New()
+			hashers[employee] = h
+		}
+		fmt.Fprintf(h, "%s\n", key)
+		for _, rec := range []any{record(payrollMap, key), record(timeMap, key), record(benefitsMap, key)} {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return nil, fmt.Errorf("cannot hash the inputs for %s: %v", key, err)
+			}
+			h.Write(append(data, '\n'))
+		}
+	}
+	hashes := make(map[string]string, len(hashers))
+	for employee, h := range hashers {
+		hashes[employee] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
+
+// record returns m's record for key, or nil when it has none.
+func record[V any](m map[string]V, key string) any {
+	if rec, ok := m[key]; ok {
+		return rec
+	}
+	return nil
+}
+
+// readManifest reads an -incremental sidecar; a missing one is not an error, it
+// just means there is nothing to build on.
+func readManifest(filename string) (IncrementalManifest, bool, error) {
+	var m IncrementalManifest
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, fmt.Errorf("cannot read incremental manifest: %v", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, false, fmt.Errorf("cannot parse incremental manifest %s: %v", filename, err)
+	}
+	return m, true, nil
+}
+
+// writeManifest writes an -incremental sidecar.
+func writeManifest(filename string, m IncrementalManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode incremental manifest: %v", err)
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write incremental manifest: %v", err)
+	}
+	return nil
+}
+
+// changedEmployees lists the employees whose input hash differs from the prior
+// run's, new employees included.
+func changedEmployees(prior, current map[string]string) map[string]bool {
+	changed := make(map[string]bool)
+	for employee, h := range current {
+		if prior[employee] != h {
+			changed[employee] = true
+		}
+	}
+	return changed
+}
+
+// mergeIncremental combines the prior register's lines for employees that did not
+// change with the lines recomputed for those that did, dropping employees no
+// longer in the inputs. Employees come in key order, as computeRegister writes
+// them, each with their lines in the order they were computed.
+func mergeIncremental(prior, recomputed []PayRegister, current map[string]string, changed map[string]bool) []PayRegister {
+	lines := make(map[string][]PayRegister)
+	for _, reg := range prior {
+		employee := makeKey(reg.EmployeeID, "")
+		if _, ok := current[employee]; ok && !changed[employee] {
+			// A csv register holds the rate rounded, and readRegisterFile skips it.
+			reg.EffectiveTaxRate = effectiveTaxRate(reg)
+			lines[employee] = append(lines[employee], reg)
+		}
+	}
+	for _, reg := range recomputed {
+		employee := makeKey(reg.EmployeeID, "")
+		lines[employee] = append(lines[employee], reg)
+	}
+	merged := make([]PayRegister, 0, len(prior)+len(recomputed))
+	for _, employee := range sortedKeys(lines) {
+		merged = append(merged, lines[employee]...)
+	}
+	return merged
+}
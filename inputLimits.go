@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// InputLimits bound what the readers accept from an untrusted file (-fuzz-safe),
+// so a hostile one cannot make them allocate without limit. Zero means no limit.
+type InputLimits struct {
+	MaxFields     int   // fields per row
+	MaxFieldBytes int   // bytes per field
+	MaxAmount     Money // magnitude of any amount
+	MaxHours      int   // magnitude of any hours cell
+}
+
+// defaultInputLimits are -fuzz-safe's limits unless the -max-* flags change them:
+// far above any real payroll file, far below what would exhaust memory.
+var defaultInputLimits = InputLimits{
+	MaxFields:     256,
+	MaxFieldBytes: 1024,
+	MaxAmount:     100_000_000_00,
+	MaxHours:      10_000,
+}
+
+// maxRowBytes bounds the bytes one CSV record may span: every field at its limit,
+// quoted, plus its delimiter. Zero when either limit is unset.
+func (l InputLimits) maxRowBytes() int {
+	return l.MaxFields * (l.MaxFieldBytes + 3)
+}
+
+// checkRow rejects a row with too many fields or too long a field.
+func (l InputLimits) checkRow(row []string, line int) error {
+	if l.MaxFields > 0 && len(row) > l.MaxFields {
+		return fmt.Errorf("row %d has %d fields, more than the limit of %d", line, len(row), l.MaxFields)
+	}
+	if l.MaxFieldBytes > 0 {
+		for i, cell := range row {
+			if len(cell) > l.MaxFieldBytes {
+				return fmt.Errorf("row %d field %d is %d bytes, more than the limit of %d", line, i+1, len(cell), l.MaxFieldBytes)
+			}
+		}
+	}
+	return nil
+}
+
+// bounded passes on a parsed amount, rejecting one larger than MaxAmount. It takes
+// the parser's results as they are, e.g. opts.bounded(parseMoney(cell)).
+func (opts ReaderOptions) bounded(m Money, err error) (Money, error) {
+	if err == nil && opts.Limits.MaxAmount > 0 && (m > opts.Limits.MaxAmount || m < -opts.Limits.MaxAmount) {
+		return 0, fmt.Errorf("amount %s is beyond the limit of %s", m, opts.Limits.MaxAmount)
+	}
+	return m, err
+}
+
+// errRowTooLong ends a read whose current record runs past maxRowBytes.
+var errRowTooLong = errors.New("record too long")
+
+// boundedReader fails a read once more than left bytes have been read since the
+// last reset, which readCSV does before each record. The CSV reader buffers
+// ahead, so the bound is loose by that buffer's size, but it stays a bound: a
+// single record can no longer grow without end.
+type boundedReader struct {
+	r     io.Reader
+	limit int
+	left  int
+}
+
+func (b *boundedReader) reset() {
+	// Allow for what the CSV reader's buffer had already read ahead.
+	b.left = b.limit + 64*1024
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, errRowTooLong
+	}
+	if len(p) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= n
+	return n, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fuzzLimits are small -fuzz-safe limits, so the fuzzer reaches them quickly.
+var fuzzLimits = InputLimits{MaxFields: 8, MaxFieldBytes: 32, MaxAmount: 1_000_00, MaxHours: 100}
+
+// writeInput writes data to a file in a fresh temp dir and returns its path.
+func writeInput(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.csv")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func FuzzReadCSV(f *testing.F) {
+	f.Add(selfTestPayroll)
+	f.Add(selfTestTime)
+	f.Add(selfTestBenefits)
+	f.Add("Employee ID,First Name,Last Name,Job Title,Pay Period,Hourly Rate\n001,Jane,Doe,Cook,2024-06,20.00\n")
+	f.Add("Employee ID;Pay Period;Regular Hours\n001;2024-06;\"8\n0\"\n")
+	f.Fuzz(func(t *testing.T, data string) {
+		path := writeInput(t, data)
+		opts := ReaderOptions{Limits: fuzzLimits}
+		readCSV(path, "payroll", opts, func(cols columnMap, row []string, line int) error {
+			// readCSV joins an Employee Name from First Name and Last Name columns
+			// after checking the limits, so a row may carry one field, of two
+			// names and a separator, past them.
+			if len(row) > fuzzLimits.MaxFields+1 {
+				t.Fatalf("row %d has %d fields past the limit of %d", line, len(row), fuzzLimits.MaxFields)
+			}
+			for _, cell := range row {
+				if len(cell) > 2*fuzzLimits.MaxFieldBytes+1 {
+					t.Fatalf("row %d has a %d-byte field past the limit of %d", line, len(cell), fuzzLimits.MaxFieldBytes)
+				}
+			}
+			return nil
+		})
+		if payrollMap, err := readPayrollRecords(path, opts); err == nil {
+			for _, p := range payrollMap {
+				if p.HourlyRate > fuzzLimits.MaxAmount || p.HourlyRate < -fuzzLimits.MaxAmount {
+					t.Fatalf("employee %q's rate %s is past the limit of %s", p.EmployeeID, p.HourlyRate, fuzzLimits.MaxAmount)
+				}
+			}
+		}
+		readTimeRecords(path, opts)
+		readBenefitsRecords(path, opts)
+	})
+}
+
+func TestInputLimits(t *testing.T) {
+	opts := ReaderOptions{Limits: fuzzLimits}
+	for _, tc := range []struct {
+		name, payroll, want string
+	}{
+		{"too many fields", "Employee ID,Employee Name,Job Title,Pay Period,Hourly Rate,A,B,C,D\n001,A,B,2024-06,10.00,1,2,3,4\n", "9 fields"},
+		{"too long a field", "Employee ID,Employee Name,Job Title,Pay Period,Hourly Rate\n001," + strings.Repeat("A", 33) + ",B,2024-06,10.00\n", "33 bytes"},
+		{"too large an amount", "Employee ID,Employee Name,Job Title,Pay Period,Hourly Rate\n001,A,B,2024-06,1000.01\n", "beyond the limit"},
+		{"too long a record", "Employee ID,Employee Name,Job Title,Pay Period,Hourly Rate\n001,\"" + strings.Repeat("A", 128*1024), "runs past the limit"},
+	} {
+		_, err := readPayrollRecords(writeInput(t, tc.payroll), opts)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+	}
+	_, err := readTimeRecords(writeInput(t, "Employee ID,Pay Period,Regular Hours,Overtime Hours\n001,2024-06,101,0\n"), opts)
+	if err == nil || !strings.Contains(err.Error(), "beyond the limit of 100") {
+		t.Errorf("too many hours: got error %v, want one beyond the limit", err)
+	}
+	if _, err := readPayrollRecords(writeInput(t, selfTestPayroll), opts); err != nil {
+		t.Errorf("input within the limits: %v", err)
+	}
+}
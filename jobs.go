@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JobRate is one of several jobs an employee holds in a period, each with its own rate.
+type JobRate struct {
+	Title string
+	Rate  Money
+}
+
+// JobHours are the hours worked in one job, from a time file with a Job Title column.
+type JobHours struct {
+	RegularHours  int
+	OvertimeHours int
+}
+
+// jobKey is how job titles are matched between the payroll and time files.
+func jobKey(title string) string {
+	return strings.ToUpper(strings.TrimSpace(title))
+}
+
+// addJob merges a second payroll row for the same employee and period into prev.
+// A different job title becomes another entry in Jobs; the same title replaces that
+// job's rate, as a repeated row always has.
+func (prev PayrollRecord) addJob(rec PayrollRecord) PayrollRecord {
+	if len(prev.Jobs) == 0 {
+		if jobKey(prev.JobTitle) == jobKey(rec.JobTitle) {
+			return rec
+		}
+		prev.Jobs = []JobRate{{Title: prev.JobTitle, Rate: prev.HourlyRate}}
+	}
+	for i, job := range prev.Jobs {
+		if jobKey(job.Title) == jobKey(rec.JobTitle) {
+			prev.Jobs[i].Rate = rec.HourlyRate
+			return prev
+		}
+	}
+	prev.Jobs = append(prev.Jobs, JobRate{Title: rec.JobTitle, Rate: rec.HourlyRate})
+	return prev
+}
+
+// addJobHours merges a time row for one job into prev, keeping RegularHours and
+// OvertimeHours as the totals over all jobs. A repeated job replaces its hours;
+// adjustments, units, and overtime pay add up.
+func (prev TimeRecord) addJobHours(title string, rec TimeRecord) TimeRecord {
+	if prev.Jobs == nil {
+		prev.Jobs = make(map[string]JobHours)
+	}
+	prev.Jobs[jobKey(title)] = JobHours{RegularHours: rec.RegularHours, OvertimeHours: rec.OvertimeHours}
+	prev.RegularHours, prev.OvertimeHours = 0, 0
+	for _, h := range prev.Jobs {
+		prev.RegularHours += h.RegularHours
+		prev.OvertimeHours += h.OvertimeHours
+	}
+	prev.Adjustment += rec.Adjustment
+	prev.Units += rec.Units
+	prev.OvertimePay += rec.OvertimePay
+	prev.ReportedTips += rec.ReportedTips
+	return prev
+}
+
+// addWeekHours merges a time row for one week into prev, keeping RegularHours and
+// OvertimeHours as the totals over all weeks. A repeated week is merged as merge
+// says (see ReaderOptions.TimeMerge); adjustments, units, overtime pay and tips
+// add up.
+func (prev TimeRecord) addWeekHours(week int, rec TimeRecord, merge string) (TimeRecord, error) {
+	if prev.Weeks == nil {
+		prev.Weeks = make(map[int]WeekHours)
+	}
+	hours := WeekHours{RegularHours: rec.RegularHours, OvertimeHours: rec.OvertimeHours}
+	if old, ok := prev.Weeks[week]; ok {
+		switch merge {
+		case mergeSum:
+			hours.RegularHours += old.RegularHours
+			hours.OvertimeHours += old.OvertimeHours
+		case mergeError:
+			return prev, fmt.Errorf("duplicate time record for employee %s period %s week %d (see -time-merge)", rec.EmployeeID, rec.PayPeriod, week)
+		}
+	}
+	prev.Weeks[week] = hours
+	prev.RegularHours, prev.OvertimeHours = 0, 0
+	for _, h := range prev.Weeks {
+		prev.RegularHours += h.RegularHours
+		prev.OvertimeHours += h.OvertimeHours
+	}
+	prev.Adjustment += rec.Adjustment
+	prev.Units += rec.Units
+	prev.OvertimePay += rec.OvertimePay
+	prev.ReportedTips += rec.ReportedTips
+	return prev, nil
+}
+
+// jobHours looks up the hours for each of payroll's jobs. Hours booked to a job the
+// payroll file does not list are an error, since there is no rate to pay them at.
+func jobHours(payroll PayrollRecord, timeRec TimeRecord) ([]JobHours, error) {
+	if len(timeRec.Days) > 0 {
+		return nil, fmt.Errorf("%d jobs cannot be paid from daily time records, which have no job", len(payroll.Jobs))
+	}
+	if len(timeRec.Jobs) == 0 {
+		return nil, fmt.Errorf("%d jobs in the payroll file but the time file has no Job Title hours for them", len(payroll.Jobs))
+	}
+	listed := make(map[string]bool)
+	hours := make([]JobHours, len(payroll.Jobs))
+	for i, job := range payroll.Jobs {
+		listed[jobKey(job.Title)] = true
+		hours[i] = timeRec.Jobs[jobKey(job.Title)]
+	}
+	for _, title := range sortedKeys(timeRec.Jobs) {
+		if !listed[title] {
+			return nil, fmt.Errorf("hours booked to job %q, which has no rate in the payroll file", title)
+		}
+	}
+	return hours, nil
+}
+
+// jobTitles joins the titles of a multi-job record for the register's Job Title column.
+func jobTitles(jobs []JobRate) string {
+	titles := make([]string, len(jobs))
+	for i, job := range jobs {
+		titles[i] = job.Title
+	}
+	return strings.Join(titles, " / ")
+}
+
+// splitJobs turns a multi-job employee-period into one single-job record per job
+// (-split-jobs). Benefits are deducted, and adjustments and tips paid, once, on
+// the first job's line.
+func splitJobs(payroll PayrollRecord, timeRec TimeRecord, benefitsRec BenefitsRecord) ([]PayrollRecord, []TimeRecord, []BenefitsRecord, error) {
+	hours, err := jobHours(payroll, timeRec)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var payrolls []PayrollRecord
+	var times []TimeRecord
+	var benefits []BenefitsRecord
+	for i, job := range payroll.Jobs {
+		p := payroll
+		p.JobTitle, p.HourlyRate, p.Jobs = job.Title, job.Rate, nil
+		t := TimeRecord{EmployeeID: timeRec.EmployeeID, PayPeriod: timeRec.PayPeriod,
+			RegularHours: hours[i].RegularHours, OvertimeHours: hours[i].OvertimeHours}
+		b := BenefitsRecord{EmployeeID: benefitsRec.EmployeeID, PayPeriod: benefitsRec.PayPeriod}
+		if i == 0 {
+			t.Adjustment, t.ReportedTips, b = timeRec.Adjustment, timeRec.ReportedTips, benefitsRec
+		}
+		payrolls, times, benefits = append(payrolls, p), append(times, t), append(benefits, b)
+	}
+	return payrolls, times, benefits, nil
+}
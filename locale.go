@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Locale bundles one region's defaults for the settings that otherwise each have a
+// flag of their own (-locale). A flag given explicitly still wins over its locale
+// default.
+type Locale struct {
+	Delimiter    string // input field separator, as for -delimiter; empty detects it per file
+	ThousandsSep string // as for -thousands-sep
+	DecimalMark  string // as for -decimal-mark
+	Currency     string // as for -currency
+	// DateLayout is the locale's numeric date order. It takes the place of the
+	// US month/day layout among the pay-period formats auto-detected without
+	// -period-format, so 03/04/2024 is read as 3 April in a day-first locale.
+	DateLayout string
+}
+
+// locales are the locales -locale accepts.
+var locales = map[string]Locale{
+	"en-US": {ThousandsSep: ",", DecimalMark: ".", Currency: "USD", DateLayout: "01/02/2006"},
+	"en-CA": {ThousandsSep: ",", DecimalMark: ".", Currency: "CAD", DateLayout: "02/01/2006"},
+	"en-GB": {ThousandsSep: ",", DecimalMark: ".", Currency: "GBP", DateLayout: "02/01/2006"},
+	"de-DE": {Delimiter: ";", ThousandsSep: ".", DecimalMark: ",", Currency: "EUR", DateLayout: "02.01.2006"},
+	"fr-FR": {Delimiter: ";", ThousandsSep: " ", DecimalMark: ",", Currency: "EUR", DateLayout: "02/01/2006"},
+	"ja-JP": {ThousandsSep: ",", DecimalMark: ".", Currency: "JPY", DateLayout: "2006/01/02"},
+}
+
+// lookupLocale resolves a -locale name, accepting de_DE and de-de for de-DE.
+func lookupLocale(name string) (Locale, error) {
+	lang, region, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(name), "_", "-"), "-")
+	if loc, ok := locales[strings.ToLower(lang)+"-"+strings.ToUpper(region)]; ok {
+		return loc, nil
+	}
+	names := make([]string, 0, len(locales))
+	for n := range locales {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Locale{}, fmt.Errorf("unknown locale %q (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// useDateLayout makes layout the numeric date order auto-detected for pay
+// periods, in place of the US month/day one.
+func useDateLayout(layout string) {
+	for i, l := range periodLayouts {
+		if l == "01/02/2006" {
+			periodLayouts[i] = layout
+			return
+		}
+	}
+}
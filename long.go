@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LongLine is one earning or deduction of one register line, for -format long:
+// the register turned into rows a warehouse fact table can load as-is.
+type LongLine struct {
+	Type     string // "Regular", "Overtime", "Federal Tax", a benefit's name, ...
+	Category string // earning; or tax, benefit, or deduction
+	Hours    int    // earnings only; zero for amounts not paid by the hour
+	Amount   Money
+}
+
+// longEarnings splits a line's gross into its earnings. Regular pay takes
+// whatever gross leaves after the other earnings, so the rows always add up to
+// Gross Wages, mid-period rate changes and several jobs included.
+func longEarnings(reg PayRegister) []LongLine {
+	var lines []LongLine
+	rest := reg.GrossWages - reg.Adjustment - reg.CustomEarnings
+	if reg.PieceEarnings != 0 {
+		hours := reg.RegularHours + reg.OvertimeHours + reg.DoubleTimeHours
+		hourly := reg.HourlyRate.MulHours(hours)
+		lines = append(lines,
+			LongLine{Type: "Piece Earnings", Category: "earning", Amount: reg.PieceEarnings},
+			LongLine{Type: "Hourly", Category: "earning", Hours: hours, Amount: hourly},
+			LongLine{Type: "Overtime Premium", Category: "earning", Hours: reg.OvertimeHours + reg.DoubleTimeHours, Amount: rest - reg.PieceEarnings - hourly},
+		)
+	} else {
+		// Overtime is what the overtime columns leave after double time, which
+		// also covers overtime paid as an amount (-overtime-mode amount).
+		doubleTime := reg.HourlyRate.MulHours(2 * reg.DoubleTimeHours)
+		overtime := reg.OvertimeStraight + reg.OvertimePremium - doubleTime
+		lines = append(lines,
+			LongLine{Type: "Regular", Category: "earning", Hours: reg.RegularHours, Amount: rest - overtime - doubleTime},
+			LongLine{Type: "Overtime", Category: "earning", Hours: reg.OvertimeHours, Amount: overtime},
+			LongLine{Type: "Double Time", Category: "earning", Hours: reg.DoubleTimeHours, Amount: doubleTime},
+		)
+	}
+	lines = append(lines, LongLine{Type: "Adjustment", Category: "earning", Amount: reg.Adjustment})
+	for _, e := range reg.Earnings {
+		lines = append(lines, LongLine{Type: e.Label, Category: "earning", Amount: e.Amount})
+	}
+	return lines
+}
+
+// longDeductions splits a line's total deductions into taxes, benefits (named
+// benefits on their own rows), and custom deductions. Arrears carried between
+// periods (-track-arrears) get an Arrears row, so the rows add up to Total
+// Deductions.
+func longDeductions(reg PayRegister) []LongLine {
+	lines := []LongLine{
+		{Type: "Federal Tax", Category: "tax", Amount: reg.FederalTax},
+		{Type: "State Tax", Category: "tax", Amount: reg.StateTax},
+		{Type: "Local Tax", Category: "tax", Amount: reg.LocalTax},
+		{Type: "Social Security", Category: "tax", Amount: reg.SocialSecurity},
+		{Type: "Medicare", Category: "tax", Amount: reg.Medicare},
+	}
+	for _, c := range reg.Contributions {
+		lines = append(lines, LongLine{Type: c.Name, Category: "tax", Amount: c.Employee})
+	}
+	lines = append(lines, []LongLine{
+		{Type: "Health Insurance", Category: "benefit", Amount: reg.HealthInsurance},
+		{Type: "Retirement", Category: "benefit", Amount: reg.Retirement},
+	}...)
+	other := reg.OtherBenefits
+	for _, name := range sortedKeys(reg.NamedBenefits) {
+		lines = append(lines, LongLine{Type: name, Category: "benefit", Amount: reg.NamedBenefits[name]})
+		other -= reg.NamedBenefits[name]
+	}
+	lines = append(lines, LongLine{Type: "Other Benefits", Category: "benefit", Amount: other})
+	for _, d := range reg.Deductions {
+		lines = append(lines, LongLine{Type: d.Label, Category: "deduction", Amount: d.Amount})
+	}
+	var listed Money
+	for _, l := range lines {
+		listed += l.Amount
+	}
+	lines = append(lines, LongLine{Type: "Arrears", Category: "deduction", Amount: reg.TotalDeductions - listed})
+	return lines
+}
+
+// longFilenames names -format long's two tables after filename:
+// payroll_register.csv -> payroll_register_earnings.csv and
+// payroll_register_deductions.csv.
+func longFilenames(filename string) (earnings, deductions string) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	return base + "_earnings.csv", base + "_deductions.csv"
+}
+
+// writeRegisterLong writes the register as two long tables, one row per earning
+// and one per deduction of each line, leaving out zero rows.
+func writeRegisterLong(registers []PayRegister, filename string, opts WriterOptions) error {
+	earnings, deductions := longFilenames(filename)
+	if err := writeLongTable(registers, earnings, "Earning", longEarnings, opts); err != nil {
+		return err
+	}
+	return writeLongTable(registers, deductions, "Deduction", longDeductions, opts)
+}
+
+// writeLongTable writes one long table; only earnings have an Hours column.
+func writeLongTable(registers []PayRegister, filename, kind string, split func(PayRegister) []LongLine, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	withHours := kind == "Earning"
+	header := []string{"Employee ID", "Pay Period", kind + " Type", "Category"}
+	if withHours {
+		header = append(header, "Hours")
+	}
+	header = append(header, "Amount", "Currency")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write %s header: %v", strings.ToLower(kind), err)
+	}
+	for _, reg := range registers {
+		money := opts.formatter(reg.Currency)
+		for _, l := range split(reg) {
+			if l.Amount == 0 && l.Hours == 0 {
+				continue
+			}
+			row := []string{csvText(reg.EmployeeID), csvText(reg.PayPeriod), csvText(l.Type), l.Category}
+			if withHours {
+				row = append(row, strconv.Itoa(l.Hours))
+			}
+			row = append(row, money(l.Amount), reg.Currency)
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("cannot write %s row: %v", strings.ToLower(kind), err)
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write output file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// phaseBuckets are the histogram upper bounds, in seconds, for per-phase durations.
+var phaseBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// runMetrics collects pipeline counters for the optional Prometheus exporter. It
+// renders the text exposition format directly so no client library is needed.
+type runMetrics struct {
+	mu             sync.Mutex
+	recordsRead    map[string]int
+	registers      int
+	rowErrors      int
+	phaseDurations map[string][]float64
+	totalGross     map[string]Money
+	totalNet       map[string]Money
+}
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		recordsRead:    make(map[string]int),
+		phaseDurations: make(map[string][]float64),
+		totalGross:     make(map[string]Money),
+		totalNet:       make(map[string]Money),
+	}
+}
+
+// observeRead records how many records were read from one input file.
+func (m *runMetrics) observeRead(file string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recordsRead[file] += n
+}
+
+// observePhase records the duration of one pipeline phase.
+func (m *runMetrics) observePhase(phase string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phaseDurations[phase] = append(m.phaseDurations[phase], d.Seconds())
+}
+
+// observeRegisters records the computed registers and row errors.
+func (m *runMetrics) observeRegisters(registers []PayRegister, rowErrors int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registers += len(registers)
+	m.rowErrors += rowErrors
+	for _, reg := range registers {
+		m.totalGross[reg.Currency] += reg.GrossWages
+		m.totalNet[reg.Currency] += reg.NetPay
+	}
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (m *runMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP payroll_records_read_total Input records read, by file.")
+	fmt.Fprintln(&b, "# TYPE payroll_records_read_total counter")
+	files := make([]string, 0, len(m.recordsRead))
+	for f := range m.recordsRead {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		fmt.Fprintf(&b, "payroll_records_read_total{file=%q} %d\n", f, m.recordsRead[f])
+	}
+
+	fmt.Fprintln(&b, "# HELP payroll_registers_computed_total Register rows computed.")
+	fmt.Fprintln(&b, "# TYPE payroll_registers_computed_total counter")
+	fmt.Fprintf(&b, "payroll_registers_computed_total %d\n", m.registers)
+
+	fmt.Fprintln(&b, "# HELP payroll_row_errors_total Rows skipped because they failed to compute.")
+	fmt.Fprintln(&b, "# TYPE payroll_row_errors_total counter")
+	fmt.Fprintf(&b, "payroll_row_errors_total %d\n", m.rowErrors)
+
+	fmt.Fprintln(&b, "# HELP payroll_gross_wages_total Sum of gross wages across computed registers, by currency.")
+	fmt.Fprintln(&b, "# TYPE payroll_gross_wages_total gauge")
+	for _, c := range sortedKeys(m.totalGross) {
+		fmt.Fprintf(&b, "payroll_gross_wages_total{currency=%q} %s\n", c, m.totalGross[c])
+	}
+
+	fmt.Fprintln(&b, "# HELP payroll_net_pay_total Sum of net pay across computed registers, by currency.")
+	fmt.Fprintln(&b, "# TYPE payroll_net_pay_total gauge")
+	for _, c := range sortedKeys(m.totalNet) {
+		fmt.Fprintf(&b, "payroll_net_pay_total{currency=%q} %s\n", c, m.totalNet[c])
+	}
+
+	fmt.Fprintln(&b, "# HELP payroll_phase_duration_seconds Duration of each pipeline phase.")
+	fmt.Fprintln(&b, "# TYPE payroll_phase_duration_seconds histogram")
+	phases := make([]string, 0, len(m.phaseDurations))
+	for p := range m.phaseDurations {
+		phases = append(phases, p)
+	}
+	sort.Strings(phases)
+	for _, p := range phases {
+		obs := m.phaseDurations[p]
+		var sum float64
+		for _, o := range obs {
+			sum += o
+		}
+		for _, le := range phaseBuckets {
+			n := 0
+			for _, o := range obs {
+				if o <= le {
+					n++
+				}
+			}
+			fmt.Fprintf(&b, "payroll_phase_duration_seconds_bucket{phase=%q,le=\"%g\"} %d\n", p, le, n)
+		}
+		fmt.Fprintf(&b, "payroll_phase_duration_seconds_bucket{phase=%q,le=\"+Inf\"} %d\n", p, len(obs))
+		fmt.Fprintf(&b, "payroll_phase_duration_seconds_sum{phase=%q} %g\n", p, sum)
+		fmt.Fprintf(&b, "payroll_phase_duration_seconds_count{phase=%q} %d\n", p, len(obs))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+// serveMetrics starts the /metrics endpoint on addr in the background. The listener
+// is opened synchronously so a bad address fails the run up front.
+func serveMetrics(addr string, m *runMetrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot listen on metrics address %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	go http.Serve(ln, mux)
+	return nil
+}
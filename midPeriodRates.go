@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MidPeriodRate is a raise (or cut) that takes effect partway through a pay period.
+// Hours worked before EffectiveDate are paid at the payroll file's rate for the
+// period, the rest at NewRate.
+type MidPeriodRate struct {
+	EffectiveDate time.Time
+	NewRate       Money
+	// HoursBefore, when set, is how many of the period's hours were worked before
+	// EffectiveDate. Otherwise the split comes from the daily time file if there is
+	// one, or else from calendar days.
+	HoursBefore *int
+}
+
+// periodEnd is the day after the last day of the period starting at start, inferred
+// from the pay frequency (a period named by its month spans the month instead). Monthly and longer schedules follow the calendar;
+// semimonthly periods end on the 15th and at month end.
+func periodEnd(start time.Time, periodsPerYear int) time.Time {
+	switch periodsPerYear {
+	case 1:
+		return start.AddDate(1, 0, 0)
+	case 4:
+		return start.AddDate(0, 3, 0)
+	case 12:
+		return start.AddDate(0, 1, 0)
+	case 24:
+		if start.Day() < 16 {
+			return time.Date(start.Year(), start.Month(), 16, 0, 0, 0, 0, start.Location())
+		}
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location())
+	case 26:
+		return start.AddDate(0, 0, 14)
+	case 52:
+		return start.AddDate(0, 0, 7)
+	}
+	if periodsPerYear <= 0 {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 365/periodsPerYear)
+}
+
+// shareBefore is the fraction of the period's hours worked at the old rate.
+func (c MidPeriodRate) shareBefore(timeRec TimeRecord, payPeriod string, periodsPerYear int) (float64, error) {
+	total := timeRec.RegularHours + timeRec.OvertimeHours + timeRec.DoubleTimeHours
+	if c.HoursBefore != nil {
+		if *c.HoursBefore > total {
+			return 0, fmt.Errorf("rate change lists %d hours before %s but the period has %d", *c.HoursBefore, c.EffectiveDate.Format("2006-01-02"), total)
+		}
+		if total == 0 {
+			return 0, nil
+		}
+		return float64(*c.HoursBefore) / float64(total), nil
+	}
+	if len(timeRec.Days) > 0 {
+		before, all := 0, 0
+		for _, d := range timeRec.Days {
+			all += d.Hours
+			if d.Date.Before(c.EffectiveDate) {
+				before += d.Hours
+			}
+		}
+		if all == 0 {
+			return 0, nil
+		}
+		return float64(before) / float64(all), nil
+	}
+	start, err := parsePeriod(payPeriod)
+	if err != nil {
+		return 0, err
+	}
+	end := periodEnd(start, periodsPerYear)
+	if isMonthPeriod(payPeriod) {
+		end = start.AddDate(0, 1, 0)
+	}
+	if c.EffectiveDate.Before(start) || !c.EffectiveDate.Before(end) {
+		return 0, fmt.Errorf("rate change effective %s falls outside pay period %s (%s to %s)", c.EffectiveDate.Format("2006-01-02"),
+			payPeriod, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	return c.EffectiveDate.Sub(start).Hours() / end.Sub(start).Hours(), nil
+}
+
+// divideHours divides hours by share, rounding the part before the change to whole
+// hours so the two parts always add back up to hours.
+func divideHours(hours int, share float64) (before, after int) {
+	before = int(math.Round(float64(hours) * share))
+	return before, hours - before
+}
+
+// readMidPeriodRates reads the -mid-period-rates CSV (Employee ID, Pay Period,
+// Effective Date as YYYY-MM-DD, New Rate, and an optional Hours Before column) into
+// a map keyed like the other inputs, by EmployeeID|PayPeriod.
+func readMidPeriodRates(filename string, opts ReaderOptions) (map[string]MidPeriodRate, error) {
+	changes := make(map[string]MidPeriodRate)
+	err := readCSV(filename, "mid-period rates", opts, func(cols columnMap, row []string, line int) error {
+		if len(row) < 4 {
+			return opts.shortRow(row, line, 4)
+		}
+		if err := requireIdentifiers(row, line); err != nil {
+			return err
+		}
+		effective, err := parseDate(row[2])
+		if err != nil {
+			return fmt.Errorf("error parsing Effective Date in row %d: %v", line, err)
+		}
+		newRate, err := opts.money(row[3])
+		if err != nil {
+			return fmt.Errorf("error parsing New Rate in row %d: %v", line, err)
+		}
+		change := MidPeriodRate{EffectiveDate: effective, NewRate: newRate}
+		if v := strings.TrimSpace(cols.value(row, "Hours Before")); v != "" {
+			hours, err := strconv.Atoi(v)
+			if err != nil || hours < 0 {
+				return fmt.Errorf("error parsing Hours Before in row %d: want a whole number of hours, got %q", line, v)
+			}
+			change.HoursBefore = &hours
+		}
+		period, err := opts.period(row[1])
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		key := makeKey(opts.employeeID(row[0]), period)
+		if _, ok := changes[key]; ok {
+			return fmt.Errorf("second rate change for employee %s period %s in row %d; only one per period is supported", opts.employeeID(row[0]), period, line)
+		}
+		changes[key] = change
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
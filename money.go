@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in integer cents. All monetary fields are held as Money so
+// totals are penny-exact; float64 is only used for rates and multipliers.
+type Money int64
+
+// rateScale is the fixed-point scale applied to rates in MulRate (nine decimal
+// places), which keeps cents*rate within int64 for any single paycheck.
+const rateScale = 1_000_000_000
+
+// parseMoney parses a decimal string such as "1234.5", "-0.07", or "1.25E+01" into
+// cents. Digits beyond the second decimal place are rounded half away from zero.
+// Scientific notation is handled by shifting the decimal point in the text, so it is
+// exactly as precise as the plain form.
+func parseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("parsing %q: empty amount", s)
+	}
+	neg := false
+	digits := s
+	switch digits[0] {
+	case '-':
+		neg = true
+		digits = digits[1:]
+	case '+':
+		digits = digits[1:]
+	}
+	mantissa, exponent, hasExp := strings.Cut(strings.ToLower(digits), "e")
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("parsing %q: invalid amount", s)
+	}
+	if hasExp {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil || exp > 18 || exp < -18 {
+			return 0, fmt.Errorf("parsing %q: invalid exponent", s)
+		}
+		whole, frac = shiftDecimal(whole, frac, exp)
+	}
+	var cents int64
+	if whole != "" {
+		w, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || w > math.MaxInt64/100 {
+			return 0, fmt.Errorf("parsing %q: amount out of range", s)
+		}
+		cents = w * 100
+	}
+	frac += "000"
+	cents += int64(frac[0]-'0')*10 + int64(frac[1]-'0')
+	if frac[2] >= '5' {
+		cents++
+	}
+	if neg {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+// shiftDecimal moves the decimal point of whole.frac by exp places (right when
+// positive), padding with zeros as needed.
+func shiftDecimal(whole, frac string, exp int) (string, string) {
+	all := whole + frac
+	point := len(whole) + exp
+	switch {
+	case point <= 0:
+		return "", strings.Repeat("0", -point) + all
+	case point >= len(all):
+		return all + strings.Repeat("0", point-len(all)), ""
+	}
+	return all[:point], all[point:]
+}
+
+// isDigits reports whether s consists only of ASCII digits (the empty string qualifies).
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// divRound divides n by d (d > 0), rounding half away from zero.
+func divRound(n, d int64) int64 {
+	if n < 0 {
+		return -((-n + d/2) / d)
+	}
+	return (n + d/2) / d
+}
+
+// MulRate multiplies m by a decimal factor (a tax rate, an overtime multiplier times
+// hours, ...) in fixed point, rounding the result half away from zero to the cent.
+func (m Money) MulRate(rate float64) Money {
+	scaled := int64(math.Round(rate * rateScale))
+	return Money(divRound(int64(m)*scaled, rateScale))
+}
+
+// RoundingAdjustment is the rounding applied by MulRate (rounded minus exact), in
+// cents, split by whether it landed in gross or in deductions.
+type RoundingAdjustment struct {
+	Gross      float64
+	Deductions float64
+}
+
+// Net is the rounding's effect on net pay.
+func (r RoundingAdjustment) Net() float64 {
+	return r.Gross - r.Deductions
+}
+
+// Add accumulates another adjustment into r.
+func (r *RoundingAdjustment) Add(o RoundingAdjustment) {
+	r.Gross += o.Gross
+	r.Deductions += o.Deductions
+}
+
+// roundedMul is MulRate that also records its rounding into *acc.
+func roundedMul(m Money, rate float64, acc *float64) Money {
+	rounded := m.MulRate(rate)
+	*acc += float64(rounded) - float64(m)*rate
+	return rounded
+}
+
+// MulHours multiplies an hourly amount by a whole number of hours; no rounding is needed.
+func (m Money) MulHours(hours int) Money {
+	return m * Money(hours)
+}
+
+// withinTolerance reports whether a difference is no larger than tol either way.
+// Every reconciliation check compares through it (-cents-tolerance).
+func withinTolerance(diff, tol Money) bool {
+	return diff <= tol && -diff <= tol
+}
+
+// Float64 returns m in whole currency units, for ratios and reporting only.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats m as a plain two-decimal amount, e.g. "-12.30".
+func (m Money) String() string {
+	sign := ""
+	c := int64(m)
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// DisplayFormat is how amounts read in the human-facing outputs (paystubs,
+// statements, -preview): the thousands separator, none when empty, and the decimal
+// mark, "." when empty. Machine-readable outputs (the register, NDJSON, the CSV
+// reports) never use it, so they always parse back.
+type DisplayFormat struct {
+	ThousandsSep string
+	DecimalMark  string
+}
+
+// Money formats m for display, like m.String() but grouped.
+func (f DisplayFormat) Money(m Money) string {
+	return f.apply(m.String())
+}
+
+// apply regroups the first number in an already formatted amount ("-$1234567.89",
+// "1234.50 USD"), leaving any sign, symbol or code around it alone.
+func (f DisplayFormat) apply(amount string) string {
+	start := strings.IndexAny(amount, "0123456789")
+	if start < 0 || (f.ThousandsSep == "" && (f.DecimalMark == "" || f.DecimalMark == ".")) {
+		return amount
+	}
+	end := start
+	for end < len(amount) && amount[end] >= '0' && amount[end] <= '9' {
+		end++
+	}
+	digits := amount[start:end]
+	var b strings.Builder
+	b.WriteString(amount[:start])
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(f.ThousandsSep)
+		}
+		b.WriteByte(digits[i])
+	}
+	rest := amount[end:]
+	if strings.HasPrefix(rest, ".") && f.DecimalMark != "" {
+		rest = f.DecimalMark + rest[1:]
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// MarshalJSON encodes m as a decimal number in currency units (12.30, not 1230
+// cents), so JSON consumers see the same amounts as the CSV.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts an amount in currency units, as a JSON number or string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	v, err := parseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Currency controls how Money is rendered in output files.
+type Currency struct {
+	Code     string
+	Symbol   string
+	Decimals int
+}
+
+// currencies lists the codes accepted by -currency. The zero Currency (no symbol,
+// two decimals) is the default and reproduces the historical output exactly.
+var currencies = map[string]Currency{
+	"USD": {Code: "USD", Symbol: "$", Decimals: 2},
+	"CAD": {Code: "CAD", Symbol: "CA$", Decimals: 2},
+	"EUR": {Code: "EUR", Symbol: "€", Decimals: 2},
+	"GBP": {Code: "GBP", Symbol: "£", Decimals: 2},
+	"JPY": {Code: "JPY", Symbol: "¥", Decimals: 0},
+}
+
+// lookupCurrency resolves a -currency value; "" selects the plain default.
+func lookupCurrency(code string) (Currency, error) {
+	if code == "" {
+		return Currency{Decimals: 2}, nil
+	}
+	cur, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		codes := make([]string, 0, len(currencies))
+		for c := range currencies {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		return Currency{}, fmt.Errorf("unknown currency %q (valid: %s)", code, strings.Join(codes, ", "))
+	}
+	return cur, nil
+}
+
+// Format renders m with the currency's symbol and number of decimal places.
+func (c Currency) Format(m Money) string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	var amount string
+	switch {
+	case c.Decimals <= 0:
+		amount = strconv.FormatInt(divRound(cents, 100), 10)
+	case c.Decimals == 1:
+		tenths := divRound(cents, 10)
+		amount = fmt.Sprintf("%d.%d", tenths/10, tenths%10)
+	default:
+		amount = fmt.Sprintf("%d.%02d%s", cents/100, cents%100, strings.Repeat("0", c.Decimals-2))
+	}
+	return sign + c.Symbol + amount
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ACHOriginator identifies the employer and its bank in a NACHA file:
+//
+//	"ach": {"companyName": "ACME PAYROLL", "companyId": "1234567890", "originRouting": "021000021"}
+//
+// DestinationRouting and DestinationName default to the originating bank;
+// OriginName defaults to CompanyName.
+type ACHOriginator struct {
+	CompanyName        string `json:"companyName"`
+	CompanyID          string `json:"companyId"`
+	OriginRouting      string `json:"originRouting"`
+	OriginName         string `json:"originName"`
+	DestinationRouting string `json:"destinationRouting"`
+	DestinationName    string `json:"destinationName"`
+}
+
+// check validates the originator's identifiers.
+func (o ACHOriginator) check() error {
+	switch {
+	case strings.TrimSpace(o.CompanyName) == "":
+		return fmt.Errorf("companyName is required")
+	case len(o.CompanyID) == 0 || len(o.CompanyID) > 10:
+		return fmt.Errorf("companyId must be 1 to 10 characters")
+	}
+	if err := checkRoutingNumber(o.OriginRouting); err != nil {
+		return fmt.Errorf("originRouting: %v", err)
+	}
+	if o.DestinationRouting != "" {
+		if err := checkRoutingNumber(o.DestinationRouting); err != nil {
+			return fmt.Errorf("destinationRouting: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkRoutingNumber checks an ABA routing number's length and check digit.
+func checkRoutingNumber(routing string) error {
+	if len(routing) != 9 || strings.IndexFunc(routing, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return fmt.Errorf("want 9 digits, got %q", routing)
+	}
+	sum := 0
+	for i, weight := range []int{3, 7, 1, 3, 7, 1, 3, 7, 1} {
+		sum += int(routing[i]-'0') * weight
+	}
+	if sum%10 != 0 {
+		return fmt.Errorf("%s fails the routing number check digit", routing)
+	}
+	return nil
+}
+
+// BankAccount is where one of an employee's deposit accounts lives.
+type BankAccount struct {
+	Routing string
+	Number  string
+	Savings bool
+}
+
+// readBankInfo reads the -bank-info CSV: Employee ID, Account (the name used in
+// -deposit-allocations), Routing Number, Account Number, and Account Type
+// (checking, the default, or savings), located by header. The result is keyed
+// by employee ID and account name.
+func readBankInfo(filename string, opts ReaderOptions) (map[string]BankAccount, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a bank info file must have a header row")
+	}
+	accounts := make(map[string]BankAccount)
+	err := readCSV(filename, "bank info", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		name := strings.TrimSpace(cols.value(row, "Account"))
+		if name == "" {
+			return fmt.Errorf("missing Account in row %d", line)
+		}
+		key := bankAccountKey(id, name)
+		if _, ok := accounts[key]; ok {
+			return fmt.Errorf("employee %s lists account %s twice (row %d)", id, name, line)
+		}
+		a := BankAccount{
+			Routing: strings.TrimSpace(cols.value(row, "Routing Number")),
+			Number:  strings.TrimSpace(cols.value(row, "Account Number")),
+		}
+		if err := checkRoutingNumber(a.Routing); err != nil {
+			return fmt.Errorf("error parsing Routing Number in row %d: %v", line, err)
+		}
+		if a.Number == "" || len(a.Number) > 17 || strings.IndexFunc(a.Number, func(r rune) bool { return !unicode.IsDigit(r) && !unicode.IsUpper(r) && r != '-' }) >= 0 {
+			return fmt.Errorf("error parsing Account Number in row %d: want up to 17 digits, capitals or hyphens, got %q", line, a.Number)
+		}
+		switch kind := strings.ToLower(strings.TrimSpace(cols.value(row, "Account Type"))); kind {
+		case "", "checking":
+		case "savings":
+			a.Savings = true
+		default:
+			return fmt.Errorf("error parsing Account Type in row %d: want checking or savings, got %q", line, kind)
+		}
+		accounts[key] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// bankAccountKey keys readBankInfo's map; account names match case-insensitively.
+func bankAccountKey(employeeID, account string) string {
+	return employeeID + "|" + strings.ToUpper(account)
+}
+
+// nextBusinessDay is the first weekday after t, the default ACH effective date.
+func nextBusinessDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, 1)
+	for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// achField fits s to width: upper-cased, left-justified and space-filled, or cut.
+// Characters outside printable ASCII become spaces.
+func achField(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return ' '
+		}
+		return unicode.ToUpper(r)
+	}, s)
+	if len(s) > width {
+		return s[:width]
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// achNumber zero-fills n to width.
+func achNumber(n int64, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// writeNACHA writes the deposit lines as a NACHA file of PPD credits, one batch
+// with an entry per line and account, settling on effective. Lines for no
+// account (employees without allocations) and lines depositing nothing are left
+// out; each line left out for want of an account is returned as an unallocated
+// warning so the caller can say so. A deposit to an account missing from
+// bankInfo, or in a currency other than US dollars, is an error: the file must
+// pay everyone it lists in full.
+func writeNACHA(lines []DepositLine, bankInfo map[string]BankAccount, origin ACHOriginator, effective time.Time, filename string) (_ []Warning, err error) {
+	type entry struct {
+		line    DepositLine
+		account BankAccount
+	}
+	var entries []entry
+	var warnings []Warning
+	for _, l := range lines {
+		if l.Account == "" {
+			if l.Amount > 0 {
+				warnings = append(warnings, Warning{Category: "unallocated", EmployeeID: l.Register.EmployeeID, PayPeriod: l.Register.PayPeriod,
+					Message: fmt.Sprintf("net pay %s has no deposit allocation and is not in %s", l.Amount, filename), Value: l.Amount.String()})
+			}
+			continue
+		}
+		if l.Amount <= 0 {
+			continue
+		}
+		if c := l.Register.Currency; c != "" && !strings.EqualFold(c, "USD") {
+			return nil, fmt.Errorf("employee %s period %s is paid in %s; ACH pays US dollars only", l.Register.EmployeeID, l.Register.PayPeriod, c)
+		}
+		account, ok := bankInfo[bankAccountKey(l.Register.EmployeeID, l.Account)]
+		if !ok {
+			return nil, fmt.Errorf("no bank info for employee %s account %s", l.Register.EmployeeID, l.Account)
+		}
+		if l.Amount > 99999999_99 {
+			return nil, fmt.Errorf("employee %s period %s deposits %s to %s, more than an ACH entry can carry", l.Register.EmployeeID, l.Register.PayPeriod, l.Amount, l.Account)
+		}
+		entries = append(entries, entry{l, account})
+	}
+
+	file, err := createOutput(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create NACHA file: %v", err)
+	}
+	defer closeOutput(file, &err)
+	w := bufio.NewWriter(file)
+	records := 0
+	write := func(fields ...string) {
+		w.WriteString(strings.Join(fields, "") + "\n")
+		records++
+	}
+
+	now := time.Now()
+	destination := firstNonEmpty(origin.DestinationRouting, origin.OriginRouting)
+	odfi := origin.OriginRouting[:8]
+	batch := achNumber(1, 7)
+	write("1", "01", " "+destination, fmt.Sprintf("%10s", origin.CompanyID), now.Format("060102"), now.Format("1504"), "A", "094", "10", "1",
+		achField(origin.DestinationName, 23), achField(firstNonEmpty(origin.OriginName, origin.CompanyName), 23), achField("", 8))
+	write("5", "220", achField(origin.CompanyName, 16), achField("", 20), achField(origin.CompanyID, 10), "PPD", achField("PAYROLL", 10),
+		effective.Format("060102"), effective.Format("060102"), "   ", "1", odfi, batch)
+	var hash, credits int64
+	for i, e := range entries {
+		code := "22"
+		if e.account.Savings {
+			code = "32"
+		}
+		rdfi, _ := strconv.ParseInt(e.account.Routing[:8], 10, 64)
+		hash += rdfi
+		credits += int64(e.line.Amount)
+		write("6", code, e.account.Routing, achField(e.account.Number, 17), achNumber(int64(e.line.Amount), 10),
+			achField(e.line.Register.EmployeeID, 15), achField(e.line.Register.EmployeeName, 22), "  ", "0", odfi, achNumber(int64(i+1), 7))
+	}
+	hashField := achNumber(hash%10_000_000_000, 10)
+	write("8", "220", achNumber(int64(len(entries)), 6), hashField, achNumber(0, 12), achNumber(credits, 12), achField(origin.CompanyID, 10),
+		achField("", 19), achField("", 6), odfi, batch)
+	blocks := (records + 1 + 9) / 10
+	write("9", achNumber(1, 6), achNumber(int64(blocks), 6), achNumber(int64(len(entries)), 8), hashField, achNumber(0, 12), achNumber(credits, 12), achField("", 39))
+	for records%10 != 0 {
+		write(strings.Repeat("9", 94))
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot write NACHA file: %v", err)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNACHAUnallocated(t *testing.T) {
+	// 012 deposits to checking; 013 has no allocation and is left out with a
+	// warning; 014 has no net pay to deposit.
+	lines := []DepositLine{
+		{PayRegister{EmployeeID: "012", EmployeeName: "Jane Doe", PayPeriod: "2024-06"}, DepositSplit{Account: "checking", Amount: 120000}},
+		{PayRegister{EmployeeID: "013", PayPeriod: "2024-06"}, DepositSplit{Amount: 95050}},
+		{PayRegister{EmployeeID: "014", PayPeriod: "2024-06"}, DepositSplit{}},
+	}
+	bankInfo := map[string]BankAccount{bankAccountKey("012", "checking"): {Routing: "021000021", Number: "12345678"}}
+	origin := ACHOriginator{CompanyName: "ACME PAYROLL", CompanyID: "1234567890", OriginRouting: "021000021"}
+	path := filepath.Join(t.TempDir(), "payroll.ach")
+	warnings, err := writeNACHA(lines, bankInfo, origin, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Category != "unallocated" || warnings[0].EmployeeID != "013" ||
+		warnings[0].PayPeriod != "2024-06" || warnings[0].Value != "950.50" {
+		t.Errorf("got warnings %+v, want employee 013's 950.50 unallocated", warnings)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data)%95 != 0 || len(data)/95%10 != 0 {
+		t.Errorf("got a %d-byte file, want whole blocks of ten 94-character records", len(data))
+	}
+}
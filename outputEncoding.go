@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Register encodings (-output-encoding), for tools that cannot read plain UTF-8.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom" // UTF-8 after a byte order mark, for Excel and older Windows tools
+	encodingLatin1  = "latin1"    // ISO 8859-1: one byte per character, U+0000 to U+00FF only
+)
+
+// utf8BOM is the UTF-8 byte order mark. The readers skip it.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// encodeOutput wraps w to write in encoding: a BOM first for encodingUTF8BOM, or
+// every character transcoded for encodingLatin1. Plain UTF-8 passes w through.
+func encodeOutput(w io.Writer, encoding string) (io.Writer, error) {
+	switch encoding {
+	case encodingUTF8BOM:
+		if _, err := w.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+	case encodingLatin1:
+		return &latin1Writer{w: w}, nil
+	}
+	return w, nil
+}
+
+// checkLatin1 reports the first character of a row's cells that latin1 cannot
+// encode, so the register can name the row before anything is written for it.
+// Invalid UTF-8 reads as U+FFFD, which latin1 cannot encode either.
+func checkLatin1(row []string) error {
+	for _, cell := range row {
+		for _, r := range cell {
+			if r > 0xFF {
+				return fmt.Errorf("%q in %q has no latin1 encoding", r, cell)
+			}
+		}
+	}
+	return nil
+}
+
+// latin1Writer transcodes UTF-8 to latin1. A character split across two writes
+// is held back until the rest of it arrives.
+type latin1Writer struct {
+	w       io.Writer
+	pending []byte
+}
+
+func (l *latin1Writer) Write(p []byte) (int, error) {
+	in := append(l.pending, p...)
+	out := make([]byte, 0, len(in))
+	i := 0
+	for i < len(in) && utf8.FullRune(in[i:]) {
+		r, size := utf8.DecodeRune(in[i:])
+		if r > 0xFF {
+			return 0, fmt.Errorf("%q has no latin1 encoding", r)
+		}
+		out = append(out, byte(r))
+		i += size
+	}
+	l.pending = append([]byte(nil), in[i:]...)
+	if _, err := l.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DailyHours is one day's hours worked, from the optional daily time file.
+type DailyHours struct {
+	Date  time.Time
+	Hours int
+}
+
+// OvertimeRules decides how a period's daily hours split into regular, overtime (1.5x),
+// and double time (2x). A zero threshold disables that rule.
+type OvertimeRules struct {
+	Name string
+	// DailyOvertimeAfter and DailyDoubleTimeAfter are per-day thresholds.
+	DailyOvertimeAfter   int
+	DailyDoubleTimeAfter int
+	// WeeklyOvertimeAfter applies to regular hours accumulated within an ISO week.
+	WeeklyOvertimeAfter int
+}
+
+// overtimeRuleSets are the named rule sets accepted by -overtime-rules. "custom" is
+// built from the individual threshold flags instead.
+var overtimeRuleSets = map[string]OvertimeRules{
+	"federal":    {Name: "federal", WeeklyOvertimeAfter: 40},
+	"california": {Name: "california", DailyOvertimeAfter: 8, DailyDoubleTimeAfter: 12, WeeklyOvertimeAfter: 40},
+}
+
+// lookupOvertimeRules resolves a -overtime-rules name; custom uses the given thresholds.
+func lookupOvertimeRules(name string, custom OvertimeRules) (OvertimeRules, error) {
+	name = strings.ToLower(name)
+	if name == "custom" {
+		custom.Name = "custom"
+		return custom, nil
+	}
+	rules, ok := overtimeRuleSets[name]
+	if !ok {
+		return OvertimeRules{}, fmt.Errorf("unknown overtime rule set %q (want federal, california, or custom)", name)
+	}
+	return rules, nil
+}
+
+// splitHours applies the rules to a period's days and returns the aggregate regular,
+// overtime, and double-time hours. Days are processed in date order so the weekly
+// threshold is reached on the right day.
+func (r OvertimeRules) splitHours(days []DailyHours) (regular, overtime, doubleTime int) {
+	sorted := make([]DailyHours, len(days))
+	copy(sorted, days)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	weekRegular := make(map[string]int)
+	for _, d := range sorted {
+		reg, ot, dt := d.Hours, 0, 0
+		if r.DailyDoubleTimeAfter > 0 && reg > r.DailyDoubleTimeAfter {
+			dt = reg - r.DailyDoubleTimeAfter
+			reg = r.DailyDoubleTimeAfter
+		}
+		if r.DailyOvertimeAfter > 0 && reg > r.DailyOvertimeAfter {
+			ot = reg - r.DailyOvertimeAfter
+			reg = r.DailyOvertimeAfter
+		}
+		if r.WeeklyOvertimeAfter > 0 {
+			year, week := d.Date.ISOWeek()
+			wk := fmt.Sprintf("%d-%02d", year, week)
+			if room := r.WeeklyOvertimeAfter - weekRegular[wk]; reg > room {
+				if room < 0 {
+					room = 0
+				}
+				ot += reg - room
+				reg = room
+			}
+			weekRegular[wk] += reg
+		}
+		regular += reg
+		overtime += ot
+		doubleTime += dt
+	}
+	return regular, overtime, doubleTime
+}
+
+// splitWeeks applies the weekly threshold to each week of a period reported week by
+// week, moving regular hours past it to overtime, and returns the period's totals.
+// Daily thresholds need days and so do not apply.
+func (r OvertimeRules) splitWeeks(weeks map[int]WeekHours) (regular, overtime int) {
+	for _, w := range weeks {
+		reg, ot := w.RegularHours, w.OvertimeHours
+		if r.WeeklyOvertimeAfter > 0 && reg > r.WeeklyOvertimeAfter {
+			ot += reg - r.WeeklyOvertimeAfter
+			reg = r.WeeklyOvertimeAfter
+		}
+		regular += reg
+		overtime += ot
+	}
+	return regular, overtime
+}
+
+// readDailyTimeRecords reads the optional daily time file (Employee ID, Pay Period,
+// Date, Hours) and attaches each day to its TimeRecord in timeMap, creating the
+// record when the period-level time file has no row for it.
+func readDailyTimeRecords(filename string, timeMap map[string]TimeRecord, opts ReaderOptions) error {
+	return readCSV(filename, "daily time", opts, func(cols columnMap, row []string, line int) error {
+		if len(row) < 4 {
+			return opts.shortRow(row, line, 4)
+		}
+		if err := requireIdentifiers(row, line); err != nil {
+			return err
+		}
+		date, err := parseDate(row[2])
+		if err != nil {
+			return fmt.Errorf("error parsing Date in row %d: %v", line, err)
+		}
+		hours, err := opts.hours(row[3])
+		if err != nil {
+			return fmt.Errorf("error parsing Hours in row %d: %v", line, err)
+		}
+		period, err := opts.period(row[1])
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		id := opts.employeeID(row[0])
+		key := makeKey(id, period)
+		rec, ok := timeMap[key]
+		if !ok {
+			rec = TimeRecord{EmployeeID: id, PayPeriod: period}
+		}
+		rec.Days = append(rec.Days, DailyHours{Date: date, Hours: hours})
+		timeMap[key] = rec
+		return nil
+	})
+}
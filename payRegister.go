@@ -1,14 +1,34 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/rand"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
 	"log"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// registerSchemaVersion identifies the column layout written by writeRegister.
+// Bump it whenever a column is added, removed, or reordered.
+const registerSchemaVersion = 13
+
 // Data structures for the three input files
 
 type PayrollRecord struct {
@@ -16,7 +36,33 @@ type PayrollRecord struct {
 	EmployeeName string
 	JobTitle     string
 	PayPeriod    string
-	HourlyRate   float64
+	HourlyRate   Money
+	WorkLocality string
+	// FICAExempt waives Social Security and MedicareExempt waives Medicare, for
+	// students, certain visa holders, and some government employees.
+	FICAExempt     bool
+	MedicareExempt bool
+	// OvertimeExempt marks an FLSA-exempt employee, whose overtime hours are paid
+	// per the config's ExemptOvertimePay; so does an exempt job title.
+	OvertimeExempt bool
+	// OvertimeAfter, when positive, is the employee's own weekly overtime threshold
+	// in hours (optional Overtime After column), overriding the overtime rules'
+	// when hours are split from daily or weekly rows.
+	OvertimeAfter int
+	// PieceRate, when nonzero, is the pay per unit produced for a piece-rate
+	// employee (optional Piece Rate column); the units come from the time file.
+	PieceRate Money
+	// EmployeeType (e.g. FULL-TIME, PART-TIME) drives benefits eligibility.
+	EmployeeType string
+	// Currency is the ISO code the employee is paid in; blank means the run's currency.
+	Currency string
+	// Jobs lists every job, with its rate, when the payroll file has rows for more
+	// than one job title in this period. JobTitle and HourlyRate are then the first.
+	Jobs []JobRate
+	// SSN is the employee's Social Security number from the optional SSN column,
+	// for tax forms. It is masked to the last four digits in every output unless
+	// -full-ssn is set.
+	SSN string
 }
 
 type TimeRecord struct {
@@ -24,330 +70,4532 @@ type TimeRecord struct {
 	PayPeriod     string
 	RegularHours  int
 	OvertimeHours int
+	// Adjustment is a one-off correction to gross (positive or negative), read from
+	// the optional Adjustment column. A nonzero value marks the row as an adjustment.
+	Adjustment Money
+	// DoubleTimeHours are paid at 2x; they only arise from daily overtime rules.
+	DoubleTimeHours int
+	// Days is the optional daily breakdown; when present, computeRegister derives
+	// regular, overtime, and double-time hours from it using the overtime rules.
+	Days []DailyHours
+	// Jobs holds the hours per job, keyed by jobKey, when the time file has a Job
+	// Title column; RegularHours and OvertimeHours are then the totals.
+	Jobs map[string]JobHours
+	// Weeks holds the hours per week of the period, keyed by the Week column's
+	// index, when the time file reports a multi-week period one row per week.
+	// RegularHours and OvertimeHours are then the totals, and computeRegister
+	// applies the weekly overtime threshold to each week separately.
+	Weeks map[int]WeekHours
+	// Units is the number of pieces produced, for piece-rate pay (optional Units
+	// column). Like adjustments, units from several rows add up.
+	Units int
+	// OvertimePay is the overtime already priced by the source system (optional
+	// Overtime Pay column). It is only paid under -overtime-mode amount, in place
+	// of overtime hours at 1.5x; like adjustments, several rows add up.
+	OvertimePay Money
+	// ReportedTips are tips the employee already received in cash (optional
+	// Reported Tips column): taxed as wages but not paid out. Several rows add up.
+	ReportedTips Money
+}
+
+// WeekHours are the hours worked in one week of a pay period.
+type WeekHours struct {
+	RegularHours  int
+	OvertimeHours int
+}
+
+// isCorrection reports whether any of the record's hours are negative, which is how
+// an over-reported earlier period is corrected (-allow-corrections).
+func (t TimeRecord) isCorrection() bool {
+	if t.RegularHours < 0 || t.OvertimeHours < 0 || t.DoubleTimeHours < 0 {
+		return true
+	}
+	for _, d := range t.Days {
+		if d.Hours < 0 {
+			return true
+		}
+	}
+	for _, h := range t.Jobs {
+		if h.RegularHours < 0 || h.OvertimeHours < 0 {
+			return true
+		}
+	}
+	for _, h := range t.Weeks {
+		if h.RegularHours < 0 || h.OvertimeHours < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// plus adds other's hours and amounts to t's, as for a period reported in
+// several pieces. Days are appended, as the daily time file appends them, and
+// job and week hours add up per job and week.
+func (t TimeRecord) plus(other TimeRecord) TimeRecord {
+	t.RegularHours += other.RegularHours
+	t.OvertimeHours += other.OvertimeHours
+	t.DoubleTimeHours += other.DoubleTimeHours
+	t.Adjustment += other.Adjustment
+	t.Units += other.Units
+	t.OvertimePay += other.OvertimePay
+	t.ReportedTips += other.ReportedTips
+	if len(other.Days) > 0 {
+		t.Days = append(slices.Clone(t.Days), other.Days...)
+	}
+	if len(other.Jobs) > 0 {
+		jobs := maps.Clone(t.Jobs)
+		if jobs == nil {
+			jobs = make(map[string]JobHours)
+		}
+		for key, h := range other.Jobs {
+			sum := jobs[key]
+			sum.RegularHours += h.RegularHours
+			sum.OvertimeHours += h.OvertimeHours
+			jobs[key] = sum
+		}
+		t.Jobs = jobs
+	}
+	if len(other.Weeks) > 0 {
+		weeks := maps.Clone(t.Weeks)
+		if weeks == nil {
+			weeks = make(map[int]WeekHours)
+		}
+		for week, h := range other.Weeks {
+			sum := weeks[week]
+			sum.RegularHours += h.RegularHours
+			sum.OvertimeHours += h.OvertimeHours
+			weeks[week] = sum
+		}
+		t.Weeks = weeks
+	}
+	return t
 }
 
 type BenefitsRecord struct {
 	EmployeeID      string
 	PayPeriod       string
-	HealthInsurance float64
-	Retirement      float64
-	OtherBenefits   float64
+	HealthInsurance Money
+	Retirement      Money
+	OtherBenefits   Money
+	// NamedBenefits holds any further benefit columns, keyed by header name (life
+	// insurance, FSA, commuter, ...). They count as "other" benefits.
+	NamedBenefits map[string]Money
+	// EmployerContribution is what the employer pays toward benefits on top of the
+	// employee's deductions (optional Employer Contribution column).
+	EmployerContribution Money
+	// ImputedIncome is the value of employer-paid benefits that counts as taxable
+	// income (group-term life over $50,000, say): taxed, but neither paid nor
+	// deducted. It comes from the optional Imputed Income column and from named
+	// benefits the config lists in ImputedBenefits.
+	ImputedIncome Money
+}
+
+// plus adds other's amounts to b's, as for an employee enrolled in several plans.
+func (b BenefitsRecord) plus(other BenefitsRecord) BenefitsRecord {
+	b.HealthInsurance += other.HealthInsurance
+	b.Retirement += other.Retirement
+	b.OtherBenefits += other.OtherBenefits
+	b.EmployerContribution += other.EmployerContribution
+	b.ImputedIncome += other.ImputedIncome
+	if len(other.NamedBenefits) > 0 {
+		named := maps.Clone(b.NamedBenefits)
+		if named == nil {
+			named = make(map[string]Money)
+		}
+		for name, amount := range other.NamedBenefits {
+			named[name] += amount
+		}
+		b.NamedBenefits = named
+	}
+	return b
+}
+
+// benefitsReservedColumns are optional benefits columns with their own meaning,
+// which therefore are not read as named benefits.
+var benefitsReservedColumns = map[string]bool{
+	normalizeHeader("Employer Contribution"): true,
+	normalizeHeader("Imputed Income"):        true,
+}
+
+// otherTotal is the Other Benefits column plus every named benefit.
+func (b BenefitsRecord) otherTotal() Money {
+	total := b.OtherBenefits
+	for _, amount := range b.NamedBenefits {
+		total += amount
+	}
+	return total
 }
 
 // Structure for the computed pay register
 
 type PayRegister struct {
-	EmployeeID      string
-	EmployeeName    string
-	JobTitle        string
-	PayPeriod       string
-	HourlyRate      float64
-	RegularHours    int
-	OvertimeHours   int
-	GrossWages      float64
-	FederalTax      float64
-	StateTax        float64
-	SocialSecurity  float64
-	Medicare        float64
-	HealthInsurance float64
-	Retirement      float64
-	OtherBenefits   float64
-	TotalBenefits   float64
-	TotalDeductions float64
-	NetPay          float64
+	EmployeeID      string `json:"employeeId"`
+	EmployeeName    string `json:"employeeName"`
+	JobTitle        string `json:"jobTitle"`
+	PayPeriod       string `json:"payPeriod"`
+	WorkLocality    string `json:"workLocality"`
+	HourlyRate      Money  `json:"hourlyRate"`
+	RegularHours    int    `json:"regularHours"`
+	OvertimeHours   int    `json:"overtimeHours"`
+	DoubleTimeHours int    `json:"doubleTimeHours"`
+	Adjustment      Money  `json:"adjustment"`
+	GrossWages      Money  `json:"grossWages"`
+	// ImputedIncome is added to every tax base but not to gross or net pay.
+	ImputedIncome Money `json:"imputedIncome"`
+	// PieceEarnings is PieceRate times Units for a piece-rate employee; GrossWages
+	// includes it, and overtime is paid on the regular rate it implies.
+	PieceRate     Money `json:"pieceRate,omitempty"`
+	Units         int   `json:"units,omitempty"`
+	PieceEarnings Money `json:"pieceEarnings,omitempty"`
+	// OvertimeStraight and OvertimePremium split the overtime and double-time pay
+	// in GrossWages for cost accounting: the hours at the straight rate, and the
+	// extra (0.5x for overtime, 1x for double time, or a piece worker's premium on
+	// the regular rate). Together they are exactly what those hours add to gross.
+	OvertimeStraight Money `json:"overtimeStraight"`
+	OvertimePremium  Money `json:"overtimePremium"`
+	// TaxableWages is the federal income-tax base (by default gross less pre-tax benefits).
+	TaxableWages    Money `json:"taxableWages"`
+	FederalTax      Money `json:"federalTax"`
+	StateTax        Money `json:"stateTax"`
+	LocalTax        Money `json:"localTax"`
+	SocialSecurity  Money `json:"socialSecurity"`
+	Medicare        Money `json:"medicare"`
+	HealthInsurance Money `json:"healthInsurance"`
+	Retirement      Money `json:"retirement"`
+	// OtherBenefits includes the named benefits itemized in NamedBenefits.
+	OtherBenefits Money            `json:"otherBenefits"`
+	NamedBenefits map[string]Money `json:"namedBenefits,omitempty"`
+	TotalBenefits Money            `json:"totalBenefits"`
+	// CustomDeductions totals the DeductionRule amounts itemized in Deductions.
+	CustomDeductions Money `json:"customDeductions"`
+	// ArrearsCollected is arrears from earlier periods withheld on this line, and
+	// ArrearsOutstanding what is still owed after it (-track-arrears).
+	ArrearsCollected   Money `json:"arrearsCollected"`
+	ArrearsOutstanding Money `json:"arrearsOutstanding"`
+	TotalDeductions    Money `json:"totalDeductions"`
+	NetPay             Money `json:"netPay"`
+	// EffectiveTaxRate is income and FICA taxes over gross wages; 0 when gross is 0.
+	EffectiveTaxRate float64 `json:"effectiveTaxRate"`
+	IsAdjustment     bool    `json:"isAdjustment"`
+	// IsCorrection marks a line with negative hours reversing an earlier period's.
+	IsCorrection bool   `json:"isCorrection,omitempty"`
+	Currency     string `json:"currency"`
+	// BenefitsImputed marks a row computed with zero benefits because the
+	// benefits file had no record for it (-missing-benefits=zero).
+	BenefitsImputed bool        `json:"benefitsImputed,omitempty"`
+	Deductions      []Deduction `json:"deductions,omitempty"`
+	// BenefitsDefaulted marks a row computed with its job title's default
+	// benefits from the config because the benefits file had no record for it.
+	BenefitsDefaulted bool `json:"benefitsDefaulted,omitempty"`
+	// SSN is the payroll file's SSN, masked to ***-**-6789 unless -full-ssn.
+	SSN string `json:"ssn,omitempty"`
+	// EmployerSocialSecurity, EmployerMedicare, and EmployerBenefits are the
+	// employer-side costs of this row; TotalEmployerCost adds them to gross.
+	EmployerSocialSecurity Money `json:"employerSocialSecurity"`
+	EmployerMedicare       Money `json:"employerMedicare"`
+	EmployerBenefits       Money `json:"employerBenefits"`
+	TotalEmployerCost      Money `json:"totalEmployerCost"`
+	// EmployerMatch is the employer's match on the Retirement contribution per the
+	// config's RetirementMatch, after its annual cap.
+	EmployerMatch Money `json:"employerMatch,omitempty"`
+	// SocialSecurityWages is the Social Security taxable base, zero when none was
+	// withheld (exempt, or under the withholding floor).
+	SocialSecurityWages Money `json:"socialSecurityWages"`
+	// MedicareWages is the Medicare taxable base, likewise zero when none was
+	// withheld. StateWages is the state income-tax base, withheld on or not.
+	MedicareWages Money `json:"medicareWages"`
+	StateWages    Money `json:"stateWages"`
+	// NetPayRoundingCarry is the amount left over when NetPay was rounded to whole
+	// dollars (-round-net-dollars): positive when the employee was paid less than
+	// owed. It is not a CSV column, so NetPay then differs from gross less
+	// deductions by this amount and any carry brought in.
+	NetPayRoundingCarry Money `json:"netPayRoundingCarry,omitempty"`
+	// OvertimeAfter is the weekly overtime threshold the hours were split at, the
+	// employee's own or the overtime rules'; zero when the time file gave overtime
+	// hours directly and nothing was split.
+	OvertimeAfter int `json:"overtimeAfter,omitempty"`
+	// Rounding is the rounding applied while computing this row.
+	Rounding RoundingAdjustment `json:"-"`
+	// TaxDetails records each tax's base, rate, and method (-explain-taxes).
+	TaxDetails []TaxDetail `json:"-"`
+	// Contributions are the config's statutory contributions, when it lists any
+	// in place of Social Security and Medicare; each gets a register column.
+	Contributions []ContributionAmount `json:"contributions,omitempty"`
+	// CustomEarnings totals the EarningsComponent amounts itemized in Earnings;
+	// GrossWages includes them. The column is written when any line has some.
+	CustomEarnings Money     `json:"customEarnings,omitempty"`
+	Earnings       []Earning `json:"earnings,omitempty"`
+	// TipWages are the line's reported tips, in its tax bases but not in
+	// GrossWages; TipCredit is the part of them counted toward the config's
+	// TipMinimumWage. Both get register columns when any line has tips.
+	TipWages  Money `json:"tipWages,omitempty"`
+	TipCredit Money `json:"tipCredit,omitempty"`
+	// PTO is the line's paid time off accrual, when the employee has an accrual
+	// rate; it gets the register's PTO columns.
+	PTO *PTOAccrued `json:"pto,omitempty"`
 }
 
-// makeKey combines EmployeeID and PayPeriod for map keys.
-func makeKey(employeeID, payPeriod string) string {
-	return employeeID + "|" + payPeriod
+// TaxConfig holds the withholding rates and pay schedule used by computeRegister.
+type TaxConfig struct {
+	FederalRate        float64 `json:"federalRate"`
+	StateRate          float64 `json:"stateRate"`
+	SocialSecurityRate float64 `json:"socialSecurityRate"`
+	MedicareRate       float64 `json:"medicareRate"`
+	PeriodsPerYear     int     `json:"periodsPerYear"`
+
+	// FederalBrackets and StateBrackets, when set, replace FederalRate and
+	// StateRate with graduated withholding on annualized wages (see
+	// incomeTaxRate). A -tax-table file usually supplies them per year.
+	FederalBrackets []TaxBracket `json:"federalBrackets,omitempty"`
+	StateBrackets   []TaxBracket `json:"stateBrackets,omitempty"`
+
+	// LocalTaxRates maps a work locality code to its local income tax rate, e.g.
+	// {"NYC": 0.03876, "PHILADELPHIA": 0.0375}. Localities not listed levy no
+	// local tax; none are listed by default.
+	LocalTaxRates map[string]float64 `json:"localTaxRates"`
+
+	// BenefitEligibility maps an employee type to the benefit categories
+	// ("health", "retirement", "other") it may receive, e.g. {"PART-TIME":
+	// ["retirement", "other"]}. Types not listed, including a blank type, are
+	// eligible for everything; none are listed by default.
+	BenefitEligibility map[string][]string `json:"benefitEligibility"`
+
+	// PreTaxBenefits marks benefit categories ("health", "retirement", "other")
+	// deducted before income taxes. Unlisted categories are post-tax.
+	PreTaxBenefits map[string]bool `json:"preTaxBenefits"`
+
+	// ImputedBenefits names benefits columns (beyond Other Benefits) whose amounts
+	// are employer-paid benefits taxable as imputed income rather than deductions
+	// from pay, matched like headers.
+	ImputedBenefits []string `json:"imputedBenefits"`
+
+	// UntaxedTips lists the taxes (as in TaxableBases) whose base leaves reported
+	// tips out, e.g. "federal" where tips are exempt from income tax. Tips are
+	// wages for every other tax and for contributions.
+	UntaxedTips []string `json:"untaxedTips,omitempty"`
+	// TipMinimumWage, when set, is the hourly wage a tipped employee's cash wages
+	// plus tips must come to; the tips needed to reach it are the line's
+	// TipCredit, and a line the tips leave short is warned about.
+	TipMinimumWage Money `json:"tipMinimumWage,omitempty"`
+
+	// OvertimeExemptTitles lists job titles exempt from overtime pay (managers,
+	// certain professionals), matched case-insensitively. ExemptOvertimePay says
+	// what exempt employees' overtime and double-time hours earn: "regular" (the
+	// default) pays them at the straight rate, "none" not at all.
+	OvertimeExemptTitles []string `json:"overtimeExemptTitles"`
+	ExemptOvertimePay    string   `json:"exemptOvertimePay"`
+
+	// DefaultBenefits maps a job title, matched case-insensitively, to the
+	// benefits an employee with that title is computed with when the benefits file
+	// has no record for them. Employees with a benefits record are unaffected.
+	DefaultBenefits map[string]DefaultBenefits `json:"defaultBenefits"`
+
+	// RetirementMatch is the employer's match on the Retirement column; nil
+	// means no match.
+	RetirementMatch *RetirementMatch `json:"retirementMatch,omitempty"`
+
+	// Contributions, when any are listed, replace Social Security and Medicare
+	// with these statutory contributions (CPP and EI, National Insurance, ...).
+	Contributions []Contribution `json:"contributions,omitempty"`
+
+	// Deductions are client-specific deductions (union dues, loan repayments)
+	// taken after the standard ones, in order; none by default.
+	Deductions []DeductionConfig `json:"deductions,omitempty"`
+
+	// ACH identifies the employer and its bank for -nacha-out.
+	ACH *ACHOriginator `json:"ach,omitempty"`
+
+	// ReportingCurrency and FXRates drive the optional currency summary: each rate
+	// converts one unit of the keyed currency into the reporting currency.
+	ReportingCurrency string             `json:"reportingCurrency"`
+	FXRates           map[string]float64 `json:"fxRates"`
+
+	// EmployerSocialSecurityRate and EmployerMedicareRate are the employer's
+	// matching FICA share, paid on top of gross and never withheld from the employee.
+	EmployerSocialSecurityRate float64 `json:"employerSocialSecurityRate"`
+	EmployerMedicareRate       float64 `json:"employerMedicareRate"`
+
+	// SocialSecurityWageBase is the year's Social Security wage base, the taxable
+	// wages after which Social Security, employee and employer, stops; 0 means
+	// none. Per-year tables can each set their own.
+	SocialSecurityWageBase Money `json:"socialSecurityWageBase"`
+
+	// TaxableBases declares the base each tax ("federal", "state", "local",
+	// "socialSecurity", "medicare") is computed on. Employer FICA shares the
+	// employee base.
+	TaxableBases map[string]TaxableBase `json:"taxableBases"`
+
+	// Years holds per-tax-year tables keyed by year ("2024"). Each is layered over
+	// the rest of this config when loaded, so it only lists what changed that year.
+	// When any are present, every period must fall in a configured year.
+	Years map[string]TaxConfig `json:"years,omitempty"`
 }
 
-// readPayrollRecords reads payroll_data.csv and returns a map keyed by EmployeeID|PayPeriod.
-func readPayrollRecords(filename string) (map[string]PayrollRecord, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open payroll file: %v", err)
+// DefaultBenefits is one job title's fallback benefits, per pay period.
+type DefaultBenefits struct {
+	HealthInsurance      Money `json:"healthInsurance"`
+	Retirement           Money `json:"retirement"`
+	OtherBenefits        Money `json:"otherBenefits"`
+	EmployerContribution Money `json:"employerContribution"`
+}
+
+// Taxable base kinds for TaxableBase.Kind.
+const (
+	baseGross            = "gross"
+	baseGrossMinusPretax = "gross-minus-pretax"
+	baseCustom           = "custom"
+)
+
+// TaxableBase is what one tax is levied on: gross, gross less every pre-tax
+// benefit, or (custom) gross less only the benefit categories in Exclude, e.g. a
+// Section 125 health plan that also reduces the FICA base while a 401k does not.
+type TaxableBase struct {
+	Kind    string   `json:"kind"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// taxNames are the taxes that take a TaxableBase.
+var taxNames = []string{"federal", "state", "local", "socialSecurity", "medicare"}
+
+// defaultTaxConfig returns the flat rates the register has always used, on a biweekly schedule.
+func defaultTaxConfig() TaxConfig {
+	return TaxConfig{
+		FederalRate:        0.12,
+		StateRate:          0.05,
+		SocialSecurityRate: 0.062,
+		MedicareRate:       0.0145,
+		PeriodsPerYear:     26,
+
+		EmployerSocialSecurityRate: 0.062,
+		EmployerMedicareRate:       0.0145,
+		TaxableBases: map[string]TaxableBase{
+			"federal":        {Kind: baseGrossMinusPretax},
+			"state":          {Kind: baseGrossMinusPretax},
+			"local":          {Kind: baseGrossMinusPretax},
+			"socialSecurity": {Kind: baseGross},
+			"medicare":       {Kind: baseGross},
+		},
 	}
-	defer file.Close()
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// loadTaxConfig reads a JSON config file over the defaults, so a file only needs the
+// settings it changes. It may carry // comments, as the -init-config template does.
+func loadTaxConfig(filename string) (TaxConfig, error) {
+	cfg := defaultTaxConfig()
+	data, err := readInput(filename, ReaderOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("cannot read payroll csv: %v", err)
+		return cfg, fmt.Errorf("cannot read config file: %w", err)
 	}
-
-	payrollMap := make(map[string]PayrollRecord)
-	// Skip header
-	for i, row := range records {
-		if i == 0 {
-			continue
+	data = stripJSONComments(data)
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("cannot parse config file %s: %v", filename, err)
+	}
+	if err := cfg.layerYears(data); err != nil {
+		return cfg, fmt.Errorf("config file %s: %v", filename, err)
+	}
+	// Each year starts from the base config, so its copies of the base's
+	// problems are left out.
+	problems := unknownConfigKeys(data)
+	base := cfg.problems()
+	problems = append(problems, base...)
+	for _, year := range sortedKeys(cfg.Years) {
+		for _, p := range cfg.Years[year].problems() {
+			if !slices.Contains(base, p) {
+				problems = append(problems, "years."+year+"."+p)
+			}
 		}
-		if len(row) < 5 {
-			continue
+	}
+	if len(problems) > 0 {
+		return cfg, configError{"config file " + filename, problems}
+	}
+	return cfg, nil
+}
+
+// layerYears rebuilds cfg.Years from the raw config so each year's table starts
+// from the base config (a deep copy, via JSON) rather than from zero values.
+func (cfg *TaxConfig) layerYears(data []byte) error {
+	var raw struct {
+		Years map[string]json.RawMessage `json:"years"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw.Years) == 0 {
+		return err
+	}
+	base := *cfg
+	cfg.Years = make(map[string]TaxConfig, len(raw.Years))
+	for year, yearJSON := range raw.Years {
+		if _, err := strconv.Atoi(year); err != nil {
+			return fmt.Errorf("tax year %q is not a year", year)
 		}
-		hourlyRate, err := strconv.ParseFloat(row[4], 64)
+		yearCfg, err := base.clone()
 		if err != nil {
-			return nil, fmt.Errorf("error parsing Hourly Rate in row %d: %v", i+1, err)
+			return err
 		}
-		rec := PayrollRecord{
-			EmployeeID:   row[0],
-			EmployeeName: row[1],
-			JobTitle:     row[2],
-			PayPeriod:    row[3],
-			HourlyRate:   hourlyRate,
+		if err := json.Unmarshal(yearJSON, &yearCfg); err != nil {
+			return fmt.Errorf("cannot parse tax year %s: %v", year, err)
 		}
-		key := makeKey(rec.EmployeeID, rec.PayPeriod)
-		payrollMap[key] = rec
+		yearCfg.Years = nil
+		cfg.Years[year] = yearCfg
 	}
-	return payrollMap, nil
+	return nil
 }
 
-// readTimeRecords reads time_data.csv and returns a map keyed by EmployeeID|PayPeriod.
-func readTimeRecords(filename string) (map[string]TimeRecord, error) {
-	file, err := os.Open(filename)
+// clone returns a deep copy of the config, via JSON, without its per-year tables.
+func (cfg TaxConfig) clone() (TaxConfig, error) {
+	cfg.Years = nil
+	data, err := json.Marshal(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("cannot open time file: %v", err)
+		return TaxConfig{}, err
 	}
-	defer file.Close()
+	var c TaxConfig
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// forPeriod returns the tax table that applies to a pay period: the config itself
+// when no per-year tables are configured, else the table for the period's year.
+func (cfg TaxConfig) forPeriod(period string) (TaxConfig, error) {
+	if len(cfg.Years) == 0 {
+		return cfg, nil
+	}
+	start, err := parsePeriod(period)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read time csv: %v", err)
+		return cfg, err
+	}
+	yearCfg, ok := cfg.Years[strconv.Itoa(start.Year())]
+	if !ok {
+		return cfg, fmt.Errorf("no tax table configured for year %d", start.Year())
 	}
+	return yearCfg, nil
+}
 
-	timeMap := make(map[string]TimeRecord)
-	for i, row := range records {
-		if i == 0 {
-			continue // skip header
+// validate checks the parts of a config that JSON decoding cannot, reporting
+// every problem found rather than only the first.
+func (cfg TaxConfig) validate(source string) error {
+	if problems := cfg.problems(); len(problems) > 0 {
+		return configError{source, problems}
+	}
+	return nil
+}
+
+// problems lists what is wrong with the config, each prefixed with the path of
+// the field at fault.
+func (cfg TaxConfig) problems() []string {
+	var problems []string
+	add := func(path, format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	for path, rate := range map[string]float64{
+		"federalRate": cfg.FederalRate, "stateRate": cfg.StateRate,
+		"socialSecurityRate": cfg.SocialSecurityRate, "medicareRate": cfg.MedicareRate,
+		"employerSocialSecurityRate": cfg.EmployerSocialSecurityRate, "employerMedicareRate": cfg.EmployerMedicareRate,
+	} {
+		checkConfigRate(path, rate, add)
+	}
+	for locality, rate := range cfg.LocalTaxRates {
+		checkConfigRate("localTaxRates."+locality, rate, add)
+	}
+	checkBrackets("federalBrackets", cfg.FederalBrackets, add)
+	checkBrackets("stateBrackets", cfg.StateBrackets, add)
+	checkUntaxedTips(cfg.UntaxedTips, add)
+	if cfg.TipMinimumWage < 0 {
+		add("tipMinimumWage", "must not be negative (0 means none), got %s", cfg.TipMinimumWage)
+	}
+	if cfg.PeriodsPerYear < 0 {
+		add("periodsPerYear", "must not be negative, got %d", cfg.PeriodsPerYear)
+	}
+	if cfg.SocialSecurityWageBase < 0 {
+		add("socialSecurityWageBase", "must not be negative (0 means none), got %s", cfg.SocialSecurityWageBase)
+	}
+	for code, rate := range cfg.FXRates {
+		if rate <= 0 {
+			add("fxRates."+code, "must be positive, got %g", rate)
 		}
-		if len(row) < 4 {
-			continue
+	}
+	switch cfg.ExemptOvertimePay {
+	case "", exemptPayRegular, exemptPayNone:
+	default:
+		add("exemptOvertimePay", "must be %q or %q, got %q", exemptPayRegular, exemptPayNone, cfg.ExemptOvertimePay)
+	}
+	for tax, base := range cfg.TaxableBases {
+		path := "taxableBases." + tax
+		if !slices.Contains(taxNames, tax) {
+			add(path, "unknown tax %q (valid: %s)", tax, strings.Join(taxNames, ", "))
 		}
-		regularHours, err := strconv.Atoi(row[2])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Regular Hours in row %d: %v", i+1, err)
+		switch base.Kind {
+		case baseGross, baseGrossMinusPretax, baseCustom:
+		default:
+			add(path+".kind", "unknown kind %q (want gross, gross-minus-pretax, or custom)", base.Kind)
 		}
-		overtimeHours, err := strconv.Atoi(row[3])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Overtime Hours in row %d: %v", i+1, err)
+		for _, category := range base.Exclude {
+			if !slices.Contains([]string{"health", "retirement", "other"}, category) {
+				add(path+".exclude", "unknown benefit category %q", category)
+			}
 		}
-		rec := TimeRecord{
-			EmployeeID:    row[0],
-			PayPeriod:     row[1],
-			RegularHours:  regularHours,
-			OvertimeHours: overtimeHours,
+	}
+	if cfg.RetirementMatch != nil {
+		if err := cfg.RetirementMatch.check(); err != nil {
+			add("retirementMatch", "%v", err)
 		}
-		key := makeKey(rec.EmployeeID, rec.PayPeriod)
-		timeMap[key] = rec
 	}
-	return timeMap, nil
+	checkContributions(cfg.Contributions, add)
+	checkDeductions(cfg.Deductions, add)
+	if cfg.ACH != nil {
+		if err := cfg.ACH.check(); err != nil {
+			add("ach", "%v", err)
+		}
+	}
+	for title, b := range cfg.DefaultBenefits {
+		if b.HealthInsurance < 0 || b.Retirement < 0 || b.OtherBenefits < 0 || b.EmployerContribution < 0 {
+			add("defaultBenefits."+title, "amounts must not be negative")
+		}
+	}
+	slices.Sort(problems)
+	return problems
 }
 
-// readBenefitsRecords reads benefits.csv and returns a map keyed by EmployeeID|PayPeriod.
-func readBenefitsRecords(filename string) (map[string]BenefitsRecord, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open benefits file: %v", err)
-	}
-	defer file.Close()
+// Values for ComputeOptions.Join (-join).
+const (
+	joinInner = ""
+	joinLeft  = "left"
+	joinOuter = "outer"
+)
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("cannot read benefits csv: %v", err)
+// withOrphanPayroll adds a zero-rate payroll record, for -join outer, for every
+// key that has a time or benefits record but no payroll record, returning the
+// enlarged copy and the keys added. payrollMap itself is left alone.
+func withOrphanPayroll(payrollMap map[string]PayrollRecord, timeMap map[string]TimeRecord, benefitsMap map[string]BenefitsRecord) (map[string]PayrollRecord, map[string]bool) {
+	all := maps.Clone(payrollMap)
+	orphans := make(map[string]bool)
+	add := func(key, employeeID, period string) {
+		if _, ok := all[key]; !ok {
+			all[key] = PayrollRecord{EmployeeID: employeeID, PayPeriod: period}
+			orphans[key] = true
+		}
+	}
+	for key, rec := range timeMap {
+		add(key, rec.EmployeeID, rec.PayPeriod)
+	}
+	for key, rec := range benefitsMap {
+		add(key, rec.EmployeeID, rec.PayPeriod)
 	}
+	return all, orphans
+}
 
-	benefitsMap := make(map[string]BenefitsRecord)
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-		if len(row) < 5 {
-			continue
-		}
-		healthInsurance, err := strconv.ParseFloat(row[2], 64)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Health Insurance in row %d: %v", i+1, err)
-		}
-		retirement, err := strconv.ParseFloat(row[3], 64)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Retirement in row %d: %v", i+1, err)
+// Values for TaxConfig.ExemptOvertimePay.
+const (
+	exemptPayRegular = "regular"
+	exemptPayNone    = "none"
+)
+
+// overtimeExempt reports whether a job title is one of the config's overtime-exempt titles.
+func (cfg TaxConfig) overtimeExempt(title string) bool {
+	return slices.ContainsFunc(cfg.OvertimeExemptTitles, func(t string) bool { return jobKey(t) == jobKey(title) })
+}
+
+// defaultBenefits returns the config's fallback benefits record for a job title,
+// if it has one.
+func (cfg TaxConfig) defaultBenefits(employeeID, period, title string) (BenefitsRecord, bool) {
+	for t, b := range cfg.DefaultBenefits {
+		if jobKey(t) == jobKey(title) {
+			return BenefitsRecord{
+				EmployeeID:           employeeID,
+				PayPeriod:            period,
+				HealthInsurance:      b.HealthInsurance,
+				Retirement:           b.Retirement,
+				OtherBenefits:        b.OtherBenefits,
+				EmployerContribution: b.EmployerContribution,
+			}, true
 		}
-		otherBenefits, err := strconv.ParseFloat(row[4], 64)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing Other Benefits in row %d: %v", i+1, err)
+	}
+	return BenefitsRecord{}, false
+}
+
+// matchCapped reports whether any year's retirement match has an annual cap,
+// which needs each employee's periods computed in order.
+func (cfg TaxConfig) matchCapped() bool {
+	if cfg.RetirementMatch != nil && cfg.RetirementMatch.AnnualCap > 0 {
+		return true
+	}
+	for _, yearCfg := range cfg.Years {
+		if yearCfg.matchCapped() {
+			return true
 		}
-		rec := BenefitsRecord{
-			EmployeeID:      row[0],
-			PayPeriod:       row[1],
-			HealthInsurance: healthInsurance,
-			Retirement:      retirement,
-			OtherBenefits:   otherBenefits,
+	}
+	return false
+}
+
+// localTaxRate resolves a work locality against the config table; unknown or blank localities resolve to zero.
+func (cfg TaxConfig) localTaxRate(locality string) float64 {
+	return cfg.LocalTaxRates[strings.ToUpper(strings.TrimSpace(locality))]
+}
+
+// eligibleFor reports whether an employee type may receive a benefit category.
+func (cfg TaxConfig) eligibleFor(employeeType, category string) bool {
+	allowed, ok := cfg.BenefitEligibility[strings.ToUpper(strings.TrimSpace(employeeType))]
+	if !ok {
+		return true
+	}
+	return slices.Contains(allowed, category)
+}
+
+// category returns the benefit amount in one category ("health", "retirement", "other").
+func (b BenefitsRecord) category(name string) Money {
+	switch name {
+	case "health":
+		return b.HealthInsurance
+	case "retirement":
+		return b.Retirement
+	case "other":
+		return b.otherTotal()
+	}
+	return 0
+}
+
+// preTaxTotal sums the benefits the config marks as pre-tax.
+func (cfg TaxConfig) preTaxTotal(b BenefitsRecord) Money {
+	var total Money
+	for _, category := range []string{"health", "retirement", "other"} {
+		if cfg.PreTaxBenefits[category] {
+			total += b.category(category)
 		}
-		key := makeKey(rec.EmployeeID, rec.PayPeriod)
-		benefitsMap[key] = rec
 	}
-	return benefitsMap, nil
+	return total
 }
 
-// computeRegister computes the pay register by merging the three datasets.
-func computeRegister(payrollMap map[string]PayrollRecord, timeMap map[string]TimeRecord, benefitsMap map[string]BenefitsRecord) []PayRegister {
-	var registers []PayRegister
+// taxableBase returns the amount a tax is levied on, per its TaxableBases entry.
+// A tax with no entry is levied on gross.
+func (cfg TaxConfig) taxableBase(tax string, gross Money, b BenefitsRecord) Money {
+	return cfg.levyBase(cfg.TaxableBases[tax], gross, b)
+}
 
-	for key, payroll := range payrollMap {
-		timeRec, okTime := timeMap[key]
-		benefitsRec, okBenefits := benefitsMap[key]
-		if !okTime || !okBenefits {
-			// Skip if any record is missing.
-			continue
+// levyBase returns the amount a TaxableBase comes to; a zero one is gross.
+func (cfg TaxConfig) levyBase(base TaxableBase, gross Money, b BenefitsRecord) Money {
+	switch base.Kind {
+	case baseGrossMinusPretax:
+		return gross - cfg.preTaxTotal(b)
+	case baseCustom:
+		for _, category := range base.Exclude {
+			gross -= b.category(category)
+		}
+	}
+	return gross
+}
+
+// moveImputedBenefits takes the named benefits listed in ImputedBenefits out of
+// b's deductions and adds them to its imputed income. b.NamedBenefits must already
+// be a copy (applyEligibility makes one).
+func (cfg TaxConfig) moveImputedBenefits(b *BenefitsRecord) {
+	for _, name := range sortedKeys(b.NamedBenefits) {
+		if slices.ContainsFunc(cfg.ImputedBenefits, func(n string) bool { return normalizeHeader(n) == normalizeHeader(name) }) {
+			b.ImputedIncome += b.NamedBenefits[name]
+			delete(b.NamedBenefits, name)
 		}
+	}
+}
+
+// RegisterMeta is the provenance record written next to each register file.
+type RegisterMeta struct {
+	SchemaVersion  int       `json:"schemaVersion"`
+	GeneratedAt    string    `json:"generatedAt"`
+	RecordCount    int       `json:"recordCount"`
+	PeriodsPerYear int       `json:"periodsPerYear"`
+	TaxConfig      TaxConfig `json:"taxConfig"`
+	// Columns lists the standard columns written when they are not all of
+	// registerHeader: a -columns subset, or optional columns left out for want of
+	// data.
+	Columns []string `json:"columns,omitempty"`
+}
 
-		// Compute Gross Wages:
-		// GrossWages = HourlyRate * RegularHours + 1.5 * HourlyRate * OvertimeHours
-		grossWages := payroll.HourlyRate*float64(timeRec.RegularHours) +
-			1.5*payroll.HourlyRate*float64(timeRec.OvertimeHours)
+// foldKeyCase, set by -fold-key-case, makes join keys case-insensitive so that
+// "2024-Q1" and "2024-q1" (or "e12" and "E12") match. Records keep the spelling they
+// were read with for display; only the map keys are folded.
+var foldKeyCase bool
 
-		// Compute Taxes
-		federalTax := 0.12 * grossWages
-		stateTax := 0.05 * grossWages
-		socialSecurity := 0.062 * grossWages
-		medicare := 0.0145 * grossWages
+// makeKey combines EmployeeID and PayPeriod for map keys.
+func makeKey(employeeID, payPeriod string) string {
+	if foldKeyCase {
+		return strings.ToUpper(employeeID) + "|" + strings.ToUpper(payPeriod)
+	}
+	return employeeID + "|" + payPeriod
+}
 
-		// Total Benefits
-		totalBenefits := benefitsRec.HealthInsurance + benefitsRec.Retirement + benefitsRec.OtherBenefits
+// sortedKeys returns the keys of m in canonical (sorted EmployeeID|PayPeriod) order.
+// Anything that iterates a record map and accumulates must go through this so that
+// identical inputs always produce byte-identical output.
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
 
-		// Total Deductions = Taxes + Total Benefits
-		totalDeductions := federalTax + stateTax + socialSecurity + medicare + totalBenefits
+// columnMap resolves header names to column positions, so optional columns can be found by name.
+type columnMap struct {
+	index map[string]int
+	// names is the header as written, for readers that itemize arbitrary columns.
+	names []string
+}
 
-		// Net Pay
-		netPay := grossWages - totalDeductions
+// normalizeHeader folds case, spaces, and underscores so "Work Locality" matches "work_locality".
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, " ", "")
+	return strings.ReplaceAll(name, "_", "")
+}
 
-		reg := PayRegister{
-			EmployeeID:      payroll.EmployeeID,
-			EmployeeName:    payroll.EmployeeName,
-			JobTitle:        payroll.JobTitle,
-			PayPeriod:       payroll.PayPeriod,
-			HourlyRate:      payroll.HourlyRate,
-			RegularHours:    timeRec.RegularHours,
-			OvertimeHours:   timeRec.OvertimeHours,
-			GrossWages:      grossWages,
-			FederalTax:      federalTax,
-			StateTax:        stateTax,
-			SocialSecurity:  socialSecurity,
-			Medicare:        medicare,
-			HealthInsurance: benefitsRec.HealthInsurance,
-			Retirement:      benefitsRec.Retirement,
-			OtherBenefits:   benefitsRec.OtherBenefits,
-			TotalBenefits:   totalBenefits,
-			TotalDeductions: totalDeductions,
-			NetPay:          netPay,
+// newColumnMap builds a columnMap from a header row. Two columns whose names match
+// after normalization are an error, since a by-name lookup could pick either one;
+// blank header cells are ignored.
+func newColumnMap(header []string) (columnMap, error) {
+	cols := columnMap{index: make(map[string]int, len(header)), names: header}
+	for i, name := range header {
+		key := normalizeHeader(name)
+		if key == "" {
+			continue
 		}
+		if j, dup := cols.index[key]; dup {
+			return cols, fmt.Errorf("duplicate column %q (columns %d and %d)", strings.TrimSpace(name), j+1, i+1)
+		}
+		cols.index[key] = i
+	}
+	return cols, nil
+}
+
+// lookup returns the position of the named column, if the header has it.
+func (c columnMap) lookup(name string) (int, bool) {
+	i, ok := c.index[normalizeHeader(name)]
+	return i, ok
+}
+
+// value returns the named column from row, or "" if the column is absent from the header or the row.
+func (c columnMap) value(row []string, name string) string {
+	i, ok := c.lookup(name)
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// optionalBool parses an optional yes/no column; a blank or absent cell is false.
+func (c columnMap) optionalBool(row []string, name string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(c.value(row, name))) {
+	case "", "0", "n", "no", "f", "false":
+		return false, nil
+	case "1", "y", "yes", "t", "true":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", c.value(row, name))
+}
 
-		registers = append(registers, reg)
+// optionalMoney parses an optional amount column; a blank or absent cell is zero.
+func (c columnMap) optionalMoney(row []string, name string) (Money, error) {
+	v := c.value(row, name)
+	if strings.TrimSpace(v) == "" {
+		return 0, nil
 	}
+	return parseMoney(v)
+}
 
-	return registers
+// ReaderOptions controls how the input readers parse their files.
+type ReaderOptions struct {
+	// NoHeader means row 0 is data; columns are then purely positional and optional
+	// columns such as Work Locality are unavailable.
+	NoHeader bool
+	// Delimiter overrides the field separator; zero means sniff it per file.
+	Delimiter rune
+	// OpenRetries is how many more times a failed open is attempted when the error
+	// looks transient; OpenRetryDelay is the first wait, doubled after each attempt.
+	OpenRetries    int
+	OpenRetryDelay time.Duration
+	// IDWidth, when positive, zero-pads numeric Employee IDs to this width before
+	// they are used as join keys.
+	IDWidth int
+	// AnonymizeSalt, when set, replaces Employee IDs with salted hashes and names
+	// with pseudonyms as records are read; amounts are untouched.
+	AnonymizeSalt []byte
+	// BlankAsZero reads an empty amount or hours cell as zero instead of rejecting
+	// the row. Identifier columns must still be filled in.
+	BlankAsZero bool
+	// BenefitsMerge says what to do with a second benefits row for the same
+	// employee and period: mergeLast (the default) keeps the later row, mergeSum
+	// adds the amounts together, and mergeError rejects the file.
+	BenefitsMerge string
+	// TimeMerge does the same for a second time row for the same employee, period
+	// and week (or period alone, without a Week column). Rows for different weeks
+	// always add up.
+	TimeMerge string
+	// Archive, when set, makes file names refer to entries in this zip archive
+	// rather than to paths on disk.
+	Archive *zip.Reader
+	// AllowCorrections accepts negative hours, which reverse hours over-reported in
+	// an earlier period. Without it a negative hours cell is an error.
+	AllowCorrections bool
+	// Sheet names the worksheet read from .xlsx inputs; empty means the first.
+	Sheet string
+	// ColumnMapping renames and reorders source columns per dataset, for files
+	// whose headers differ from ours. It needs a header row.
+	ColumnMapping ColumnMapping
+	// StrictColumns makes a row whose field count differs from the header's, or
+	// that is too short for the columns a reader needs, an error instead of being
+	// skipped (-strict-columns).
+	StrictColumns bool
+	// Problems, when set (-collect-all), receives the rows the readers reject, which
+	// are then skipped instead of ending the read.
+	Problems *problemLog
+	// KeepWhitespace passes cells to the parsers exactly as read. By default every
+	// cell is trimmed, so " 123 " joins with "123".
+	KeepWhitespace bool
+	// PeriodLayout, when set, normalizes every Pay Period read to this layout
+	// (see normalizePeriod) so equivalent spellings join.
+	PeriodLayout string
+	// CheckSSN rejects a payroll row whose SSN cell is filled in but is not a
+	// number the SSA issues (-check-ssn-format); see checkSSN.
+	CheckSSN bool
+	// RequireOvertimePay rejects a time file without an Overtime Pay column
+	// (-overtime-mode amount), whose overtime would otherwise go unpaid.
+	RequireOvertimePay bool
+	// Limits bound row and field sizes and the magnitude of amounts and hours
+	// (-fuzz-safe); the zero value accepts anything.
+	Limits InputLimits
+	// NameOrder is how an Employee Name is joined from separate First Name and
+	// Last Name columns: nameFirstLast (the default when empty) or nameLastFirst.
+	NameOrder string
 }
 
-// writeRegister writes the computed pay register to a CSV file.
-func writeRegister(registers []PayRegister, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("cannot create output file: %v", err)
+// Merge strategies for ReaderOptions.BenefitsMerge and TimeMerge (-benefits-merge,
+// -time-merge).
+const (
+	mergeLast  = "last"
+	mergeSum   = "sum"
+	mergeError = "error"
+)
+
+// mergeTime combines a second time record for an employee and period with the
+// first, per TimeMerge: their sum, an error, or (by default) the second alone.
+// The time reader and the server's JSON records share it.
+func (opts ReaderOptions) mergeTime(prev, rec TimeRecord) (TimeRecord, error) {
+	switch opts.TimeMerge {
+	case mergeSum:
+		return prev.plus(rec), nil
+	case mergeError:
+		return rec, fmt.Errorf("duplicate time record for employee %s period %s", rec.EmployeeID, rec.PayPeriod)
 	}
-	defer file.Close()
+	return rec, nil
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// money parses an amount cell, honouring BlankAsZero.
+func (opts ReaderOptions) money(cell string) (Money, error) {
+	if opts.BlankAsZero && strings.TrimSpace(cell) == "" {
+		return 0, nil
+	}
+	return opts.bounded(parseMoney(cell))
+}
 
-	// Write header
-	header := []string{
-		"Employee ID", "Employee Name", "Job Title", "Pay Period", "Hourly Rate",
-		"Regular Hours", "Overtime Hours", "Gross Wages", "Federal Tax", "State Tax",
-		"Social Security", "Medicare", "Health Insurance", "Retirement", "Other Benefits",
-		"Total Benefits", "Total Deductions", "Net Pay",
+// hours parses a whole-hours cell, honouring BlankAsZero. Scientific notation such
+// as "4E+01" is accepted as long as the value is a whole number.
+func (opts ReaderOptions) hours(cell string) (int, error) {
+	cell = strings.TrimSpace(cell)
+	if opts.BlankAsZero && cell == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(cell)
+	if err == nil {
+		return opts.checkSign(n)
 	}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("cannot write header: %v", err)
+	if !strings.ContainsAny(cell, "eE") {
+		return 0, err
 	}
+	f, ferr := strconv.ParseFloat(cell, 64)
+	if ferr != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, err
+	}
+	return opts.checkSign(int(f))
+}
 
-	// Write each record (format numbers to 2 decimals)
-	for _, reg := range registers {
-		row := []string{
-			reg.EmployeeID,
-			reg.EmployeeName,
-			reg.JobTitle,
-			reg.PayPeriod,
-			fmt.Sprintf("%.2f", reg.HourlyRate),
-			strconv.Itoa(reg.RegularHours),
-			strconv.Itoa(reg.OvertimeHours),
-			fmt.Sprintf("%.2f", reg.GrossWages),
-			fmt.Sprintf("%.2f", reg.FederalTax),
-			fmt.Sprintf("%.2f", reg.StateTax),
-			fmt.Sprintf("%.2f", reg.SocialSecurity),
-			fmt.Sprintf("%.2f", reg.Medicare),
-			fmt.Sprintf("%.2f", reg.HealthInsurance),
-			fmt.Sprintf("%.2f", reg.Retirement),
-			fmt.Sprintf("%.2f", reg.OtherBenefits),
-			fmt.Sprintf("%.2f", reg.TotalBenefits),
-			fmt.Sprintf("%.2f", reg.TotalDeductions),
-			fmt.Sprintf("%.2f", reg.NetPay),
-		}
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("cannot write row: %v", err)
-		}
+// checkSign rejects negative hours unless AllowCorrections is set, and hours
+// beyond Limits.MaxHours.
+func (opts ReaderOptions) checkSign(hours int) (int, error) {
+	if hours < 0 && !opts.AllowCorrections {
+		return 0, fmt.Errorf("negative hours %d (use -allow-corrections for correction rows)", hours)
+	}
+	if limit := opts.Limits.MaxHours; limit > 0 && (hours > limit || hours < -limit) {
+		return 0, fmt.Errorf("hours %d are beyond the limit of %d", hours, limit)
 	}
+	return hours, nil
+}
 
+// requireIdentifiers rejects a row whose Employee ID or Pay Period (the first two
+// columns of every input) is blank; those cells cannot default to anything.
+func requireIdentifiers(row []string, line int) error {
+	if strings.TrimSpace(row[0]) == "" {
+		return fmt.Errorf("missing Employee ID in row %d", line)
+	}
+	if strings.TrimSpace(row[1]) == "" {
+		return fmt.Errorf("missing Pay Period in row %d", line)
+	}
 	return nil
 }
 
-func main() {
-	// File names (adjust as needed)
-	payrollFile := "payroll_data.csv"
-	timeFile := "time_data.csv"
-	benefitsFile := "benefits.csv"
-	outputFile := "payroll_register.csv"
-
-	// Start total timer.
-	totalStart := time.Now()
+// openWithRetry opens filename, retrying transient failures (as seen on network
+// mounts) with exponential backoff. Missing files and permission errors are returned
+// immediately; after the last retry the last error is returned unchanged.
+func openWithRetry(filename string, opts ReaderOptions) (*os.File, error) {
+	delay := opts.OpenRetryDelay
+	for attempt := 0; ; attempt++ {
+		file, err := os.Open(filename)
+		if err == nil || attempt >= opts.OpenRetries ||
+			errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return file, err
+		}
+		logf("Opening %s failed (%v); retrying in %v", filename, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
 
-	// Step 1: Read Input Files
-	readStart := time.Now()
-	payrollMap, err := readPayrollRecords(payrollFile)
-	if err != nil {
-		log.Fatalf("Error reading payroll records: %v", err)
+// sniffDelimiter picks the field separator from a file's first line: tab when it has
+// more tabs than commas, otherwise comma. Ties (including a single-column line) fall
+// back to comma, the historical format.
+func sniffDelimiter(firstLine []byte) rune {
+	if bytes.Count(firstLine, []byte{'\t'}) > bytes.Count(firstLine, []byte{','}) {
+		return '\t'
 	}
+	return ','
+}
 
-	timeMap, err := readTimeRecords(timeFile)
-	if err != nil {
-		log.Fatalf("Error reading time records: %v", err)
+// parseDelimiter interprets a -delimiter value: "" sniffs, "tab" or "\t" is a tab,
+// anything else must be a single character.
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
 	}
+	r := []rune(s)
+	if len(r) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character, tab, or empty for auto-detect; got %q", s)
+	}
+	return r[0], nil
+}
 
-	benefitsMap, err := readBenefitsRecords(benefitsFile)
+// readCSV opens filename and calls fn for each data row after the header. fn receives
+// the header's columnMap and the line the row starts on; that differs from the record
+// index once a quoted field (an employee name, say) spans several lines. A filename
+// ending in .xlsx is read as an Excel workbook instead, one sheet row per row. An
+// http(s):// or s3:// filename is fetched (see openInput).
+func readCSV(filename, kind string, opts ReaderOptions, fn func(cols columnMap, row []string, line int) error) error {
+	var file io.ReadCloser
+	var err error
+	if opts.Archive != nil {
+		file, err = opts.Archive.Open(filename)
+	} else {
+		file, err = openInput(filename, opts)
+	}
 	if err != nil {
-		log.Fatalf("Error reading benefits records: %v", err)
+		return fmt.Errorf("cannot open %s file: %w", kind, err)
 	}
-	readDuration := time.Since(readStart)
-	fmt.Printf("Time to read input files: %v\n", readDuration)
-
-	// Step 2: Compute the Pay Register
-	computeStart := time.Now()
-	registers := computeRegister(payrollMap, timeMap, benefitsMap)
-	computeDuration := time.Since(computeStart)
-	fmt.Printf("Time to compute pay register: %v\n", computeDuration)
-	fmt.Printf("Computed %d register records.\n", len(registers))
+	defer file.Close()
 
-	// Step 3: Write the Output CSV
-	writeStart := time.Now()
-	if err := writeRegister(registers, outputFile); err != nil {
-		log.Fatalf("Error writing register file: %v", err)
+	// next returns the following row and the line it starts on, or io.EOF.
+	var next func() ([]string, int, error)
+	if isXLSX(filename) {
+		rows, err := readXLSXRows(file, opts.Sheet)
+		if err != nil {
+			return fmt.Errorf("cannot read %s workbook: %v", kind, err)
+		}
+		next = func() ([]string, int, error) {
+			if len(rows) == 0 {
+				return nil, 0, io.EOF
+			}
+			row := rows[0]
+			rows = rows[1:]
+			return row.cells, row.line, nil
+		}
+	} else {
+		buffered := bufio.NewReader(file)
+		if bom, _ := buffered.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+			buffered.Discard(len(utf8BOM))
+		}
+		delimiter := opts.Delimiter
+		if delimiter == 0 {
+			peek, _ := buffered.Peek(64 * 1024)
+			if i := bytes.IndexByte(peek, '\n'); i >= 0 {
+				peek = peek[:i]
+			}
+			delimiter = sniffDelimiter(peek)
+		}
+		var bounded *boundedReader
+		var source io.Reader = buffered
+		if limit := opts.Limits.maxRowBytes(); limit > 0 {
+			bounded = &boundedReader{r: buffered, limit: limit}
+			source = bounded
+		}
+		reader := csv.NewReader(source)
+		reader.Comma = delimiter
+		if opts.StrictColumns {
+			// Checked below against the header, with the row in the message.
+			reader.FieldsPerRecord = -1
+		}
+		next = func() ([]string, int, error) {
+			if bounded != nil {
+				bounded.reset()
+			}
+			row, err := reader.Read()
+			if err != nil {
+				if errors.Is(err, errRowTooLong) {
+					err = fmt.Errorf("cannot read %s csv: a record runs past the limit of %d bytes", kind, opts.Limits.maxRowBytes())
+				} else if err != io.EOF {
+					err = fmt.Errorf("cannot read %s csv: %v", kind, err)
+				}
+				return nil, 0, err
+			}
+			line, _ := reader.FieldPos(0)
+			return row, line, nil
+		}
 	}
-	writeDuration := time.Since(writeStart)
-	fmt.Printf("Time to write output file: %v\n", writeDuration)
 
-	// Total elapsed time
-	totalDuration := time.Since(totalStart)
-	fmt.Printf("Total elapsed time: %v\n", totalDuration)
-	fmt.Printf("Pay register computed and saved to %s\n", outputFile)
+	cols := columnMap{}
+	var order []int // from ColumnMapping; nil keeps the file's column order
+	width := -1     // the header's field count, for StrictColumns
+	if _, mapped := opts.ColumnMapping[kind]; mapped && opts.NoHeader {
+		return fmt.Errorf("cannot map %s columns: the file has no header row", kind)
+	}
+	if opts.NoHeader {
+		// No header means no optional columns, so one the options need is missing.
+		if err := opts.checkHeader(kind, cols); err != nil {
+			return fmt.Errorf("invalid %s file: %v", kind, err)
+		}
+	}
+	// First and last names in columns of their own, to join into Employee Name.
+	var names *nameColumns
+	for i := 0; ; i++ {
+		row, line, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// A rejected row ends the read, or under -collect-all is noted and skipped.
+		reject := func(err error) error {
+			if opts.Problems != nil {
+				opts.Problems.add(kind, exitParseError, err)
+				return nil
+			}
+			return err
+		}
+		if err := opts.Limits.checkRow(row, line); err != nil {
+			if i == 0 && !opts.NoHeader {
+				return fmt.Errorf("invalid %s header: %v", kind, err)
+			}
+			if err := reject(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if opts.StrictColumns {
+			if err := checkRowWidth(row, line, &width, isXLSX(filename)); err != nil {
+				if err := reject(err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if i == 0 && !opts.NoHeader {
+			// Header: used to locate optional columns such as Work Locality. A source
+			// with first and last names in columns of their own gets an Employee Name
+			// column after the rest, moved into place like a mapped one.
+			mapping := opts.ColumnMapping
+			if names = findNameColumns(mapping.rename(kind, row), opts.NameOrder); names != nil {
+				row = append(row, "Employee Name")
+				if _, mapped := mapping[kind]; !mapped {
+					mapping = ColumnMapping{kind: {}}
+				}
+			}
+			if row, order, err = mapping.arrange(kind, row); err != nil {
+				return fmt.Errorf("invalid %s header: %v", kind, err)
+			}
+			if cols, err = newColumnMap(row); err != nil {
+				return fmt.Errorf("invalid %s header: %v", kind, err)
+			}
+			if err := opts.checkHeader(kind, cols); err != nil {
+				return fmt.Errorf("invalid %s header: %v", kind, err)
+			}
+			continue
+		}
+		if names != nil {
+			row = append(row, names.join(row))
+		}
+		if order != nil {
+			row = pick(row, order)
+		}
+		if !opts.KeepWhitespace {
+			for j := range row {
+				row[j] = strings.TrimSpace(row[j])
+			}
+		}
+		if err := fn(cols, row, line); err != nil {
+			if err := reject(err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkHeader rejects a header lacking an optional column the options make
+// necessary, once, so that a file with no rows fails as well.
+func (opts ReaderOptions) checkHeader(kind string, cols columnMap) error {
+	if _, ok := cols.lookup("Overtime Pay"); kind == "time" && opts.RequireOvertimePay && !ok {
+		return fmt.Errorf("-overtime-mode amount needs an Overtime Pay column in the time file")
+	}
+	return nil
+}
+
+// checkRowWidth compares a row's field count with the first row's (the header, or
+// the first data row without one), which it records in *width. Workbook rows are
+// padded to the widest row and blank trailing cells are normal in a spreadsheet, so
+// there only cells past the header's width count.
+func checkRowWidth(row []string, line int, width *int, workbook bool) error {
+	if *width < 0 {
+		*width = len(row)
+		return nil
+	}
+	n := len(row)
+	if workbook {
+		for n > *width && row[n-1] == "" {
+			n--
+		}
+	}
+	if n != *width {
+		return fmt.Errorf("row %d has %d fields, want %d: %q", line, n, *width, strings.Join(row, ","))
+	}
+	return nil
+}
+
+// shortRow decides what happens to a row with fewer than the want columns a reader
+// needs: it is skipped (nil), or under StrictColumns rejected.
+func (opts ReaderOptions) shortRow(row []string, line, want int) error {
+	if !opts.StrictColumns {
+		return nil
+	}
+	return fmt.Errorf("row %d has %d fields, want at least %d: %q", line, len(row), want, strings.Join(row, ","))
+}
+
+// readPayrollRecords reads payroll_data.csv and returns a map keyed by EmployeeID|PayPeriod.
+func readPayrollRecords(filename string, opts ReaderOptions) (map[string]PayrollRecord, error) {
+	payrollMap := make(map[string]PayrollRecord)
+	if err := readCSV(filename, "payroll", opts, payrollRows(payrollMap, opts)); err != nil {
+		return nil, err
+	}
+	return payrollMap, nil
+}
+
+// payrollRows parses one payroll row into payrollMap. The payroll reader and the
+// combined-file reader share it.
+func payrollRows(payrollMap map[string]PayrollRecord, opts ReaderOptions) func(cols columnMap, row []string, line int) error {
+	return func(cols columnMap, row []string, line int) error {
+		if len(row) < 5 {
+			return opts.shortRow(row, line, 5)
+		}
+		if err := requireIdentifiers(row, line); err != nil {
+			return err
+		}
+		hourlyRate, err := opts.money(row[4])
+		if err != nil {
+			return fmt.Errorf("error parsing Hourly Rate in row %d: %v", line, err)
+		}
+		ficaExempt, err := cols.optionalBool(row, "FICA Exempt")
+		if err != nil {
+			return fmt.Errorf("error parsing FICA Exempt in row %d: %v", line, err)
+		}
+		medicareExempt, err := cols.optionalBool(row, "Medicare Exempt")
+		if err != nil {
+			return fmt.Errorf("error parsing Medicare Exempt in row %d: %v", line, err)
+		}
+		overtimeExempt, err := cols.optionalBool(row, "Overtime Exempt")
+		if err != nil {
+			return fmt.Errorf("error parsing Overtime Exempt in row %d: %v", line, err)
+		}
+		overtimeAfter := 0
+		if v := strings.TrimSpace(cols.value(row, "Overtime After")); v != "" {
+			if overtimeAfter, err = strconv.Atoi(v); err != nil || overtimeAfter < 1 {
+				return fmt.Errorf("error parsing Overtime After in row %d: want whole hours from 1, got %q", line, v)
+			}
+		}
+		pieceRate, err := opts.bounded(cols.optionalMoney(row, "Piece Rate"))
+		if err != nil {
+			return fmt.Errorf("error parsing Piece Rate in row %d: %v", line, err)
+		}
+		period, err := opts.period(row[3])
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		ssn := strings.TrimSpace(cols.value(row, "SSN"))
+		if ssn != "" && opts.CheckSSN {
+			if ssn, err = checkSSN(ssn); err != nil {
+				return fmt.Errorf("error parsing SSN in row %d: %v", line, err)
+			}
+		}
+		rec := PayrollRecord{
+			EmployeeID:     opts.employeeID(row[0]),
+			EmployeeName:   row[1],
+			JobTitle:       row[2],
+			PayPeriod:      period,
+			HourlyRate:     hourlyRate,
+			WorkLocality:   cols.value(row, "Work Locality"),
+			FICAExempt:     ficaExempt,
+			MedicareExempt: medicareExempt,
+			OvertimeExempt: overtimeExempt,
+			OvertimeAfter:  overtimeAfter,
+			PieceRate:      pieceRate,
+			EmployeeType:   cols.value(row, "Employee Type"),
+			Currency:       strings.ToUpper(strings.TrimSpace(cols.value(row, "Currency"))),
+			SSN:            ssn,
+		}
+		if opts.AnonymizeSalt != nil {
+			rec.EmployeeName = pseudonymousName(rec.EmployeeID)
+			rec.SSN = ""
+		}
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		if prev, ok := payrollMap[key]; ok {
+			rec = prev.addJob(rec)
+		}
+		payrollMap[key] = rec
+		return nil
+	}
+}
+
+// readTimeRecords reads time_data.csv and returns a map keyed by EmployeeID|PayPeriod.
+func readTimeRecords(filename string, opts ReaderOptions) (map[string]TimeRecord, error) {
+	timeMap := make(map[string]TimeRecord)
+	if err := readCSV(filename, "time", opts, timeRows(timeMap, opts)); err != nil {
+		return nil, err
+	}
+	return timeMap, nil
+}
+
+// timeRows parses one time row into timeMap. The time reader and the
+// combined-file reader share it.
+func timeRows(timeMap map[string]TimeRecord, opts ReaderOptions) func(cols columnMap, row []string, line int) error {
+	return func(cols columnMap, row []string, line int) error {
+		if len(row) < 4 {
+			return opts.shortRow(row, line, 4)
+		}
+		if err := requireIdentifiers(row, line); err != nil {
+			return err
+		}
+		regularHours, err := opts.hours(row[2])
+		if err != nil {
+			return fmt.Errorf("error parsing Regular Hours in row %d: %v", line, err)
+		}
+		overtimeHours, err := opts.hours(row[3])
+		if err != nil {
+			return fmt.Errorf("error parsing Overtime Hours in row %d: %v", line, err)
+		}
+		adjustment, err := opts.bounded(cols.optionalMoney(row, "Adjustment"))
+		if err != nil {
+			return fmt.Errorf("error parsing Adjustment in row %d: %v", line, err)
+		}
+		period, err := opts.period(row[1])
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		units := 0
+		if v := cols.value(row, "Units"); strings.TrimSpace(v) != "" {
+			if units, err = opts.hours(v); err != nil {
+				return fmt.Errorf("error parsing Units in row %d: %v", line, err)
+			}
+		}
+		overtimePay, err := opts.bounded(cols.optionalMoney(row, "Overtime Pay"))
+		if err != nil {
+			return fmt.Errorf("error parsing Overtime Pay in row %d: %v", line, err)
+		}
+		reportedTips, err := opts.bounded(cols.optionalMoney(row, "Reported Tips"))
+		if err != nil {
+			return fmt.Errorf("error parsing Reported Tips in row %d: %v", line, err)
+		}
+		rec := TimeRecord{
+			EmployeeID:    opts.employeeID(row[0]),
+			PayPeriod:     period,
+			RegularHours:  regularHours,
+			OvertimeHours: overtimeHours,
+			Adjustment:    adjustment,
+			Units:         units,
+			OvertimePay:   overtimePay,
+			ReportedTips:  reportedTips,
+		}
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		job := cols.value(row, "Job Title")
+		week := 0
+		if v := strings.TrimSpace(cols.value(row, "Week")); v != "" {
+			if week, err = strconv.Atoi(v); err != nil || week < 1 {
+				return fmt.Errorf("error parsing Week in row %d: want a week number from 1, got %q", line, v)
+			}
+			if strings.TrimSpace(job) != "" {
+				return fmt.Errorf("row %d has both a Job Title and a Week; hours can be split by one or the other", line)
+			}
+		}
+		prev, ok := timeMap[key]
+		switch {
+		case strings.TrimSpace(job) != "":
+			if !ok {
+				prev = TimeRecord{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod}
+			}
+			rec = prev.addJobHours(job, rec)
+		case week > 0:
+			if ok && len(prev.Weeks) == 0 {
+				return fmt.Errorf("row %d gives week %d of a period already given without a Week for employee %s period %s", line, week, rec.EmployeeID, rec.PayPeriod)
+			}
+			if !ok {
+				prev = TimeRecord{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod}
+			}
+			if rec, err = prev.addWeekHours(week, rec, opts.TimeMerge); err != nil {
+				return fmt.Errorf("row %d: %v", line, err)
+			}
+		case ok && len(prev.Weeks) > 0:
+			return fmt.Errorf("row %d has no Week but employee %s period %s is given by week", line, rec.EmployeeID, rec.PayPeriod)
+		case ok:
+			if rec, err = opts.mergeTime(prev, rec); err != nil {
+				return fmt.Errorf("%v in row %d (see -time-merge)", err, line)
+			}
+		}
+		timeMap[key] = rec
+		return nil
+	}
+}
+
+// readBenefitsRecords reads benefits.csv and returns a map keyed by EmployeeID|PayPeriod.
+func readBenefitsRecords(filename string, opts ReaderOptions) (map[string]BenefitsRecord, error) {
+	benefitsMap := make(map[string]BenefitsRecord)
+	if err := readCSV(filename, "benefits", opts, benefitsRows(benefitsMap, opts)); err != nil {
+		return nil, err
+	}
+	return benefitsMap, nil
+}
+
+// benefitsRows parses one benefits row into benefitsMap. The benefits reader and the
+// combined-file reader share it.
+func benefitsRows(benefitsMap map[string]BenefitsRecord, opts ReaderOptions) func(cols columnMap, row []string, line int) error {
+	return func(cols columnMap, row []string, line int) error {
+		if len(row) < 5 {
+			return opts.shortRow(row, line, 5)
+		}
+		if err := requireIdentifiers(row, line); err != nil {
+			return err
+		}
+		healthInsurance, err := opts.money(row[2])
+		if err != nil {
+			return fmt.Errorf("error parsing Health Insurance in row %d: %v", line, err)
+		}
+		retirement, err := opts.money(row[3])
+		if err != nil {
+			return fmt.Errorf("error parsing Retirement in row %d: %v", line, err)
+		}
+		otherBenefits, err := opts.money(row[4])
+		if err != nil {
+			return fmt.Errorf("error parsing Other Benefits in row %d: %v", line, err)
+		}
+		employerContribution, err := opts.bounded(cols.optionalMoney(row, "Employer Contribution"))
+		if err != nil {
+			return fmt.Errorf("error parsing Employer Contribution in row %d: %v", line, err)
+		}
+		imputedIncome, err := opts.bounded(cols.optionalMoney(row, "Imputed Income"))
+		if err != nil {
+			return fmt.Errorf("error parsing Imputed Income in row %d: %v", line, err)
+		}
+		period, err := opts.period(row[1])
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		rec := BenefitsRecord{
+			EmployeeID:           opts.employeeID(row[0]),
+			PayPeriod:            period,
+			HealthInsurance:      healthInsurance,
+			Retirement:           retirement,
+			OtherBenefits:        otherBenefits,
+			EmployerContribution: employerContribution,
+			ImputedIncome:        imputedIncome,
+		}
+		// Columns after Other Benefits are named benefits; they need a header to be named.
+		for i := 5; i < len(row) && i < len(cols.names); i++ {
+			name := strings.TrimSpace(cols.names[i])
+			if name == "" || benefitsReservedColumns[normalizeHeader(name)] || strings.TrimSpace(row[i]) == "" {
+				continue
+			}
+			amount, err := opts.bounded(parseMoney(row[i]))
+			if err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", name, line, err)
+			}
+			if rec.NamedBenefits == nil {
+				rec.NamedBenefits = make(map[string]Money)
+			}
+			rec.NamedBenefits[name] = amount
+		}
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		if prev, ok := benefitsMap[key]; ok {
+			switch opts.BenefitsMerge {
+			case mergeSum:
+				rec = prev.plus(rec)
+			case mergeError:
+				return fmt.Errorf("duplicate benefits record for employee %s period %s in row %d (see -benefits-merge)", rec.EmployeeID, rec.PayPeriod, line)
+			}
+		}
+		benefitsMap[key] = rec
+		return nil
+	}
+}
+
+// RowError records why a single employee-period could not be computed. Fatal
+// errors come from checks configured to fail the run rather than skip the row.
+type RowError struct {
+	EmployeeID string
+	PayPeriod  string
+	Err        error
+	Fatal      bool
+}
+
+// fatalRowError marks an error from computeRow as failing the whole run.
+type fatalRowError struct{ error }
+
+// checkAction selects whether a failed data-quality check warns or fails the run.
+type checkAction string
+
+const (
+	actionWarn  checkAction = "warn"
+	actionError checkAction = "error"
+)
+
+// parseCheckAction validates a warn|error flag value.
+func parseCheckAction(s string) (checkAction, error) {
+	switch a := checkAction(strings.ToLower(s)); a {
+	case actionWarn, actionError:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid action %q (want warn or error)", s)
+}
+
+// ComputeOptions holds run-level switches for computeRegister that are not tax rates.
+type ComputeOptions struct {
+	// Period, when set, restricts computation to that single PayPeriod.
+	Period string
+	// Employees, when set, restricts computation to these employees, keyed as
+	// employeeSet keys them (-employee, -employees-file).
+	Employees map[string]bool
+	// YTDSeed holds year-to-date totals from a prior payroll system, keyed by
+	// ytdKey; the Social Security and contribution wage bases count from them
+	// (-ytd-seed).
+	YTDSeed map[string]YTDSeed
+	// EarningsRounding is when overtime pay is rounded to the cent:
+	// roundEarningsComponents (the default when empty) or roundEarningsGross.
+	EarningsRounding string
+	// PTOAccruals maps an employee ID to how fast they accrue paid time off;
+	// listed employees' lines carry a PTOAccrued (-pto-accruals).
+	PTOAccruals map[string]PTOAccrual
+	// Since and Until, when non-zero, restrict computation to periods starting
+	// within [Since, Until], using parsePeriod.
+	Since time.Time
+	Until time.Time
+
+	// MaxRegularHours and MaxOvertimeHours cap plausible hours per period; zero disables the cap.
+	MaxRegularHours  int
+	MaxOvertimeHours int
+	HoursCapAction   checkAction
+
+	// MaxTotalHours caps regular, overtime and double time together, and
+	// MaxOvertimeRatio flags overtime and double time above that multiple of the
+	// regular hours, the mark of swapped columns; zero disables either. Both are
+	// hours caps, handled per HoursCapAction.
+	MaxTotalHours    int
+	MaxOvertimeRatio float64
+
+	// DeductionsExceedGrossAction flags rows whose total deductions exceed gross
+	// wages (a benefits data-entry error, usually); empty disables the check.
+	DeductionsExceedGrossAction checkAction
+
+	// ZeroRateAction flags hourly employees with hours but a zero hourly rate,
+	// usually a rate that failed to import; empty disables the check.
+	ZeroRateAction checkAction
+
+	// DeductionRules run after the standard deductions and the config's
+	// Deductions; none by default.
+	DeductionRules []DeductionRule
+
+	// EarningsComponents compute gross, in order: builtinEarnings by default,
+	// with any client-specific components appended. Nil means the built-ins.
+	EarningsComponents []EarningsComponent
+
+	// WithholdingFloor, when positive, waives income tax withholding on a line
+	// whose gross is above zero but below it, for incidental payments.
+	// WithholdingFloorFICA waives Social Security and Medicare there too.
+	WithholdingFloor     Money
+	WithholdingFloorFICA bool
+
+	// TrackArrears withholds benefits and custom deductions only as far as net pay
+	// above NetFloor allows, carrying the rest into the employee's next period.
+	TrackArrears bool
+	NetFloor     Money
+
+	// SplitJobs writes one register line per job for employees with several jobs
+	// in a period, instead of one combined line.
+	SplitJobs bool
+
+	// MidPeriodRates holds rate changes taking effect inside a period, keyed by
+	// EmployeeID|PayPeriod; other periods are paid at a single rate.
+	MidPeriodRates map[string]MidPeriodRate
+
+	// TaxOverrides replaces federal and/or state withholding for the employees
+	// it lists; everyone else is taxed normally.
+	TaxOverrides map[string]TaxOverride
+
+	// OvertimeRules split daily hours into regular, overtime, and double time.
+	OvertimeRules OvertimeRules
+
+	// OvertimeAmounts pays overtime as the time file's Overtime Pay amount, added
+	// to gross as it is, instead of as overtime hours at 1.5x (-overtime-mode
+	// amount). The hours are still reported; double time is still paid by the hour.
+	OvertimeAmounts bool
+
+	// IncludeZeroHours keeps payroll-listed employees with no time record (unpaid
+	// leave, say) by computing them with zero hours instead of dropping them.
+	IncludeZeroHours bool
+
+	// ZeroMissingBenefits computes a payroll+time match that has no benefits row
+	// with zero benefits, flagging it as imputed, instead of dropping it.
+	ZeroMissingBenefits bool
+
+	// TaxRounding is the policy for rounding a line's taxes to the cent
+	// (-tax-rounding): roundPerTax or roundTotal; empty means roundPerTax.
+	TaxRounding string
+
+	// Join says which records get a register line (-join): joinInner (the
+	// default) needs all three; joinLeft keeps every payroll record, zero-filling
+	// missing time and benefits; joinOuter also keeps time and benefits records
+	// with no payroll record, computed at a zero rate. Zero-filled lines are
+	// flagged with a warning.
+	Join string
+
+	// RoundGrossForTax computes every tax on gross rounded to the nearest whole
+	// currency unit, as some jurisdictions require; the register still shows exact gross.
+	RoundGrossForTax bool
+
+	// BenefitsPerYear is how many times a year the benefits file's amounts are
+	// quoted for (12 for monthly premiums); they are prorated to the pay period
+	// using the config's PeriodsPerYear. Zero means they are already per period.
+	BenefitsPerYear int
+
+	// RequireTaxEntry makes a work locality missing from the config's local tax
+	// table an error instead of silently meaning no local tax.
+	RequireTaxEntry bool
+
+	// NoFederal, NoState, and NoFICA leave the named taxes out of the whole run
+	// (-no-federal, -no-state, -no-fica), for non-wage disbursements such as
+	// reimbursements; their columns stay, at zero. NoFICA covers the employer
+	// share too.
+	NoFederal bool
+	NoState   bool
+	NoFICA    bool
+
+	// FullSSN puts employees' SSNs in the register unmasked (-full-ssn); by
+	// default only the last four digits are shown.
+	FullSSN bool
+
+	// RoundNetDollars rounds each line's net pay to a whole currency unit for cash
+	// payout, recording the remainder in NetPayRoundingCarry. With CarryNetRounding
+	// the remainder is added to the employee's next line before it is rounded.
+	RoundNetDollars  bool
+	CarryNetRounding bool
+
+	// Progress, when set, is called with each register as it is computed.
+	Progress func(PayRegister)
+}
+
+// benefitFrequencies are the -benefits-frequency values and how many quotes a year each means.
+var benefitFrequencies = map[string]int{
+	"period":      0,
+	"weekly":      52,
+	"biweekly":    26,
+	"semimonthly": 24,
+	"monthly":     12,
+	"annual":      1,
+}
+
+// defaultComputeOptions disables every optional check.
+func defaultComputeOptions() ComputeOptions {
+	return ComputeOptions{HoursCapAction: actionWarn, OvertimeRules: overtimeRuleSets["federal"], EarningsComponents: builtinEarnings()}
+}
+
+// check records a failed data-quality check for a row: a warning under actionWarn,
+// or a fatal error under actionError. value is the figure that failed it.
+func check(action checkAction, category string, payroll PayrollRecord, message, value string, warnings *[]Warning) error {
+	if action == actionError {
+		return fatalRowError{fmt.Errorf("%s: %s", category, message)}
+	}
+	*warnings = append(*warnings, Warning{
+		Category:   category,
+		EmployeeID: payroll.EmployeeID,
+		PayPeriod:  payroll.PayPeriod,
+		Message:    message,
+		Value:      value,
+	})
+	return nil
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("employee %s period %s: %v", e.EmployeeID, e.PayPeriod, e.Err)
+}
+
+// Warning is a non-fatal finding about one employee-period, such as a data
+// discrepancy that computeRegister corrected.
+type Warning struct {
+	Category   string
+	EmployeeID string
+	PayPeriod  string
+	Message    string
+	// Value is the figure at fault, when there is one (the hours over a cap, the
+	// deductions over gross), for the -warnings-file sidecar.
+	Value string
+}
+
+func (w Warning) String() string {
+	if w.EmployeeID == "" {
+		return fmt.Sprintf("[%s] period %s: %s", w.Category, w.PayPeriod, w.Message)
+	}
+	if w.PayPeriod == "" {
+		return fmt.Sprintf("[%s] employee %s: %s", w.Category, w.EmployeeID, w.Message)
+	}
+	return fmt.Sprintf("[%s] employee %s period %s: %s", w.Category, w.EmployeeID, w.PayPeriod, w.Message)
+}
+
+// ComputeResult is everything computeRegister produces for a run.
+type ComputeResult struct {
+	Registers []PayRegister
+	RowErrors []RowError
+	Warnings  []Warning
+	// Rounding totals the per-row rounding by currency, for booking a GL offset.
+	Rounding map[string]RoundingAdjustment
+}
+
+// computeRegister computes the pay register by merging the three datasets.
+// Registers are returned in canonical key order. A row that fails to compute is
+// skipped and reported in RowErrors; the remaining rows still compute.
+func computeRegister(payrollMap map[string]PayrollRecord, timeMap map[string]TimeRecord, benefitsMap map[string]BenefitsRecord, cfg TaxConfig, opts ComputeOptions) ComputeResult {
+	result := ComputeResult{Rounding: make(map[string]RoundingAdjustment)}
+	netCarry := make(map[string]Money) // employee|currency -> unpaid net rounding
+	arrears := make(map[string]Money)  // employee|currency -> uncollected deductions
+	matched := make(map[string]Money)  // employee|year|currency -> employer match so far
+	levied := make(map[string]Money)   // employee|year|currency[|contribution] -> SS wages or earnings so far
+	seedLevied(levied, opts.YTDSeed)
+	// employee|year|currency -> PTO accrued so far
+	ptoAccrued := make(map[string]PTOAccrued)
+
+	var orphans map[string]bool
+	if opts.Join == joinOuter {
+		payrollMap, orphans = withOrphanPayroll(payrollMap, timeMap, benefitsMap)
+	}
+	keys := sortedKeys(payrollMap)
+	if opts.TrackArrears || cfg.matchCapped() || cfg.contributionsCapped() || cfg.socialSecurityCapped() || len(opts.PTOAccruals) > 0 || opts.RoundNetDollars && opts.CarryNetRounding {
+		// Arrears, the year's match, wage bases, PTO accrued and the
+		// net rounding carry must reach the employee's next period in time, not
+		// in label order.
+		keys = chronologicalKeys(payrollMap)
+	}
+	for _, key := range keys {
+		payroll := payrollMap[key]
+		if opts.Period != "" && payroll.PayPeriod != opts.Period || !opts.includes(payroll.EmployeeID) {
+			continue
+		}
+		if !opts.Since.IsZero() || !opts.Until.IsZero() {
+			start, err := parsePeriod(payroll.PayPeriod)
+			if err != nil {
+				result.RowErrors = append(result.RowErrors, RowError{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Err: err})
+				continue
+			}
+			if start.Before(opts.Since) || !opts.Until.IsZero() && start.After(opts.Until) {
+				continue
+			}
+		}
+		var joinWarnings []Warning
+		joinWarning := func(message string) {
+			joinWarnings = append(joinWarnings, Warning{Category: "join-zero-filled", EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Message: message})
+		}
+		if orphans[key] {
+			joinWarning("no payroll record; computed with a zero hourly rate")
+		}
+		timeRec, okTime := timeMap[key]
+		if !okTime && (opts.IncludeZeroHours || opts.Join != joinInner) {
+			timeRec, okTime = TimeRecord{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod}, true
+			if opts.Join != joinInner {
+				joinWarning("no time record; computed with zero hours")
+			}
+		}
+		benefitsRec, okBenefits := benefitsMap[key]
+		imputed, defaulted := false, false
+		if okTime && !okBenefits {
+			benefitsRec, okBenefits = cfg.defaultBenefits(payroll.EmployeeID, payroll.PayPeriod, payroll.JobTitle)
+			defaulted = okBenefits
+		}
+		if okTime && !okBenefits && (opts.ZeroMissingBenefits || opts.Join != joinInner) {
+			benefitsRec, okBenefits, imputed = BenefitsRecord{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod}, true, true
+		}
+		if !okTime || !okBenefits {
+			// Skip if any record is missing.
+			continue
+		}
+
+		rowCfg, err := cfg.forPeriod(payroll.PayPeriod)
+		if err != nil {
+			// A missing tax year is a configuration problem, not a data problem.
+			result.RowErrors = append(result.RowErrors, RowError{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Err: err, Fatal: true})
+			continue
+		}
+		// With -split-jobs a multi-job employee gets one line per job.
+		payrolls, times, benefits := []PayrollRecord{payroll}, []TimeRecord{timeRec}, []BenefitsRecord{benefitsRec}
+		if opts.SplitJobs && len(payroll.Jobs) > 1 {
+			if payrolls, times, benefits, err = splitJobs(payroll, timeRec, benefitsRec); err != nil {
+				result.RowErrors = append(result.RowErrors, RowError{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Err: err})
+				continue
+			}
+		}
+		for i := range payrolls {
+			reg, warnings, err := safeComputeRow(payrolls[i], times[i], benefits[i], rowCfg, opts)
+			if imputed && i == 0 {
+				reg.BenefitsImputed = true
+				warnings = append(warnings, Warning{
+					Category:   "benefits-imputed",
+					EmployeeID: payroll.EmployeeID,
+					PayPeriod:  payroll.PayPeriod,
+					Message:    "no benefits record; computed with zero benefits",
+				})
+			}
+			if defaulted && i == 0 {
+				reg.BenefitsDefaulted = true
+				warnings = append(warnings, Warning{
+					Category:   "benefits-default",
+					EmployeeID: payroll.EmployeeID,
+					PayPeriod:  payroll.PayPeriod,
+					Message:    fmt.Sprintf("no benefits record; computed with the default benefits for job title %q", payroll.JobTitle),
+				})
+			}
+			if i == 0 {
+				warnings = append(joinWarnings, warnings...)
+			}
+			result.Warnings = append(result.Warnings, warnings...)
+			if err != nil {
+				_, fatal := err.(fatalRowError)
+				result.RowErrors = append(result.RowErrors, RowError{EmployeeID: payroll.EmployeeID, PayPeriod: payroll.PayPeriod, Err: err, Fatal: fatal})
+				continue
+			}
+			if match := rowCfg.RetirementMatch; match != nil && match.AnnualCap > 0 && reg.EmployerMatch > 0 {
+				// The cap is per calendar year, so a period with no year goes uncapped.
+				if start, err := parsePeriod(reg.PayPeriod); err != nil {
+					result.Warnings = append(result.Warnings, Warning{Category: "match-cap", EmployeeID: reg.EmployeeID, PayPeriod: reg.PayPeriod,
+						Message: fmt.Sprintf("annual match cap not applied: %v", err), Value: reg.EmployerMatch.String()})
+				} else {
+					matchKey := ytdKey(reg.EmployeeID, start.Year(), reg.Currency)
+					reg.EmployerMatch = min(reg.EmployerMatch, max(match.AnnualCap-matched[matchKey], 0))
+					matched[matchKey] += reg.EmployerMatch
+					reg.TotalEmployerCost = computeEmployerCost(reg)
+				}
+			}
+			capSocialSecurity(&reg, rowCfg, levied)
+			capContributions(&reg, rowCfg, levied)
+			if accrual, ok := opts.PTOAccruals[reg.EmployeeID]; ok {
+				accruePTO(&reg, accrual, i == 0, ptoAccrued)
+			}
+			if opts.TrackArrears {
+				arrearsKey := makeKey(reg.EmployeeID, reg.Currency)
+				applyArrears(&reg, arrears[arrearsKey], opts.NetFloor)
+				arrears[arrearsKey] = reg.ArrearsOutstanding
+			}
+			if opts.RoundNetDollars {
+				// With a carry, keys are in chronological order above, so the carry
+				// reaches the employee's next period.
+				carryKey := makeKey(reg.EmployeeID, reg.Currency)
+				owed := reg.NetPay
+				if opts.CarryNetRounding {
+					owed += netCarry[carryKey]
+				}
+				reg.NetPay = Money(divRound(int64(owed), 100) * 100)
+				reg.NetPayRoundingCarry = owed - reg.NetPay
+				netCarry[carryKey] = reg.NetPayRoundingCarry
+			}
+			result.Registers = append(result.Registers, reg)
+			if opts.Progress != nil {
+				opts.Progress(reg)
+			}
+			total := result.Rounding[reg.Currency]
+			total.Add(reg.Rounding)
+			result.Rounding[reg.Currency] = total
+		}
+	}
+
+	return result
+}
+
+// safeComputeRow runs computeRow, converting a panic into an error so one bad
+// record cannot take down the whole run.
+func safeComputeRow(payroll PayrollRecord, timeRec TimeRecord, benefitsRec BenefitsRecord, cfg TaxConfig, opts ComputeOptions) (reg PayRegister, warnings []Warning, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during computation: %v", r)
+		}
+	}()
+	return computeRow(payroll, timeRec, benefitsRec, cfg, opts)
+}
+
+// applyEligibility zeroes benefit amounts the employee's type is not eligible for,
+// returning a warning for each one that was listed with a nonzero amount.
+func applyEligibility(payroll PayrollRecord, benefitsRec *BenefitsRecord, cfg TaxConfig) []Warning {
+	var warnings []Warning
+	check := func(category string, amount *Money) {
+		if *amount == 0 || cfg.eligibleFor(payroll.EmployeeType, category) {
+			return
+		}
+		warnings = append(warnings, Warning{
+			Category:   "eligibility",
+			EmployeeID: payroll.EmployeeID,
+			PayPeriod:  payroll.PayPeriod,
+			Message:    fmt.Sprintf("%s employee not eligible for %s benefit; %s zeroed", payroll.EmployeeType, category, *amount),
+			Value:      amount.String(),
+		})
+		*amount = 0
+	}
+	check("health", &benefitsRec.HealthInsurance)
+	check("retirement", &benefitsRec.Retirement)
+	check("other", &benefitsRec.OtherBenefits)
+	// Copy before zeroing so the caller's benefits map is left untouched.
+	named := maps.Clone(benefitsRec.NamedBenefits)
+	for _, name := range sortedKeys(named) {
+		amount := named[name]
+		check("other", &amount)
+		named[name] = amount
+	}
+	benefitsRec.NamedBenefits = named
+	return warnings
+}
+
+// applyOvertimeExemption takes the overtime and double-time hours of an exempt
+// employee, or of an employee's exempt jobs, out of the premium columns: they move
+// to regular hours, or are dropped when the config pays exempt overtime nothing.
+// Each decision is returned as a warning so exemptions can be audited.
+func applyOvertimeExemption(payroll PayrollRecord, timeRec *TimeRecord, cfg TaxConfig) []Warning {
+	var warnings []Warning
+	exempt := func(title string, regular, overtime, doubleTime *int) {
+		premium := *overtime + *doubleTime
+		if premium == 0 || !(payroll.OvertimeExempt || cfg.overtimeExempt(title)) {
+			return
+		}
+		reason := fmt.Sprintf("job title %q is overtime-exempt", title)
+		if payroll.OvertimeExempt {
+			reason = "employee is overtime-exempt"
+		}
+		paid := "paid at the regular rate"
+		if cfg.ExemptOvertimePay == exemptPayNone {
+			paid = "not paid"
+		} else {
+			*regular += premium
+		}
+		*overtime, *doubleTime = 0, 0
+		warnings = append(warnings, Warning{
+			Category:   "overtime-exempt",
+			EmployeeID: payroll.EmployeeID,
+			PayPeriod:  payroll.PayPeriod,
+			Message:    fmt.Sprintf("%s; %d overtime hour(s) %s", reason, premium, paid),
+			Value:      strconv.Itoa(premium),
+		})
+	}
+	if len(payroll.Jobs) <= 1 {
+		exempt(payroll.JobTitle, &timeRec.RegularHours, &timeRec.OvertimeHours, &timeRec.DoubleTimeHours)
+		return warnings
+	}
+	// Copy before changing so the caller's time map is left untouched.
+	jobs := maps.Clone(timeRec.Jobs)
+	for _, job := range payroll.Jobs {
+		h, ok := jobs[jobKey(job.Title)]
+		if !ok {
+			continue
+		}
+		var doubleTime int
+		exempt(job.Title, &h.RegularHours, &h.OvertimeHours, &doubleTime)
+		jobs[jobKey(job.Title)] = h
+	}
+	timeRec.Jobs, timeRec.RegularHours, timeRec.OvertimeHours = jobs, 0, 0
+	for _, h := range jobs {
+		timeRec.RegularHours += h.RegularHours
+		timeRec.OvertimeHours += h.OvertimeHours
+	}
+	return warnings
+}
+
+// computeRow computes the register line for one matched employee-period.
+func computeRow(payroll PayrollRecord, timeRec TimeRecord, benefitsRec BenefitsRecord, cfg TaxConfig, opts ComputeOptions) (PayRegister, []Warning, error) {
+	warnings := applyEligibility(payroll, &benefitsRec, cfg)
+	cfg.moveImputedBenefits(&benefitsRec)
+	var rounding RoundingAdjustment
+
+	// Prorate benefits quoted at another frequency to this pay period.
+	if opts.BenefitsPerYear > 0 && opts.BenefitsPerYear != cfg.PeriodsPerYear {
+		factor := float64(opts.BenefitsPerYear) / float64(cfg.PeriodsPerYear)
+		benefitsRec.HealthInsurance = roundedMul(benefitsRec.HealthInsurance, factor, &rounding.Deductions)
+		benefitsRec.Retirement = roundedMul(benefitsRec.Retirement, factor, &rounding.Deductions)
+		benefitsRec.OtherBenefits = roundedMul(benefitsRec.OtherBenefits, factor, &rounding.Deductions)
+		benefitsRec.ImputedIncome = roundedMul(benefitsRec.ImputedIncome, factor, &rounding.Deductions)
+		// applyEligibility already copied NamedBenefits, so this does not touch the input map.
+		for _, name := range sortedKeys(benefitsRec.NamedBenefits) {
+			benefitsRec.NamedBenefits[name] = roundedMul(benefitsRec.NamedBenefits[name], factor, &rounding.Deductions)
+		}
+	}
+
+	// A daily breakdown overrides the period-level hours; a weekly one has the weekly
+	// threshold applied to each week rather than to the period's total. An
+	// employee's contractual threshold replaces the rules' weekly one.
+	rules := opts.OvertimeRules
+	if payroll.OvertimeAfter > 0 {
+		rules.WeeklyOvertimeAfter = payroll.OvertimeAfter
+	}
+	overtimeAfter := 0
+	if len(timeRec.Days) > 0 {
+		timeRec.RegularHours, timeRec.OvertimeHours, timeRec.DoubleTimeHours = rules.splitHours(timeRec.Days)
+		overtimeAfter = rules.WeeklyOvertimeAfter
+	} else if len(timeRec.Weeks) > 0 {
+		timeRec.RegularHours, timeRec.OvertimeHours = rules.splitWeeks(timeRec.Weeks)
+		overtimeAfter = rules.WeeklyOvertimeAfter
+	}
+
+	// Guard against impossible hours from timekeeping glitches.
+	if opts.MaxRegularHours > 0 && timeRec.RegularHours > opts.MaxRegularHours {
+		msg := fmt.Sprintf("regular hours %d exceed cap of %d", timeRec.RegularHours, opts.MaxRegularHours)
+		if err := check(opts.HoursCapAction, "hours-cap", payroll, msg, strconv.Itoa(timeRec.RegularHours), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+	if opts.MaxOvertimeHours > 0 && timeRec.OvertimeHours > opts.MaxOvertimeHours {
+		msg := fmt.Sprintf("overtime hours %d exceed cap of %d", timeRec.OvertimeHours, opts.MaxOvertimeHours)
+		if err := check(opts.HoursCapAction, "hours-cap", payroll, msg, strconv.Itoa(timeRec.OvertimeHours), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+	totalHours := timeRec.RegularHours + timeRec.OvertimeHours + timeRec.DoubleTimeHours
+	if opts.MaxTotalHours > 0 && totalHours > opts.MaxTotalHours {
+		msg := fmt.Sprintf("total hours %d exceed cap of %d", totalHours, opts.MaxTotalHours)
+		if err := check(opts.HoursCapAction, "hours-cap", payroll, msg, strconv.Itoa(totalHours), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+	// Far more overtime than regular hours usually means swapped columns.
+	extraHours := timeRec.OvertimeHours + timeRec.DoubleTimeHours
+	if opts.MaxOvertimeRatio > 0 && extraHours > 0 && float64(extraHours) > opts.MaxOvertimeRatio*float64(max(timeRec.RegularHours, 0)) {
+		msg := fmt.Sprintf("overtime and double time hours %d are more than %g times the %d regular hours; are the columns swapped?", extraHours, opts.MaxOvertimeRatio, timeRec.RegularHours)
+		if err := check(opts.HoursCapAction, "overtime-ratio", payroll, msg, strconv.Itoa(extraHours), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+
+	warnings = append(warnings, applyOvertimeExemption(payroll, &timeRec, cfg)...)
+
+	if opts.RequireTaxEntry {
+		locality := strings.ToUpper(strings.TrimSpace(payroll.WorkLocality))
+		if _, ok := cfg.LocalTaxRates[locality]; locality != "" && !ok {
+			return PayRegister{}, warnings, fatalRowError{fmt.Errorf("work locality %q has no entry in the local tax table", payroll.WorkLocality)}
+		}
+	}
+
+	// A zero rate on worked hours silently yields zero gross. Salaried and
+	// piece-rate employees may legitimately carry no hourly rate.
+	hours := timeRec.RegularHours + timeRec.OvertimeHours + timeRec.DoubleTimeHours
+	if opts.ZeroRateAction != "" && payroll.HourlyRate == 0 && payroll.PieceRate == 0 && hours > 0 &&
+		!strings.EqualFold(strings.TrimSpace(payroll.EmployeeType), "SALARIED") {
+		msg := fmt.Sprintf("hourly rate is zero but %d hours were worked", hours)
+		if err := check(opts.ZeroRateAction, "zero-rate", payroll, msg, strconv.Itoa(hours), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+
+	// Compute Gross Wages from the earnings components, by default the built-in
+	// ones (see builtinEarnings):
+	// GrossWages = HourlyRate * RegularHours + 1.5 * HourlyRate * OvertimeHours
+	//              + 2 * HourlyRate * DoubleTimeHours + Adjustment
+	// Each component is rounded to the cent as it is computed, so the register
+	// always adds up exactly. The components are handed the hours by the rate
+	// they are paid at. Under OvertimeAmounts no overtime hours are paid by the
+	// hour; the time file's Overtime Pay is instead.
+	paidOvertime := timeRec.OvertimeHours
+	if opts.OvertimeAmounts {
+		paidOvertime = 0
+	}
+	var shifts []PayShift
+	change, hasChange := opts.MidPeriodRates[makeKey(payroll.EmployeeID, payroll.PayPeriod)]
+	if hasChange && len(payroll.Jobs) > 1 {
+		return PayRegister{}, warnings, fmt.Errorf("a mid-period rate change cannot be applied to an employee with %d jobs", len(payroll.Jobs))
+	}
+	if payroll.PieceRate != 0 {
+		if hasChange || len(payroll.Jobs) > 1 {
+			return PayRegister{}, warnings, fmt.Errorf("piece-rate pay cannot be combined with a mid-period rate change or several jobs")
+		}
+		shifts = []PayShift{{payroll.HourlyRate, timeRec.RegularHours, paidOvertime, timeRec.DoubleTimeHours}}
+	} else if hasChange {
+		// A mid-period raise: each kind of hours is split at the same share, the
+		// part before the change paid at the period's rate and the rest at the new one.
+		share, err := change.shareBefore(timeRec, payroll.PayPeriod, cfg.PeriodsPerYear)
+		if err != nil {
+			return PayRegister{}, warnings, err
+		}
+		regularBefore, regularAfter := divideHours(timeRec.RegularHours, share)
+		overtimeBefore, overtimeAfter := divideHours(paidOvertime, share)
+		doubleBefore, doubleAfter := divideHours(timeRec.DoubleTimeHours, share)
+		shifts = []PayShift{{payroll.HourlyRate, regularBefore, overtimeBefore, doubleBefore}, {change.NewRate, regularAfter, overtimeAfter, doubleAfter}}
+	} else if len(payroll.Jobs) > 1 {
+		// Several jobs in one line: each job's hours are paid at that job's rate.
+		hours, err := jobHours(payroll, timeRec)
+		if err != nil {
+			return PayRegister{}, warnings, err
+		}
+		for i, job := range payroll.Jobs {
+			overtime := hours[i].OvertimeHours
+			if opts.OvertimeAmounts {
+				overtime = 0
+			}
+			shifts = append(shifts, PayShift{job.Rate, hours[i].RegularHours, overtime, 0})
+		}
+	} else {
+		shifts = []PayShift{{payroll.HourlyRate, timeRec.RegularHours, paidOvertime, timeRec.DoubleTimeHours}}
+	}
+	ctx := EmployeePeriod{Payroll: payroll, Time: timeRec, Shifts: shifts, EarningsRounding: opts.EarningsRounding, OvertimeAmounts: opts.OvertimeAmounts}
+	grossWages, customEarnings, earnings, tally := applyEarningsComponents(ctx, opts.EarningsComponents)
+	overtimeStraight, overtimePremium, pieceEarnings := tally.overtimeStraight, tally.overtimePremium, tally.piece
+	rounding.Gross += tally.rounding
+
+	// Compute Taxes. Each tax is computed on its configured taxable base (by default
+	// income taxes on gross less pre-tax benefits, FICA on gross), all derived from
+	// the same (optionally rounded) gross.
+	taxBase := grossWages
+	if opts.RoundGrossForTax {
+		taxBase = Money(divRound(int64(grossWages), 100) * 100)
+	}
+	// Imputed income and reported tips are taxed like wages but never paid out;
+	// taxes the config exempts tips from take them back out of their base.
+	taxBase += benefitsRec.ImputedIncome + timeRec.ReportedTips
+	levied := func(tax string) Money {
+		base := cfg.taxableBase(tax, taxBase, benefitsRec)
+		if cfg.untaxedTips(tax) {
+			base -= timeRec.ReportedTips
+		}
+		return base
+	}
+	override := opts.TaxOverrides[payroll.EmployeeID]
+	taxableWages := levied("federal")
+	stateWages := levied("state")
+	// Payments below the withholding floor have no income tax withheld, and with
+	// WithholdingFloorFICA no FICA either.
+	waiveIncome := opts.WithholdingFloor > 0 && grossWages > 0 && grossWages < opts.WithholdingFloor
+	waiveFICA := waiveIncome && opts.WithholdingFloorFICA
+	localBase := levied("local")
+	federalRate := cfg.incomeTaxRate(cfg.FederalBrackets, cfg.FederalRate, taxableWages)
+	stateRate := cfg.incomeTaxRate(cfg.StateBrackets, cfg.StateRate, stateWages)
+	var federalTax, stateTax, localTax Money
+	if waiveIncome {
+		waived := "income tax"
+		if waiveFICA {
+			waived = "income tax or FICA"
+		}
+		warnings = append(warnings, Warning{
+			Category:   "withholding-floor",
+			EmployeeID: payroll.EmployeeID,
+			PayPeriod:  payroll.PayPeriod,
+			Message:    fmt.Sprintf("gross %s is below the withholding floor of %s; no %s withheld", grossWages, opts.WithholdingFloor, waived),
+			Value:      grossWages.String(),
+		})
+	} else {
+		if !opts.NoFederal {
+			federalTax = override.Federal.tax(taxableWages, federalRate, &rounding.Deductions)
+		}
+		if !opts.NoState {
+			stateTax = override.State.tax(stateWages, stateRate, &rounding.Deductions)
+		}
+		localTax = roundedMul(localBase, cfg.localTaxRate(payroll.WorkLocality), &rounding.Deductions)
+	}
+	socialSecurityBase := levied("socialSecurity")
+	medicareBase := levied("medicare")
+	// Exempt employees still get the columns, just at zero, so the layout is stable.
+	var socialSecurity, medicare, employerSocialSecurity, employerMedicare Money
+	var socialSecurityWages, medicareWages Money
+	// Configured contributions take the place of Social Security and Medicare.
+	usFICA := len(cfg.Contributions) == 0
+	if usFICA && !payroll.FICAExempt && !waiveFICA && !opts.NoFICA {
+		socialSecurityWages = socialSecurityBase
+		socialSecurity = roundedMul(socialSecurityBase, cfg.SocialSecurityRate, &rounding.Deductions)
+		employerSocialSecurity = socialSecurityBase.MulRate(cfg.EmployerSocialSecurityRate)
+	}
+	if usFICA && !payroll.MedicareExempt && !waiveFICA && !opts.NoFICA {
+		medicareWages = medicareBase
+		medicare = roundedMul(medicareBase, cfg.MedicareRate, &rounding.Deductions)
+		employerMedicare = medicareBase.MulRate(cfg.EmployerMedicareRate)
+	}
+	var contributions []ContributionAmount
+	for _, c := range cfg.Contributions {
+		amount := ContributionAmount{Name: c.Name}
+		if !payroll.FICAExempt && !waiveFICA && !opts.NoFICA {
+			amount = c.contribute(cfg.levyBase(c.Base, taxBase, benefitsRec), &rounding.Deductions)
+		}
+		contributions = append(contributions, amount)
+	}
+	// How each tax came about, for -explain-taxes: the same bases, rates, and
+	// branches as above.
+	income := func(o TaxOverrideValue, rate float64, off bool) (string, float64) {
+		switch {
+		case off:
+			return "disabled", 0
+		case waiveIncome:
+			return "withholding floor", 0
+		case o.Amount != nil:
+			return "override amount", 0
+		case o.Rate != nil:
+			return "override rate", *o.Rate
+		}
+		return "rate", rate
+	}
+	fica := func(exempt bool, rate float64) (string, float64) {
+		switch {
+		case opts.NoFICA:
+			return "disabled", 0
+		case exempt:
+			return "exempt", 0
+		case waiveFICA:
+			return "withholding floor", 0
+		}
+		return "rate", rate
+	}
+	federalHow, federalRate := income(override.Federal, federalRate, opts.NoFederal)
+	stateHow, stateRate := income(override.State, stateRate, opts.NoState)
+	if federalHow == "rate" && len(cfg.FederalBrackets) > 0 {
+		federalHow = "brackets"
+	}
+	if stateHow == "rate" && len(cfg.StateBrackets) > 0 {
+		stateHow = "brackets"
+	}
+	localHow, localRate := income(TaxOverrideValue{}, cfg.localTaxRate(payroll.WorkLocality), false)
+	socialSecurityHow, socialSecurityRate := fica(payroll.FICAExempt, cfg.SocialSecurityRate)
+	medicareHow, medicareRate := fica(payroll.MedicareExempt, cfg.MedicareRate)
+	if !usFICA {
+		socialSecurityHow, socialSecurityRate = "replaced by contributions", 0
+		medicareHow, medicareRate = "replaced by contributions", 0
+	}
+	taxDetails := []TaxDetail{
+		{"federal", cfg.baseKind("federal"), taxableWages, federalRate, federalHow, federalTax},
+		{"state", cfg.baseKind("state"), stateWages, stateRate, stateHow, stateTax},
+		{"local", cfg.baseKind("local"), localBase, localRate, localHow, localTax},
+		{"socialSecurity", cfg.baseKind("socialSecurity"), socialSecurityBase, socialSecurityRate, socialSecurityHow, socialSecurity},
+		{"medicare", cfg.baseKind("medicare"), medicareBase, medicareRate, medicareHow, medicare},
+	}
+	for i, c := range cfg.Contributions {
+		how, rate := fica(payroll.FICAExempt, c.Rate)
+		basis := fmt.Sprintf("%s above %s", levyKind(c.Base), c.Threshold)
+		taxDetails = append(taxDetails, TaxDetail{c.Name, basis, contributions[i].Earnings, rate, how, contributions[i].Employee})
+	}
+	if opts.TaxRounding == roundTotal {
+		amounts := roundTaxesTotal(taxDetails)
+		for i := range taxDetails {
+			rounding.Deductions += float64(amounts[i] - taxDetails[i].Amount)
+			taxDetails[i].Amount = amounts[i]
+		}
+		federalTax, stateTax, localTax, socialSecurity, medicare = amounts[0], amounts[1], amounts[2], amounts[3], amounts[4]
+		for i := range contributions {
+			contributions[i].Employee = amounts[5+i]
+		}
+	}
+
+	// Total Benefits
+	totalBenefits := benefitsRec.HealthInsurance + benefitsRec.Retirement + benefitsRec.otherTotal()
+
+	// Total Deductions = Taxes + Total Benefits
+	totalDeductions := federalTax + stateTax + localTax + socialSecurity + medicare + contributionTotal(contributions) + totalBenefits
+
+	// Net Pay
+	netPay := grossWages - totalDeductions
+
+	// The tips counted toward a tip minimum wage; any shortfall is the employer's.
+	tipCredit, tipShortfall := cfg.tipCredit(grossWages, timeRec.ReportedTips, timeRec.RegularHours+timeRec.OvertimeHours+timeRec.DoubleTimeHours)
+	if tipShortfall > 0 {
+		warnings = append(warnings, Warning{
+			Category:   "tip-shortfall",
+			EmployeeID: payroll.EmployeeID,
+			PayPeriod:  payroll.PayPeriod,
+			Message:    fmt.Sprintf("cash wages %s plus tips %s fall %s short of the tip minimum wage of %s an hour", grossWages, timeRec.ReportedTips, tipShortfall, cfg.TipMinimumWage),
+			Value:      tipShortfall.String(),
+		})
+	}
+
+	reg := PayRegister{
+		EmployeeID:      payroll.EmployeeID,
+		EmployeeName:    payroll.EmployeeName,
+		JobTitle:        payroll.JobTitle,
+		PayPeriod:       payroll.PayPeriod,
+		WorkLocality:    payroll.WorkLocality,
+		HourlyRate:      payroll.HourlyRate,
+		RegularHours:    timeRec.RegularHours,
+		OvertimeHours:   timeRec.OvertimeHours,
+		DoubleTimeHours: timeRec.DoubleTimeHours,
+		Adjustment:      timeRec.Adjustment,
+		GrossWages:      grossWages,
+		ImputedIncome:   benefitsRec.ImputedIncome,
+		CustomEarnings:  customEarnings,
+		Earnings:        earnings,
+		TipWages:        timeRec.ReportedTips,
+		TipCredit:       tipCredit,
+		PieceRate:       payroll.PieceRate,
+		Units:           timeRec.Units,
+		PieceEarnings:   pieceEarnings,
+		TaxableWages:    taxableWages,
+		FederalTax:      federalTax,
+		StateTax:        stateTax,
+		LocalTax:        localTax,
+		SocialSecurity:  socialSecurity,
+		Medicare:        medicare,
+		HealthInsurance: benefitsRec.HealthInsurance,
+		Retirement:      benefitsRec.Retirement,
+		OtherBenefits:   benefitsRec.otherTotal(),
+		NamedBenefits:   benefitsRec.NamedBenefits,
+		TotalBenefits:   totalBenefits,
+		TotalDeductions: totalDeductions,
+		NetPay:          netPay,
+		IsAdjustment:    timeRec.Adjustment != 0,
+		IsCorrection:    timeRec.isCorrection(),
+		Currency:        payroll.Currency,
+		Rounding:        rounding,
+
+		EmployerSocialSecurity: employerSocialSecurity,
+		EmployerMedicare:       employerMedicare,
+		EmployerBenefits:       benefitsRec.EmployerContribution,
+
+		SocialSecurityWages: socialSecurityWages,
+		MedicareWages:       medicareWages,
+		StateWages:          stateWages,
+		OvertimeAfter:       overtimeAfter,
+
+		OvertimeStraight: overtimeStraight,
+		OvertimePremium:  overtimePremium,
+		TaxDetails:       taxDetails,
+		Contributions:    contributions,
+	}
+	if len(payroll.Jobs) > 1 {
+		reg.JobTitle = jobTitles(payroll.Jobs)
+	}
+	reg.SSN = payroll.SSN
+	if !opts.FullSSN {
+		reg.SSN = maskSSN(payroll.SSN)
+	}
+	if cfg.RetirementMatch != nil {
+		reg.EmployerMatch = cfg.RetirementMatch.match(reg.Retirement, reg.GrossWages)
+	}
+	applyDeductionRules(&reg, append(cfg.deductionRules(), opts.DeductionRules...))
+	reg.TotalEmployerCost = computeEmployerCost(reg)
+	reg.EffectiveTaxRate = effectiveTaxRate(reg)
+
+	if opts.DeductionsExceedGrossAction != "" && reg.TotalDeductions > reg.GrossWages {
+		msg := fmt.Sprintf("total deductions %s exceed gross wages %s (benefits %s)", reg.TotalDeductions, reg.GrossWages, reg.TotalBenefits)
+		if err := check(opts.DeductionsExceedGrossAction, "deductions-exceed-gross", payroll, msg, reg.TotalDeductions.String(), &warnings); err != nil {
+			return PayRegister{}, warnings, err
+		}
+	}
+	return reg, warnings, nil
+}
+
+// metaFilename returns the sidecar path for a register file, e.g. out.csv -> out.meta.json.
+func metaFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".meta.json"
+}
+
+// writeRegisterMeta writes the provenance sidecar describing a register file.
+func writeRegisterMeta(filename string, recordCount int, cfg TaxConfig, columns []string) error {
+	meta := RegisterMeta{
+		SchemaVersion:  registerSchemaVersion,
+		GeneratedAt:    time.Now().UTC().Format(time.RFC3339),
+		RecordCount:    recordCount,
+		PeriodsPerYear: cfg.PeriodsPerYear,
+		TaxConfig:      cfg,
+		Columns:        columns,
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode register metadata: %v", err)
+	}
+	if err := os.WriteFile(metaFilename(filename), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write register metadata: %v", err)
+	}
+	return nil
+}
+
+// WriterOptions controls how registers are rendered by the writers.
+type WriterOptions struct {
+	Currency Currency
+	// NoHeader omits the header row.
+	NoHeader bool
+	// Columns, when set, limits the register to these columns in this order.
+	Columns []string
+	// CollapseBenefits folds named benefits into Other Benefits instead of
+	// itemizing them in columns of their own after the standard ones.
+	CollapseBenefits bool
+	// NumberFormat is how amounts are written: decimal2 (the default, per
+	// Currency), cents (integer minor units), or raw (shortest exact decimal).
+	NumberFormat string
+	// TotalsRow appends one TOTAL row per currency after the data rows.
+	TotalsRow bool
+	// AllColumns writes every register column, also those no line has data for
+	// (see registerOptionalColumns), for a layout that does not vary by run.
+	AllColumns bool
+	// BufferSize is the CSV register's write buffer in bytes; zero means the
+	// encoding/csv default. encoding/csv keeps its own 4096-byte buffer in front
+	// of a smaller one, so BufferSize can raise the memory held but not lower it.
+	// FlushEvery, when positive, flushes the buffer every that many rows so
+	// output streams steadily instead of arriving at the end.
+	BufferSize int
+	FlushEvery int
+	// Display groups thousands and sets the decimal mark in the human-facing
+	// outputs only (-preview, paystubs, statements); the register ignores it.
+	Display DisplayFormat
+	// Redact hides every amount; main sets it for the register only (-redact).
+	Redact Redaction
+	// Encoding is the CSV register's character encoding: encodingUTF8 (the
+	// default when empty), encodingUTF8BOM, or encodingLatin1.
+	Encoding string
+}
+
+// Number formats accepted by -number-format.
+const (
+	numberDecimal2 = "decimal2"
+	numberCents    = "cents"
+	numberRaw      = "raw"
+)
+
+// formatter returns the amount formatter for a row paid in currency code. Symbols are
+// only rendered when -currency asked for them; each row then uses its own currency.
+// The cents and raw number formats never carry a symbol.
+func (opts WriterOptions) formatter(code string) func(Money) string {
+	switch opts.NumberFormat {
+	case numberCents:
+		return opts.Redact.wrap(func(m Money) string { return strconv.FormatInt(int64(m), 10) })
+	case numberRaw:
+		return opts.Redact.wrap(func(m Money) string { return strconv.FormatFloat(m.Float64(), 'f', -1, 64) })
+	}
+	if opts.Currency.Symbol != "" {
+		if cur, ok := currencies[code]; ok {
+			return opts.Redact.wrap(cur.Format)
+		}
+	}
+	return opts.Redact.wrap(opts.Currency.Format)
+}
+
+// currencyLabel is the Currency column value: the row's own code, else the run's.
+func (opts WriterOptions) currencyLabel(reg PayRegister) string {
+	if reg.Currency != "" {
+		return reg.Currency
+	}
+	return opts.Currency.Code
+}
+
+// defaultWriterOptions reproduces the historical output: plain amounts with two decimals.
+func defaultWriterOptions() WriterOptions {
+	return WriterOptions{Currency: Currency{Decimals: 2}}
+}
+
+// csvText canonicalizes a free-text field for CSV output. encoding/csv reads a CRLF
+// inside a quoted field back as a bare LF, so writing bare LFs keeps names that
+// contain line breaks identical across a write/read round trip.
+func csvText(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// rowType labels a register line for the Row Type column so adjustment rows can be
+// reconciled against the original run.
+func rowType(reg PayRegister) string {
+	if reg.IsCorrection {
+		return "CORRECTION"
+	}
+	if reg.IsAdjustment {
+		return "ADJUSTMENT"
+	}
+	return "REGULAR"
+}
+
+// stdoutName is the output path that means "write to standard output".
+const stdoutName = "-"
+
+// nopWriteCloser adapts os.Stdout so writers can Close it without closing stdout.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// noMkdir is set by -no-mkdir: createOutput then leaves missing parent directories
+// as an error instead of creating them.
+var noMkdir bool
+
+// createOutput opens filename for writing, or standard output when filename is "-".
+// Missing parent directories are created unless -no-mkdir is set. Every writer should
+// go through this rather than os.Create so any format can be piped.
+func createOutput(filename string) (io.WriteCloser, error) {
+	if filename == stdoutName {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filename
+	}
+	if !noMkdir {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("cannot create directory for %s: %v", path, err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s: %v", path, errors.Unwrap(err))
+	}
+	return file, nil
+}
+
+// closeOutput closes a file from createOutput, for deferring in a writer with a
+// named err result. A failed close is the write error when there was no other:
+// on NFS and similar filesystems, Close is where a delayed write failure shows.
+func closeOutput(file io.Closer, err *error) {
+	if cerr := file.Close(); cerr != nil && *err == nil {
+		*err = fmt.Errorf("cannot close output file: %v", cerr)
+	}
+}
+
+// registerHeader is the full register column list, in output order.
+var registerHeader = []string{
+	"Employee ID", "Employee Name", "Job Title", "Pay Period", "Hourly Rate",
+	"Regular Hours", "Overtime Hours", "Double Time Hours", "Units", "Piece Earnings", "Overtime Straight", "Overtime Premium", "Adjustment", "Gross Wages", "Imputed Income", "Federal Tax", "State Tax",
+	"Local Tax", "Social Security", "Medicare", "Health Insurance", "Retirement", "Other Benefits",
+	"Total Benefits", "Custom Deductions", "Arrears Collected", "Arrears Outstanding", "Total Deductions", "Net Pay", "Effective Tax Rate", "Row Type", "Currency",
+}
+
+// registerOptionalColumns are the registerHeader columns past the original
+// eighteen. Each is written only when some line has data for it, as reported
+// here, so a run using none of the features behind them keeps the original
+// layout; -all-columns writes them all. Effective Tax Rate has data on every line
+// and so is written only on request. A totals row brings Row Type, which marks
+// it, and a -currency code brings Currency.
+var registerOptionalColumns = map[string]func(PayRegister) bool{
+	"Double Time Hours":   func(r PayRegister) bool { return r.DoubleTimeHours != 0 },
+	"Units":               func(r PayRegister) bool { return r.Units != 0 },
+	"Piece Earnings":      func(r PayRegister) bool { return r.PieceEarnings != 0 },
+	"Overtime Straight":   func(r PayRegister) bool { return r.OvertimeStraight != 0 },
+	"Overtime Premium":    func(r PayRegister) bool { return r.OvertimePremium != 0 },
+	"Adjustment":          func(r PayRegister) bool { return r.Adjustment != 0 },
+	"Imputed Income":      func(r PayRegister) bool { return r.ImputedIncome != 0 },
+	"Local Tax":           func(r PayRegister) bool { return r.LocalTax != 0 },
+	"Custom Deductions":   func(r PayRegister) bool { return r.CustomDeductions != 0 },
+	"Arrears Collected":   func(r PayRegister) bool { return r.ArrearsCollected != 0 },
+	"Arrears Outstanding": func(r PayRegister) bool { return r.ArrearsOutstanding != 0 },
+	"Effective Tax Rate":  func(PayRegister) bool { return false },
+	"Row Type":            func(r PayRegister) bool { return r.IsAdjustment || r.IsCorrection },
+	"Currency":            func(r PayRegister) bool { return r.Currency != "" },
+}
+
+// registerColumns returns the indexes into registerHeader of the columns the
+// register is written with: the -columns selection when there is one, and
+// otherwise every column but the optional ones no line has data for.
+func registerColumns(registers []PayRegister, opts WriterOptions) ([]int, error) {
+	if len(opts.Columns) > 0 {
+		return selectColumns(opts.Columns)
+	}
+	var columns []int
+	for i, name := range registerHeader {
+		has, optional := registerOptionalColumns[name]
+		switch {
+		case !optional || opts.AllColumns:
+		case name == "Row Type" && opts.TotalsRow:
+		case name == "Currency" && opts.Currency.Code != "":
+		case !slices.ContainsFunc(registers, has):
+			continue
+		}
+		columns = append(columns, i)
+	}
+	return columns, nil
+}
+
+// selectColumns resolves -columns names (matched like input headers, so "net_pay"
+// finds "Net Pay") to indexes into registerHeader. No names means every column.
+func selectColumns(names []string) ([]int, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	index, err := newColumnMap(registerHeader)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]int, 0, len(names))
+	for _, name := range names {
+		i, ok := index.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown register column %q (valid: %s)", name, strings.Join(registerHeader, ", "))
+		}
+		columns = append(columns, i)
+	}
+	return columns, nil
+}
+
+// registerRow renders a register as registerHeader's columns. otherBenefits is the
+// Other Benefits value to show, which excludes any named benefits itemized separately.
+func registerRow(reg PayRegister, opts WriterOptions, otherBenefits Money) []string {
+	money := opts.formatter(reg.Currency)
+	return []string{
+		csvText(reg.EmployeeID),
+		csvText(reg.EmployeeName),
+		csvText(reg.JobTitle),
+		csvText(reg.PayPeriod),
+		money(reg.HourlyRate),
+		strconv.Itoa(reg.RegularHours),
+		strconv.Itoa(reg.OvertimeHours),
+		strconv.Itoa(reg.DoubleTimeHours),
+		strconv.Itoa(reg.Units),
+		money(reg.PieceEarnings),
+		money(reg.OvertimeStraight),
+		money(reg.OvertimePremium),
+		money(reg.Adjustment),
+		money(reg.GrossWages),
+		money(reg.ImputedIncome),
+		money(reg.FederalTax),
+		money(reg.StateTax),
+		money(reg.LocalTax),
+		money(reg.SocialSecurity),
+		money(reg.Medicare),
+		money(reg.HealthInsurance),
+		money(reg.Retirement),
+		money(otherBenefits),
+		money(reg.TotalBenefits),
+		money(reg.CustomDeductions),
+		money(reg.ArrearsCollected),
+		money(reg.ArrearsOutstanding),
+		money(reg.TotalDeductions),
+		money(reg.NetPay),
+		strconv.FormatFloat(reg.EffectiveTaxRate, 'f', 4, 64),
+		rowType(reg),
+		opts.currencyLabel(reg),
+	}
+}
+
+// registerTotals sums hours and amounts per currency, in currency order. Text
+// fields are left blank except Currency, and Pay Period when every row shares one.
+func registerTotals(registers []PayRegister) []PayRegister {
+	totals := make(map[string]*PayRegister)
+	for i, reg := range registers {
+		t := totals[reg.Currency]
+		if t == nil {
+			t = &PayRegister{Currency: reg.Currency, PayPeriod: reg.PayPeriod, NamedBenefits: make(map[string]Money)}
+			totals[reg.Currency] = t
+		}
+		if i > 0 && reg.PayPeriod != t.PayPeriod {
+			t.PayPeriod = ""
+		}
+		t.RegularHours += reg.RegularHours
+		t.OvertimeHours += reg.OvertimeHours
+		t.DoubleTimeHours += reg.DoubleTimeHours
+		t.Units += reg.Units
+		t.PieceEarnings += reg.PieceEarnings
+		t.OvertimeStraight += reg.OvertimeStraight
+		t.OvertimePremium += reg.OvertimePremium
+		t.Adjustment += reg.Adjustment
+		t.GrossWages += reg.GrossWages
+		t.ImputedIncome += reg.ImputedIncome
+		t.FederalTax += reg.FederalTax
+		t.StateTax += reg.StateTax
+		t.LocalTax += reg.LocalTax
+		t.SocialSecurity += reg.SocialSecurity
+		t.Medicare += reg.Medicare
+		t.HealthInsurance += reg.HealthInsurance
+		t.Retirement += reg.Retirement
+		t.OtherBenefits += reg.OtherBenefits
+		for name, amount := range reg.NamedBenefits {
+			t.NamedBenefits[name] += amount
+		}
+		t.Contributions = addContributions(t.Contributions, reg.Contributions)
+		t.CustomEarnings += reg.CustomEarnings
+		t.TipWages += reg.TipWages
+		t.TipCredit += reg.TipCredit
+		if reg.PTO != nil {
+			if t.PTO == nil {
+				t.PTO = &PTOAccrued{}
+			}
+			t.PTO.Hours = roundHours(t.PTO.Hours + reg.PTO.Hours)
+			t.PTO.Value += reg.PTO.Value
+		}
+		t.TotalBenefits += reg.TotalBenefits
+		t.CustomDeductions += reg.CustomDeductions
+		t.ArrearsCollected += reg.ArrearsCollected
+		t.ArrearsOutstanding += reg.ArrearsOutstanding
+		t.TotalDeductions += reg.TotalDeductions
+		t.NetPay += reg.NetPay
+	}
+	var out []PayRegister
+	for _, code := range sortedKeys(totals) {
+		t := totals[code]
+		t.EffectiveTaxRate = effectiveTaxRate(*t)
+		out = append(out, *t)
+	}
+	return out
+}
+
+// dedupeRegisters finds register lines that repeat an earlier line's employee and
+// period. With keepFirst it drops them, keeping the first line of each; otherwise
+// registers is returned as is. Either way the duplicates are described, one message
+// per dropped or offending line.
+func dedupeRegisters(registers []PayRegister, keepFirst bool) ([]PayRegister, []string) {
+	seen := make(map[string]int) // key -> index in registers of the first line
+	var kept []PayRegister
+	var dupes []string
+	for i, reg := range registers {
+		key := makeKey(reg.EmployeeID, reg.PayPeriod)
+		if first, ok := seen[key]; ok {
+			dupes = append(dupes, fmt.Sprintf("employee %s period %s: line %d repeats line %d (net %s vs %s)",
+				reg.EmployeeID, reg.PayPeriod, i+1, first+1, reg.NetPay, registers[first].NetPay))
+			continue
+		}
+		seen[key] = i
+		kept = append(kept, reg)
+	}
+	if !keepFirst {
+		return registers, dupes
+	}
+	return kept, dupes
+}
+
+// isZeroRow reports whether a register line pays, withholds and deducts nothing:
+// gross, imputed income, tips, every tax, benefits, custom deductions and net all
+// zero.
+func isZeroRow(reg PayRegister) bool {
+	return reg.GrossWages == 0 && reg.ImputedIncome == 0 && reg.TipWages == 0 && reg.FederalTax == 0 && reg.StateTax == 0 &&
+		reg.LocalTax == 0 && reg.SocialSecurity == 0 && reg.Medicare == 0 && reg.TotalBenefits == 0 &&
+		reg.CustomDeductions == 0 && reg.TotalDeductions == 0 && reg.NetPay == 0
+}
+
+// omitZeroRows drops the lines isZeroRow matches, returning the rest in order and
+// how many were dropped.
+func omitZeroRows(registers []PayRegister) ([]PayRegister, int) {
+	kept := registers[:0:0]
+	for _, reg := range registers {
+		if !isZeroRow(reg) {
+			kept = append(kept, reg)
+		}
+	}
+	return kept, len(registers) - len(kept)
+}
+
+// groupByPeriod splits registers by PayPeriod, keeping their order within each period.
+func groupByPeriod(registers []PayRegister) map[string][]PayRegister {
+	groups := make(map[string][]PayRegister)
+	for _, reg := range registers {
+		groups[reg.PayPeriod] = append(groups[reg.PayPeriod], reg)
+	}
+	return groups
+}
+
+// shardOf assigns an employee to one of n shards by an FNV-1a hash of the ID, so
+// the same employee always lands in the same shard, whatever the periods or run.
+func shardOf(employeeID string, n int) int {
+	if foldKeyCase {
+		employeeID = strings.ToUpper(employeeID)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(employeeID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// groupByShard splits registers into n shards by shardOf, keeping their order
+// within each shard. Every shard is returned, empty or not.
+func groupByShard(registers []PayRegister, n int) [][]PayRegister {
+	shards := make([][]PayRegister, n)
+	for _, reg := range registers {
+		i := shardOf(reg.EmployeeID, n)
+		shards[i] = append(shards[i], reg)
+	}
+	return shards
+}
+
+// shardFilename inserts a shard number into an output path:
+// payroll_register.csv -> payroll_register_shard-2-of-8.csv.
+func shardFilename(filename string, shard, n int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s_shard-%d-of-%d%s", strings.TrimSuffix(filename, ext), shard+1, n, ext)
+}
+
+// periodFilename inserts a pay period into an output path:
+// payroll_register.csv -> payroll_register_2024-06.csv.
+func periodFilename(filename, period string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "_" + sanitizeFilename(period) + ext
+}
+
+// writeRegister writes the computed pay register to a CSV file, plus a .meta.json sidecar
+// recording the schema version, generation time, and tax configuration used. When
+// filename is "-" the register goes to stdout and no sidecar is written.
+func writeRegister(registers []PayRegister, filename string, cfg TaxConfig, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	written, err := writeRegisterCSV(file, registers, opts)
+	if err != nil {
+		return err
+	}
+	if filename == stdoutName {
+		return nil
+	}
+	return writeRegisterMeta(filename, len(registers), cfg, written)
+}
+
+// writeRegisterCSV writes the register's CSV to w. It returns the columns
+// written when -columns selected a subset, nil when it wrote them all.
+func writeRegisterCSV(w io.Writer, registers []PayRegister, opts WriterOptions) ([]string, error) {
+	out, err := encodeOutput(w, opts.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write output file: %v", err)
+	}
+	var buffered *bufio.Writer
+	if opts.BufferSize > 0 {
+		buffered = bufio.NewWriterSize(out, opts.BufferSize)
+		out = buffered
+	}
+	writer := csv.NewWriter(out)
+	// flush pushes buffered rows to the file and reports any write error that
+	// buffering held back. Below 4096 bytes the sized buffer is a layer of its
+	// own under encoding/csv's, and needs flushing too.
+	flush := func() error {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("cannot write output file: %v", err)
+		}
+		if buffered != nil {
+			if err := buffered.Flush(); err != nil {
+				return fmt.Errorf("cannot write output file: %v", err)
+			}
+		}
+		return nil
+	}
+
+	columns, err := registerColumns(registers, opts)
+	if err != nil {
+		return nil, err
+	}
+	// A -columns selection leaves out everything it does not name, the columns
+	// after the standard ones included.
+	selected := len(opts.Columns) > 0
+	project := func(full []string) []string {
+		out := make([]string, len(columns))
+		for i, c := range columns {
+			out[i] = full[c]
+		}
+		return out
+	}
+
+	// Named benefits get one trailing column each, unless collapsed or a column
+	// subset was requested.
+	var named []string
+	if !opts.CollapseBenefits && !selected {
+		seen := make(map[string]bool)
+		for _, reg := range registers {
+			for name := range reg.NamedBenefits {
+				seen[name] = true
+			}
+		}
+		named = sortedKeys(seen)
+	}
+	// Statutory contributions, when the config has any, get one column each
+	// before the named benefits.
+	var contributed []string
+	if !selected {
+		contributed = contributionNames(registers)
+	}
+	// Custom earnings, tips, then PTO accruals get their columns after the
+	// contributions'.
+	custom := !selected && hasCustomEarnings(registers)
+	tips := !selected && hasTips(registers)
+	pto := !selected && hasPTO(registers)
+	extra := func(reg PayRegister, money func(Money) string) []string {
+		var cells []string
+		for _, name := range contributed {
+			cells = append(cells, money(contributionAmount(reg, name)))
+		}
+		if custom {
+			cells = append(cells, money(reg.CustomEarnings))
+		}
+		if tips {
+			cells = append(cells, money(reg.TipWages), money(reg.TipCredit))
+		}
+		if pto {
+			cells = append(cells, ptoCells(reg, money)...)
+		}
+		for _, name := range named {
+			cells = append(cells, money(reg.NamedBenefits[name]))
+		}
+		return cells
+	}
+
+	// Write header
+	if !opts.NoHeader {
+		header := project(registerHeader)
+		for _, name := range contributed {
+			header = append(header, contributionColumn(name))
+		}
+		if custom {
+			header = append(header, "Custom Earnings")
+		}
+		if tips {
+			header = append(header, tipColumns...)
+		}
+		if pto {
+			header = append(header, ptoColumns...)
+		}
+		if err := writer.Write(append(header, named...)); err != nil {
+			return nil, fmt.Errorf("cannot write header: %v", err)
+		}
+	}
+
+	// Write each record (amounts formatted per the row's currency)
+	for i, reg := range registers {
+		money := opts.formatter(reg.Currency)
+		other := reg.OtherBenefits
+		for _, name := range named {
+			other -= reg.NamedBenefits[name]
+		}
+		row := append(project(registerRow(reg, opts, other)), extra(reg, money)...)
+		if opts.Encoding == encodingLatin1 {
+			if err := checkLatin1(row); err != nil {
+				return nil, fmt.Errorf("cannot write row for employee %s period %s: %v", reg.EmployeeID, reg.PayPeriod, err)
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, fmt.Errorf("cannot write row: %v", err)
+		}
+		if opts.FlushEvery > 0 && (i+1)%opts.FlushEvery == 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.TotalsRow {
+		for _, total := range registerTotals(registers) {
+			money := opts.formatter(total.Currency)
+			other := total.OtherBenefits
+			for _, name := range named {
+				other -= total.NamedBenefits[name]
+			}
+			row := registerRow(total, opts, other)
+			row[0], row[4], row[slices.Index(registerHeader, "Row Type")] = "TOTAL", "", "TOTAL"
+			if err := writer.Write(append(project(row), extra(total, money)...)); err != nil {
+				return nil, fmt.Errorf("cannot write totals row: %v", err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(columns) < len(registerHeader) {
+		return project(registerHeader), nil
+	}
+	return nil, nil
+}
+
+// ndjsonFlushEvery is how many NDJSON lines are buffered before flushing, so a
+// consumer reading a pipe sees rows steadily rather than all at the end.
+const ndjsonFlushEvery = 100
+
+// writeRegisterNDJSON writes one JSON object per register per line (NDJSON), in
+// register order, flushing every ndjsonFlushEvery lines. Amounts are decimal numbers
+// in currency units. Like writeRegister, it writes a .meta.json sidecar unless
+// filename is "-".
+func writeRegisterNDJSON(registers []PayRegister, filename string, cfg TaxConfig) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	buffered := bufio.NewWriter(file)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+	for i, reg := range registers {
+		if err := encoder.Encode(reg); err != nil {
+			return fmt.Errorf("cannot write row: %v", err)
+		}
+		if (i+1)%ndjsonFlushEvery == 0 {
+			if err := buffered.Flush(); err != nil {
+				return fmt.Errorf("cannot write row: %v", err)
+			}
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("cannot write output file: %v", err)
+	}
+
+	if filename == stdoutName {
+		return nil
+	}
+	return writeRegisterMeta(filename, len(registers), cfg, nil)
+}
+
+func main() {
+	outputFile := flag.String("out", "payroll_register.csv", "output register path, or - for stdout")
+	strict := flag.Bool("strict", false, "treat any per-row computation error as fatal")
+	strictColumns := flag.Bool("strict-columns", false, "reject input rows whose field count differs from the header's, or that are too short to read, instead of skipping them")
+	failFast := flag.Bool("fail-fast", true, "stop at the first problem in the data (the default; see -collect-all)")
+	collectAll := flag.Bool("collect-all", false, "read and compute everything, then report every problem found (bad rows, unmatched records, failed rows) and exit non-zero if there were any")
+	serveAddr := flag.String("serve", "", "if set (e.g. :8080), serve POST /compute on this address instead of processing files")
+	reportFile := flag.String("report", "", "if set, write a JSON run report (timings, counts, warnings, errors, totals, settings) to this path, also when the run fails")
+	warningsFile := flag.String("warnings-file", "", "if set, write every warning, failed row and unmatched record as a CSV (Severity, Category, Employee ID, Pay Period, Message, Value) to this path, also when the run fails")
+	metricsAddr := flag.String("metrics-addr", "", "if set, serve Prometheus metrics for this run at http://ADDR/metrics")
+	metricsLinger := flag.Duration("metrics-linger", 30*time.Second, "how long to keep the metrics endpoint up after the run so it can be scraped")
+	maxRegularHours := flag.Int("max-regular-hours", 0, "flag rows with more regular hours than this (0 disables)")
+	maxOvertimeHours := flag.Int("max-overtime-hours", 0, "flag rows with more overtime hours than this (0 disables)")
+	maxTotalHours := flag.Int("max-total-hours", 0, "flag rows with more regular, overtime and double time hours together than this (0 disables)")
+	maxOvertimeRatio := flag.Float64("max-overtime-ratio", 0, "flag rows whose overtime and double time hours are more than this multiple of their regular hours, e.g. swapped columns (0 disables)")
+	hoursCapAction := flag.String("hours-cap-action", "warn", "what to do when an hours cap is exceeded: warn or error")
+	zeroRateAction := flag.String("zero-rate-action", "warn", "what to do when an hourly employee has hours but a zero rate: warn, error, or off")
+	deductionsExceedGross := flag.String("deductions-exceed-gross", "warn", "what to do when a row's deductions exceed its gross wages: warn, error, or off")
+	timeZone := flag.String("tz", "UTC", "IANA time zone (e.g. America/New_York) that pay periods and dates are interpreted in")
+	fuzzSafe := flag.Bool("fuzz-safe", false, "bound what the readers accept from untrusted inputs (fields per row, bytes per field, amount and hours magnitudes; see -max-*), rejecting rows beyond the bounds")
+	maxFields := flag.Int("max-fields", defaultInputLimits.MaxFields, "with -fuzz-safe, the most fields a row may have")
+	maxFieldBytes := flag.Int("max-field-bytes", defaultInputLimits.MaxFieldBytes, "with -fuzz-safe, the most bytes a field may have")
+	maxAmount := flag.String("max-amount", defaultInputLimits.MaxAmount.String(), "with -fuzz-safe, the largest amount (positive or negative) a cell may hold")
+	maxHours := flag.Int("max-hours", defaultInputLimits.MaxHours, "with -fuzz-safe, the most hours (positive or negative) a cell may hold")
+	allowCorrections := flag.Bool("allow-corrections", false, "accept negative hours as corrections to an earlier period; they are flagged with Row Type CORRECTION")
+	xlsxSheet := flag.String("xlsx-sheet", "", "worksheet to read from .xlsx inputs (default the first sheet)")
+	columnMapFile := flag.String("column-map", "", "JSON file renaming each dataset's source columns to the expected names")
+	nameOrder := flag.String("name-order", nameFirstLast, "how Employee Name is joined for sources with First Name and Last Name columns instead: first-last (Jordan Brown) or last-first (Brown, Jordan)")
+	trimFields := flag.Bool("trim-fields", true, "trim surrounding whitespace from every input cell")
+	flag.BoolVar(&foldKeyCase, "fold-key-case", false, "match Employee IDs and Pay Periods across files case-insensitively")
+	flag.StringVar(&periodLayout, "period-format", "", "Go time layout for PayPeriod values (e.g. 2006-01); auto-detected when empty")
+	period := flag.String("period", "", "only compute registers for this pay period")
+	var employees employeeList
+	flag.Var(&employees, "employee", "only compute registers for this employee ID; repeat for several")
+	employeesFile := flag.String("employees-file", "", "only compute registers for the employee IDs listed in this file, one per line (with -employee, for both)")
+	configFile := flag.String("config", "", "JSON tax config file; settings it omits keep their defaults")
+	taxTableFile := flag.String("tax-table", "", "optional CSV or JSON of per-year tax brackets, rates, wage bases and thresholds by jurisdiction, layered over -config's per-year tables; once any year is configured every period must fall in one")
+	initConfig := flag.String("init-config", "", "write a commented config file with every setting at its default to this path (- for stdout) and exit")
+	incrementalFile := flag.String("incremental", "", "prior register (csv or ndjson) to build on: recompute only the employees whose input records changed since the run that wrote it, carry the rest over, and write a .inputs.json manifest next to -out for the next run; computes everything when the prior or its manifest is missing or the flags or config changed")
+	cacheDir := flag.String("cache-dir", "", "if set, reuse the register from an earlier run with identical inputs and flags, caching each new register here")
+	noCache := flag.Bool("no-cache", false, "ignore -cache-dir for this run: always recompute and do not store")
+	compareConfig := flag.String("compare-config", "", "compute the register under -config (or the defaults) and under this config, write the per-employee differences to -compare-out, and exit")
+	compareOut := flag.String("compare-out", "config_comparison.csv", "output path for -compare-config")
+	grossUpNet := flag.String("gross-up", "", "if set, solve for the gross that nets this amount when paid on its own (a bonus), write that register line to -out as CSV, and exit")
+	grossUpLocality := flag.String("gross-up-locality", "", "work locality whose local tax -gross-up includes")
+	fxSummaryFile := flag.String("fx-summary", "", "if set, write per-currency totals converted to the reporting currency to this path")
+	explainTaxesFile := flag.String("explain-taxes", "", "if set, write each register line's taxes with the base, rate, and method each was computed with to this path")
+	projectionFile := flag.String("projection", "", "if set, write each employee's year projected from their latest period (amounts times periods per year, Social Security and contributions stopping at their wage bases as the register does) to this path")
+	w2PreviewFile := flag.String("w2-preview", "", "if set, write a simplified W-2 preview (boxes 1-6, 16, and 17) per employee and calendar year to this path")
+	ssWageBaseFile := flag.String("ss-wage-base", "", "if set, write each employee's year-to-date Social Security wages, the wage base, and what remains below it to this path")
+	employerCostFile := flag.String("employer-cost", "", "if set, write a per-employee fully-loaded employer cost report to this path")
+	statementFor := flag.String("statement", "", "if set, write a consolidated statement for this employee ID to -statement-out")
+	statementFrom := flag.String("statement-from", "", "first period the -statement covers (any pay-period format); open when empty")
+	statementTo := flag.String("statement-to", "", "last period the -statement covers (any pay-period format); open when empty")
+	statementOut := flag.String("statement-out", "-", "statement path: a PDF when it ends in .pdf, otherwise text; - for stdout")
+	paystubsDir := flag.String("paystubs-dir", "", "if set, write one PDF paystub per employee and period into this directory")
+	exportFile := flag.String("export", "", "if set, also write the register in a payroll service's import layout (see -export-mapping) to this path")
+	exportMapping := flag.String("export-mapping", "paydata", "layout for -export: a built-in mapping (paydata) or a JSON mapping file")
+	remittanceFile := flag.String("remittance", "", "if set, also write a per-agency tax remittance summary CSV to this path")
+	depositAllocationsFile := flag.String("deposit-allocations", "", "optional CSV of direct-deposit accounts per employee (Employee ID, Account, and Amount or Percent), split by -deposit-out")
+	depositOut := flag.String("deposit-out", "", "if set, write each register line's net pay split across the employee's -deposit-allocations accounts to this path")
+	nachaOut := flag.String("nacha-out", "", "if set, write the -deposit-allocations deposits as a NACHA (ACH) file of PPD credits to this path; needs -bank-info and the config's ach settings")
+	bankInfoFile := flag.String("bank-info", "", "CSV of employees' bank accounts for -nacha-out (Employee ID, Account, Routing Number, Account Number, Account Type)")
+	achEffectiveDate := flag.String("ach-effective-date", "", "settlement date (YYYY-MM-DD) for -nacha-out; defaults to the next weekday")
+	rateChangesFile := flag.String("rate-changes", "", "if set, write a report of hourly-rate changes between periods to this path")
+	benefitChangesFile := flag.String("benefit-changes", "", "if set, write a report of benefits that started, stopped, or changed between an employee's periods to this path")
+	benefitChangePercent := flag.Float64("benefit-change-percent", 10, "smallest change, in percent of the old amount, -benefit-changes reports as CHANGED (0 reports every change)")
+	periodGapsFile := flag.String("period-gaps", "", "if set, write a report of employees missing from some of the run's pay periods to this path")
+	expectedPeriods := flag.String("expected-periods", "", "comma-separated pay periods -period-gaps expects every employee in (default: every period in the payroll file)")
+	netRatioFile := flag.String("net-ratio-stats", "", "if set, write the mean, median and percentiles of employees' net-to-gross ratio, overall and per job title, to this path")
+	topN := flag.Int("top-n", 0, "if positive, write the N highest-paid employees over the run to -top-file")
+	topBy := flag.String("top-by", "gross", "metric that ranks -top-n: gross or net")
+	topFile := flag.String("top-file", "top_earners.csv", "output path for the -top-n report")
+	dailyTimeFile := flag.String("daily-time", "", "optional daily hours CSV (Employee ID, Pay Period, Date, Hours) to derive overtime from")
+	ytdSeedFile := flag.String("ytd-seed", "", "optional CSV of year-to-date totals from a prior payroll system (Employee ID, Year, Gross, Social Security Wages, Medicare Wages, Federal Withheld, and optionally Currency, Federal Taxable Wages and <contribution> Earnings) that wage bases and the year-to-date reports start from")
+	retroPayFile := flag.String("retro-pay", "", "optional CSV of raises approved after the fact (Employee ID, Old Rate, New Rate, From Period, Through Period); the rate difference owed on each covered register line's hours is written to -retro-out")
+	retroOut := flag.String("retro-out", "retro_pay.csv", "output path for -retro-pay")
+	ptoAccrualsFile := flag.String("pto-accruals", "", "optional CSV of paid time off accrual rates (Employee ID, Hours Per Period and/or Hours Per Hour Worked); listed employees' lines get PTO accrued hours, their value at the hourly rate, and year-to-date totals")
+	taxOverridesFile := flag.String("tax-overrides", "", "optional CSV of per-employee federal/state withholding overrides (Employee ID, Federal Rate, State Rate, Federal Amount, State Amount)")
+	midPeriodRatesFile := flag.String("mid-period-rates", "", "optional CSV of raises effective inside a pay period (Employee ID, Pay Period, Effective Date, New Rate, optional Hours Before)")
+	withholdingFloor := flag.String("withholding-floor", "0", "withhold no income tax from lines whose gross is below this amount")
+	withholdingFloorFICA := flag.Bool("withholding-floor-fica", false, "with -withholding-floor, waive Social Security and Medicare below the floor too")
+	trackArrears := flag.Bool("track-arrears", false, "withhold benefits and custom deductions only as far as net pay above -net-floor allows, carrying the rest to the employee's next period")
+	netFloor := flag.String("net-floor", "0", "net pay -track-arrears always leaves the employee")
+	splitJobsFlag := flag.Bool("split-jobs", false, "write one register line per job for employees with several jobs in a period, instead of one combined line")
+	overtimeMode := flag.String("overtime-mode", "hours", "how overtime is paid: hours (overtime hours at 1.5x) or amount (the time file's Overtime Pay column, added to gross as is)")
+	overtimeRules := flag.String("overtime-rules", "federal", "overtime rule set for daily hours: federal, california, or custom")
+	dailyOTAfter := flag.Int("daily-ot-after", 0, "custom rules: daily hours after which overtime applies (0 disables)")
+	dailyDTAfter := flag.Int("daily-dt-after", 0, "custom rules: daily hours after which double time applies (0 disables)")
+	weeklyOTAfter := flag.Int("weekly-ot-after", 40, "custom rules: weekly regular hours after which overtime applies (0 disables)")
+	inputNoHeader := flag.Bool("input-no-header", false, "input files have no header row; columns are read by position")
+	outputNoHeader := flag.Bool("output-no-header", false, "omit the header row from the output register")
+	delimiter := flag.String("delimiter", "", "input field separator (e.g. , or tab); auto-detected per file when empty")
+	openRetries := flag.Int("open-retries", 0, "retry opening an input file this many times on transient errors")
+	openRetryDelay := flag.Duration("open-retry-delay", 200*time.Millisecond, "wait before the first open retry; doubled after each attempt")
+	includeZeroHours := flag.Bool("include-zero-hours", false, "keep payroll employees missing from the time file, with zero hours")
+	collapseBenefits := flag.Bool("collapse-benefits", false, "fold named benefit columns into Other Benefits instead of itemizing them")
+	columns := flag.String("columns", "", "comma-separated register columns to write, in order (default: all with data in this run)")
+	allColumns := flag.Bool("all-columns", false, "write every register column, also those with no data in this run such as Double Time Hours or Local Tax, for a layout that does not vary by run")
+	generate := flag.Int("generate", 0, "write synthetic input CSVs for this many employees into -generate-dir and exit")
+	generatePeriods := flag.Int("generate-periods", 1, "number of monthly pay periods per generated employee")
+	generateDir := flag.String("generate-dir", ".", "directory for -generate output")
+	seed := flag.Int64("seed", 1, "random seed for -generate; the same seed produces identical files")
+	outputFormat := flag.String("format", "csv", "register output format, or a comma-separated list of them written side by side (named after -out with each format's extension): csv, ndjson, json, html, fixed (needs -fixed-spec), or long (earnings and deductions tables, one row per amount)")
+	fixedSpecFile := flag.String("fixed-spec", "", "JSON field layout for -format fixed")
+	taxRounding := flag.String("tax-rounding", roundPerTax, "how a line's taxes are rounded to the cent: per-tax (each tax on its own) or total (exact until the line's total tax is rounded, then divided among the taxes by largest remainder)")
+	earningsRounding := flag.String("earnings-rounding", roundEarningsComponents, "when overtime pay is rounded to the cent: components (each job's or rate's overtime on its own, as always) or gross (summed exact and rounded once)")
+	joinMode := flag.String("join", "inner", "which records get a register line: inner (payroll, time and benefits all present), left (every payroll record, missing time and benefits zero-filled), or outer (also time and benefits records with no payroll record, at a zero rate); zero-filled lines are flagged")
+	missingBenefits := flag.String("missing-benefits", "skip", "employees with no benefits record: skip them, or zero to compute with zero benefits")
+	roundGrossForTax := flag.Bool("round-gross-for-tax", false, "compute taxes on gross rounded to the nearest whole currency unit")
+	expectedNet := flag.String("expected-net", "", "if set, fail unless total net pay matches this amount within -cents-tolerance")
+	expectedNetFile := flag.String("expected-net-file", "", "CSV of expected net pay per period (Pay Period, Expected Net) to reconcile against")
+	netTolerance := flag.String("net-tolerance", "", "largest net pay difference -expected-net and -expected-net-file accept, overriding -cents-tolerance")
+	benefitsFrequency := flag.String("benefits-frequency", "period", "how benefit amounts are quoted: period, weekly, biweekly, semimonthly, monthly, or annual")
+	dedupeOutput := flag.String("dedupe-output", "off", "guard against several register lines for one employee and period: off, error (fail the run), or first (keep the first line)")
+	omitZero := flag.Bool("omit-zero-rows", false, "drop register lines whose gross, taxes, benefits, deductions and net are all zero (by default they are written)")
+	preview := flag.Int("preview", 0, "if positive, print the first N computed registers as a table")
+	tui := flag.Bool("tui", false, "show a live progress dashboard when stdout is a terminal")
+	normalizeIDs := flag.Int("normalize-ids", 0, "zero-pad numeric employee IDs to this width before joining (0 leaves IDs as written)")
+	since := flag.String("since", "", "only compute periods starting on or after this date (any pay-period format)")
+	until := flag.String("until", "", "only compute periods starting on or before this date (any pay-period format)")
+	flag.BoolVar(&noMkdir, "no-mkdir", false, "fail instead of creating missing output directories")
+	outputEncoding := flag.String("output-encoding", encodingUTF8, "character encoding of the CSV register: utf-8, utf-8-bom (with a byte order mark, for Excel and older Windows tools), or latin1 (fails on characters latin1 lacks)")
+	numberFormat := flag.String("number-format", numberDecimal2, "how amounts are written: decimal2, cents, or raw")
+	thousandsSep := flag.String("thousands-sep", "", "thousands separator for amounts in -preview, paystubs and statements, e.g. \",\" (the register is never grouped)")
+	decimalMark := flag.String("decimal-mark", ".", "decimal mark for amounts in -preview, paystubs and statements")
+	requireTaxEntry := flag.Bool("require-tax-entry", false, "fail when an employee's work locality is missing from the local tax table")
+	roundNetDollars := flag.Bool("round-net-dollars", false, "round each line's net pay to whole dollars for cash payout; the remainder is reported as netPayRoundingCarry")
+	carryNetRounding := flag.Bool("carry-net-rounding", false, "with -round-net-dollars, add each remainder to the employee's next period before rounding (implies -round-net-dollars)")
+	splitByPeriod := flag.Bool("split-by-period", false, "write one register file per pay period, named after -out (e.g. payroll_register_2024-06.csv)")
+	shards := flag.Int("shards", 0, "if positive, write the register as this many files, each employee in one shard by a hash of their ID (e.g. payroll_register_shard-1-of-4.csv)")
+	totalsRow := flag.Bool("totals-row", false, "append a TOTAL row per currency to the CSV register")
+	writeBuffer := flag.Int("write-buffer", 0, "CSV register write buffer size in bytes (0: the encoding/csv default of 4096, which also buffers in front of a smaller one)")
+	flushEvery := flag.Int("flush-every", 0, "flush the CSV register every N rows so output streams steadily (0: only at the end)")
+	anonymize := flag.Bool("anonymize", false, "replace employee IDs with salted hashes and names with pseudonyms on read, for sharing reproducers")
+	checkSSNFormat := flag.Bool("check-ssn-format", false, "reject payroll rows whose SSN column holds a malformed or never-issued number")
+	fullSSN := flag.Bool("full-ssn", false, "show employees' full SSNs in the register and paystubs instead of only the last four digits")
+	anonymizeSalt := flag.String("anonymize-salt", "", "salt for -anonymize; the same salt gives the same pseudonyms across runs (default: random per run)")
+	blankAsZero := flag.Bool("blank-as-zero", false, "read blank amount and hours cells in the input files as zero instead of rejecting the row")
+	combinedFile := flag.String("combined", "", "read payroll, time, and benefits from this one pre-joined file (all three files' columns, by header name) instead of the three files")
+	sortedInput := flag.Bool("sorted-input", false, "the payroll, time, and benefits files are each sorted by Employee ID|Pay Period: join them in one streaming pass, one employee at a time, instead of loading them whole (rows out of order are rejected)")
+	archiveFile := flag.String("archive", "", "read the payroll, time, and benefits files from this zip archive instead of the working directory")
+	benefitsMerge := flag.String("benefits-merge", mergeLast, "what to do with several benefits rows for one employee and period: last (keep the later row), sum, or error")
+	timeMerge := flag.String("time-merge", mergeLast, "what to do with several time rows for one employee, period and week: last (keep the later row), sum, or error")
+	auditLog := flag.String("audit-log", "", "append a hash-chained record of this run (input, config and output hashes, record count) to this file")
+	verifyAuditLog := flag.String("verify-audit-log", "", "check the hash chain of an -audit-log file and exit")
+	quiet := flag.Bool("quiet", false, "print nothing but errors: no timings, progress lines or warnings")
+	verbose := flag.Bool("verbose", false, "also log per-record detail, such as each unmatched record skipped")
+	priorYear := flag.String("compare-to-prior-year", "", "last year's register CSV(s), comma-separated: compare -current-year's year-to-date gross, taxes, net and headcount with them period by period, write the growth to -yoy-out, and exit")
+	currentYear := flag.String("current-year", "", "this year's register CSV(s), comma-separated, for -compare-to-prior-year")
+	yoyOut := flag.String("yoy-out", "yoy_growth.csv", "output path for -compare-to-prior-year")
+	verifyFile := flag.String("verify", "", "check an existing register CSV for internal arithmetic consistency and statutorily impossible taxes (against -config's rates) and exit")
+	centsTolerance := flag.Int("cents-tolerance", 1, "largest difference, in cents, every check accepts: -verify, -round-trip-check, -expected-net and -expected-net-file")
+	verifyTolerance := flag.Float64("verify-tolerance", 0, "largest difference -verify and -round-trip-check accept, in currency units, overriding -cents-tolerance")
+	roundTripCheck := flag.Bool("round-trip-check", false, "re-read the register after writing it and fail unless it matches what was computed")
+	redact := flag.String("redact", "", "hide the register's amounts for reviewers without compensation access: mask (every amount written as ***) or bucket (as the -redact-bucket range holding it); IDs, names, titles and hours are kept")
+	redactWidth := flag.String("redact-bucket", "1000", "range width for -redact bucket")
+	previewDiff := flag.Bool("preview-diff", false, "before overwriting an existing -out register (csv or ndjson), print how each employee's net pay differs from it and which employees were added or removed")
+	watch := flag.Bool("watch", false, "rerun whenever an input file (or -config, -daily-time, -tax-overrides) changes, until interrupted")
+	watchInterval := flag.Duration("watch-interval", 500*time.Millisecond, "how often -watch polls the input files")
+	watchDebounce := flag.Duration("watch-debounce", time.Second, "how long the input files must be unchanged before -watch reruns")
+	selfTest := flag.Bool("selftest", false, "run the built-in pipeline self-test on synthetic data and exit")
+	currencyCode := flag.String("currency", "", "currency code for output amounts (USD, CAD, EUR, GBP, JPY); default is plain two-decimal amounts")
+	flag.Usage = usageWithExitCodes
+	noFederal := flag.Bool("no-federal", false, "withhold no federal income tax in this run (e.g. for reimbursements); the column stays, at zero")
+	noState := flag.Bool("no-state", false, "withhold no state income tax in this run; the column stays, at zero")
+	noFICA := flag.Bool("no-fica", false, "withhold no Social Security or Medicare, and charge no employer share, in this run; the columns stay, at zero")
+	normalizePeriods := flag.Bool("normalize-period", false, "rewrite every Pay Period read in one canonical form (-canonical-period-format), so 2024-06, Jun 2024, 2024/06, and P2024-06 join as one period")
+	canonicalPeriodFormat := flag.String("canonical-period-format", "2006-01-02", "layout -normalize-period writes periods in; month periods keep a month layout (2006-01) when this one has a day. One of the auto-detected formats")
+	locale := flag.String("locale", "", "regional defaults for -delimiter, -thousands-sep, -decimal-mark, -currency, and day/month order in pay periods (en-US, en-CA, en-GB, de-DE, fr-FR, ja-JP); those flags still override it")
+	maxProcs := flag.Int("max-procs", 0, "use at most this many CPUs, to share a host politely (0: all of them)")
+	flag.Parse()
+	if *quiet && *verbose {
+		fatalf(exitUsage, "-quiet and -verbose cannot be used together")
+	}
+	if *quiet {
+		verbosity = verbosityQuiet
+	} else if *verbose {
+		verbosity = verbosityVerbose
+	}
+	if *locale != "" {
+		loc, err := lookupLocale(*locale)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -locale: %v", err)
+		}
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		for _, d := range []struct {
+			name  string
+			value *string
+			def   string
+		}{
+			{"delimiter", delimiter, loc.Delimiter},
+			{"thousands-sep", thousandsSep, loc.ThousandsSep},
+			{"decimal-mark", decimalMark, loc.DecimalMark},
+			{"currency", currencyCode, loc.Currency},
+		} {
+			if !explicit[d.name] {
+				*d.value = d.def
+			}
+		}
+		if !explicit["period-format"] {
+			useDateLayout(loc.DateLayout)
+		}
+	}
+	// -cents-tolerance is the one knob for every comparison; the older per-check
+	// tolerance flags still override it when given.
+	if *centsTolerance < 0 {
+		fatalf(exitUsage, "-cents-tolerance must not be negative")
+	}
+	verifyTol, netTol := Money(*centsTolerance), Money(*centsTolerance)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "verify-tolerance" {
+			verifyTol = Money(math.Round(*verifyTolerance * 100))
+		}
+	})
+	if *netTolerance != "" {
+		tol, err := parseMoney(*netTolerance)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -net-tolerance %q", *netTolerance)
+		}
+		netTol = tol
+	}
+	if verifyTol < 0 || netTol < 0 {
+		fatalf(exitUsage, "-verify-tolerance and -net-tolerance must not be negative")
+	}
+	// -max-procs caps the cores the run uses, the garbage collector's included.
+	// Anything that fans work out should size itself by runtime.GOMAXPROCS(0).
+	if *maxProcs < 0 {
+		fatalf(exitUsage, "-max-procs must not be negative")
+	}
+	if *maxProcs > 0 {
+		runtime.GOMAXPROCS(*maxProcs)
+		verbosef("GOMAXPROCS set to %d (%d CPUs available)", *maxProcs, runtime.NumCPU())
+	}
+	if loc, err := time.LoadLocation(*timeZone); err != nil {
+		fatalf(exitUsage, "Invalid -tz: %v", err)
+	} else {
+		periodLocation = loc
+	}
+
+	if *selfTest {
+		failures := runSelfTest()
+		for _, f := range failures {
+			fmt.Fprintf(os.Stderr, "selftest FAIL: %s\n", f)
+		}
+		if len(failures) > 0 {
+			os.Exit(exitFailure)
+		}
+		fmt.Println("selftest PASS")
+		return
+	}
+	if *verifyAuditLog != "" {
+		entries, err := readAuditLog(*verifyAuditLog)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error reading audit log: %v", err)
+		}
+		if n, err := verifyAuditChain(entries); err != nil {
+			fatalf(exitMismatch, "Audit log %s is broken at entry %d: %v", *verifyAuditLog, n+1, err)
+		}
+		fmt.Printf("audit log OK: %d entries chained\n", len(entries))
+		return
+	}
+	if *verifyFile != "" {
+		d, err := parseDelimiter(*delimiter)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -delimiter: %v", err)
+		}
+		opts := ReaderOptions{Delimiter: d}
+		// The configured FICA rates are what the taxes are checked against.
+		cfg := defaultTaxConfig()
+		if *configFile != "" {
+			if cfg, err = loadTaxConfig(*configFile); err != nil {
+				fatalf(inputExitCode(err), "Error loading config: %v", err)
+			}
+		}
+		if *taxTableFile != "" {
+			if err := cfg.applyTaxTable(*taxTableFile, opts); err != nil {
+				fatalf(inputExitCode(err), "Error loading tax table: %v", err)
+			}
+		}
+		problems, n, err := verifyRegisterFile(*verifyFile, opts, cfg, *overtimeMode == "amount", verifyTol)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error verifying register: %v", err)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "inconsistent: %s\n", p)
+		}
+		if len(problems) > 0 {
+			fatalf(exitMismatch, "%d inconsistency(ies) in %d register line(s)", len(problems), n)
+		}
+		fmt.Printf("verify OK: %d register line(s) consistent\n", n)
+		return
+	}
+	if *priorYear != "" {
+		if *currentYear == "" {
+			fatalf(exitUsage, "-compare-to-prior-year needs this year's registers in -current-year")
+		}
+		d, err := parseDelimiter(*delimiter)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -delimiter: %v", err)
+		}
+		opts := ReaderOptions{Delimiter: d}
+		prior, err := readRegisterSet(*priorYear, opts)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error reading prior year's registers: %v", err)
+		}
+		current, err := readRegisterSet(*currentYear, opts)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error reading current year's registers: %v", err)
+		}
+		rows, n := compareYears(prior, current)
+		if n == 0 {
+			fatalf(exitFailure, "No pay periods to compare: both years need register lines")
+		}
+		if extra := len(registerPeriods(current)) - n; extra > 0 {
+			logf("Warning: the prior year has only %d period(s); the current year's last %d are not compared", n, extra)
+		}
+		if err := writeYTDGrowth(rows, *yoyOut, defaultWriterOptions()); err != nil {
+			fatalf(exitFailure, "Error writing year-over-year report: %v", err)
+		}
+		fmt.Printf("Compared %d period(s) year over year; saved to %s\n", n, *yoyOut)
+		return
+	}
+	if *generate > 0 {
+		if err := generateData(*generateDir, *generate, *generatePeriods, *seed); err != nil {
+			fatalf(exitFailure, "Error generating data: %v", err)
+		}
+		fmt.Printf("Generated %d employees x %d periods in %s (seed %d)\n", *generate, *generatePeriods, *generateDir, *seed)
+		return
+	}
+	if *initConfig != "" {
+		if err := writeConfigTemplate(*initConfig); err != nil {
+			fatalf(exitFailure, "Error writing config template: %v", err)
+		}
+		if *initConfig != stdoutName {
+			fmt.Printf("Wrote default config to %s\n", *initConfig)
+		}
+		return
+	}
+
+	if *reportFile != "" {
+		activeReport = newRunReport(*reportFile)
+	}
+	if *warningsFile != "" {
+		activeWarnings = newWarningsFile(*warningsFile)
+	}
+
+	// File names (adjust as needed)
+	payrollFile := inputPath("payroll_data.csv")
+	timeFile := inputPath("time_data.csv")
+	benefitsFile := inputPath("benefits.csv")
+	if *watch {
+		watched := []string{payrollFile, timeFile, benefitsFile}
+		if *archiveFile != "" {
+			watched = []string{*archiveFile}
+		}
+		if *combinedFile != "" {
+			watched = []string{*combinedFile}
+		}
+		for _, f := range []string{*configFile, *taxTableFile, *dailyTimeFile, *taxOverridesFile, *midPeriodRatesFile, *ytdSeedFile, *ptoAccrualsFile} {
+			if f != "" {
+				watched = append(watched, f)
+			}
+		}
+		if slices.ContainsFunc(watched, isRemote) {
+			fatalf(exitUsage, "-watch can only watch local files, not URLs")
+		}
+		if err := watchAndRerun(watched, *watchInterval, *watchDebounce, os.Stdout); err != nil {
+			fatalf(exitFailure, "Error watching inputs: %v", err)
+		}
+		return
+	}
+	taxConfig := defaultTaxConfig()
+	if *configFile != "" {
+		cfg, err := loadTaxConfig(*configFile)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error loading config: %v", err)
+		}
+		taxConfig = cfg
+	}
+	if *taxTableFile != "" {
+		d, err := parseDelimiter(*delimiter)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -delimiter: %v", err)
+		}
+		if err := taxConfig.applyTaxTable(*taxTableFile, ReaderOptions{Delimiter: d}); err != nil {
+			fatalf(inputExitCode(err), "Error loading tax table: %v", err)
+		}
+	}
+	if *nachaOut != "" && taxConfig.ACH == nil {
+		fatalf(exitUsage, "-nacha-out needs the employer's ach settings in -config")
+	}
+	writerOpts := defaultWriterOptions()
+	currency, err := lookupCurrency(*currencyCode)
+	if err != nil {
+		fatalf(exitUsage, "Invalid -currency: %v", err)
+	}
+	writerOpts.Currency = currency
+	writerOpts.NoHeader = *outputNoHeader
+	formats, err := parseFormats(*outputFormat)
+	if err != nil {
+		fatalf(exitUsage, "Invalid -format: %v", err)
+	}
+	if len(formats) > 1 && *outputFile == stdoutName {
+		fatalf(exitUsage, "-format with several formats needs a file name for -out, not -")
+	}
+	if slices.Contains(formats, "long") && *outputFile == stdoutName {
+		fatalf(exitUsage, "-format long writes two tables and needs a file name for -out, not -")
+	}
+	var fixedSpec FieldSpec
+	if slices.Contains(formats, "fixed") {
+		if *fixedSpecFile == "" {
+			fatalf(exitUsage, "-format fixed needs -fixed-spec")
+		}
+		if fixedSpec, err = loadFieldSpec(*fixedSpecFile); err != nil {
+			fatalf(inputExitCode(err), "Error loading fixed-width spec: %v", err)
+		}
+	}
+	// -round-trip-check re-reads the formats it can: csv and ndjson.
+	rereadable := func(format string) bool { return format == "csv" || format == "ndjson" }
+	var exporter RegisterExporter
+	if *exportFile != "" {
+		mapping, err := loadExportMapping(*exportMapping)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error loading export mapping: %v", err)
+		}
+		exporter = mapping
+	}
+	if *roundTripCheck {
+		switch {
+		case !slices.ContainsFunc(formats, rereadable):
+			fatalf(exitUsage, "-round-trip-check cannot re-read -format %s", *outputFormat)
+		case *outputFile == stdoutName:
+			fatalf(exitUsage, "-round-trip-check cannot re-read a register written to stdout")
+		case *outputNoHeader && slices.Contains(formats, "csv"):
+			fatalf(exitUsage, "-round-trip-check needs the header row to re-read the register")
+		}
+	}
+	// diffFormat is the -format -preview-diff re-reads the previous output in.
+	var diffFormat string
+	if *previewDiff {
+		if i := slices.IndexFunc(formats, rereadable); i >= 0 {
+			diffFormat = formats[i]
+		}
+		switch {
+		case diffFormat == "":
+			fatalf(exitUsage, "-preview-diff cannot re-read -format %s", *outputFormat)
+		case *outputFile == stdoutName:
+			fatalf(exitUsage, "-preview-diff needs a file name for -out, not -")
+		case *splitByPeriod || *shards > 0:
+			fatalf(exitUsage, "-preview-diff cannot be used with -split-by-period or -shards")
+		case *outputNoHeader && diffFormat == "csv":
+			fatalf(exitUsage, "-preview-diff needs the header row to re-read the register")
+		}
+	}
+	writerOpts.CollapseBenefits = *collapseBenefits
+	writerOpts.TotalsRow = *totalsRow
+	writerOpts.AllColumns = *allColumns
+	if *writeBuffer < 0 || *flushEvery < 0 {
+		fatalf(exitUsage, "-write-buffer and -flush-every must not be negative")
+	}
+	writerOpts.BufferSize, writerOpts.FlushEvery = *writeBuffer, *flushEvery
+	if *splitByPeriod && *outputFile == stdoutName {
+		fatalf(exitUsage, "-split-by-period needs a file name for -out, not -")
+	}
+	if *shards < 0 {
+		fatalf(exitUsage, "-shards must not be negative")
+	}
+	if *shards > 0 && *outputFile == stdoutName {
+		fatalf(exitUsage, "-shards needs a file name for -out, not -")
+	}
+	if (*depositAllocationsFile == "") != (*depositOut == "" && *nachaOut == "") {
+		fatalf(exitUsage, "-deposit-allocations must be used with -deposit-out or -nacha-out, and they with it")
+	}
+	if (*nachaOut == "") != (*bankInfoFile == "") {
+		fatalf(exitUsage, "-nacha-out and -bank-info must be used together")
+	}
+	achEffective := nextBusinessDay(time.Now())
+	if *achEffectiveDate != "" {
+		if achEffective, err = time.Parse("2006-01-02", *achEffectiveDate); err != nil {
+			fatalf(exitUsage, "-ach-effective-date: want YYYY-MM-DD, got %q", *achEffectiveDate)
+		}
+	}
+	if *shards > 0 && *splitByPeriod {
+		fatalf(exitUsage, "-shards and -split-by-period cannot be used together")
+	}
+	switch *numberFormat {
+	case numberDecimal2, numberCents, numberRaw:
+		writerOpts.NumberFormat = *numberFormat
+	default:
+		fatalf(exitUsage, "Invalid -number-format %q (want decimal2, cents, or raw)", *numberFormat)
+	}
+	if *decimalMark == "" || *decimalMark == *thousandsSep || strings.ContainsAny(*decimalMark+*thousandsSep, "0123456789-") {
+		fatalf(exitUsage, "Invalid -thousands-sep %q / -decimal-mark %q: they must differ and contain no digits", *thousandsSep, *decimalMark)
+	}
+	writerOpts.Display = DisplayFormat{ThousandsSep: *thousandsSep, DecimalMark: *decimalMark}
+	// registerOpts are writerOpts plus -redact, which hides amounts in the register
+	// only; the other reports are not for sharing.
+	registerOpts := writerOpts
+	switch *redact {
+	case "":
+	case redactMask, redactBucket:
+		registerOpts.Redact.Scheme = *redact
+		if registerOpts.Redact.Bucket, err = parseMoney(*redactWidth); err != nil || registerOpts.Redact.Bucket <= 0 {
+			fatalf(exitUsage, "Invalid -redact-bucket %q: want a positive amount", *redactWidth)
+		}
+		for _, format := range formats {
+			if format != "csv" && format != "html" && format != "long" {
+				fatalf(exitUsage, "-redact cannot hide amounts in -format %s (want csv, html, or long)", format)
+			}
+		}
+		switch {
+		case *roundTripCheck:
+			fatalf(exitUsage, "-redact cannot be used with -round-trip-check: a redacted register does not read back")
+		case *previewDiff:
+			fatalf(exitUsage, "-redact cannot be used with -preview-diff: a redacted register does not read back")
+		}
+	default:
+		fatalf(exitUsage, "Invalid -redact %q (want mask or bucket)", *redact)
+	}
+	switch *outputEncoding {
+	case encodingUTF8, encodingUTF8BOM:
+	case encodingLatin1:
+		// The readers take UTF-8, so a latin1 register does not read back.
+		switch {
+		case *roundTripCheck && slices.Contains(formats, "csv"):
+			fatalf(exitUsage, "-output-encoding latin1 cannot be used with -round-trip-check")
+		case diffFormat == "csv":
+			fatalf(exitUsage, "-output-encoding latin1 cannot be used with -preview-diff")
+		}
+	default:
+		fatalf(exitUsage, "Invalid -output-encoding %q (want utf-8, utf-8-bom, or latin1)", *outputEncoding)
+	}
+	registerOpts.Encoding = *outputEncoding
+	if *incrementalFile != "" {
+		// Carried-over lines are re-read from the prior register, so it must hold
+		// everything the run writes, in a form that reads back.
+		if ok, blocker := cacheableRun(); !ok {
+			fatalf(exitUsage, "-incremental cannot be used with -%s: carried-over lines lack what it needs", blocker)
+		}
+		switch {
+		case len(formats) != 1 || !rereadable(formats[0]):
+			fatalf(exitUsage, "-incremental needs a single -format it can re-read: csv or ndjson")
+		case *outputFile == stdoutName:
+			fatalf(exitUsage, "-incremental needs a file name for -out, not -")
+		case *sortedInput || *cacheDir != "":
+			fatalf(exitUsage, "-incremental cannot be used with -sorted-input or -cache-dir")
+		case formats[0] == "csv" && (*outputNoHeader || *columns != "" || *redact != "" || writerOpts.NumberFormat == numberCents || *outputEncoding == encodingLatin1):
+			fatalf(exitUsage, "-incremental needs a complete csv register that reads back: no -no-header, -columns, -redact, -number-format cents or -output-encoding latin1")
+		}
+	}
+	switch *benefitsMerge {
+	case mergeLast, mergeSum, mergeError:
+	default:
+		fatalf(exitUsage, "Invalid -benefits-merge %q (want last, sum, or error)", *benefitsMerge)
+	}
+	switch *timeMerge {
+	case mergeLast, mergeSum, mergeError:
+	default:
+		fatalf(exitUsage, "Invalid -time-merge %q (want last, sum, or error)", *timeMerge)
+	}
+	if *overtimeMode != "hours" && *overtimeMode != "amount" {
+		fatalf(exitUsage, "Invalid -overtime-mode %q (want hours or amount)", *overtimeMode)
+	}
+	if *auditLog != "" && *outputFile == stdoutName {
+		fatalf(exitUsage, "-audit-log needs a file name for -out, not -, to hash the register")
+	}
+	if *combinedFile != "" && *archiveFile != "" {
+		fatalf(exitUsage, "-combined and -archive cannot be used together")
+	}
+	if *sortedInput {
+		// These need every employee's records at once.
+		for name, set := range map[string]bool{"combined": *combinedFile != "", "daily-time": *dailyTimeFile != "", "compare-config": *compareConfig != "",
+			"period-gaps": *periodGapsFile != "", "rate-changes": *rateChangesFile != "", "benefit-changes": *benefitChangesFile != ""} {
+			if set {
+				fatalf(exitUsage, "-sorted-input cannot be used with -%s", name)
+			}
+		}
+	}
+	switch *dedupeOutput {
+	case "off", "error", "first":
+	default:
+		fatalf(exitUsage, "Invalid -dedupe-output %q (want off, error, or first)", *dedupeOutput)
+	}
+	if *topBy != "gross" && *topBy != "net" {
+		fatalf(exitUsage, "Invalid -top-by %q (want gross or net)", *topBy)
+	}
+	if *benefitChangePercent < 0 {
+		fatalf(exitUsage, "-benefit-change-percent must not be negative")
+	}
+	if *columns != "" {
+		writerOpts.Columns = strings.Split(*columns, ",")
+		if _, err := selectColumns(writerOpts.Columns); err != nil {
+			fatalf(exitUsage, "Invalid -columns: %v", err)
+		}
+	}
+	if *openRetries < 0 || *openRetryDelay < 0 {
+		fatalf(exitUsage, "-open-retries and -open-retry-delay must not be negative")
+	}
+	readerOpts := ReaderOptions{NoHeader: *inputNoHeader, OpenRetries: *openRetries, OpenRetryDelay: *openRetryDelay, IDWidth: *normalizeIDs, BlankAsZero: *blankAsZero, BenefitsMerge: *benefitsMerge, TimeMerge: *timeMerge, KeepWhitespace: !*trimFields, AllowCorrections: *allowCorrections, StrictColumns: *strictColumns, Sheet: *xlsxSheet, CheckSSN: *checkSSNFormat}
+	if *normalizePeriods {
+		// Normalized periods are re-parsed later by auto-detection, so the layout
+		// must be one it knows, and -period-format would pin every input to one.
+		if periodLayout != "" {
+			fatalf(exitUsage, "-normalize-period and -period-format cannot be used together")
+		}
+		if !slices.Contains(periodLayouts, *canonicalPeriodFormat) {
+			fatalf(exitUsage, "Invalid -canonical-period-format %q: want one of %s", *canonicalPeriodFormat, strings.Join(periodLayouts, ", "))
+		}
+		readerOpts.PeriodLayout = *canonicalPeriodFormat
+	}
+	if *fuzzSafe {
+		limit, err := parseMoney(*maxAmount)
+		if err != nil || limit <= 0 || *maxFields <= 0 || *maxFieldBytes <= 0 || *maxHours <= 0 {
+			fatalf(exitUsage, "-max-fields, -max-field-bytes, -max-amount and -max-hours must be positive")
+		}
+		readerOpts.Limits = InputLimits{MaxFields: *maxFields, MaxFieldBytes: *maxFieldBytes, MaxAmount: limit, MaxHours: *maxHours}
+	}
+	// problems collects instead of stopping under -collect-all; nil means fail fast.
+	var problems *problemLog
+	if *collectAll {
+		explicitFailFast := false
+		flag.Visit(func(f *flag.Flag) { explicitFailFast = explicitFailFast || f.Name == "fail-fast" })
+		if *failFast && explicitFailFast {
+			fatalf(exitUsage, "-fail-fast and -collect-all cannot be used together")
+		}
+		problems = &problemLog{}
+		readerOpts.Problems = problems
+	}
+	// fail ends the run, or under -collect-all records the problem and carries on.
+	fail := func(stage string, code int, format string, err error) {
+		if problems == nil {
+			fatalf(code, format, err)
+		}
+		problems.add(stage, code, err)
+	}
+	if *anonymize {
+		readerOpts.AnonymizeSalt = []byte(*anonymizeSalt)
+		if *anonymizeSalt == "" {
+			readerOpts.AnonymizeSalt = make([]byte, 32)
+			if _, err := rand.Read(readerOpts.AnonymizeSalt); err != nil {
+				fatalf(exitFailure, "Cannot generate anonymization salt: %v", err)
+			}
+		}
+	}
+	if *columnMapFile != "" {
+		if readerOpts.ColumnMapping, err = loadColumnMapping(*columnMapFile); err != nil {
+			fatalf(inputExitCode(err), "Error loading column map: %v", err)
+		}
+	}
+	switch *nameOrder {
+	case nameFirstLast, nameLastFirst:
+		readerOpts.NameOrder = *nameOrder
+	default:
+		fatalf(exitUsage, "Invalid -name-order %q (want first-last or last-first)", *nameOrder)
+	}
+	if readerOpts.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		fatalf(exitUsage, "Invalid -delimiter: %v", err)
+	}
+	computeOpts := defaultComputeOptions()
+	roster := []string(employees)
+	if *employeesFile != "" {
+		ids, err := readEmployeesFile(*employeesFile, readerOpts)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error reading -employees-file: %v", err)
+		}
+		roster = append(roster, ids...)
+	}
+	if len(roster) > 0 {
+		computeOpts.Employees = employeeSet(roster, readerOpts)
+	}
+	if *period != "" {
+		if computeOpts.Period, err = readerOpts.period(*period); err != nil {
+			fatalf(exitUsage, "Invalid -period: %v", err)
+		}
+	}
+	if *since != "" {
+		if computeOpts.Since, err = parsePeriod(*since); err != nil {
+			fatalf(exitUsage, "Invalid -since: %v", err)
+		}
+	}
+	if *until != "" {
+		if computeOpts.Until, err = parsePeriod(*until); err != nil {
+			fatalf(exitUsage, "Invalid -until: %v", err)
+		}
+	}
+	computeOpts.MaxRegularHours = *maxRegularHours
+	computeOpts.MaxOvertimeHours = *maxOvertimeHours
+	computeOpts.MaxTotalHours = *maxTotalHours
+	if *maxOvertimeRatio < 0 || math.IsNaN(*maxOvertimeRatio) || math.IsInf(*maxOvertimeRatio, 0) {
+		fatalf(exitUsage, "-max-overtime-ratio must be a non-negative number, got %g", *maxOvertimeRatio)
+	}
+	computeOpts.MaxOvertimeRatio = *maxOvertimeRatio
+	computeOpts.IncludeZeroHours = *includeZeroHours
+	computeOpts.RoundGrossForTax = *roundGrossForTax
+	computeOpts.RequireTaxEntry = *requireTaxEntry
+	computeOpts.FullSSN = *fullSSN
+	computeOpts.NoFederal = *noFederal
+	computeOpts.NoState = *noState
+	computeOpts.NoFICA = *noFICA
+	computeOpts.SplitJobs = *splitJobsFlag
+	computeOpts.TrackArrears = *trackArrears
+	if computeOpts.WithholdingFloor, err = parseMoney(*withholdingFloor); err != nil {
+		fatalf(exitUsage, "Invalid -withholding-floor: %v", err)
+	}
+	computeOpts.WithholdingFloorFICA = *withholdingFloorFICA
+	if computeOpts.NetFloor, err = parseMoney(*netFloor); err != nil {
+		fatalf(exitUsage, "Invalid -net-floor: %v", err)
+	}
+	computeOpts.RoundNetDollars = *roundNetDollars || *carryNetRounding
+	computeOpts.CarryNetRounding = *carryNetRounding
+	perYear, ok := benefitFrequencies[strings.ToLower(*benefitsFrequency)]
+	if !ok {
+		fatalf(exitUsage, "Invalid -benefits-frequency %q (want period, weekly, biweekly, semimonthly, monthly, or annual)", *benefitsFrequency)
+	}
+	if perYear > 0 && taxConfig.PeriodsPerYear <= 0 {
+		fatalf(exitUsage, "-benefits-frequency needs a positive periodsPerYear in the tax config")
+	}
+	computeOpts.BenefitsPerYear = perYear
+	switch *taxRounding {
+	case roundPerTax, roundTotal:
+		computeOpts.TaxRounding = *taxRounding
+	default:
+		fatalf(exitUsage, "Invalid -tax-rounding %q (want per-tax or total)", *taxRounding)
+	}
+	switch *earningsRounding {
+	case roundEarningsComponents, roundEarningsGross:
+		computeOpts.EarningsRounding = *earningsRounding
+	default:
+		fatalf(exitUsage, "Invalid -earnings-rounding %q (want components or gross)", *earningsRounding)
+	}
+	switch *joinMode {
+	case "inner":
+	case joinLeft, joinOuter:
+		computeOpts.Join = *joinMode
+	default:
+		fatalf(exitUsage, "Invalid -join %q (want inner, left, or outer)", *joinMode)
+	}
+	switch *missingBenefits {
+	case "skip":
+	case "zero":
+		computeOpts.ZeroMissingBenefits = true
+	default:
+		fatalf(exitUsage, "Invalid -missing-benefits %q (want skip or zero)", *missingBenefits)
+	}
+	if computeOpts.HoursCapAction, err = parseCheckAction(*hoursCapAction); err != nil {
+		fatalf(exitUsage, "Invalid -hours-cap-action: %v", err)
+	}
+	if *zeroRateAction != "off" {
+		if computeOpts.ZeroRateAction, err = parseCheckAction(*zeroRateAction); err != nil {
+			fatalf(exitUsage, "Invalid -zero-rate-action: %v", err)
+		}
+	}
+	if *deductionsExceedGross != "off" {
+		if computeOpts.DeductionsExceedGrossAction, err = parseCheckAction(*deductionsExceedGross); err != nil {
+			fatalf(exitUsage, "Invalid -deductions-exceed-gross: %v", err)
+		}
+	}
+	custom := OvertimeRules{DailyOvertimeAfter: *dailyOTAfter, DailyDoubleTimeAfter: *dailyDTAfter, WeeklyOvertimeAfter: *weeklyOTAfter}
+	if computeOpts.OvertimeRules, err = lookupOvertimeRules(*overtimeRules, custom); err != nil {
+		fatalf(exitUsage, "Invalid -overtime-rules: %v", err)
+	}
+	computeOpts.OvertimeAmounts = *overtimeMode == "amount"
+	readerOpts.RequireOvertimePay = computeOpts.OvertimeAmounts
+
+	if *serveAddr != "" {
+		// Server mode: no input files are read here; each request brings its own.
+		if *dailyTimeFile != "" {
+			fatalf(exitUsage, "-daily-time cannot be used with -serve")
+		}
+		if *midPeriodRatesFile != "" {
+			if computeOpts.MidPeriodRates, err = readMidPeriodRates(*midPeriodRatesFile, readerOpts); err != nil {
+				fatalf(inputExitCode(err), "Error reading mid-period rates: %v", err)
+			}
+		}
+		if *taxOverridesFile != "" {
+			if computeOpts.TaxOverrides, err = readTaxOverrides(*taxOverridesFile, readerOpts); err != nil {
+				fatalf(inputExitCode(err), "Error reading tax overrides: %v", err)
+			}
+		}
+		logf("Serving POST /compute on %s", *serveAddr)
+		err := serveRegisters(*serveAddr, registerServer{cfg: taxConfig, readerOpts: readerOpts, opts: computeOpts})
+		fatalf(exitFailure, "Error serving: %v", err)
+	}
+
+	metrics := newRunMetrics()
+	if *metricsAddr != "" {
+		if err := serveMetrics(*metricsAddr, metrics); err != nil {
+			fatalf(exitFailure, "Error starting metrics endpoint: %v", err)
+		}
+	}
+
+	// Progress messages go to stderr when the register itself is going to stdout,
+	// so they don't corrupt piped CSV.
+	var status io.Writer = os.Stdout
+	if *outputFile == stdoutName {
+		status = os.Stderr
+	}
+	if verbosity == verbosityQuiet {
+		status = io.Discard
+	}
+	// The dashboard replaces the plain progress lines; without a terminal (or when
+	// the register itself is on stdout) the plain output is kept.
+	var dash *dashboard
+	if *tui && *outputFile != stdoutName && isTerminal(os.Stdout) {
+		dash = newDashboard(os.Stdout, "read", "compute", "write")
+		status = io.Discard
+		computeOpts.Progress = dash.observe
+	}
+
+	if *grossUpNet != "" {
+		// Gross-up mode: no input files are read. The line is for the one -employee
+		// named, if any, in -period, under that period's config.
+		target, err := parseMoney(*grossUpNet)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -gross-up: %v", err)
+		}
+		payroll := PayrollRecord{EmployeeID: "GROSS-UP", PayPeriod: computeOpts.Period, WorkLocality: *grossUpLocality}
+		if len(roster) == 1 {
+			payroll.EmployeeID = roster[0]
+		}
+		periodCfg, err := taxConfig.forPeriod(payroll.PayPeriod)
+		if err != nil {
+			fatalf(exitUsage, "Error selecting the config for -gross-up: %v", err)
+		}
+		reg, err := grossUpRow(target, payroll, periodCfg, computeOpts)
+		if err != nil {
+			fatalf(exitFailure, "Error grossing up %s: %v", target, err)
+		}
+		if err := writeRegister([]PayRegister{reg}, *outputFile, periodCfg, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing gross-up register: %v", err)
+		}
+		fmt.Fprintf(status, "Net pay %s needs gross pay %s (nets %s); saved to %s\n", target, reg.GrossWages, reg.NetPay, *outputFile)
+		return
+	}
+
+	// Start total timer.
+	totalStart := time.Now()
+	if activeReport != nil {
+		activeReport.TaxConfig = &taxConfig
+		activeReport.OutputFile = *outputFile
+	}
+
+	// inputs are every file the register depends on, for the cache and the audit log.
+	inputs := []string{payrollFile, timeFile, benefitsFile}
+	if *archiveFile != "" {
+		inputs = []string{*archiveFile}
+	}
+	if *combinedFile != "" {
+		inputs = []string{*combinedFile}
+	}
+	for _, f := range []string{*configFile, *taxTableFile, *dailyTimeFile, *taxOverridesFile, *midPeriodRatesFile, *fixedSpecFile, *columnMapFile, *employeesFile, *ytdSeedFile, *ptoAccrualsFile} {
+		if f != "" {
+			inputs = append(inputs, f)
+		}
+	}
+
+	cacheKey := ""
+	if *cacheDir != "" && !*noCache {
+		if ok, blocker := cacheableRun(); !ok {
+			fmt.Fprintf(status, "Not using the cache: -%s produces output it does not hold\n", blocker)
+		} else if len(formats) > 1 {
+			fmt.Fprintf(status, "Not using the cache: it holds one register file, -format lists %d\n", len(formats))
+		} else {
+			if cacheKey, err = runCacheKey(inputs); err != nil {
+				fatalf(exitFailure, "Error hashing inputs for the cache: %v", err)
+			}
+			hit, err := restoreFromCache(*cacheDir, cacheKey, *outputFile)
+			if err != nil {
+				fatalf(exitFailure, "Error reading cache: %v", err)
+			}
+			if hit {
+				fmt.Fprintf(status, "Inputs unchanged; pay register restored from cache to %s\n", *outputFile)
+				if activeReport != nil {
+					activeReport.FromCache = true
+				}
+				activeReport.finish(0, "")
+				return
+			}
+		}
+	}
+
+	// Step 1: Read Input Files
+	readStart := time.Now()
+	dash.startPhase("read")
+	inputOpts := readerOpts
+	if *archiveFile != "" {
+		// zip needs random access, so a remote archive is downloaded whole first.
+		data, err := readInput(*archiveFile, readerOpts)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error opening archive: %v", err)
+		}
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			fatalf(exitParseError, "Error opening archive: %v", err)
+		}
+		entries, err := findArchiveInputs(archive)
+		if err != nil {
+			fatalf(exitInputNotFound, "Error reading archive %s: %v", *archiveFile, err)
+		}
+		inputOpts.Archive = archive
+		payrollFile, timeFile, benefitsFile = entries["payroll"], entries["time"], entries["benefits"]
+	}
+	inputsRead := true
+	var payrollMap map[string]PayrollRecord
+	var timeMap map[string]TimeRecord
+	var benefitsMap map[string]BenefitsRecord
+	if *combinedFile != "" {
+		if payrollMap, timeMap, benefitsMap, err = readCombinedRecords(*combinedFile, inputOpts); err != nil {
+			fail("combined", inputExitCode(err), "Error reading combined records: %v", err)
+			timeMap, inputsRead = make(map[string]TimeRecord), false
+		}
+	} else if !*sortedInput {
+		if payrollMap, err = readPayrollRecords(payrollFile, inputOpts); err != nil {
+			fail("payroll", inputExitCode(err), "Error reading payroll records: %v", err)
+			inputsRead = false
+		}
+		if timeMap, err = readTimeRecords(timeFile, inputOpts); err != nil {
+			fail("time", inputExitCode(err), "Error reading time records: %v", err)
+			timeMap, inputsRead = make(map[string]TimeRecord), false
+		}
+		if benefitsMap, err = readBenefitsRecords(benefitsFile, inputOpts); err != nil {
+			fail("benefits", inputExitCode(err), "Error reading benefits records: %v", err)
+			inputsRead = false
+		}
+	}
+
+	if *dailyTimeFile != "" {
+		if err := readDailyTimeRecords(*dailyTimeFile, timeMap, readerOpts); err != nil {
+			fail("daily time", inputExitCode(err), "Error reading daily time records: %v", err)
+		}
+	}
+	if *midPeriodRatesFile != "" {
+		if computeOpts.MidPeriodRates, err = readMidPeriodRates(*midPeriodRatesFile, readerOpts); err != nil {
+			fail("mid-period rates", inputExitCode(err), "Error reading mid-period rates: %v", err)
+		}
+	}
+	if *taxOverridesFile != "" {
+		if computeOpts.TaxOverrides, err = readTaxOverrides(*taxOverridesFile, readerOpts); err != nil {
+			fail("tax overrides", inputExitCode(err), "Error reading tax overrides: %v", err)
+		}
+	}
+	if *ytdSeedFile != "" {
+		if computeOpts.YTDSeed, err = readYTDSeed(*ytdSeedFile, readerOpts); err != nil {
+			fail("ytd seed", inputExitCode(err), "Error reading YTD seed: %v", err)
+		}
+	}
+	if *ptoAccrualsFile != "" {
+		if computeOpts.PTOAccruals, err = readPTOAccruals(*ptoAccrualsFile, readerOpts); err != nil {
+			fail("pto accruals", inputExitCode(err), "Error reading PTO accruals: %v", err)
+		}
+	}
+	var retroRaises map[string][]RetroRaise
+	if *retroPayFile != "" {
+		if retroRaises, err = readRetroRaises(*retroPayFile, readerOpts); err != nil {
+			fail("retro pay", inputExitCode(err), "Error reading retro pay raises: %v", err)
+		}
+	}
+	var depositAllocations map[string][]DepositAllocation
+	if *depositAllocationsFile != "" {
+		if depositAllocations, err = readDepositAllocations(*depositAllocationsFile, readerOpts); err != nil {
+			fail("deposit allocations", inputExitCode(err), "Error reading deposit allocations: %v", err)
+		}
+	}
+	var bankInfo map[string]BankAccount
+	if *bankInfoFile != "" {
+		if bankInfo, err = readBankInfo(*bankInfoFile, readerOpts); err != nil {
+			fail("bank info", inputExitCode(err), "Error reading bank info: %v", err)
+		}
+	}
+	// With -sorted-input reading and computing are one pass, done here, once the
+	// compute options are complete; the maps stay empty.
+	var joined *SortedJoin
+	if *sortedInput {
+		join := computeSortedRegister(payrollFile, timeFile, benefitsFile, inputOpts, taxConfig, computeOpts)
+		for _, kind := range []string{"payroll", "time", "benefits"} {
+			if err := join.Errors[kind]; err != nil {
+				fail(kind, inputExitCode(err), "Error reading "+kind+" records: %v", err)
+				inputsRead = false
+			}
+		}
+		joined = &join
+	}
+	// Unmatched records are only worth listing when every input could be read;
+	// otherwise they are all unmatched.
+	unmatched := func() []error {
+		if joined != nil {
+			return joined.Unmatched
+		}
+		return unmatchedRecords(payrollMap, timeMap, benefitsMap, taxConfig, computeOpts)
+	}
+	if problems != nil && inputsRead {
+		for _, err := range unmatched() {
+			problems.add("join", exitValidation, err)
+		}
+	} else if verbosity >= verbosityVerbose {
+		for _, err := range unmatched() {
+			verbosef("Skipping unmatched record: %v", err)
+		}
+	}
+	if activeWarnings != nil && inputsRead {
+		activeWarnings.unmatched(unmatched())
+	}
+	// The cross-file ID and period checks need whole maps, so -sorted-input skips them.
+	if joined == nil {
+		for _, w := range checkEmployeeIDs(map[string][]string{
+			"payroll":  employeeIDs(payrollMap),
+			"time":     employeeIDs(timeMap),
+			"benefits": employeeIDs(benefitsMap),
+		}) {
+			logf("Warning: %v", w)
+			activeReport.warn(w)
+			activeWarnings.warn(w)
+		}
+		for _, w := range checkPayPeriods(map[string][]string{
+			"payroll":  payPeriods(payrollMap),
+			"time":     payPeriods(timeMap),
+			"benefits": payPeriods(benefitsMap),
+		}) {
+			logf("Warning: %v", w)
+			activeReport.warn(w)
+			activeWarnings.warn(w)
+		}
+	}
+	if len(roster) > 0 {
+		inputIDs := [][]string{employeeIDs(payrollMap), employeeIDs(timeMap), employeeIDs(benefitsMap)}
+		if joined != nil {
+			inputIDs = [][]string{joined.EmployeeIDs}
+		}
+		for _, id := range missingEmployees(roster, readerOpts, inputIDs...) {
+			w := Warning{Category: "employee-not-found", EmployeeID: id, Message: "listed by -employee or -employees-file but not in the inputs"}
+			logf("Warning: %v", w)
+			activeReport.warn(w)
+			activeWarnings.warn(w)
+		}
+	}
+	readDuration := time.Since(readStart)
+	records := map[string]int{"payroll": len(payrollMap), "time": len(timeMap), "benefits": len(benefitsMap)}
+	if joined != nil {
+		records = joined.Records
+	}
+	if activeReport != nil {
+		for kind, n := range records {
+			activeReport.Records[kind] = n
+		}
+	}
+	activeReport.phase("read", readDuration)
+	metrics.observeRead("payroll", records["payroll"])
+	metrics.observeRead("time", records["time"])
+	metrics.observeRead("benefits", records["benefits"])
+	metrics.observePhase("read", readDuration)
+	dash.endPhase("read", readDuration)
+	fmt.Fprintf(status, "Time to read input files: %v\n", readDuration)
+	verbosef("Read %d payroll, %d time and %d benefits record(s)", records["payroll"], records["time"], records["benefits"])
+
+	if *compareConfig != "" {
+		problems.exitIfAny()
+		candidate, err := loadTaxConfig(*compareConfig)
+		if err != nil {
+			fatalf(inputExitCode(err), "Error loading comparison config: %v", err)
+		}
+		baseResult := computeRegister(payrollMap, timeMap, benefitsMap, taxConfig, computeOpts)
+		newResult := computeRegister(payrollMap, timeMap, benefitsMap, candidate, computeOpts)
+		for _, rowErr := range append(baseResult.RowErrors, newResult.RowErrors...) {
+			logf("Skipping row: %v", rowErr)
+		}
+		deltas := compareRegisters(baseResult.Registers, newResult.Registers)
+		if err := writeRegisterComparison(deltas, *compareOut, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing config comparison: %v", err)
+		}
+		fmt.Fprintf(status, "Compared %d register line(s) under %s; saved to %s\n", len(deltas), *compareConfig, *compareOut)
+		return
+	}
+
+	// An incremental run restricts the computation to the employees whose inputs
+	// changed; the others are carried over from the prior register below.
+	var manifest IncrementalManifest
+	var prior []PayRegister
+	var changed map[string]bool
+	if *incrementalFile != "" {
+		hashes, err := employeeInputHashes(payrollMap, timeMap, benefitsMap)
+		if err != nil {
+			fatalf(exitFailure, "Error hashing inputs for -incremental: %v", err)
+		}
+		var runFiles []string
+		for _, f := range []string{*configFile, *taxTableFile, *taxOverridesFile, *midPeriodRatesFile, *columnMapFile, *employeesFile, *ytdSeedFile, *ptoAccrualsFile} {
+			if f != "" {
+				runFiles = append(runFiles, f)
+			}
+		}
+		run, err := runCacheKey(runFiles)
+		if err != nil {
+			fatalf(exitFailure, "Error hashing inputs for -incremental: %v", err)
+		}
+		manifest = IncrementalManifest{Run: run, Employees: hashes}
+		previous, found, err := readManifest(manifestFilename(*incrementalFile))
+		if err != nil {
+			fatalf(exitFailure, "Error reading -incremental manifest: %v", err)
+		}
+		switch {
+		case !found:
+			fmt.Fprintf(status, "Incremental run: no manifest for %s; computing every employee\n", *incrementalFile)
+		case previous.Run != run:
+			fmt.Fprintf(status, "Incremental run: the flags, config or side files changed since %s; computing every employee\n", *incrementalFile)
+		default:
+			var ok bool
+			if prior, ok, err = readPreviousRegister(*incrementalFile, formats[0], 1); err != nil {
+				fatalf(inputExitCode(err), "Error reading -incremental register: %v", err)
+			}
+			if !ok {
+				fmt.Fprintf(status, "Incremental run: %s does not exist; computing every employee\n", *incrementalFile)
+				break
+			}
+			changed = changedEmployees(previous.Employees, hashes)
+			restricted := make(map[string]bool, len(changed))
+			for employee := range changed {
+				if computeOpts.Employees == nil || computeOpts.Employees[employee] {
+					restricted[employee] = true
+				}
+			}
+			computeOpts.Employees = restricted
+			fmt.Fprintf(status, "Incremental run: recomputing %d of %d employee(s), carrying the rest over from %s\n", len(changed), len(hashes), *incrementalFile)
+		}
+	}
+
+	// Step 2: Compute the Pay Register
+	computeStart := time.Now()
+	dash.startPhase("compute")
+	var result ComputeResult
+	if joined != nil {
+		result = joined.ComputeResult
+	} else {
+		result = computeRegister(payrollMap, timeMap, benefitsMap, taxConfig, computeOpts)
+	}
+	registers, rowErrors := result.Registers, result.RowErrors
+	if changed != nil {
+		registers = mergeIncremental(prior, registers, manifest.Employees, changed)
+	}
+	for _, w := range result.Warnings {
+		logf("Warning: %v", w)
+		activeReport.warn(w)
+		activeWarnings.warn(w)
+	}
+	activeReport.rowErrors(rowErrors)
+	activeWarnings.rowErrors(rowErrors)
+	activeReport.registers(registers)
+	for _, rowErr := range rowErrors {
+		if problems != nil {
+			// Every row that failed, fatal or not, is a problem to fix.
+			problems.add("compute", exitValidation, rowErr)
+			continue
+		}
+		if rowErr.Fatal {
+			fatalf(exitValidation, "Aborting: %v", rowErr)
+		}
+		logf("Skipping row: %v", rowErr)
+	}
+	problems.exitIfAny()
+	if *strict && len(rowErrors) > 0 {
+		fatalf(exitValidation, "%d row(s) failed to compute and -strict is set", len(rowErrors))
+	}
+	computeDuration := time.Since(computeStart)
+	activeReport.phase("compute", computeDuration)
+	metrics.observeRegisters(registers, len(rowErrors))
+	metrics.observePhase("compute", computeDuration)
+	dash.endPhase("compute", computeDuration)
+	fmt.Fprintf(status, "Time to compute pay register: %v\n", computeDuration)
+	fmt.Fprintf(status, "Computed %d register records.\n", len(registers))
+	for _, code := range sortedKeys(result.Rounding) {
+		r := result.Rounding[code]
+		label := ""
+		if code != "" {
+			label = " (" + code + ")"
+		}
+		fmt.Fprintf(status, "Rounding adjustment%s: gross %+.4f, deductions %+.4f, net %+.4f\n",
+			label, r.Gross/100, r.Deductions/100, r.Net()/100)
+	}
+	for _, reg := range registers {
+		if reg.IsAdjustment && reg.GrossWages < 0 {
+			logf("Adjustment row for employee %s period %s has negative gross %s", reg.EmployeeID, reg.PayPeriod, reg.GrossWages)
+		}
+		if reg.IsCorrection {
+			logf("Correction row for employee %s period %s: %d regular, %d overtime hours, gross %s",
+				reg.EmployeeID, reg.PayPeriod, reg.RegularHours, reg.OvertimeHours, reg.GrossWages)
+		}
+	}
+	if *dedupeOutput != "off" {
+		var dupes []string
+		registers, dupes = dedupeRegisters(registers, *dedupeOutput == "first")
+		for _, d := range dupes {
+			if *dedupeOutput == "error" {
+				log.Printf("Duplicate register line: %s", d)
+			} else {
+				verbosef("Dropping duplicate register line: %s", d)
+			}
+		}
+		if len(dupes) > 0 && *dedupeOutput == "error" {
+			fatalf(exitValidation, "%d duplicate register line(s) and -dedupe-output=error", len(dupes))
+		}
+		if len(dupes) > 0 {
+			fmt.Fprintf(status, "Dropped %d duplicate register line(s), keeping the first for each employee and period.\n", len(dupes))
+			activeReport.registers(registers)
+		}
+	}
+	if *omitZero {
+		var omitted int
+		if registers, omitted = omitZeroRows(registers); omitted > 0 {
+			fmt.Fprintf(status, "Omitted %d all-zero register line(s).\n", omitted)
+			activeReport.registers(registers)
+		}
+	}
+	if *preview > 0 {
+		// Like the progress lines, the table keeps off stdout when the register is there.
+		var previewOut io.Writer = os.Stdout
+		if *outputFile == stdoutName {
+			previewOut = os.Stderr
+		}
+		if err := writePreview(previewOut, registers, *preview, writerOpts); err != nil {
+			logf("Warning: cannot print preview: %v", err)
+		}
+	}
+
+	if *previewDiff {
+		filename := *outputFile
+		if len(formats) > 1 {
+			filename = formatFilename(filename, diffFormat)
+		}
+		scale := Money(1)
+		if diffFormat == "csv" && writerOpts.NumberFormat == numberCents {
+			scale = 100
+		}
+		previous, found, err := readPreviousRegister(filename, diffFormat, scale)
+		switch {
+		case err != nil:
+			logf("Warning: cannot read the previous %s for -preview-diff: %v", filename, err)
+		case !found:
+			fmt.Printf("No previous %s to compare with.\n", filename)
+		default:
+			if err := writePreviewDiff(os.Stdout, filename, diffEmployeeNet(previous, registers), writerOpts); err != nil {
+				logf("Warning: cannot print preview diff: %v", err)
+			}
+		}
+	}
+
+	// Step 3: Write the Output CSV
+	writeStart := time.Now()
+	dash.startPhase("write")
+	writeFormat := func(registers []PayRegister, filename, format string) error {
+		switch format {
+		case "ndjson":
+			return writeRegisterNDJSON(registers, filename, taxConfig)
+		case "json":
+			return writeRegisterJSON(registers, filename, taxConfig)
+		case "html":
+			return writeRegisterHTML(registers, filename, registerOpts)
+		case "fixed":
+			return writeRegisterFixed(registers, filename, fixedSpec)
+		case "long":
+			return writeRegisterLong(registers, filename, registerOpts)
+		}
+		return writeRegister(registers, filename, taxConfig, registerOpts)
+	}
+	if *roundTripCheck {
+		write := writeFormat
+		writeFormat = func(registers []PayRegister, filename, format string) error {
+			if err := write(registers, filename, format); err != nil {
+				return err
+			}
+			if !rereadable(format) {
+				return nil
+			}
+			if err := checkRoundTrip(registers, filename, format, writerOpts, verifyTol); err != nil {
+				fatalf(exitMismatch, "Round-trip check failed: %v", err)
+			}
+			return nil
+		}
+	}
+	// writeOutput writes registers to filename in each -format, returning the files
+	// written. A single format keeps filename as given. The formats share one
+	// .meta.json sidecar, so csv, whose sidecar also lists the columns, goes last.
+	writeOutput := func(registers []PayRegister, filename string) ([]string, error) {
+		if len(formats) == 1 {
+			return formatOutputs(filename, formats[0]), writeFormat(registers, filename, formats[0])
+		}
+		var names, written []string
+		for _, format := range formats {
+			names = append(names, formatFilename(filename, format))
+			written = append(written, formatOutputs(names[len(names)-1], format)...)
+		}
+		for _, csvPass := range []bool{false, true} {
+			for i, format := range formats {
+				if (format == "csv") != csvPass {
+					continue
+				}
+				if err := writeFormat(registers, names[i], format); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return written, nil
+	}
+	var written []string
+	if *splitByPeriod {
+		groups := groupByPeriod(registers)
+		for _, p := range sortedKeys(groups) {
+			filename := periodFilename(*outputFile, p)
+			names, err := writeOutput(groups[p], filename)
+			if err != nil {
+				fatalf(exitFailure, "Error writing register file for period %s: %v", p, err)
+			}
+			written = append(written, names...)
+			fmt.Fprintf(status, "Wrote %d register records for period %s to %s\n", len(groups[p]), p, filename)
+		}
+	} else if *shards > 0 {
+		for i, shard := range groupByShard(registers, *shards) {
+			filename := shardFilename(*outputFile, i, *shards)
+			names, err := writeOutput(shard, filename)
+			if err != nil {
+				fatalf(exitFailure, "Error writing register file for shard %d: %v", i+1, err)
+			}
+			written = append(written, names...)
+			fmt.Fprintf(status, "Wrote %d register records for shard %d of %d to %s\n", len(shard), i+1, *shards, filename)
+		}
+	} else if written, err = writeOutput(registers, *outputFile); err != nil {
+		fatalf(exitFailure, "Error writing register file: %v", err)
+	}
+	if *incrementalFile != "" {
+		if err := writeManifest(manifestFilename(*outputFile), manifest); err != nil {
+			fatalf(exitFailure, "Error writing -incremental manifest: %v", err)
+		}
+	}
+	if cacheKey != "" {
+		if err := saveToCache(*cacheDir, cacheKey, *outputFile, formats[0] != "fixed"); err != nil {
+			logf("Warning: %v", err)
+		}
+	}
+	if exporter != nil {
+		if err := writeExport(registers, *exportFile, exporter); err != nil {
+			fatalf(exitFailure, "Error writing export: %v", err)
+		}
+	}
+	if *remittanceFile != "" {
+		if err := writeRemittance(computeRemittance(registers), *remittanceFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing remittance summary: %v", err)
+		}
+	}
+	if *retroPayFile != "" {
+		lines, warnings := computeRetroPay(registers, retroRaises)
+		for _, w := range warnings {
+			logf("Warning: %v", w)
+			activeReport.warn(w)
+			activeWarnings.warn(w)
+		}
+		if err := writeRetroPay(lines, *retroOut, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing retro pay: %v", err)
+		}
+	}
+	if *depositOut != "" {
+		if err := writeDepositAllocations(depositLines(registers, depositAllocations), *depositOut, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing deposit allocations: %v", err)
+		}
+	}
+	if *nachaOut != "" {
+		warnings, err := writeNACHA(depositLines(registers, depositAllocations), bankInfo, *taxConfig.ACH, achEffective, *nachaOut)
+		if err != nil {
+			fatalf(exitFailure, "Error writing NACHA file: %v", err)
+		}
+		for _, w := range warnings {
+			logf("Warning: %v", w)
+			activeReport.warn(w)
+			activeWarnings.warn(w)
+		}
+	}
+	if *ssWageBaseFile != "" {
+		lines, err := socialSecurityWageBase(registers, taxConfig, computeOpts.YTDSeed)
+		if err != nil {
+			fatalf(exitValidation, "Error computing Social Security wage base report: %v", err)
+		}
+		if err := writeSocialSecurityWageBase(lines, *ssWageBaseFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing Social Security wage base report: %v", err)
+		}
+	}
+	if *w2PreviewFile != "" {
+		previews, err := w2Previews(registers, taxConfig, computeOpts.YTDSeed)
+		if err != nil {
+			fatalf(exitValidation, "Error computing W-2 preview: %v", err)
+		}
+		if err := writeW2Previews(previews, *w2PreviewFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing W-2 preview: %v", err)
+		}
+	}
+	if *projectionFile != "" {
+		projections, err := projectAnnual(registers, taxConfig)
+		if err != nil {
+			fatalf(exitValidation, "Error computing annual projection: %v", err)
+		}
+		if err := writeAnnualProjections(projections, *projectionFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing annual projection: %v", err)
+		}
+	}
+	if *explainTaxesFile != "" {
+		if err := writeTaxExplanations(registers, *explainTaxesFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing tax explanation: %v", err)
+		}
+	}
+	if *employerCostFile != "" {
+		if err := writeEmployerCost(registers, *employerCostFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing employer cost report: %v", err)
+		}
+	}
+	if *periodGapsFile != "" {
+		var expected []string
+		if *expectedPeriods != "" {
+			for _, p := range strings.Split(*expectedPeriods, ",") {
+				p, err := readerOpts.period(strings.TrimSpace(p))
+				if err != nil {
+					fatalf(exitUsage, "Invalid -expected-periods: %v", err)
+				}
+				expected = append(expected, p)
+			}
+		}
+		gaps, err := detectPeriodGaps(payrollMap, expected)
+		if err != nil {
+			fatalf(exitParseError, "Error detecting period gaps: %v", err)
+		}
+		if err := writePeriodGaps(gaps, *periodGapsFile); err != nil {
+			fatalf(exitFailure, "Error writing period gap report: %v", err)
+		}
+	}
+	if *netRatioFile != "" {
+		if err := writeNetRatioStats(netRatioStats(registers), *netRatioFile); err != nil {
+			fatalf(exitFailure, "Error writing net ratio statistics: %v", err)
+		}
+	}
+	if *topN > 0 {
+		earners, err := topEarners(registers, *topN, *topBy)
+		if err != nil {
+			fatalf(exitFailure, "Error ranking top earners: %v", err)
+		}
+		if err := writeTopEarners(earners, *topFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing top earners report: %v", err)
+		}
+	}
+	if *rateChangesFile != "" {
+		changes, err := detectRateChanges(payrollMap)
+		if err != nil {
+			fatalf(exitParseError, "Error detecting rate changes: %v", err)
+		}
+		if err := writeRateChanges(changes, *rateChangesFile); err != nil {
+			fatalf(exitFailure, "Error writing rate change report: %v", err)
+		}
+	}
+	if *benefitChangesFile != "" {
+		changes, err := detectBenefitChanges(benefitsMap, payrollMap, *benefitChangePercent)
+		if err != nil {
+			fatalf(exitParseError, "Error detecting benefit changes: %v", err)
+		}
+		if err := writeBenefitChanges(changes, *benefitChangesFile, writerOpts); err != nil {
+			fatalf(exitFailure, "Error writing benefit change report: %v", err)
+		}
+	}
+	if *paystubsDir != "" {
+		if err := writePaystubsPDF(registers, *paystubsDir, writerOpts.Display); err != nil {
+			fatalf(exitFailure, "Error writing paystubs: %v", err)
+		}
+	}
+	if *statementFor != "" {
+		var from, to time.Time
+		if *statementFrom != "" {
+			if from, err = parsePeriod(*statementFrom); err != nil {
+				fatalf(exitUsage, "Invalid -statement-from: %v", err)
+			}
+		}
+		if *statementTo != "" {
+			if to, err = parsePeriod(*statementTo); err != nil {
+				fatalf(exitUsage, "Invalid -statement-to: %v", err)
+			}
+		}
+		st, err := employeeStatement(registers, readerOpts.employeeID(*statementFor), from, to)
+		if err != nil {
+			fatalf(exitValidation, "Error building statement: %v", err)
+		}
+		if err := writeStatement(st, *statementOut, writerOpts.Display); err != nil {
+			fatalf(exitFailure, "Error writing statement: %v", err)
+		}
+	}
+	if *fxSummaryFile != "" {
+		summary, err := computeCurrencySummary(registers, taxConfig)
+		if err != nil {
+			fatalf(exitValidation, "Error computing currency summary: %v", err)
+		}
+		if err := writeCurrencySummary(summary, *fxSummaryFile); err != nil {
+			fatalf(exitFailure, "Error writing currency summary: %v", err)
+		}
+	}
+	if *expectedNet != "" || *expectedNetFile != "" {
+		expected := make(map[string]Money)
+		if *expectedNetFile != "" {
+			if expected, err = readExpectedNet(*expectedNetFile, readerOpts); err != nil {
+				fatalf(inputExitCode(err), "Error reading expected net file: %v", err)
+			}
+		}
+		if *expectedNet != "" {
+			if expected[""], err = parseMoney(*expectedNet); err != nil {
+				fatalf(exitUsage, "Invalid -expected-net: %v", err)
+			}
+		}
+		results, err := reconcileNet(registers, expected)
+		if err != nil {
+			fatalf(exitValidation, "Error reconciling net pay: %v", err)
+		}
+		mismatches := 0
+		for _, r := range results {
+			label := "total"
+			if r.Period != "" {
+				label = "period " + r.Period
+			}
+			diff := r.Difference()
+			if !withinTolerance(diff, netTol) {
+				mismatches++
+				log.Printf("Net pay mismatch for %s: computed %s, expected %s, difference %s", label, r.Computed, r.Expected, diff)
+			} else {
+				fmt.Fprintf(status, "Net pay reconciled for %s: %s (difference %s)\n", label, r.Computed, diff)
+			}
+		}
+		if mismatches > 0 {
+			fatalf(exitMismatch, "%d net pay total(s) failed reconciliation", mismatches)
+		}
+	}
+	writeDuration := time.Since(writeStart)
+	activeReport.phase("write", writeDuration)
+	metrics.observePhase("write", writeDuration)
+	dash.endPhase("write", writeDuration)
+	fmt.Fprintf(status, "Time to write output file: %v\n", writeDuration)
+
+	// Total elapsed time
+	totalDuration := time.Since(totalStart)
+	metrics.observePhase("total", totalDuration)
+	fmt.Fprintf(status, "Total elapsed time: %v\n", totalDuration)
+	fmt.Fprintf(status, "Pay register computed and saved to %s\n", strings.Join(written, ", "))
+	dash.finish(*outputFile)
+	if *auditLog != "" {
+		entry := AuditEntry{Time: time.Now().UTC(), Records: len(registers)}
+		if entry.Inputs, err = hashFiles(inputs); err == nil {
+			if entry.Outputs, err = hashFiles(written); err == nil {
+				if entry.ConfigHash, err = configHash(taxConfig); err == nil {
+					err = appendAuditEntry(*auditLog, entry)
+				}
+			}
+		}
+		if err != nil {
+			fatalf(exitFailure, "Error writing audit log: %v", err)
+		}
+	}
+	activeReport.phase("total", totalDuration)
+	activeReport.finish(0, "")
+	activeWarnings.finish()
+
+	if *metricsAddr != "" {
+		fmt.Fprintf(status, "Serving metrics on %s for %v\n", *metricsAddr, *metricsLinger)
+		time.Sleep(*metricsLinger)
+	}
 }
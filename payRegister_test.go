@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runPipeline reads the three inputs in dir, computes the register under the
+// default config and returns it as written.
+func runPipeline(t *testing.T, dir string) []byte {
+	t.Helper()
+	opts := defaultWriterOptions()
+	opts.TotalsRow = true
+	var buf bytes.Buffer
+	if _, err := writeRegisterCSV(&buf, computePipeline(t, dir), opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// computePipeline reads the three inputs in dir and computes the register under
+// the default config.
+func computePipeline(t *testing.T, dir string) []PayRegister {
+	t.Helper()
+	payrollMap, err := readPayrollRecords(filepath.Join(dir, "payroll_data.csv"), ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeMap, err := readTimeRecords(filepath.Join(dir, "time_data.csv"), ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	benefitsMap, err := readBenefitsRecords(filepath.Join(dir, "benefits.csv"), ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := computeRegister(payrollMap, timeMap, benefitsMap, defaultTaxConfig(), defaultComputeOptions())
+	for _, rowErr := range result.RowErrors {
+		t.Errorf("unexpected row error: %v", rowErr)
+	}
+	return result.Registers
+}
+
+func TestPipelineDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	if err := generateData(dir, 200, 3, 7); err != nil {
+		t.Fatal(err)
+	}
+	first := runPipeline(t, dir)
+	if len(first) == 0 {
+		t.Fatal("the pipeline wrote nothing")
+	}
+	for i := 0; i < 2; i++ {
+		if again := runPipeline(t, dir); !bytes.Equal(first, again) {
+			t.Fatalf("run %d over identical input produced different output", i+2)
+		}
+	}
+}
+
+func TestWriteBufferSize(t *testing.T) {
+	// A buffer under encoding/csv's 4096 bytes sits beneath its own, and the
+	// register must still arrive whole, with and without -flush-every.
+	dir := t.TempDir()
+	if err := generateData(dir, 20, 3, 5); err != nil {
+		t.Fatal(err)
+	}
+	registers := computePipeline(t, dir)
+	write := func(size, every int) []byte {
+		opts := defaultWriterOptions()
+		opts.BufferSize, opts.FlushEvery = size, every
+		var buf bytes.Buffer
+		if _, err := writeRegisterCSV(&buf, registers, opts); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	want := write(0, 0)
+	for _, size := range []int{100, 1000, 4095, 8192} {
+		for _, every := range []int{0, 7} {
+			if got := write(size, every); !bytes.Equal(got, want) {
+				t.Errorf("-write-buffer %d -flush-every %d: wrote %d bytes, want the %d written unbuffered", size, every, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	names := map[string]string{
+		"001": `Doe, Jane`,
+		"002": `Jane "JJ" Doe`,
+		"003": "Jane\nDoe",
+		"004": "Jane\r\nDoe, \"JJ\"",
+	}
+	var registers []PayRegister
+	for _, id := range sortedKeys(names) {
+		registers = append(registers, PayRegister{EmployeeID: id, EmployeeName: names[id], JobTitle: `Cook, "Line"`, PayPeriod: "2024-06"})
+	}
+	path := filepath.Join(t.TempDir(), "register.csv")
+	if err := writeRegister(registers, path, defaultTaxConfig(), defaultWriterOptions()); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readRegisterFile(path, ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(registers) {
+		t.Fatalf("read back %d lines, want %d", len(read), len(registers))
+	}
+	for i, reg := range read {
+		want := registers[i]
+		if reg.EmployeeID != want.EmployeeID || reg.EmployeeName != csvText(want.EmployeeName) || reg.JobTitle != want.JobTitle {
+			t.Errorf("line %d: got %q %q %q, want %q %q %q", i+1, reg.EmployeeID, reg.EmployeeName, reg.JobTitle,
+				want.EmployeeID, csvText(want.EmployeeName), want.JobTitle)
+		}
+	}
+}
+
+func TestHoursPlausibility(t *testing.T) {
+	for _, tc := range []struct {
+		name                    string
+		regular, overtime       int
+		maxTotal                int
+		maxRatio                float64
+		action                  checkAction
+		wantCategory, wantValue string
+		wantErr                 bool
+	}{
+		// Swapped hour columns, 80 overtime against 5 regular, trip the ratio.
+		{"swapped columns", 5, 80, 0, 2, actionWarn, "overtime-ratio", "80", false},
+		{"ratio within", 40, 10, 0, 2, actionWarn, "", "", false},
+		{"overtime without regular", 0, 8, 0, 2, actionWarn, "overtime-ratio", "8", false},
+		{"total over the cap", 150, 40, 168, 0, actionWarn, "hours-cap", "190", false},
+		{"ratio as an error", 5, 80, 0, 2, actionError, "", "", true},
+	} {
+		opts := defaultComputeOptions()
+		opts.MaxTotalHours, opts.MaxOvertimeRatio, opts.HoursCapAction = tc.maxTotal, tc.maxRatio, tc.action
+		_, warnings, err := computeRow(PayrollRecord{EmployeeID: "011", PayPeriod: "2024-06", HourlyRate: 2000},
+			TimeRecord{EmployeeID: "011", PayPeriod: "2024-06", RegularHours: tc.regular, OvertimeHours: tc.overtime}, BenefitsRecord{}, defaultTaxConfig(), opts)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		var got []Warning
+		for _, w := range warnings {
+			if w.Category == "overtime-ratio" || w.Category == "hours-cap" {
+				got = append(got, w)
+			}
+		}
+		switch {
+		case tc.wantCategory == "" && len(got) > 0:
+			t.Errorf("%s: got warnings %v, want none", tc.name, got)
+		case tc.wantCategory != "" && (len(got) != 1 || got[0].Category != tc.wantCategory || got[0].Value != tc.wantValue):
+			t.Errorf("%s: got warnings %v, want %s on %s hours", tc.name, got, tc.wantCategory, tc.wantValue)
+		}
+	}
+}
+
+func TestMatchCapUnparsedPeriod(t *testing.T) {
+	// A period with no year cannot count toward a yearly cap, so its match is
+	// left whole and the line warned of.
+	cfg := defaultTaxConfig()
+	cfg.RetirementMatch = &RetirementMatch{Tiers: []MatchTier{{MatchPercent: 100, UpToPercent: 5}}, AnnualCap: 1000}
+	payrollMap, timeMap, benefitsMap := map[string]PayrollRecord{}, map[string]TimeRecord{}, map[string]BenefitsRecord{}
+	for _, period := range []string{"2024-06", "week 23"} {
+		key := makeKey("015", period)
+		payrollMap[key] = PayrollRecord{EmployeeID: "015", PayPeriod: period, HourlyRate: 2000}
+		timeMap[key] = TimeRecord{EmployeeID: "015", PayPeriod: period, RegularHours: 80}
+		benefitsMap[key] = BenefitsRecord{EmployeeID: "015", PayPeriod: period, Retirement: 8000}
+	}
+	result := computeRegister(payrollMap, timeMap, benefitsMap, cfg, defaultComputeOptions())
+	for _, rowErr := range result.RowErrors {
+		t.Fatalf("unexpected row error: %v", rowErr)
+	}
+	matches := make(map[string]Money)
+	for _, reg := range result.Registers {
+		matches[reg.PayPeriod] = reg.EmployerMatch
+	}
+	if matches["2024-06"] != 1000 || matches["week 23"] != 8000 {
+		t.Errorf("got matches %v, want 10.00 capped and 80.00 uncapped", matches)
+	}
+	var warned bool
+	for _, w := range result.Warnings {
+		warned = warned || w.Category == "match-cap" && w.PayPeriod == "week 23"
+	}
+	if !warned {
+		t.Errorf("got warnings %v, want a match-cap warning for week 23", result.Warnings)
+	}
+}
+
+func TestRequireOvertimePay(t *testing.T) {
+	const want = "needs an Overtime Pay column"
+	for _, tc := range []struct {
+		name, data string
+		noHeader   bool
+		wantErr    bool
+	}{
+		{"header only", "Employee ID,Pay Period,Regular Hours,Overtime Hours\n", false, true},
+		{"rows without the column", "Employee ID,Pay Period,Regular Hours,Overtime Hours\n001,2024-06,80,5\n", false, true},
+		{"no header", "001,2024-06,80,5\n", true, true},
+		{"with the column", "Employee ID,Pay Period,Regular Hours,Overtime Hours,Overtime Pay\n001,2024-06,80,5,150.00\n", false, false},
+	} {
+		_, err := readTimeRecords(writeInput(t, tc.data), ReaderOptions{RequireOvertimePay: true, NoHeader: tc.noHeader})
+		if gotErr := err != nil && strings.Contains(err.Error(), want); gotErr != tc.wantErr || (err != nil && !gotErr) {
+			t.Errorf("%s: got error %v, want one mentioning %q: %v", tc.name, err, want, tc.wantErr)
+		}
+	}
+}
+
+func TestDefaultLocalTaxRates(t *testing.T) {
+	// No locality is taxed unless a config lists it, and a config's list is the
+	// whole list.
+	config := writeInput(t, `{"localTaxRates": {"BOSTON": 0.01}}`)
+	cfg, err := loadTaxConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		cfg      TaxConfig
+		locality string
+		want     Money
+	}{
+		{"default", defaultTaxConfig(), "NYC", 0},
+		{"config", cfg, "NYC", 0},
+		{"config", cfg, "BOSTON", 1600},
+	} {
+		reg, _, err := computeRow(PayrollRecord{EmployeeID: "016", PayPeriod: "2024-06", HourlyRate: 2000, WorkLocality: tc.locality},
+			TimeRecord{EmployeeID: "016", PayPeriod: "2024-06", RegularHours: 80}, BenefitsRecord{}, tc.cfg, defaultComputeOptions())
+		if err != nil {
+			t.Errorf("%s %s: %v", tc.name, tc.locality, err)
+			continue
+		}
+		if reg.LocalTax != tc.want {
+			t.Errorf("%s %s: got local tax %s, want %s", tc.name, tc.locality, reg.LocalTax, tc.want)
+		}
+	}
+}
+
+func TestDefaultBenefitEligibility(t *testing.T) {
+	// Every employee type is eligible for every benefit unless a config says
+	// otherwise.
+	config := writeInput(t, `{"benefitEligibility": {"PART-TIME": ["retirement", "other"]}}`)
+	cfg, err := loadTaxConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		cfg  TaxConfig
+		want Money
+	}{
+		{"default", defaultTaxConfig(), 7500},
+		{"config", cfg, 0},
+	} {
+		reg, _, err := computeRow(PayrollRecord{EmployeeID: "017", PayPeriod: "2024-06", HourlyRate: 2000, EmployeeType: "Part-Time"},
+			TimeRecord{EmployeeID: "017", PayPeriod: "2024-06", RegularHours: 80}, BenefitsRecord{HealthInsurance: 7500}, tc.cfg, defaultComputeOptions())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if reg.HealthInsurance != tc.want {
+			t.Errorf("%s: got health insurance %s for a part-time employee, want %s", tc.name, reg.HealthInsurance, tc.want)
+		}
+	}
+}
+
+func TestRegisterColumns(t *testing.T) {
+	original := []string{"Employee ID", "Employee Name", "Job Title", "Pay Period", "Hourly Rate",
+		"Regular Hours", "Overtime Hours", "Gross Wages", "Federal Tax", "State Tax",
+		"Social Security", "Medicare", "Health Insurance", "Retirement", "Other Benefits",
+		"Total Benefits", "Total Deductions", "Net Pay"}
+	plain := PayRegister{EmployeeID: "018", PayPeriod: "2024-06", HourlyRate: 2000, RegularHours: 80, GrossWages: 160000, NetPay: 120000, EffectiveTaxRate: 0.25}
+	doubled := plain
+	doubled.DoubleTimeHours, doubled.LocalTax, doubled.IsAdjustment = 2, 1000, true
+	for _, tc := range []struct {
+		name      string
+		registers []PayRegister
+		opts      func(*WriterOptions)
+		want      []string
+	}{
+		{"no such data", []PayRegister{plain}, func(*WriterOptions) {}, original},
+		{"double time, local tax and an adjustment", []PayRegister{plain, doubled}, func(*WriterOptions) {},
+			slices.Concat(original[:7], []string{"Double Time Hours"}, original[7:10], []string{"Local Tax"}, original[10:], []string{"Row Type"})},
+		{"totals and a currency", []PayRegister{plain}, func(o *WriterOptions) { o.TotalsRow, o.Currency.Code = true, "USD" },
+			slices.Concat(original, []string{"Row Type", "Currency"})},
+		{"-all-columns", []PayRegister{plain}, func(o *WriterOptions) { o.AllColumns = true }, registerHeader},
+		{"-columns", []PayRegister{doubled}, func(o *WriterOptions) { o.Columns = []string{"Net Pay", "Employee ID"} }, []string{"Net Pay", "Employee ID"}},
+	} {
+		opts := defaultWriterOptions()
+		tc.opts(&opts)
+		var buf bytes.Buffer
+		if _, err := writeRegisterCSV(&buf, tc.registers, opts); err != nil {
+			t.Fatal(err)
+		}
+		header, _, _ := strings.Cut(buf.String(), "\n")
+		if want := strings.Join(tc.want, ","); header != want {
+			t.Errorf("%s: got header\n%s\nwant\n%s", tc.name, header, want)
+		}
+	}
+
+	// A named benefit's column follows the standard ones the register has, however
+	// many were left out.
+	named := plain
+	named.OtherBenefits, named.NamedBenefits = 2500, map[string]Money{"Dental": 2500}
+	path := filepath.Join(t.TempDir(), "register.csv")
+	if err := writeRegister([]PayRegister{named}, path, defaultTaxConfig(), defaultWriterOptions()); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readRegisterFile(path, ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1 || read[0].NamedBenefits["Dental"] != 2500 || read[0].NetPay != 120000 {
+		t.Errorf("got %+v, want the Dental benefit and net pay read back", read)
+	}
+}
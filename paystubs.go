@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writePaystubsPDF writes one single-page PDF paystub per register into dir, named
+// after the sanitized employee ID and pay period. The PDF is assembled by hand (one
+// page, the built-in Helvetica font) so no PDF library is needed. Amounts are
+// written in the display format.
+func writePaystubsPDF(registers []PayRegister, dir string, display DisplayFormat) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create paystub directory: %v", err)
+	}
+	for _, reg := range registers {
+		name := sanitizeFilename(reg.EmployeeID) + "_" + sanitizeFilename(reg.PayPeriod)
+		if reg.IsAdjustment {
+			name += "_adjustment"
+		}
+		path := filepath.Join(dir, name+".pdf")
+		if err := os.WriteFile(path, renderPaystubPDF(paystubLines(reg, display)), 0644); err != nil {
+			return fmt.Errorf("cannot write paystub %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// sanitizeFilename keeps letters, digits, '-' and '.', replacing everything else
+// (path separators included) with '_'.
+func sanitizeFilename(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// paystubLine is one line of a paystub: a label and, for amount rows, a right-aligned value.
+type paystubLine struct {
+	Label string
+	Value string
+	Bold  bool
+}
+
+// paystubLines lays out a register as the sections of a paystub.
+func paystubLines(reg PayRegister, display DisplayFormat) []paystubLine {
+	amount := func(m Money) string {
+		if reg.Currency != "" {
+			return display.Money(m) + " " + reg.Currency
+		}
+		return display.Money(m)
+	}
+	lines := []paystubLine{
+		{Label: "EARNINGS STATEMENT", Bold: true},
+		{Label: "Employee: " + reg.EmployeeName + " (" + reg.EmployeeID + ")"},
+		{Label: "Job Title: " + reg.JobTitle},
+		{Label: "Pay Period: " + reg.PayPeriod},
+	}
+	if reg.SSN != "" {
+		lines = append(lines, paystubLine{Label: "SSN: " + reg.SSN})
+	}
+	lines = append(lines, paystubLine{}, paystubLine{Label: "Earnings", Bold: true})
+	if reg.PieceEarnings != 0 {
+		// Piece work: straight time for every hour, then the overtime premium on
+		// the regular rate (see pieceGross).
+		hours := reg.RegularHours + reg.OvertimeHours + reg.DoubleTimeHours
+		straight := reg.PieceEarnings + reg.HourlyRate.MulHours(hours)
+		lines = append(lines, paystubLine{Label: fmt.Sprintf("Piece Work (%d units @ %s)", reg.Units, display.Money(reg.PieceRate)), Value: amount(reg.PieceEarnings)})
+		if reg.HourlyRate != 0 {
+			lines = append(lines, paystubLine{Label: fmt.Sprintf("Hourly (%d hrs @ %s)", hours, display.Money(reg.HourlyRate)), Value: amount(reg.HourlyRate.MulHours(hours))})
+		}
+		if premium := reg.GrossWages - reg.Adjustment - reg.CustomEarnings - straight; premium != 0 {
+			lines = append(lines, paystubLine{Label: fmt.Sprintf("Overtime Premium (%d OT, %d DT hrs)", reg.OvertimeHours, reg.DoubleTimeHours), Value: amount(premium)})
+		}
+	} else {
+		lines = append(lines, paystubLine{Label: fmt.Sprintf("Regular (%d hrs @ %s)", reg.RegularHours, display.Money(reg.HourlyRate)), Value: amount(reg.HourlyRate.MulHours(reg.RegularHours))})
+		if reg.OvertimeHours != 0 {
+			label := fmt.Sprintf("Overtime (%d hrs)", reg.OvertimeHours)
+			if reg.OvertimeAfter > 0 {
+				label = fmt.Sprintf("Overtime (%d hrs, over %d/week)", reg.OvertimeHours, reg.OvertimeAfter)
+			}
+			lines = append(lines, paystubLine{Label: label, Value: amount(reg.HourlyRate.MulRate(1.5 * float64(reg.OvertimeHours)))})
+		}
+		if reg.DoubleTimeHours != 0 {
+			lines = append(lines, paystubLine{Label: fmt.Sprintf("Double Time (%d hrs)", reg.DoubleTimeHours), Value: amount(reg.HourlyRate.MulHours(2 * reg.DoubleTimeHours))})
+		}
+	}
+	if reg.Adjustment != 0 {
+		lines = append(lines, paystubLine{Label: "Adjustment", Value: amount(reg.Adjustment)})
+	}
+	for _, e := range reg.Earnings {
+		lines = append(lines, paystubLine{Label: e.Label, Value: amount(e.Amount)})
+	}
+	lines = append(lines,
+		paystubLine{Label: "Gross Wages", Value: amount(reg.GrossWages), Bold: true},
+		paystubLine{},
+		paystubLine{Label: "Taxes", Bold: true},
+		paystubLine{Label: "Federal Income Tax", Value: amount(reg.FederalTax)},
+		paystubLine{Label: "State Income Tax", Value: amount(reg.StateTax)},
+	)
+	if reg.LocalTax != 0 {
+		lines = append(lines, paystubLine{Label: "Local Income Tax (" + reg.WorkLocality + ")", Value: amount(reg.LocalTax)})
+	}
+	if len(reg.Contributions) == 0 {
+		lines = append(lines,
+			paystubLine{Label: "Social Security", Value: amount(reg.SocialSecurity)},
+			paystubLine{Label: "Medicare", Value: amount(reg.Medicare)},
+		)
+	}
+	for _, c := range reg.Contributions {
+		lines = append(lines, paystubLine{Label: c.Name, Value: amount(c.Employee)})
+	}
+	lines = append(lines,
+		paystubLine{},
+		paystubLine{Label: "Deductions", Bold: true},
+		paystubLine{Label: "Health Insurance", Value: amount(reg.HealthInsurance)},
+		paystubLine{Label: "Retirement", Value: amount(reg.Retirement)},
+	)
+	other := reg.OtherBenefits
+	for _, name := range sortedKeys(reg.NamedBenefits) {
+		lines = append(lines, paystubLine{Label: name, Value: amount(reg.NamedBenefits[name])})
+		other -= reg.NamedBenefits[name]
+	}
+	if other != 0 || len(reg.NamedBenefits) == 0 {
+		lines = append(lines, paystubLine{Label: "Other Benefits", Value: amount(other)})
+	}
+	for _, d := range reg.Deductions {
+		lines = append(lines, paystubLine{Label: d.Label, Value: amount(d.Amount)})
+	}
+	lines = append(lines,
+		paystubLine{Label: "Total Deductions", Value: amount(reg.TotalDeductions), Bold: true},
+		paystubLine{},
+		paystubLine{Label: "NET PAY", Value: amount(reg.NetPay), Bold: true},
+	)
+	return lines
+}
+
+// pdfString escapes s for a PDF literal string. Helvetica's standard encoding only
+// covers ASCII reliably, so anything else is replaced with '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// pdfLinesPerPage is how many 16pt lines fit between the top and bottom margins.
+const pdfLinesPerPage = 44
+
+// renderPaystubPDF builds a minimal PDF 1.4 document containing the lines on US
+// Letter pages, starting a new page every pdfLinesPerPage lines.
+func renderPaystubPDF(lines []paystubLine) []byte {
+	var pages []string
+	for start := 0; start < len(lines) || start == 0; start += pdfLinesPerPage {
+		end := min(start+pdfLinesPerPage, len(lines))
+		var content bytes.Buffer
+		y := 740
+		for _, l := range lines[start:end] {
+			font := "/F1"
+			if l.Bold {
+				font = "/F2"
+			}
+			if l.Label != "" {
+				fmt.Fprintf(&content, "BT %s 11 Tf 60 %d Td (%s) Tj ET\n", font, y, pdfString(l.Label))
+			}
+			if l.Value != "" {
+				// Approximate right alignment: Helvetica digits are 0.556 em wide.
+				x := 550 - int(float64(len(l.Value))*11*0.556)
+				fmt.Fprintf(&content, "BT %s 11 Tf %d %d Td (%s) Tj ET\n", font, x, y, pdfString(l.Value))
+			}
+			y -= 16
+		}
+		pages = append(pages, content.String())
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes two
+	// objects, the page and its content stream.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold >>",
+	}
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
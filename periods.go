@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// periodLayout is the Go time layout set by -period-format. When empty, parsePeriod
+// auto-detects among periodLayouts.
+var periodLayout string
+
+// periodLocation is the time zone pay-period and other calendar dates are
+// interpreted in (-tz, default UTC). The input dates carry no zone of their own, so
+// a period starts at midnight in this zone, and its start decides which tax year
+// and -since/-until window it falls in.
+var periodLocation = time.UTC
+
+// periodLayouts are the pay-period formats recognized without -period-format, tried
+// in order. A period is identified by the date it starts on.
+var periodLayouts = []string{
+	"2006-01-02",
+	"2006-01",
+	"2006/01/02",
+	"2006/01",
+	"01/02/2006",
+	"Jan 2006",
+	"January 2006",
+}
+
+// parsePeriod converts a PayPeriod string into the date the period starts on. Every
+// feature that needs chronological order should go through this helper.
+func parsePeriod(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if periodLayout != "" {
+		t, err := time.ParseInLocation(periodLayout, s, periodLocation)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse pay period %q with layout %q", s, periodLayout)
+		}
+		return t, nil
+	}
+	for _, layout := range periodLayouts {
+		if t, err := time.ParseInLocation(layout, s, periodLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse pay period %q: expected one of %s, or set -period-format", s, strings.Join(periodLayouts, ", "))
+}
+
+// normalizePeriod rewrites a pay period in layout (-normalize-period), so that
+// "2024-06", "Jun 2024", "2024/06", and "P2024-06" all become the same string. A
+// month period stays a month: with a layout that has a day it is written
+// 2006-01, and a dated period given a month layout is written 2006-01-02, so
+// distinct periods never collapse into one.
+func normalizePeriod(s, layout string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 1 && (s[0] == 'P' || s[0] == 'p') && s[1] >= '0' && s[1] <= '9' {
+		s = s[1:]
+	}
+	start, err := parsePeriod(s)
+	if err != nil {
+		return "", err
+	}
+	switch month := isMonthPeriod(s); {
+	case month && !monthLayouts[layout]:
+		layout = "2006-01"
+	case !month && monthLayouts[layout]:
+		layout = "2006-01-02"
+	}
+	return start.Format(layout), nil
+}
+
+// parseDate parses a YYYY-MM-DD date cell (daily time, effective dates) in periodLocation.
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", strings.TrimSpace(s), periodLocation)
+}
+
+// monthLayouts are the period layouts that name a whole calendar month rather than
+// the date a period starts on.
+var monthLayouts = map[string]bool{"2006-01": true, "2006/01": true, "Jan 2006": true, "January 2006": true}
+
+// isMonthPeriod reports whether s names a whole month ("2024-06", "Jun 2024"), so the
+// period spans that month whatever the configured pay frequency.
+func isMonthPeriod(s string) bool {
+	s = strings.TrimSpace(s)
+	if periodLayout != "" {
+		return monthLayouts[periodLayout]
+	}
+	for _, layout := range periodLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return monthLayouts[layout]
+		}
+	}
+	return false
+}
+
+// chronologicalKeys orders EmployeeID|PayPeriod keys by employee and then by period
+// start, for features that carry amounts from one period to the next. Periods that
+// do not parse sort after the others, by label.
+func chronologicalKeys[V any](m map[string]V) []string {
+	keys := sortedKeys(m)
+	starts := make(map[string]time.Time, len(keys))
+	for _, key := range keys {
+		_, period, _ := strings.Cut(key, "|")
+		if start, err := parsePeriod(period); err == nil {
+			starts[key] = start
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		idI, _, _ := strings.Cut(keys[i], "|")
+		idJ, _, _ := strings.Cut(keys[j], "|")
+		if idI != idJ {
+			return idI < idJ
+		}
+		si, okI := starts[keys[i]]
+		sj, okJ := starts[keys[j]]
+		if okI != okJ {
+			return okI
+		}
+		return si.Before(sj)
+	})
+	return keys
+}
+
+// payPeriods lists the distinct pay periods of a record map, sorted.
+func payPeriods[V any](m map[string]V) []string {
+	seen := make(map[string]bool)
+	for key := range m {
+		_, period, _ := strings.Cut(key, "|")
+		seen[period] = true
+	}
+	return sortedKeys(seen)
+}
+
+// checkPayPeriods warns about pay periods found in some input files but not the
+// others. Records in such a period cannot join, and a whole period going missing
+// usually means the files write periods differently ("2024-06" against "2024-P12").
+// When another file has a period starting on the same date, or the period is not
+// in a recognized format, the warning says so.
+func checkPayPeriods(files map[string][]string) []Warning {
+	sets := make(map[string]map[string]bool)
+	all := make(map[string]bool)
+	for name, periods := range files {
+		if len(periods) == 0 {
+			continue
+		}
+		sets[name] = make(map[string]bool)
+		for _, p := range periods {
+			sets[name][p] = true
+			all[p] = true
+		}
+	}
+	if len(sets) < 2 {
+		return nil
+	}
+	var warnings []Warning
+	for _, period := range sortedKeys(all) {
+		var present, missing []string
+		for _, name := range sortedKeys(sets) {
+			if sets[name][period] {
+				present = append(present, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("in %s but not %s", strings.Join(present, ", "), strings.Join(missing, ", "))
+		start, err := parsePeriod(period)
+		if err != nil {
+			msg += "; it is not a recognized period format, so the files likely write periods differently (see -period-format)"
+		}
+		for _, name := range missing {
+			for _, other := range sortedKeys(sets[name]) {
+				if otherStart, err2 := parsePeriod(other); err == nil && err2 == nil && otherStart.Equal(start) {
+					msg += fmt.Sprintf("; %s has %q for the same date, a likely format mismatch", name, other)
+				}
+			}
+		}
+		warnings = append(warnings, Warning{Category: "pay-period", PayPeriod: period, Message: msg})
+	}
+	return warnings
+}
@@ -0,0 +1,15 @@
+package main
+
+// pieceGross is gross pay for piece-rate work under the FLSA regular-rate method.
+// The piece earnings plus any hourly pay for every hour worked are the straight-time
+// earnings, and the regular rate is those earnings over all hours worked. Since
+// straight time already pays each overtime hour once, overtime hours earn only a
+// further half of the regular rate and double-time hours a further whole.
+func pieceGross(pieceEarnings, rate Money, regular, overtime, doubleTime int, acc *float64) Money {
+	hours := regular + overtime + doubleTime
+	straight := pieceEarnings + rate.MulHours(hours)
+	if hours == 0 || (overtime == 0 && doubleTime == 0) {
+		return straight
+	}
+	return straight + roundedMul(straight, (0.5*float64(overtime)+float64(doubleTime))/float64(hours), acc)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+)
+
+// EmployeeNetDelta is one employee's net pay, summed over their register lines in
+// one currency, in the previous output and in this run. Previous is nil for an
+// employee the previous output did not have, Current nil for one it no longer has.
+type EmployeeNetDelta struct {
+	EmployeeID        string
+	EmployeeName      string
+	Currency          string
+	Previous, Current *Money
+}
+
+// Status describes the delta as -preview-diff prints it.
+func (d EmployeeNetDelta) Status() string {
+	switch {
+	case d.Previous == nil:
+		return "added"
+	case d.Current == nil:
+		return "removed"
+	case *d.Previous != *d.Current:
+		return "changed"
+	}
+	return "unchanged"
+}
+
+// diffEmployeeNet sums each employee's net pay per currency in previous and
+// current and pairs the sums up, in employee order.
+func diffEmployeeNet(previous, current []PayRegister) []EmployeeNetDelta {
+	deltas := make(map[string]*EmployeeNetDelta)
+	add := func(reg PayRegister, isPrevious bool) {
+		key := makeKey(reg.EmployeeID, reg.Currency)
+		d, ok := deltas[key]
+		if !ok {
+			d = &EmployeeNetDelta{EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, Currency: reg.Currency}
+			deltas[key] = d
+		}
+		side := &d.Current
+		if isPrevious {
+			side = &d.Previous
+		}
+		if *side == nil {
+			*side = new(Money)
+		}
+		**side += reg.NetPay
+	}
+	for _, reg := range previous {
+		add(reg, true)
+	}
+	for _, reg := range current {
+		add(reg, false)
+	}
+	out := make([]EmployeeNetDelta, 0, len(deltas))
+	for _, key := range sortedKeys(deltas) {
+		out = append(out, *deltas[key])
+	}
+	return out
+}
+
+// readPreviousRegister reads the register a previous run left at filename in
+// format (csv or ndjson), for -preview-diff. It returns false, without an error,
+// when there is no such file. scale converts amounts read back from a
+// -number-format cents register into cents.
+func readPreviousRegister(filename, format string, scale Money) ([]PayRegister, bool, error) {
+	if _, err := os.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	var registers []PayRegister
+	var err error
+	switch format {
+	case "ndjson":
+		registers, err = readRegisterNDJSON(filename)
+	default:
+		registers, err = readRegisterFile(filename, ReaderOptions{Delimiter: ','})
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range registers {
+		registers[i].NetPay *= scale
+	}
+	return registers, true, nil
+}
+
+// writePreviewDiff prints the employees whose net pay differs from the previous
+// output's, or who were added or removed, then a count of each status. Unchanged
+// employees are counted but not listed.
+func writePreviewDiff(w io.Writer, filename string, deltas []EmployeeNetDelta, opts WriterOptions) error {
+	counts := make(map[string]int)
+	fmt.Fprintf(w, "Changes from the previous %s:\n", filename)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Employee ID\tEmployee Name\tStatus\tPrevious Net\tNew Net\tChange\t")
+	for _, d := range deltas {
+		status := d.Status()
+		counts[status]++
+		if status == "unchanged" {
+			continue
+		}
+		format := opts.formatter(d.Currency)
+		money := func(m *Money) string {
+			if m == nil {
+				return "-"
+			}
+			return opts.Display.apply(format(*m))
+		}
+		var previous, current Money
+		if d.Previous != nil {
+			previous = *d.Previous
+		}
+		if d.Current != nil {
+			current = *d.Current
+		}
+		change := current - previous
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", d.EmployeeID, d.EmployeeName, status, money(d.Previous), money(d.Current), money(&change))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d changed, %d added, %d removed, %d unchanged\n", counts["changed"], counts["added"], counts["removed"], counts["unchanged"])
+	return err
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// YTDTotals are the gross, taxes (employee taxes and contributions, as in the
+// effective tax rate) and net of a run of register lines.
+type YTDTotals struct {
+	Gross, Taxes, Net Money
+}
+
+func (t *YTDTotals) add(reg PayRegister) {
+	t.Gross += reg.GrossWages
+	t.Taxes += reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare + contributionTotal(reg.Contributions)
+	t.Net += reg.NetPay
+}
+
+func (t YTDTotals) plus(o YTDTotals) YTDTotals {
+	return YTDTotals{t.Gross + o.Gross, t.Taxes + o.Taxes, t.Net + o.Net}
+}
+
+// YTDGrowth is one row of the -compare-to-prior-year report: this year's and last
+// year's totals through the same period index, for one employee (in one
+// currency) at the last compared period, or for everyone through each period.
+// Prior or Current is nil for an employee paid in only one of the years. The
+// headcounts, employees paid in the period itself, are set on TOTAL rows only.
+type YTDGrowth struct {
+	Index                            int
+	PriorPeriod, CurrentPeriod       string
+	EmployeeID, EmployeeName         string
+	Currency                         string
+	Prior, Current                   *YTDTotals
+	PriorHeadcount, CurrentHeadcount int
+}
+
+// registerPeriods lists the pay periods registers has lines for, in
+// chronological order.
+func registerPeriods(registers []PayRegister) []string {
+	seen := make(map[string]bool)
+	for _, reg := range registers {
+		seen[makeKey("", reg.PayPeriod)] = true
+	}
+	periods := chronologicalKeys(seen)
+	for i, key := range periods {
+		_, periods[i], _ = strings.Cut(key, "|")
+	}
+	return periods
+}
+
+// compareYears aligns two years' registers by period index, the first period of
+// each year with the other's first and so on, as far as both go, and sums each
+// year through the aligned periods. It returns one row per employee and currency,
+// in key order, then per currency one TOTAL row per period index carrying the
+// totals through it, and how many periods were compared. Lines of periods past
+// the shorter year's last are left out of both sides.
+func compareYears(prior, current []PayRegister) ([]YTDGrowth, int) {
+	priorPeriods, currentPeriods := registerPeriods(prior), registerPeriods(current)
+	n := min(len(priorPeriods), len(currentPeriods))
+	index := func(periods []string) map[string]int {
+		m := make(map[string]int, n)
+		for i, period := range periods[:n] {
+			m[period] = i
+		}
+		return m
+	}
+	priorIndex, currentIndex := index(priorPeriods), index(currentPeriods)
+
+	employees := make(map[string]*YTDGrowth)
+	// Per currency, each period index's own amounts and the employees paid in it.
+	type periodTotals struct {
+		prior, current YTDTotals
+		priorPaid      map[string]bool
+		currentPaid    map[string]bool
+	}
+	byCurrency := make(map[string][]periodTotals)
+	add := func(reg PayRegister, isPrior bool) {
+		periods := currentIndex
+		if isPrior {
+			periods = priorIndex
+		}
+		i, ok := periods[reg.PayPeriod]
+		if !ok {
+			return
+		}
+		key := makeKey(reg.EmployeeID, reg.Currency)
+		e := employees[key]
+		if e == nil {
+			e = &YTDGrowth{Index: n, PriorPeriod: priorPeriods[n-1], CurrentPeriod: currentPeriods[n-1],
+				EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, Currency: reg.Currency}
+			employees[key] = e
+		}
+		totals := byCurrency[reg.Currency]
+		if totals == nil {
+			totals = make([]periodTotals, n)
+			for j := range totals {
+				totals[j].priorPaid, totals[j].currentPaid = make(map[string]bool), make(map[string]bool)
+			}
+			byCurrency[reg.Currency] = totals
+		}
+		side, sum, paid := &e.Current, &totals[i].current, totals[i].currentPaid
+		if isPrior {
+			side, sum, paid = &e.Prior, &totals[i].prior, totals[i].priorPaid
+		}
+		if *side == nil {
+			*side = &YTDTotals{}
+		}
+		(*side).add(reg)
+		sum.add(reg)
+		paid[reg.EmployeeID] = true
+	}
+	for _, reg := range prior {
+		add(reg, true)
+	}
+	for _, reg := range current {
+		add(reg, false)
+	}
+
+	var out []YTDGrowth
+	for _, key := range sortedKeys(employees) {
+		out = append(out, *employees[key])
+	}
+	for _, code := range sortedKeys(byCurrency) {
+		var prior, current YTDTotals
+		for i, t := range byCurrency[code] {
+			prior, current = prior.plus(t.prior), current.plus(t.current)
+			priorSum, currentSum := prior, current
+			out = append(out, YTDGrowth{Index: i + 1, PriorPeriod: priorPeriods[i], CurrentPeriod: currentPeriods[i],
+				EmployeeID: "TOTAL", Currency: code, Prior: &priorSum, Current: &currentSum,
+				PriorHeadcount: len(t.priorPaid), CurrentHeadcount: len(t.currentPaid)})
+		}
+	}
+	return out, n
+}
+
+// readRegisterSet reads a comma-separated list of register CSVs as one set of
+// lines, e.g. a year's -split-by-period files.
+func readRegisterSet(filenames string, opts ReaderOptions) ([]PayRegister, error) {
+	var registers []PayRegister
+	for _, filename := range strings.Split(filenames, ",") {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		lines, err := readRegisterFile(filename, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		registers = append(registers, lines...)
+	}
+	return registers, nil
+}
+
+// growth is the change from prior to current as a fraction of prior, like the
+// register's Effective Tax Rate, or blank when there was nothing last year.
+func growth(prior, current Money) string {
+	if prior == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(current-prior)/float64(prior), 'f', 4, 64)
+}
+
+// writeYTDGrowth writes the -compare-to-prior-year report. An employee's Status is
+// both, prior only (not paid this year so far) or current only (new this year);
+// the year they are missing from counts as zero.
+func writeYTDGrowth(rows []YTDGrowth, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create year-over-year file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Period Index", "Prior Period", "Current Period", "Employee ID", "Employee Name", "Currency", "Status",
+		"Prior YTD Gross", "Current YTD Gross", "Gross Growth", "Prior YTD Taxes", "Current YTD Taxes", "Taxes Growth",
+		"Prior YTD Net", "Current YTD Net", "Net Growth", "Prior Headcount", "Current Headcount", "Headcount Change"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write year-over-year header: %v", err)
+	}
+	for _, r := range rows {
+		var prior, current YTDTotals
+		status := "both"
+		switch {
+		case r.Prior == nil:
+			current, status = *r.Current, "current only"
+		case r.Current == nil:
+			prior, status = *r.Prior, "prior only"
+		default:
+			prior, current = *r.Prior, *r.Current
+		}
+		headcounts := []string{"", "", ""}
+		if r.EmployeeID == "TOTAL" {
+			status = ""
+			headcounts = []string{strconv.Itoa(r.PriorHeadcount), strconv.Itoa(r.CurrentHeadcount), strconv.Itoa(r.CurrentHeadcount - r.PriorHeadcount)}
+		}
+		money := opts.formatter(r.Currency)
+		row := []string{strconv.Itoa(r.Index), csvText(r.PriorPeriod), csvText(r.CurrentPeriod), csvText(r.EmployeeID), csvText(r.EmployeeName),
+			opts.currencyLabel(PayRegister{Currency: r.Currency}), status,
+			money(prior.Gross), money(current.Gross), growth(prior.Gross, current.Gross),
+			money(prior.Taxes), money(current.Taxes), growth(prior.Taxes, current.Taxes),
+			money(prior.Net), money(current.Net), growth(prior.Net, current.Net)}
+		if err := writer.Write(append(row, headcounts...)); err != nil {
+			return fmt.Errorf("cannot write year-over-year row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write year-over-year file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestCompareYears(t *testing.T) {
+	// This year's only period is compared with last year's first, not its second,
+	// and an employee paid only last year counts toward last year's headcount alone.
+	lastYear := []PayRegister{{EmployeeID: "008", PayPeriod: "2023-01", GrossWages: 100000}, {EmployeeID: "009", PayPeriod: "2023-01", GrossWages: 50000},
+		{EmployeeID: "008", PayPeriod: "2023-02", GrossWages: 100000}}
+	thisYear := []PayRegister{{EmployeeID: "008", PayPeriod: "2024-01", GrossWages: 110000}}
+	rows, n := compareYears(lastYear, thisYear)
+	if n != 1 || len(rows) != 3 {
+		t.Fatalf("got %d period(s), rows %+v, want 1 period and 3 rows", n, rows)
+	}
+	if e := rows[0]; e.EmployeeID != "008" || e.Prior.Gross != 100000 || e.Current.Gross != 110000 {
+		t.Errorf("employee 008: got %+v, want 1000.00 last year against 1100.00", e)
+	}
+	if e := rows[1]; e.EmployeeID != "009" || e.Current != nil {
+		t.Errorf("employee 009: got %+v, want prior only", e)
+	}
+	if total := rows[2]; total.Prior.Gross != 150000 || total.Current.Gross != 110000 || total.PriorHeadcount != 2 || total.CurrentHeadcount != 1 {
+		t.Errorf("TOTAL: got %+v, want 1500.00 for 2 employees against 1100.00 for 1", total)
+	}
+}
+
+func TestGrowth(t *testing.T) {
+	for _, tc := range []struct {
+		prior, current Money
+		want           string
+	}{
+		{100000, 110000, "0.1000"},
+		{100000, 50000, "-0.5000"},
+		{0, 110000, ""},
+	} {
+		if got := growth(tc.prior, tc.current); got != tc.want {
+			t.Errorf("growth(%s, %s): got %q, want %q", tc.prior, tc.current, got, tc.want)
+		}
+	}
+}
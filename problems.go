@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Problem is one thing wrong with a run's data, found in -collect-all mode: a row
+// that failed to parse, a record with nothing to join to, a row that failed to
+// compute, and so on.
+type Problem struct {
+	Stage string // the input or step it was found in: payroll, time, compute, ...
+	Code  int    // the exit code it would have ended a -fail-fast run with
+	Err   error
+}
+
+// problemLog gathers every problem a -collect-all run finds, so a messy dataset can
+// be fixed in one pass instead of one error per run. A nil *problemLog means
+// -fail-fast: callers stop at the first problem as they always have.
+type problemLog struct {
+	mu       sync.Mutex // -sorted-input reads its inputs concurrently
+	problems []Problem
+}
+
+func (p *problemLog) add(stage string, code int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.problems = append(p.problems, Problem{Stage: stage, Code: code, Err: err})
+}
+
+// exitIfAny logs every problem collected, grouped by stage, and ends the run when
+// there were any. The exit code is the first problem's, so an unreadable input
+// still reports as such when it also caused later problems.
+func (p *problemLog) exitIfAny() {
+	if p == nil || len(p.problems) == 0 {
+		return
+	}
+	byStage := make(map[string][]Problem)
+	var stages []string
+	for _, prob := range p.problems {
+		if byStage[prob.Stage] == nil {
+			stages = append(stages, prob.Stage)
+		}
+		byStage[prob.Stage] = append(byStage[prob.Stage], prob)
+	}
+	log.Printf("Found %d problem(s):", len(p.problems))
+	for _, stage := range stages {
+		log.Printf("  %s (%d):", stage, len(byStage[stage]))
+		for _, prob := range byStage[stage] {
+			log.Printf("    %v", prob.Err)
+			if activeReport != nil {
+				activeReport.Errors = append(activeReport.Errors, fmt.Sprintf("%s: %v", stage, prob.Err))
+			}
+		}
+	}
+	fatalf(p.problems[0].Code, "%d problem(s) found; nothing was written", len(p.problems))
+}
+
+// unmatchedRecords lists the records that will not reach the register because
+// their employee-period is missing from another input: payroll rows without time
+// or benefits (unless opts fills those in) and time or benefits rows with no
+// payroll row. A missing benefits row is fine when cfg has default benefits for
+// the job title. computeRegister skips these silently. Each is a RowError.
+func unmatchedRecords(payrollMap map[string]PayrollRecord, timeMap map[string]TimeRecord, benefitsMap map[string]BenefitsRecord, cfg TaxConfig, opts ComputeOptions) []error {
+	var errs []error
+	for _, key := range sortedKeys(payrollMap) {
+		rec := payrollMap[key]
+		if opts.Period != "" && rec.PayPeriod != opts.Period || !opts.includes(rec.EmployeeID) {
+			continue
+		}
+		_, okTime := timeMap[key]
+		_, okBenefits := benefitsMap[key]
+		if !okBenefits {
+			_, okBenefits = cfg.defaultBenefits(rec.EmployeeID, rec.PayPeriod, rec.JobTitle)
+		}
+		switch {
+		case opts.Join != joinInner:
+			// Missing time and benefits are zero-filled.
+		case !okTime && !opts.IncludeZeroHours:
+			errs = append(errs, RowError{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod, Err: errors.New("payroll record has no time record")})
+		case !okBenefits && !opts.ZeroMissingBenefits:
+			errs = append(errs, RowError{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod, Err: errors.New("payroll record has no benefits record")})
+		}
+	}
+	var orphans []RowError
+	if opts.Join == joinOuter {
+		// Orphans get register lines of their own.
+		return errs
+	}
+	for key, rec := range timeMap {
+		if _, ok := payrollMap[key]; !ok && (opts.Period == "" || rec.PayPeriod == opts.Period) && opts.includes(rec.EmployeeID) {
+			orphans = append(orphans, RowError{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod, Err: errors.New("time record has no payroll record")})
+		}
+	}
+	for key, rec := range benefitsMap {
+		if _, ok := payrollMap[key]; !ok && (opts.Period == "" || rec.PayPeriod == opts.Period) && opts.includes(rec.EmployeeID) {
+			orphans = append(orphans, RowError{EmployeeID: rec.EmployeeID, PayPeriod: rec.PayPeriod, Err: errors.New("benefits record has no payroll record")})
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Error() < orphans[j].Error() })
+	for _, orphan := range orphans {
+		errs = append(errs, orphan)
+	}
+	return errs
+}
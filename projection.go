@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// AnnualProjection is one employee's year projected from a single period: every
+// amount of the basis line times the config's periods per year, except that Social
+// Security and capped contributions stop at their wage bases, as the register
+// stops them. It is an estimate for offer letters and planning, not year-to-date
+// actuals.
+type AnnualProjection struct {
+	EmployeeID     string
+	EmployeeName   string
+	Currency       string
+	BasisPeriod    string
+	PeriodsPerYear int
+	Gross          Money
+	FederalTax     Money
+	StateTax       Money
+	LocalTax       Money
+	SocialSecurity Money
+	Medicare       Money
+	Contributions  Money
+	// OtherDeductions is everything else the line deducts: benefits, custom
+	// deductions, and arrears or floors.
+	OtherDeductions Money
+	Net             Money
+	// SocialSecurityStops is the pay period of the year in which wages reach the
+	// Social Security wage base, 0 when they never do.
+	SocialSecurityStops int
+}
+
+// projectAnnual projects each employee's year (per currency) from their latest
+// regular register line. Amounts held to a wage base on that line (Social
+// Security's or a contribution's) project from the held amount, so a period early
+// in the year is the better basis when a wage base is set.
+func projectAnnual(registers []PayRegister, cfg TaxConfig) ([]AnnualProjection, error) {
+	basis := make(map[string]PayRegister)
+	for _, reg := range registers {
+		if reg.IsAdjustment || reg.IsCorrection {
+			continue
+		}
+		key := makeKey(reg.EmployeeID, reg.Currency)
+		if prev, ok := basis[key]; !ok || periodBefore(prev.PayPeriod, reg.PayPeriod) {
+			basis[key] = reg
+		}
+	}
+	result := make([]AnnualProjection, 0, len(basis))
+	for _, key := range sortedKeys(basis) {
+		reg := basis[key]
+		periodCfg, err := cfg.forPeriod(reg.PayPeriod)
+		if err != nil {
+			return nil, err
+		}
+		n := periodCfg.PeriodsPerYear
+		if n <= 0 {
+			return nil, fmt.Errorf("cannot project period %s: the config sets no periodsPerYear", reg.PayPeriod)
+		}
+		perYear := func(m Money) Money { return m * Money(n) }
+		p := AnnualProjection{
+			EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, Currency: reg.Currency,
+			BasisPeriod: reg.PayPeriod, PeriodsPerYear: n,
+			Gross:      perYear(reg.GrossWages),
+			FederalTax: perYear(reg.FederalTax),
+			StateTax:   perYear(reg.StateTax),
+			LocalTax:   perYear(reg.LocalTax),
+			Medicare:   perYear(reg.Medicare),
+		}
+		p.SocialSecurity, p.SocialSecurityStops = capProjection(reg.SocialSecurity, reg.SocialSecurityWages, periodCfg.SocialSecurityWageBase, n)
+		for i, a := range reg.Contributions {
+			var wageBase Money
+			if i < len(periodCfg.Contributions) && periodCfg.Contributions[i].Name == a.Name {
+				wageBase = periodCfg.Contributions[i].WageBase
+			}
+			projected, _ := capProjection(a.Employee, a.Earnings, wageBase, n)
+			p.Contributions += projected
+		}
+		taxes := reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare + contributionTotal(reg.Contributions)
+		p.OtherDeductions = perYear(reg.TotalDeductions - taxes)
+		p.Net = p.Gross - p.FederalTax - p.StateTax - p.LocalTax - p.SocialSecurity - p.Medicare - p.Contributions - p.OtherDeductions
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// periodBefore orders two pay periods by start date, falling back to their text
+// when either cannot be parsed.
+func periodBefore(a, b string) bool {
+	startA, errA := parsePeriod(a)
+	startB, errB := parsePeriod(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return startA.Before(startB)
+}
+
+// capProjection projects a per-period amount levied on wages over n periods,
+// stopping once the year's wages reach wageBase (none when zero). It also returns
+// the period the base is reached in, 0 when it is not.
+func capProjection(amount, wages, wageBase Money, n int) (Money, int) {
+	annual := amount * Money(n)
+	if wageBase <= 0 || wages <= 0 || wages*Money(n) <= wageBase {
+		return annual, 0
+	}
+	share := float64(wageBase) / float64(wages*Money(n))
+	return Money(math.Round(float64(annual) * share)), int(math.Ceil(float64(wageBase) / float64(wages)))
+}
+
+// writeAnnualProjections writes the -projection report as CSV, one row per
+// employee. Every amount column is labeled as projected.
+func writeAnnualProjections(projections []AnnualProjection, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create projection file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	header := []string{"Employee ID", "Employee Name", "Currency", "Basis Period", "Periods Per Year",
+		"Projected Gross", "Projected Federal Tax", "Projected State Tax", "Projected Local Tax",
+		"Projected Social Security", "Projected Medicare", "Projected Contributions", "Projected Other Deductions",
+		"Projected Net Pay", "Social Security Stops In Period"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write projection header: %v", err)
+	}
+	for _, p := range projections {
+		money := opts.formatter(p.Currency)
+		stops := ""
+		if p.SocialSecurityStops > 0 {
+			stops = strconv.Itoa(p.SocialSecurityStops)
+		}
+		row := []string{csvText(p.EmployeeID), csvText(p.EmployeeName), opts.currencyLabel(PayRegister{Currency: p.Currency}),
+			csvText(p.BasisPeriod), strconv.Itoa(p.PeriodsPerYear),
+			money(p.Gross), money(p.FederalTax), money(p.StateTax), money(p.LocalTax),
+			money(p.SocialSecurity), money(p.Medicare), money(p.Contributions), money(p.OtherDeductions),
+			money(p.Net), stops}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write projection row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write projection file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PTOAccrual is how fast one employee accrues paid time off (-pto-accruals):
+// PerPeriod hours each pay period plus PerHour hours for every hour worked.
+type PTOAccrual struct {
+	PerPeriod float64
+	PerHour   float64
+}
+
+// PTOAccrued is one register line's paid time off accrual: the hours accrued and
+// their value at the line's hourly rate, and the employee's totals for the
+// calendar year through this line. Hours are kept to a hundredth.
+type PTOAccrued struct {
+	Hours    float64 `json:"hours"`
+	Value    Money   `json:"value"`
+	YTDHours float64 `json:"ytdHours"`
+	YTDValue Money   `json:"ytdValue"`
+}
+
+// ptoColumns are the register columns for PTOAccrued, after any contributions'.
+var ptoColumns = []string{"PTO Accrued Hours", "PTO Accrued Value", "PTO YTD Hours", "PTO YTD Value"}
+
+// readPTOAccruals reads the -pto-accruals CSV: Employee ID, then Hours Per Period
+// and/or Hours Per Hour Worked located by header. A blank cell accrues nothing;
+// listing an employee twice is an error.
+func readPTOAccruals(filename string, opts ReaderOptions) (map[string]PTOAccrual, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a PTO accruals file must have a header row")
+	}
+	accruals := make(map[string]PTOAccrual)
+	err := readCSV(filename, "PTO accruals", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		if _, ok := accruals[id]; ok {
+			return fmt.Errorf("employee %s is listed twice (row %d)", id, line)
+		}
+		var a PTOAccrual
+		for _, rate := range []struct {
+			column string
+			dst    *float64
+		}{
+			{"Hours Per Period", &a.PerPeriod},
+			{"Hours Per Hour Worked", &a.PerHour},
+		} {
+			v := strings.TrimSpace(cols.value(row, rate.column))
+			if v == "" {
+				continue
+			}
+			hours, err := strconv.ParseFloat(v, 64)
+			if err != nil || hours < 0 || math.IsInf(hours, 0) {
+				return fmt.Errorf("error parsing %s in row %d: want a non-negative number of hours, got %q", rate.column, line, v)
+			}
+			*rate.dst = hours
+		}
+		accruals[id] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accruals, nil
+}
+
+// roundHours rounds hours to a hundredth.
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+// accruePTO sets a line's PTO accrual and counts it toward the employee's year in
+// ytd (keyed employee|year|currency). The per-period hours accrue on the first of
+// a period's lines (split jobs give several) unless it is a correction; the
+// per-hour hours accrue on every hour the line pays, negative hours reversing
+// them. Lines must arrive in each employee's chronological order.
+func accruePTO(reg *PayRegister, accrual PTOAccrual, first bool, ytd map[string]PTOAccrued) {
+	hours := accrual.PerHour * float64(reg.RegularHours+reg.OvertimeHours+reg.DoubleTimeHours)
+	if first && !reg.IsCorrection {
+		hours += accrual.PerPeriod
+	}
+	pto := PTOAccrued{Hours: roundHours(hours)}
+	pto.Value = reg.HourlyRate.MulRate(pto.Hours)
+	if start, err := parsePeriod(reg.PayPeriod); err == nil {
+		key := fmt.Sprintf("%s|%d|%s", reg.EmployeeID, start.Year(), reg.Currency)
+		sofar := ytd[key]
+		pto.YTDHours = roundHours(sofar.YTDHours + pto.Hours)
+		pto.YTDValue = sofar.YTDValue + pto.Value
+		ytd[key] = pto
+	}
+	reg.PTO = &pto
+}
+
+// hasPTO reports whether any line accrued PTO, for the register's PTO columns.
+func hasPTO(registers []PayRegister) bool {
+	for _, reg := range registers {
+		if reg.PTO != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ptoCells renders a line's PTO columns, blank when it has no accrual. A totals
+// line (one with no employee) leaves the year-to-date columns blank, since they
+// do not add across lines.
+func ptoCells(reg PayRegister, money func(Money) string) []string {
+	cells := make([]string, len(ptoColumns))
+	if reg.PTO == nil {
+		return cells
+	}
+	hours := func(h float64) string { return strconv.FormatFloat(h, 'f', 2, 64) }
+	cells[0], cells[1] = hours(reg.PTO.Hours), money(reg.PTO.Value)
+	if reg.EmployeeID != "" {
+		cells[2], cells[3] = hours(reg.PTO.YTDHours), money(reg.PTO.YTDValue)
+	}
+	return cells
+}
+
+// readPTOCell reads one of a register file's PTO columns into reg. A line with
+// every PTO cell blank has no accrual.
+func readPTOCell(reg *PayRegister, column, cell string) error {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return nil
+	}
+	if reg.PTO == nil {
+		reg.PTO = &PTOAccrued{}
+	}
+	var err error
+	switch column {
+	case "PTO Accrued Hours":
+		reg.PTO.Hours, err = strconv.ParseFloat(cell, 64)
+	case "PTO Accrued Value":
+		reg.PTO.Value, err = parseRegisterAmount(cell)
+	case "PTO YTD Hours":
+		reg.PTO.YTDHours, err = strconv.ParseFloat(cell, 64)
+	case "PTO YTD Value":
+		reg.PTO.YTDValue, err = parseRegisterAmount(cell)
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestAccruePTO(t *testing.T) {
+	// 80 hours at 0.0385 is 3.08 hours, 77.00 at 25.00, and 6.16 hours by the
+	// second period.
+	ytd := make(map[string]PTOAccrued)
+	for _, period := range []string{"2024-05", "2024-06"} {
+		reg := PayRegister{EmployeeID: "006", PayPeriod: period, HourlyRate: 2500, RegularHours: 80}
+		accruePTO(&reg, PTOAccrual{PerHour: 0.0385}, true, ytd)
+		if period == "2024-06" && (reg.PTO.Hours != 3.08 || reg.PTO.Value != 7700 || reg.PTO.YTDHours != 6.16 || reg.PTO.YTDValue != 15400) {
+			t.Errorf("got %+v, want 3.08 hours worth 77.00, 6.16 worth 154.00 year to date", *reg.PTO)
+		}
+	}
+}
+
+func TestAccruePTOPerPeriod(t *testing.T) {
+	// The per-period hours accrue once a period, on its first line, and not on
+	// a correction; a new year starts the year to date again.
+	accrual := PTOAccrual{PerPeriod: 4}
+	ytd := make(map[string]PTOAccrued)
+	for _, tc := range []struct {
+		reg          PayRegister
+		first        bool
+		hours, total float64
+	}{
+		{PayRegister{EmployeeID: "006", PayPeriod: "2024-12", RegularHours: 40}, true, 4, 4},
+		{PayRegister{EmployeeID: "006", PayPeriod: "2024-12", RegularHours: 40}, false, 0, 4},
+		{PayRegister{EmployeeID: "006", PayPeriod: "2024-12", RegularHours: -8, IsCorrection: true}, true, 0, 4},
+		{PayRegister{EmployeeID: "006", PayPeriod: "2025-01", RegularHours: 40}, true, 4, 4},
+	} {
+		reg := tc.reg
+		accruePTO(&reg, accrual, tc.first, ytd)
+		if reg.PTO.Hours != tc.hours || reg.PTO.YTDHours != tc.total {
+			t.Errorf("%s (first %v, correction %v): got %g hours, %g year to date, want %g and %g",
+				reg.PayPeriod, tc.first, reg.IsCorrection, reg.PTO.Hours, reg.PTO.YTDHours, tc.hours, tc.total)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+// Redaction schemes (-redact) for a register shared with reviewers who may see
+// who is paid and for what hours, but not how much.
+const (
+	// redactMask writes every amount as redactedAmount.
+	redactMask = "mask"
+	// redactBucket writes every amount as the range of width Redaction.Bucket
+	// that holds it, e.g. "1000.00 to 2000.00" for 1234.56 in buckets of 1000.
+	redactBucket = "bucket"
+)
+
+// redactedAmount replaces each amount under redactMask. It cannot be mistaken
+// for a real amount, zero included.
+const redactedAmount = "***"
+
+// Redaction is how the register's amounts are hidden: Scheme is redactMask,
+// redactBucket, or empty for none. Hours, rates of tax, IDs, names, titles and
+// periods are written as usual.
+type Redaction struct {
+	Scheme string
+	Bucket Money
+}
+
+// wrap applies the redaction to an amount formatter.
+func (r Redaction) wrap(format func(Money) string) func(Money) string {
+	switch r.Scheme {
+	case redactMask:
+		return func(Money) string { return redactedAmount }
+	case redactBucket:
+		return func(m Money) string {
+			low := m - m%r.Bucket
+			if m%r.Bucket < 0 {
+				low -= r.Bucket
+			}
+			return fmt.Sprintf("%s to %s", format(low), format(low+r.Bucket))
+		}
+	}
+	return format
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// isRemote reports whether an input name is a URL to fetch (http://, https://, or
+// s3://) rather than a local path.
+func isRemote(name string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://"} {
+		if len(name) > len(scheme) && strings.EqualFold(name[:len(scheme)], scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteClient fetches remote inputs. The timeout bounds the whole download.
+var remoteClient = &http.Client{Timeout: 10 * time.Minute}
+
+// openInput opens a local input file, or fetches a remote one, retrying transient
+// failures per opts either way.
+func openInput(name string, opts ReaderOptions) (io.ReadCloser, error) {
+	if !isRemote(name) {
+		return openWithRetry(name, opts)
+	}
+	delay := opts.OpenRetryDelay
+	for attempt := 0; ; attempt++ {
+		body, err := fetchRemote(name)
+		var status httpStatusError
+		if err == nil || attempt >= opts.OpenRetries || errors.As(err, &status) && status.code < 500 {
+			return body, err
+		}
+		logf("Fetching %s failed (%v); retrying in %v", name, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// httpStatusError is a fetch answered with a status other than 200. Not Found and
+// Forbidden unwrap to the fs errors a missing or unreadable local file gives, so
+// they get the same exit codes.
+type httpStatusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.url, e.status)
+}
+
+func (e httpStatusError) Unwrap() error {
+	switch e.code {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// fetchRemote starts downloading a URL and returns the response body. s3:// URLs
+// are fetched over HTTPS, signed with AWS credentials when there are any (see
+// awsCredentialsFromEnv); without them the request goes unsigned, which suffices
+// for a public bucket.
+func fetchRemote(name string) (io.ReadCloser, error) {
+	target := name
+	var creds awsCredentials
+	var region string
+	if strings.EqualFold(name[:5], "s3://") {
+		bucket, key, _ := strings.Cut(name[5:], "/")
+		if bucket == "" || key == "" {
+			return nil, fmt.Errorf("%s: want s3://bucket/key", name)
+		}
+		region = firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+		if endpoint := firstNonEmpty(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); endpoint != "" {
+			// Custom endpoints (MinIO, LocalStack) take path-style requests.
+			target = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + s3Escape(key)
+		} else {
+			target = "https://" + bucket + ".s3." + region + ".amazonaws.com/" + s3Escape(key)
+		}
+		var err error
+		if creds, err = awsCredentialsFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyID != "" {
+		signAWSv4(req, creds, region, "s3", time.Now())
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, httpStatusError{url: name, code: resp.StatusCode, status: resp.Status}
+	}
+	return resp.Body, nil
+}
+
+// readInput reads a whole local or remote input into memory.
+func readInput(name string, opts ReaderOptions) ([]byte, error) {
+	file, err := openInput(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", name, err)
+	}
+	return data, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// awsCredentials are what an S3 request is signed with.
+type awsCredentials struct {
+	AccessKeyID, SecretAccessKey, SessionToken string
+}
+
+// awsCredentialsFromEnv finds AWS credentials the way the AWS tools do, minus
+// instance roles: the AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN
+// variables, else the AWS_PROFILE (or default) profile of the shared credentials
+// file (AWS_SHARED_CREDENTIALS_FILE, or ~/.aws/credentials). Finding none is not an
+// error: the request then goes unsigned.
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{id, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return awsCredentials{}, nil
+	} else if err != nil {
+		return awsCredentials{}, fmt.Errorf("cannot read AWS credentials: %v", err)
+	}
+	defer file.Close()
+	profile := firstNonEmpty(os.Getenv("AWS_PROFILE"), "default")
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, fmt.Errorf("cannot read AWS credentials: %v", err)
+	}
+	return creds, nil
+}
+
+// s3Escape percent-encodes an object key the way Signature Version 4 expects:
+// everything but unreserved characters and the slashes between segments.
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body, which every GET has.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signAWSv4 adds AWS Signature Version 4 headers to a bodiless request.
+func signAWSv4(req *http.Request, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	request := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonical.String(), signed, emptyPayloadHash}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
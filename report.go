@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// RunReport is the machine-readable summary of a run (-report): what was read,
+// how long each phase took, what went wrong, the totals produced, and the settings
+// in effect. It is written on success and, through fatalf, on failure, so an
+// orchestrator always finds one.
+type RunReport struct {
+	Status     string    `json:"status"` // "ok" or "failed"
+	ExitCode   int       `json:"exitCode"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	OutputFile string    `json:"outputFile,omitempty"`
+	FromCache  bool      `json:"fromCache,omitempty"`
+
+	// PhaseSeconds holds the duration of each phase that ran: read, compute, write, total.
+	PhaseSeconds map[string]float64 `json:"phaseSeconds"`
+	// Records counts the records read per input and the register lines produced.
+	Records map[string]int `json:"records"`
+
+	Warnings []string `json:"warnings"`
+	// Errors lists rows skipped because they failed to compute and, for a failed
+	// run, the error that ended it.
+	Errors []string `json:"errors"`
+
+	Totals []ReportTotal `json:"totals"`
+
+	// Flags are the flags set on the command line; everything else had its default.
+	Flags     map[string]string `json:"flags"`
+	TaxConfig *TaxConfig        `json:"taxConfig,omitempty"`
+
+	path string
+}
+
+// ReportTotal sums the register lines paid in one currency.
+type ReportTotal struct {
+	Currency        string `json:"currency"`
+	Lines           int    `json:"lines"`
+	GrossWages      Money  `json:"grossWages"`
+	TotalDeductions Money  `json:"totalDeductions"`
+	NetPay          Money  `json:"netPay"`
+}
+
+// activeReport is the -report being collected, if any. fatalf writes it before exiting.
+var activeReport *RunReport
+
+func newRunReport(path string) *RunReport {
+	return &RunReport{
+		StartedAt:    time.Now().UTC(),
+		PhaseSeconds: make(map[string]float64),
+		Records:      make(map[string]int),
+		Warnings:     []string{},
+		Errors:       []string{},
+		Totals:       []ReportTotal{},
+		path:         path,
+	}
+}
+
+// phase records how long a phase took. Like the dashboard, the report methods are
+// no-ops on a nil *RunReport, so callers need not check whether -report is set.
+func (r *RunReport) phase(name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.PhaseSeconds[name] = d.Seconds()
+}
+
+// warn adds a warning.
+func (r *RunReport) warn(w fmt.Stringer) {
+	if r == nil {
+		return
+	}
+	r.Warnings = append(r.Warnings, w.String())
+}
+
+// rowErrors adds the rows that failed to compute.
+func (r *RunReport) rowErrors(errs []RowError) {
+	if r == nil {
+		return
+	}
+	for _, e := range errs {
+		r.Errors = append(r.Errors, e.Error())
+	}
+}
+
+// registers records the register lines produced and their totals per currency.
+func (r *RunReport) registers(registers []PayRegister) {
+	if r == nil {
+		return
+	}
+	r.Records["registers"] = len(registers)
+	byCurrency := make(map[string]*ReportTotal)
+	for _, reg := range registers {
+		t := byCurrency[reg.Currency]
+		if t == nil {
+			t = &ReportTotal{Currency: reg.Currency}
+			byCurrency[reg.Currency] = t
+		}
+		t.Lines++
+		t.GrossWages += reg.GrossWages
+		t.TotalDeductions += reg.TotalDeductions
+		t.NetPay += reg.NetPay
+	}
+	r.Totals = r.Totals[:0]
+	for _, code := range sortedKeys(byCurrency) {
+		r.Totals = append(r.Totals, *byCurrency[code])
+	}
+}
+
+// finish completes the report with the run's outcome and writes it. A report that
+// cannot be written is logged rather than failing the run a second time.
+func (r *RunReport) finish(exitCode int, failure string) {
+	if r == nil {
+		return
+	}
+	r.Status, r.ExitCode = "ok", exitCode
+	if exitCode != 0 {
+		r.Status = "failed"
+		r.Errors = append(r.Errors, failure)
+	}
+	r.FinishedAt = time.Now().UTC()
+	r.Flags = make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { r.Flags[f.Name] = f.Value.String() })
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		logf("Warning: cannot write run report %s: %v", r.path, err)
+	}
+}
@@ -0,0 +1,934 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RemittanceLine is the amount owed to one tax agency for one kind of tax, in one currency.
+type RemittanceLine struct {
+	Agency      string
+	Tax         string
+	Currency    string
+	Withheld    Money
+	EmployerTax Money
+}
+
+// Due is the total to deposit with the agency for this line.
+func (l RemittanceLine) Due() Money {
+	return l.Withheld + l.EmployerTax
+}
+
+// RemittanceSummary groups a run's taxes by the agency they are remitted to:
+// the IRS (federal income tax and FICA), the state, and each local jurisdiction.
+type RemittanceSummary struct {
+	Lines []RemittanceLine
+}
+
+// computeRemittance aggregates withholding across registers into per-agency totals.
+// Amounts in different currencies are kept on separate lines, never summed together.
+// Employer-side amounts are included wherever the register carries them.
+func computeRemittance(registers []PayRegister) RemittanceSummary {
+	lines := make(map[string]*RemittanceLine)
+	add := func(agency, tax, currency string, withheld, employer Money) {
+		key := agency + "|" + tax + "|" + currency
+		if lines[key] == nil {
+			lines[key] = &RemittanceLine{Agency: agency, Tax: tax, Currency: currency}
+		}
+		lines[key].Withheld += withheld
+		lines[key].EmployerTax += employer
+	}
+	for _, reg := range registers {
+		add("IRS", "Federal Income Tax", reg.Currency, reg.FederalTax, 0)
+		add("IRS", "Social Security", reg.Currency, reg.SocialSecurity, reg.EmployerSocialSecurity)
+		add("IRS", "Medicare", reg.Currency, reg.Medicare, reg.EmployerMedicare)
+		add("STATE", "State Income Tax", reg.Currency, reg.StateTax, 0)
+		if reg.LocalTax != 0 {
+			add("LOCAL:"+strings.ToUpper(strings.TrimSpace(reg.WorkLocality)), "Local Income Tax", reg.Currency, reg.LocalTax, 0)
+		}
+		for _, c := range reg.Contributions {
+			add("STATUTORY", c.Name, reg.Currency, c.Employee, c.Employer)
+		}
+	}
+
+	var summary RemittanceSummary
+	for _, key := range sortedKeys(lines) {
+		summary.Lines = append(summary.Lines, *lines[key])
+	}
+	return summary
+}
+
+// AgencyTotals returns the amount due per agency and currency, in the order they first appear.
+func (s RemittanceSummary) AgencyTotals() []RemittanceLine {
+	var totals []RemittanceLine
+	index := make(map[string]int)
+	for _, l := range s.Lines {
+		key := l.Agency + "|" + l.Currency
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, RemittanceLine{Agency: l.Agency, Tax: "Total", Currency: l.Currency})
+		}
+		totals[i].Withheld += l.Withheld
+		totals[i].EmployerTax += l.EmployerTax
+	}
+	return totals
+}
+
+// writeRemittance writes the remittance summary as CSV: one row per agency and tax,
+// followed by one total row per agency.
+func writeRemittance(summary RemittanceSummary, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create remittance file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write([]string{"Agency", "Tax", "Currency", "Employee Withholding", "Employer Tax", "Total Due"}); err != nil {
+		return fmt.Errorf("cannot write remittance header: %v", err)
+	}
+	for _, l := range append(summary.Lines, summary.AgencyTotals()...) {
+		money := opts.formatter(l.Currency)
+		currency := l.Currency
+		if currency == "" {
+			currency = opts.Currency.Code
+		}
+		row := []string{l.Agency, l.Tax, currency, money(l.Withheld), money(l.EmployerTax), money(l.Due())}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write remittance row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write remittance file: %v", err)
+	}
+	return nil
+}
+
+// CurrencyTotal is the gross and net paid in one currency, and the same amounts
+// converted into the reporting currency.
+type CurrencyTotal struct {
+	Currency          string
+	Gross             Money
+	Net               Money
+	Rate              float64
+	ReportingGross    Money
+	ReportingNet      Money
+	ReportingCurrency string
+}
+
+// computeCurrencySummary totals registers per currency and converts each total with
+// cfg.FXRates. A currency with no configured rate is an error rather than being
+// silently summed at par; rows with no currency are in the reporting currency.
+func computeCurrencySummary(registers []PayRegister, cfg TaxConfig) ([]CurrencyTotal, error) {
+	reporting := strings.ToUpper(cfg.ReportingCurrency)
+	totals := make(map[string]*CurrencyTotal)
+	for _, reg := range registers {
+		code := reg.Currency
+		if code == "" {
+			code = reporting
+		}
+		if totals[code] == nil {
+			totals[code] = &CurrencyTotal{Currency: code, ReportingCurrency: reporting}
+		}
+		totals[code].Gross += reg.GrossWages
+		totals[code].Net += reg.NetPay
+	}
+
+	var summary []CurrencyTotal
+	for _, code := range sortedKeys(totals) {
+		t := *totals[code]
+		switch rate, ok := cfg.FXRates[code]; {
+		case code == reporting:
+			t.Rate = 1
+		case ok:
+			t.Rate = rate
+		default:
+			return nil, fmt.Errorf("no FX rate configured to convert %s to reporting currency %q", code, reporting)
+		}
+		t.ReportingGross = t.Gross.MulRate(t.Rate)
+		t.ReportingNet = t.Net.MulRate(t.Rate)
+		summary = append(summary, t)
+	}
+	return summary, nil
+}
+
+// writeCurrencySummary writes per-currency totals plus a grand total in the reporting currency.
+func writeCurrencySummary(summary []CurrencyTotal, filename string) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create currency summary file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Currency", "Gross Wages", "Net Pay", "FX Rate", "Reporting Currency", "Reporting Gross Wages", "Reporting Net Pay"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write currency summary header: %v", err)
+	}
+	var total CurrencyTotal
+	for _, t := range summary {
+		row := []string{t.Currency, t.Gross.String(), t.Net.String(), strconv.FormatFloat(t.Rate, 'f', -1, 64),
+			t.ReportingCurrency, t.ReportingGross.String(), t.ReportingNet.String()}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write currency summary row: %v", err)
+		}
+		total.ReportingCurrency = t.ReportingCurrency
+		total.ReportingGross += t.ReportingGross
+		total.ReportingNet += t.ReportingNet
+	}
+	row := []string{"TOTAL", "", "", "", total.ReportingCurrency, total.ReportingGross.String(), total.ReportingNet.String()}
+	if err := writer.Write(row); err != nil {
+		return fmt.Errorf("cannot write currency summary total: %v", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write currency summary file: %v", err)
+	}
+	return nil
+}
+
+// RateChange is a change in an employee's hourly rate between consecutive pay periods.
+type RateChange struct {
+	EmployeeID      string
+	EmployeeName    string
+	PreviousPeriod  string
+	EffectivePeriod string
+	OldRate         Money
+	NewRate         Money
+}
+
+// detectRateChanges groups payroll records by employee, orders each employee's
+// periods chronologically, and reports every period whose rate differs from the one
+// before it. Results are ordered by employee, then period.
+func detectRateChanges(payrollMap map[string]PayrollRecord) ([]RateChange, error) {
+	type dated struct {
+		start time.Time
+		rec   PayrollRecord
+	}
+	byEmployee := make(map[string][]dated)
+	for _, key := range sortedKeys(payrollMap) {
+		rec := payrollMap[key]
+		start, err := parsePeriod(rec.PayPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("employee %s: %v", rec.EmployeeID, err)
+		}
+		byEmployee[rec.EmployeeID] = append(byEmployee[rec.EmployeeID], dated{start, rec})
+	}
+
+	var changes []RateChange
+	for _, id := range sortedKeys(byEmployee) {
+		history := byEmployee[id]
+		sort.SliceStable(history, func(i, j int) bool { return history[i].start.Before(history[j].start) })
+		for i := 1; i < len(history); i++ {
+			prev, cur := history[i-1].rec, history[i].rec
+			if cur.HourlyRate != prev.HourlyRate {
+				changes = append(changes, RateChange{
+					EmployeeID:      id,
+					EmployeeName:    cur.EmployeeName,
+					PreviousPeriod:  prev.PayPeriod,
+					EffectivePeriod: cur.PayPeriod,
+					OldRate:         prev.HourlyRate,
+					NewRate:         cur.HourlyRate,
+				})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// writeRateChanges writes the rate-change audit report as CSV.
+func writeRateChanges(changes []RateChange, filename string) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create rate change file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Previous Period", "Effective Period", "Old Rate", "New Rate", "Change"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write rate change header: %v", err)
+	}
+	for _, c := range changes {
+		row := []string{csvText(c.EmployeeID), csvText(c.EmployeeName), csvText(c.PreviousPeriod), csvText(c.EffectivePeriod),
+			c.OldRate.String(), c.NewRate.String(), (c.NewRate - c.OldRate).String()}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write rate change row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write rate change file: %v", err)
+	}
+	return nil
+}
+
+// BenefitChange is a benefit that started, stopped, or changed materially between
+// an employee's consecutive benefits records. A benefit that drops to zero may be a
+// real waiver or a row left out by mistake; the report is there to tell them apart.
+type BenefitChange struct {
+	EmployeeID      string
+	EmployeeName    string
+	Benefit         string
+	Kind            string // STARTED, STOPPED, or CHANGED
+	PreviousPeriod  string
+	EffectivePeriod string
+	OldAmount       Money
+	NewAmount       Money
+}
+
+// benefitAmounts lists a benefits record's employee deductions by column name:
+// the three standard columns and each named benefit.
+func benefitAmounts(b BenefitsRecord) map[string]Money {
+	amounts := map[string]Money{
+		"Health Insurance": b.HealthInsurance,
+		"Retirement":       b.Retirement,
+		"Other Benefits":   b.OtherBenefits,
+	}
+	for name, amount := range b.NamedBenefits {
+		amounts[name] = amount
+	}
+	return amounts
+}
+
+// detectBenefitChanges orders each employee's benefits records chronologically
+// and compares every benefit with the record before it. A benefit going from zero
+// to an amount has STARTED, one going to zero has STOPPED, and one whose amount
+// moved by at least minPercent percent of the old amount has CHANGED (minPercent 0
+// reports every change). Names come from the payroll records. Results are ordered
+// by employee, then period, then benefit.
+func detectBenefitChanges(benefitsMap map[string]BenefitsRecord, payrollMap map[string]PayrollRecord, minPercent float64) ([]BenefitChange, error) {
+	type dated struct {
+		start time.Time
+		rec   BenefitsRecord
+	}
+	byEmployee := make(map[string][]dated)
+	for _, key := range sortedKeys(benefitsMap) {
+		rec := benefitsMap[key]
+		start, err := parsePeriod(rec.PayPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("employee %s: %v", rec.EmployeeID, err)
+		}
+		byEmployee[rec.EmployeeID] = append(byEmployee[rec.EmployeeID], dated{start, rec})
+	}
+
+	var changes []BenefitChange
+	for _, id := range sortedKeys(byEmployee) {
+		history := byEmployee[id]
+		sort.SliceStable(history, func(i, j int) bool { return history[i].start.Before(history[j].start) })
+		for i := 1; i < len(history); i++ {
+			prev, cur := history[i-1].rec, history[i].rec
+			name := payrollMap[makeKey(id, cur.PayPeriod)].EmployeeName
+			before, after := benefitAmounts(prev), benefitAmounts(cur)
+			names := sortedKeys(before)
+			for benefit := range after {
+				if _, ok := before[benefit]; !ok {
+					names = append(names, benefit)
+				}
+			}
+			sort.Strings(names)
+			for _, benefit := range names {
+				old, amount := before[benefit], after[benefit]
+				var kind string
+				switch {
+				case old == amount:
+					continue
+				case old == 0:
+					kind = "STARTED"
+				case amount == 0:
+					kind = "STOPPED"
+				case math.Abs(float64(amount-old)) < math.Abs(float64(old))*minPercent/100:
+					continue
+				default:
+					kind = "CHANGED"
+				}
+				changes = append(changes, BenefitChange{
+					EmployeeID:      id,
+					EmployeeName:    name,
+					Benefit:         benefit,
+					Kind:            kind,
+					PreviousPeriod:  prev.PayPeriod,
+					EffectivePeriod: cur.PayPeriod,
+					OldAmount:       old,
+					NewAmount:       amount,
+				})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// writeBenefitChanges writes the benefit change report as CSV.
+func writeBenefitChanges(changes []BenefitChange, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create benefit change file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Benefit", "Change", "Previous Period", "Effective Period", "Old Amount", "New Amount", "Difference"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write benefit change header: %v", err)
+	}
+	money := opts.formatter("")
+	for _, c := range changes {
+		row := []string{csvText(c.EmployeeID), csvText(c.EmployeeName), csvText(c.Benefit), c.Kind, csvText(c.PreviousPeriod), csvText(c.EffectivePeriod),
+			money(c.OldAmount), money(c.NewAmount), money(c.NewAmount - c.OldAmount)}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write benefit change row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write benefit change file: %v", err)
+	}
+	return nil
+}
+
+// PeriodGap is an employee missing from pay periods the run covers.
+type PeriodGap struct {
+	EmployeeID     string
+	EmployeeName   string
+	PresentPeriods int
+	MissingPeriods []string
+}
+
+// detectPeriodGaps reports every employee whose payroll records skip one or more of
+// the expected periods. expected lists the periods the run should cover; when empty
+// it is every period found in payrollMap. Periods are compared by start date, so
+// "2024-06" and "Jun 2024" are the same period. Gaps are ordered by employee and
+// each employee's missing periods chronologically. New hires and leavers show up
+// too, for the periods before they started or after they left.
+func detectPeriodGaps(payrollMap map[string]PayrollRecord, expected []string) ([]PeriodGap, error) {
+	type period struct {
+		start time.Time
+		label string
+	}
+	var periods []period
+	seen := make(map[time.Time]bool)
+	addPeriod := func(label string) error {
+		start, err := parsePeriod(label)
+		if err != nil {
+			return err
+		}
+		if !seen[start] {
+			seen[start] = true
+			periods = append(periods, period{start, strings.TrimSpace(label)})
+		}
+		return nil
+	}
+	for _, label := range expected {
+		if err := addPeriod(label); err != nil {
+			return nil, fmt.Errorf("expected periods: %v", err)
+		}
+	}
+
+	present := make(map[string]map[time.Time]bool)
+	names := make(map[string]string)
+	for _, key := range sortedKeys(payrollMap) {
+		rec := payrollMap[key]
+		start, err := parsePeriod(rec.PayPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("employee %s: %v", rec.EmployeeID, err)
+		}
+		if len(expected) == 0 {
+			addPeriod(rec.PayPeriod)
+		}
+		if present[rec.EmployeeID] == nil {
+			present[rec.EmployeeID] = make(map[time.Time]bool)
+		}
+		present[rec.EmployeeID][start] = true
+		names[rec.EmployeeID] = rec.EmployeeName
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].start.Before(periods[j].start) })
+
+	var gaps []PeriodGap
+	for _, id := range sortedKeys(present) {
+		gap := PeriodGap{EmployeeID: id, EmployeeName: names[id]}
+		for _, p := range periods {
+			if present[id][p.start] {
+				gap.PresentPeriods++
+			} else {
+				gap.MissingPeriods = append(gap.MissingPeriods, p.label)
+			}
+		}
+		if len(gap.MissingPeriods) > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
+
+// writePeriodGaps writes the period-gap report as CSV, one row per employee with the
+// missing periods joined by semicolons.
+func writePeriodGaps(gaps []PeriodGap, filename string) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create period gap file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Periods Present", "Periods Missing", "Missing Periods"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write period gap header: %v", err)
+	}
+	for _, g := range gaps {
+		row := []string{csvText(g.EmployeeID), csvText(g.EmployeeName), strconv.Itoa(g.PresentPeriods),
+			strconv.Itoa(len(g.MissingPeriods)), csvText(strings.Join(g.MissingPeriods, ";"))}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write period gap row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write period gap file: %v", err)
+	}
+	return nil
+}
+
+// NetReconciliation compares computed net pay against a total supplied by finance.
+// Period is empty for the whole-run total.
+type NetReconciliation struct {
+	Period   string
+	Expected Money
+	Computed Money
+}
+
+// Difference is computed minus expected.
+func (r NetReconciliation) Difference() Money {
+	return r.Computed - r.Expected
+}
+
+// reconcileNet totals NetPay overall and per period and compares each total that has
+// an expected value; expected is keyed by pay period, with "" for the whole run. Net
+// pay in more than one currency cannot be summed, so that is an error.
+func reconcileNet(registers []PayRegister, expected map[string]Money) ([]NetReconciliation, error) {
+	totals := make(map[string]Money)
+	currency := ""
+	for i, reg := range registers {
+		if i > 0 && reg.Currency != currency {
+			return nil, fmt.Errorf("cannot reconcile net pay across currencies %q and %q", currency, reg.Currency)
+		}
+		currency = reg.Currency
+		totals[""] += reg.NetPay
+		totals[reg.PayPeriod] += reg.NetPay
+	}
+	var results []NetReconciliation
+	for _, period := range sortedKeys(expected) {
+		results = append(results, NetReconciliation{Period: period, Expected: expected[period], Computed: totals[period]})
+	}
+	return results, nil
+}
+
+// readExpectedNet reads a finance file of expected totals (Pay Period, Expected Net).
+func readExpectedNet(filename string, opts ReaderOptions) (map[string]Money, error) {
+	expected := make(map[string]Money)
+	err := readCSV(filename, "expected net", opts, func(cols columnMap, row []string, line int) error {
+		if len(row) < 2 {
+			return opts.shortRow(row, line, 2)
+		}
+		amount, err := parseMoney(row[1])
+		if err != nil {
+			return fmt.Errorf("error parsing Expected Net in row %d: %v", line, err)
+		}
+		period, err := opts.period(strings.TrimSpace(row[0]))
+		if err != nil {
+			return fmt.Errorf("error parsing Pay Period in row %d: %v", line, err)
+		}
+		expected[period] = amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expected, nil
+}
+
+// computeEmployerCost is the fully-loaded cost of a register line to the employer:
+// gross wages plus employer payroll taxes (FICA or the statutory contributions
+// replacing it), employer-paid benefit contributions, and the retirement match.
+func computeEmployerCost(reg PayRegister) Money {
+	cost := reg.GrossWages + reg.EmployerSocialSecurity + reg.EmployerMedicare + reg.EmployerBenefits + reg.EmployerMatch
+	for _, c := range reg.Contributions {
+		cost += c.Employer
+	}
+	return cost
+}
+
+// effectiveTaxRate is the share of gross wages withheld as income tax (federal, state,
+// and local) and FICA or statutory contributions. Benefits and custom deductions are
+// not taxes and are left out.
+func effectiveTaxRate(reg PayRegister) float64 {
+	if reg.GrossWages == 0 {
+		return 0
+	}
+	taxes := reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare + contributionTotal(reg.Contributions)
+	return taxes.Float64() / reg.GrossWages.Float64()
+}
+
+// writeEmployerCost writes the per-employee, per-period employer cost report as CSV.
+func writeEmployerCost(registers []PayRegister, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create employer cost file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Pay Period", "Gross Wages", "Employer Social Security",
+		"Employer Medicare", "Employer Benefits", "Employer Match", "Total Employer Cost", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write employer cost header: %v", err)
+	}
+	for _, reg := range registers {
+		money := opts.formatter(reg.Currency)
+		row := []string{csvText(reg.EmployeeID), csvText(reg.EmployeeName), csvText(reg.PayPeriod), money(reg.GrossWages),
+			money(reg.EmployerSocialSecurity), money(reg.EmployerMedicare), money(reg.EmployerBenefits), money(reg.EmployerMatch),
+			money(reg.TotalEmployerCost), opts.currencyLabel(reg)}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write employer cost row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write employer cost file: %v", err)
+	}
+	return nil
+}
+
+// WageBaseLine is one employee's progress toward the Social Security wage base in
+// one calendar year, as of the latest period in the run.
+type WageBaseLine struct {
+	EmployeeID    string
+	EmployeeName  string
+	Year          int
+	Currency      string
+	ThroughPeriod string
+	YTDWages      Money // Social Security taxable wages so far this year
+	WageBase      Money
+}
+
+// Remaining is the taxable wages left before Social Security stops, never below zero.
+func (l WageBaseLine) Remaining() Money {
+	return max(l.WageBase-l.YTDWages, 0)
+}
+
+// socialSecurityWageBase totals each employee's Social Security wages per calendar
+// year (of the period's start) and currency, adjustment lines included, against the
+// wage base cfg sets for that year. Only the periods in the run count, on top of
+// any seeded prior-system wages, so otherwise the year-to-date figures assume it
+// starts with the year's first period.
+func socialSecurityWageBase(registers []PayRegister, cfg TaxConfig, seeds map[string]YTDSeed) ([]WageBaseLine, error) {
+	lines := make(map[string]*WageBaseLine)
+	latest := make(map[string]time.Time)
+	for _, reg := range registers {
+		start, err := parsePeriod(reg.PayPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("cannot place period %q in a year: %v", reg.PayPeriod, err)
+		}
+		key := ytdKey(reg.EmployeeID, start.Year(), reg.Currency)
+		l := lines[key]
+		if l == nil {
+			yearCfg, err := cfg.forPeriod(reg.PayPeriod)
+			if err != nil {
+				return nil, err
+			}
+			if yearCfg.SocialSecurityWageBase <= 0 {
+				return nil, fmt.Errorf("the config sets no socialSecurityWageBase for %d", start.Year())
+			}
+			l = &WageBaseLine{EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, Year: start.Year(),
+				Currency: reg.Currency, WageBase: yearCfg.SocialSecurityWageBase, YTDWages: seeds[key].SocialSecurityWages}
+			lines[key] = l
+		}
+		l.YTDWages += reg.SocialSecurityWages
+		if start.After(latest[key]) || l.ThroughPeriod == "" {
+			latest[key], l.ThroughPeriod = start, reg.PayPeriod
+		}
+	}
+	result := make([]WageBaseLine, 0, len(lines))
+	for _, key := range sortedKeys(lines) {
+		result = append(result, *lines[key])
+	}
+	return result, nil
+}
+
+// writeSocialSecurityWageBase writes the -ss-wage-base report as CSV.
+func writeSocialSecurityWageBase(lines []WageBaseLine, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create wage base file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	header := []string{"Employee ID", "Employee Name", "Year", "Through Period", "YTD Social Security Wages",
+		"Wage Base", "Remaining", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write wage base header: %v", err)
+	}
+	for _, l := range lines {
+		money := opts.formatter(l.Currency)
+		row := []string{csvText(l.EmployeeID), csvText(l.EmployeeName), strconv.Itoa(l.Year), csvText(l.ThroughPeriod),
+			money(l.YTDWages), money(l.WageBase), money(l.Remaining()), l.Currency}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write wage base row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write wage base file: %v", err)
+	}
+	return nil
+}
+
+// NetRatioStats summarizes take-home pay as a share of gross (net / gross) over
+// one group of employees: all of them, or those with one job title.
+type NetRatioStats struct {
+	Group     string
+	Employees int
+	// Skipped counts employees left out because their gross over the run was
+	// zero or negative, which has no meaningful ratio.
+	Skipped            int
+	Mean, Median       float64
+	P10, P25, P75, P90 float64
+}
+
+// netRatioStats computes each employee's net-to-gross ratio over the run (total
+// net over total gross, per currency) and summarizes the ratios for all employees,
+// then per job title in title order, where an employee's ratio is over their lines
+// with that title. Percentiles interpolate linearly between the nearest ratios.
+func netRatioStats(registers []PayRegister) []NetRatioStats {
+	type totals struct{ gross, net Money }
+	groups := map[string]map[string]*totals{"": {}} // job title ("" for all) -> employee -> totals
+	for _, reg := range registers {
+		title := "title:" + reg.JobTitle
+		if groups[title] == nil {
+			groups[title] = make(map[string]*totals)
+		}
+		for _, g := range []string{"", title} {
+			key := makeKey(reg.EmployeeID, reg.Currency)
+			t := groups[g][key]
+			if t == nil {
+				t = &totals{}
+				groups[g][key] = t
+			}
+			t.gross += reg.GrossWages
+			t.net += reg.NetPay
+		}
+	}
+
+	var stats []NetRatioStats
+	for _, g := range sortedKeys(groups) {
+		st := NetRatioStats{Group: "ALL"}
+		if g != "" {
+			st.Group = strings.TrimPrefix(g, "title:")
+		}
+		var ratios []float64
+		for _, key := range sortedKeys(groups[g]) {
+			t := groups[g][key]
+			if t.gross <= 0 {
+				st.Skipped++
+				continue
+			}
+			ratios = append(ratios, float64(t.net)/float64(t.gross))
+		}
+		st.Employees = len(ratios)
+		if len(ratios) > 0 {
+			sort.Float64s(ratios)
+			sum := 0.0
+			for _, r := range ratios {
+				sum += r
+			}
+			st.Mean = sum / float64(len(ratios))
+			st.Median = percentile(ratios, 50)
+			st.P10, st.P25 = percentile(ratios, 10), percentile(ratios, 25)
+			st.P75, st.P90 = percentile(ratios, 75), percentile(ratios, 90)
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile (0-100) of sorted, interpolating linearly.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// writeNetRatioStats writes the net-to-gross statistics as CSV, ratios as
+// fractions to four places.
+func writeNetRatioStats(stats []NetRatioStats, filename string) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create net ratio file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	header := []string{"Job Title", "Employees", "Skipped (No Gross)", "Mean", "Median", "P10", "P25", "P75", "P90"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write net ratio header: %v", err)
+	}
+	ratio := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, st := range stats {
+		row := []string{csvText(st.Group), strconv.Itoa(st.Employees), strconv.Itoa(st.Skipped),
+			ratio(st.Mean), ratio(st.Median), ratio(st.P10), ratio(st.P25), ratio(st.P75), ratio(st.P90)}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write net ratio row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write net ratio file: %v", err)
+	}
+	return nil
+}
+
+// TopEarner is one employee's total pay over the run, as ranked by topEarners.
+type TopEarner struct {
+	Rank         int
+	EmployeeID   string
+	EmployeeName string
+	Currency     string
+	Periods      int
+	Gross        Money
+	Net          Money
+}
+
+// topEarners totals gross and net per employee across all periods (adjustment lines
+// included) and returns the n highest by metric, "gross" or "net". Ties are broken
+// by Employee ID. Amounts in different currencies are ranked as-is, so an employee
+// paid in two currencies appears once per currency.
+func topEarners(registers []PayRegister, n int, metric string) ([]TopEarner, error) {
+	if metric != "gross" && metric != "net" {
+		return nil, fmt.Errorf("unknown ranking metric %q (want gross or net)", metric)
+	}
+	totals := make(map[string]*TopEarner)
+	periods := make(map[string]map[string]bool)
+	for _, reg := range registers {
+		key := makeKey(reg.EmployeeID, reg.Currency)
+		t, ok := totals[key]
+		if !ok {
+			t = &TopEarner{EmployeeID: reg.EmployeeID, Currency: reg.Currency}
+			totals[key] = t
+			periods[key] = make(map[string]bool)
+		}
+		t.EmployeeName = reg.EmployeeName
+		t.Gross += reg.GrossWages
+		t.Net += reg.NetPay
+		periods[key][reg.PayPeriod] = true
+	}
+
+	ranked := make([]TopEarner, 0, len(totals))
+	for _, key := range sortedKeys(totals) {
+		t := *totals[key]
+		t.Periods = len(periods[key])
+		ranked = append(ranked, t)
+	}
+	value := func(t TopEarner) Money {
+		if metric == "net" {
+			return t.Net
+		}
+		return t.Gross
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if vi, vj := value(ranked[i]), value(ranked[j]); vi != vj {
+			return vi > vj
+		}
+		return ranked[i].EmployeeID < ranked[j].EmployeeID
+	})
+	if n < len(ranked) {
+		ranked = ranked[:n]
+	}
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked, nil
+}
+
+// writeTopEarners writes the top-earner ranking as CSV.
+func writeTopEarners(earners []TopEarner, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create top earners file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Rank", "Employee ID", "Employee Name", "Periods", "Gross Wages", "Net Pay", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write top earners header: %v", err)
+	}
+	for _, t := range earners {
+		money := opts.formatter(t.Currency)
+		row := []string{strconv.Itoa(t.Rank), csvText(t.EmployeeID), csvText(t.EmployeeName), strconv.Itoa(t.Periods),
+			money(t.Gross), money(t.Net), opts.currencyLabel(PayRegister{Currency: t.Currency})}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write top earners row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write top earners file: %v", err)
+	}
+	return nil
+}
+
+// writePreview prints the first n registers, in employee-then-period order, as an
+// aligned table (-preview) for a quick look at the numbers without opening the
+// register.
+func writePreview(w io.Writer, registers []PayRegister, n int, opts WriterOptions) error {
+	sorted := slices.Clone(registers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].EmployeeID != sorted[j].EmployeeID {
+			return sorted[i].EmployeeID < sorted[j].EmployeeID
+		}
+		return sorted[i].PayPeriod < sorted[j].PayPeriod
+	})
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Employee ID\tEmployee Name\tPay Period\tHours\tGross\tTaxes\tBenefits\tDeductions\tNet Pay\t")
+	for _, reg := range sorted {
+		format := opts.formatter(reg.Currency)
+		money := func(m Money) string { return opts.Display.apply(format(m)) }
+		taxes := reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare + contributionTotal(reg.Contributions)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", reg.EmployeeID, reg.EmployeeName, reg.PayPeriod,
+			reg.RegularHours+reg.OvertimeHours+reg.DoubleTimeHours, money(reg.GrossWages), money(taxes),
+			money(reg.TotalBenefits), money(reg.TotalDeductions), money(reg.NetPay))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(registers) > len(sorted) {
+		_, err := fmt.Fprintf(w, "(%d more)\n", len(registers)-len(sorted))
+		return err
+	}
+	return nil
+}
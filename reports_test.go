@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// errWriter fails every write, like a full disk or a closed pipe.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// shortWriter takes the first n bytes written and fails the rest.
+type shortWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if room := w.n - w.Len(); len(p) > room {
+		w.Buffer.Write(p[:max(room, 0)])
+		return max(room, 0), errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWritersReturnWriteErrors(t *testing.T) {
+	registers := []PayRegister{{EmployeeID: "001", EmployeeName: "Test Employee", PayPeriod: "2024-06", GrossWages: 100000, NetPay: 75000}}
+	if _, err := writeRegisterCSV(errWriter{}, registers, defaultWriterOptions()); err == nil {
+		t.Error("writeRegisterCSV: got no error from a failing writer")
+	}
+
+	// Through a -write-buffer smaller or larger than encoding/csv's own, a write
+	// that falls short is an error unless every byte got out.
+	var many []PayRegister
+	for i := 0; i < 200; i++ {
+		many = append(many, registers[0])
+	}
+	var full bytes.Buffer
+	if _, err := writeRegisterCSV(&full, many, defaultWriterOptions()); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{100, 1000, 4095, 8192} {
+		for _, every := range []int{0, 10} {
+			for _, limit := range []int{0, full.Len() / 2, full.Len()} {
+				opts := defaultWriterOptions()
+				opts.BufferSize, opts.FlushEvery = size, every
+				w := &shortWriter{n: limit}
+				_, err := writeRegisterCSV(w, many, opts)
+				if err == nil && !bytes.Equal(w.Bytes(), full.Bytes()) {
+					t.Errorf("-write-buffer %d -flush-every %d into %d bytes: wrote %d of %d with no error", size, every, limit, w.Len(), full.Len())
+				}
+				if err != nil && limit == full.Len() {
+					t.Errorf("-write-buffer %d -flush-every %d: got error %v with room for the whole register", size, every, err)
+				}
+			}
+		}
+	}
+	if err := writePreview(errWriter{}, registers, 10, defaultWriterOptions()); err == nil {
+		t.Error("writePreview: got no error from a failing writer")
+	}
+
+	// The report writers open their own file; /dev/full fails every write as the
+	// buffered rows are flushed.
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to write to")
+	}
+	deltas := []RegisterDelta{{EmployeeID: "001", PayPeriod: "2024-06", Base: &registers[0], New: &registers[0]}}
+	for name, write := range map[string]func(string) error{
+		"register":         func(f string) error { return writeRegister(registers, f, defaultTaxConfig(), defaultWriterOptions()) },
+		"comparison":       func(f string) error { return writeRegisterComparison(deltas, f, defaultWriterOptions()) },
+		"employer cost":    func(f string) error { return writeEmployerCost(registers, f, defaultWriterOptions()) },
+		"currency summary": func(f string) error { return writeCurrencySummary(nil, f) },
+		"period gaps":      func(f string) error { return writePeriodGaps(nil, f) },
+		"top earners":      func(f string) error { return writeTopEarners(nil, f, defaultWriterOptions()) },
+	} {
+		if err := write("/dev/full"); err == nil {
+			t.Errorf("%s: got no error writing to /dev/full", name)
+		}
+	}
+}
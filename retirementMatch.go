@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// RetirementMatch is the employer's match on employee retirement (401k)
+// contributions, such as 100% of the first 3% of gross and 50% of the next 2%:
+//
+//	{"tiers": [{"matchPercent": 100, "upToPercent": 3}, {"matchPercent": 50, "upToPercent": 5}]}
+//
+// Each tier matches the part of the contribution between the previous tier's
+// UpToPercent of gross and its own. AnnualCap, when positive, caps an
+// employee's match per calendar year.
+type RetirementMatch struct {
+	Tiers     []MatchTier `json:"tiers"`
+	AnnualCap Money       `json:"annualCap"`
+}
+
+// MatchTier is one step of a RetirementMatch, both figures in percent.
+type MatchTier struct {
+	MatchPercent float64 `json:"matchPercent"`
+	UpToPercent  float64 `json:"upToPercent"`
+}
+
+// check validates the formula: percentages not negative, tiers in rising order.
+func (m RetirementMatch) check() error {
+	if m.AnnualCap < 0 {
+		return fmt.Errorf("annualCap must not be negative")
+	}
+	prev := 0.0
+	for i, t := range m.Tiers {
+		if t.MatchPercent < 0 || t.UpToPercent <= prev {
+			return fmt.Errorf("tier %d: matchPercent must not be negative and upToPercent must rise above %g", i+1, prev)
+		}
+		prev = t.UpToPercent
+	}
+	return nil
+}
+
+// match is the employer match, before any annual cap, on one line's retirement
+// contribution. Lines with no positive gross or contribution get none.
+func (m RetirementMatch) match(contribution, gross Money) Money {
+	if contribution <= 0 || gross <= 0 {
+		return 0
+	}
+	var total Money
+	from := Money(0)
+	for _, t := range m.Tiers {
+		upTo := gross.MulRate(t.UpToPercent / 100)
+		if contribution <= from {
+			break
+		}
+		total += (min(contribution, upTo) - from).MulRate(t.MatchPercent / 100)
+		from = upTo
+	}
+	return total
+}
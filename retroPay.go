@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetroRaise is a raise approved after the fact (-retro-pay): the difference
+// between OldRate and NewRate is owed on every hour paid in the pay periods From
+// through Through, overtime and double time at their multiples.
+type RetroRaise struct {
+	OldRate, NewRate Money
+	From, Through    string
+	from, through    time.Time
+}
+
+// covers reports whether the period starting at start falls in the raise's range.
+func (r RetroRaise) covers(start time.Time) bool {
+	return !start.Before(r.from) && !start.After(r.through)
+}
+
+// readRetroRaises reads the -retro-pay CSV: Employee ID, Old Rate, New Rate, From
+// Period and Through Period, located by header. An employee may have several
+// raises as long as their periods do not overlap.
+func readRetroRaises(filename string, opts ReaderOptions) (map[string][]RetroRaise, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a retro pay file must have a header row")
+	}
+	raises := make(map[string][]RetroRaise)
+	err := readCSV(filename, "retro pay", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		var r RetroRaise
+		var err error
+		if r.OldRate, err = opts.money(cols.value(row, "Old Rate")); err != nil {
+			return fmt.Errorf("error parsing Old Rate in row %d: %v", line, err)
+		}
+		if r.NewRate, err = opts.money(cols.value(row, "New Rate")); err != nil {
+			return fmt.Errorf("error parsing New Rate in row %d: %v", line, err)
+		}
+		for _, p := range []struct {
+			column string
+			name   *string
+			start  *time.Time
+		}{
+			{"From Period", &r.From, &r.from},
+			{"Through Period", &r.Through, &r.through},
+		} {
+			period, err := opts.period(cols.value(row, p.column))
+			if err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", p.column, line, err)
+			}
+			if *p.start, err = parsePeriod(period); err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", p.column, line, err)
+			}
+			*p.name = period
+		}
+		if r.through.Before(r.from) {
+			return fmt.Errorf("Through Period %s is before From Period %s in row %d", r.Through, r.From, line)
+		}
+		for _, other := range raises[id] {
+			if !r.through.Before(other.from) && !other.through.Before(r.from) {
+				return fmt.Errorf("employee %s's raise for %s through %s overlaps the one for %s through %s (row %d)", id, r.From, r.Through, other.From, other.Through, line)
+			}
+		}
+		raises[id] = append(raises[id], r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return raises, nil
+}
+
+// RetroLine is the retro pay owed for one register line: the rate difference on
+// the hours it paid.
+type RetroLine struct {
+	EmployeeID, EmployeeName, PayPeriod, Currency string
+	RegularHours, OvertimeHours, DoubleTimeHours  int
+	OldRate, NewRate, PaidRate                    Money
+	Amount                                        Money
+}
+
+// computeRetroPay finds the register lines each raise covers and the retro pay
+// on each, in register order. Overtime is paid 1.5 and double time 2 times the
+// difference, each rounded to the cent as gross is; correction lines' negative
+// hours take retro pay back. A line paid at other than the old rate, or a raise
+// that covers no line, is returned as a retro-pay warning; the retro pay is still
+// the difference the raise states.
+func computeRetroPay(registers []PayRegister, raises map[string][]RetroRaise) ([]RetroLine, []Warning) {
+	var lines []RetroLine
+	var warnings []Warning
+	used := make(map[string][]bool)
+	for _, reg := range registers {
+		start, err := parsePeriod(reg.PayPeriod)
+		if err != nil {
+			continue
+		}
+		for i, r := range raises[reg.EmployeeID] {
+			if !r.covers(start) {
+				continue
+			}
+			if used[reg.EmployeeID] == nil {
+				used[reg.EmployeeID] = make([]bool, len(raises[reg.EmployeeID]))
+			}
+			used[reg.EmployeeID][i] = true
+			if reg.RegularHours == 0 && reg.OvertimeHours == 0 && reg.DoubleTimeHours == 0 {
+				continue
+			}
+			if reg.HourlyRate != r.OldRate {
+				warnings = append(warnings, Warning{Category: "retro-pay", EmployeeID: reg.EmployeeID, PayPeriod: reg.PayPeriod,
+					Message: fmt.Sprintf("paid at %s, not the old rate %s", reg.HourlyRate, r.OldRate), Value: reg.HourlyRate.String()})
+			}
+			delta := r.NewRate - r.OldRate
+			lines = append(lines, RetroLine{
+				EmployeeID:      reg.EmployeeID,
+				EmployeeName:    reg.EmployeeName,
+				PayPeriod:       reg.PayPeriod,
+				Currency:        reg.Currency,
+				RegularHours:    reg.RegularHours,
+				OvertimeHours:   reg.OvertimeHours,
+				DoubleTimeHours: reg.DoubleTimeHours,
+				OldRate:         r.OldRate,
+				NewRate:         r.NewRate,
+				PaidRate:        reg.HourlyRate,
+				Amount:          delta.MulHours(reg.RegularHours) + delta.MulRate(1.5*float64(reg.OvertimeHours)) + delta.MulHours(2*reg.DoubleTimeHours),
+			})
+		}
+	}
+	for _, id := range sortedKeys(raises) {
+		for i, r := range raises[id] {
+			if used[id] == nil || !used[id][i] {
+				warnings = append(warnings, Warning{Category: "retro-pay", EmployeeID: id,
+					Message: fmt.Sprintf("the raise for %s through %s covers no register line", r.From, r.Through)})
+			}
+		}
+	}
+	return lines, warnings
+}
+
+// writeRetroPay writes the -retro-pay report: one earnings row per covered
+// register line, then a TOTAL row per currency. Each employee's retro pay for a
+// period can be paid as that amount in a later run's Adjustment column.
+func writeRetroPay(lines []RetroLine, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create retro pay file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Employee ID", "Employee Name", "Pay Period", "Regular Hours", "Overtime Hours", "Double Time Hours",
+		"Paid Rate", "Old Rate", "New Rate", "Retro Pay", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write retro pay header: %v", err)
+	}
+	totals := make(map[string]Money)
+	for _, l := range lines {
+		totals[l.Currency] += l.Amount
+		money := opts.formatter(l.Currency)
+		row := []string{csvText(l.EmployeeID), csvText(l.EmployeeName), csvText(l.PayPeriod),
+			strconv.Itoa(l.RegularHours), strconv.Itoa(l.OvertimeHours), strconv.Itoa(l.DoubleTimeHours),
+			money(l.PaidRate), money(l.OldRate), money(l.NewRate), money(l.Amount), opts.currencyLabel(PayRegister{Currency: l.Currency})}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write retro pay row: %v", err)
+		}
+	}
+	for _, code := range sortedKeys(totals) {
+		row := []string{"TOTAL", "", "", "", "", "", "", "", "", opts.formatter(code)(totals[code]), opts.currencyLabel(PayRegister{Currency: code})}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write retro pay total: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write retro pay file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// retroRaise builds a raise as readRetroRaises would.
+func retroRaise(t *testing.T, oldRate, newRate Money, from, through string) RetroRaise {
+	t.Helper()
+	r := RetroRaise{OldRate: oldRate, NewRate: newRate, From: from, Through: through}
+	var err error
+	if r.from, err = parsePeriod(from); err != nil {
+		t.Fatal(err)
+	}
+	if r.through, err = parsePeriod(through); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestComputeRetroPay(t *testing.T) {
+	// The raise is owed on each covered period's hours: 10 regular and 2 overtime
+	// hours at 1.00 more is 13.00, and the period after the range owes none.
+	raises := map[string][]RetroRaise{"012": {retroRaise(t, 2000, 2100, "2024-05", "2024-05")}}
+	lines, warnings := computeRetroPay([]PayRegister{{EmployeeID: "012", PayPeriod: "2024-05", HourlyRate: 2000, RegularHours: 10, OvertimeHours: 2},
+		{EmployeeID: "012", PayPeriod: "2024-06", HourlyRate: 2100, RegularHours: 10}}, raises)
+	if len(lines) != 1 || lines[0].Amount != 1300 || len(warnings) != 0 {
+		t.Errorf("got %+v and %v, want one line of 13.00 and no warnings", lines, warnings)
+	}
+}
+
+func TestComputeRetroPayWarnings(t *testing.T) {
+	raises := map[string][]RetroRaise{
+		"012": {retroRaise(t, 2000, 2100, "2024-05", "2024-05")},
+		"013": {retroRaise(t, 2000, 2100, "2023-01", "2023-02")},
+	}
+	lines, warnings := computeRetroPay([]PayRegister{{EmployeeID: "012", PayPeriod: "2024-05", HourlyRate: 1900, RegularHours: 10}}, raises)
+	if len(lines) != 1 || lines[0].Amount != 1000 {
+		t.Errorf("got %+v, want one line of 10.00 at the raise's difference", lines)
+	}
+	want := []Warning{
+		{Category: "retro-pay", EmployeeID: "012", PayPeriod: "2024-05", Message: "not the old rate", Value: "19.00"},
+		{Category: "retro-pay", EmployeeID: "013", Message: "covers no register line"},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("got warnings %v, want the paid rate and the unused raise", warnings)
+	}
+	for i, w := range warnings {
+		if w.Category != want[i].Category || w.EmployeeID != want[i].EmployeeID || w.PayPeriod != want[i].PayPeriod ||
+			w.Value != want[i].Value || !strings.Contains(w.Message, want[i].Message) {
+			t.Errorf("warning %d: got %+v, want %+v", i, w, want[i])
+		}
+	}
+}
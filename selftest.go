@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// selfTestPayroll, selfTestTime, and selfTestBenefits are the fixture inputs for -selftest.
+// Employee 001 is the seeded employee whose figures are asserted exactly.
+const (
+	selfTestPayroll = `Employee ID,Employee Name,Job Title,Pay Period,Hourly Rate
+001,Test Employee,Engineer,2024-06,40.00
+002,"Doe, Jane ""JJ""",Clerk,2024-06,20.50
+`
+	selfTestTime = `Employee ID,Pay Period,Regular Hours,Overtime Hours
+001,2024-06,80,5
+002,2024-06,72,0
+`
+	selfTestBenefits = `Employee ID,Pay Period,Health Insurance,Retirement,Other Benefits
+001,2024-06,75.00,50.00,20.00
+002,2024-06,60.00,30.00,10.00
+`
+)
+
+// runSelfTest runs the whole read/compute/write pipeline on built-in fixtures under
+// the default tax config and checks known results. It returns one message per failure.
+func runSelfTest() []string {
+	var failures []string
+	fail := func(format string, args ...any) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	dir, err := os.MkdirTemp("", "payregister-selftest")
+	if err != nil {
+		return []string{fmt.Sprintf("cannot create temp dir: %v", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"payroll_data.csv": selfTestPayroll,
+		"time_data.csv":    selfTestTime,
+		"benefits.csv":     selfTestBenefits,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return []string{fmt.Sprintf("cannot write fixture %s: %v", name, err)}
+		}
+	}
+
+	run := func(output string) ([]PayRegister, []byte) {
+		payrollMap, err := readPayrollRecords(filepath.Join(dir, "payroll_data.csv"), ReaderOptions{})
+		if err != nil {
+			fail("reading payroll fixture: %v", err)
+			return nil, nil
+		}
+		timeMap, err := readTimeRecords(filepath.Join(dir, "time_data.csv"), ReaderOptions{})
+		if err != nil {
+			fail("reading time fixture: %v", err)
+			return nil, nil
+		}
+		benefitsMap, err := readBenefitsRecords(filepath.Join(dir, "benefits.csv"), ReaderOptions{})
+		if err != nil {
+			fail("reading benefits fixture: %v", err)
+			return nil, nil
+		}
+		result := computeRegister(payrollMap, timeMap, benefitsMap, defaultTaxConfig(), defaultComputeOptions())
+		for _, rowErr := range result.RowErrors {
+			fail("unexpected row error: %v", rowErr)
+		}
+		path := filepath.Join(dir, output)
+		if err := writeRegister(result.Registers, path, defaultTaxConfig(), defaultWriterOptions()); err != nil {
+			fail("writing register: %v", err)
+			return result.Registers, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fail("reading back register: %v", err)
+		}
+		return result.Registers, data
+	}
+
+	registers, first := run("register_1.csv")
+	if len(failures) > 0 {
+		return failures
+	}
+	if len(registers) != 2 {
+		fail("expected 2 registers, got %d", len(registers))
+		return failures
+	}
+
+	reg := registers[0]
+	expect := func(field string, got, want Money) {
+		if got != want {
+			fail("employee 001 %s: got %s, want %s", field, got, want)
+		}
+	}
+	expect("gross wages", reg.GrossWages, 350000)
+	expect("federal tax", reg.FederalTax, 42000)
+	expect("state tax", reg.StateTax, 17500)
+	expect("social security", reg.SocialSecurity, 21700)
+	expect("medicare", reg.Medicare, 5075)
+	expect("total benefits", reg.TotalBenefits, 14500)
+	expect("total deductions", reg.TotalDeductions, 100775)
+	expect("net pay", reg.NetPay, 249225)
+
+	if name := registers[1].EmployeeName; name != `Doe, Jane "JJ"` {
+		fail("employee 002 name did not survive CSV quoting: %q", name)
+	}
+
+	// Identical inputs must produce byte-identical output.
+	_, second := run("register_2.csv")
+	if !bytes.Equal(first, second) {
+		fail("two runs over identical input produced different output")
+	}
+	return failures
+}
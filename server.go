@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxComputeRequest bounds the body a /compute request may send.
+const maxComputeRequest = 64 << 20
+
+// computeRequest is the JSON body of POST /compute: the three datasets as arrays of
+// records, with field names as in the Go types (employeeID, payPeriod, hourlyRate, ...)
+// and amounts as numbers or strings in currency units.
+type computeRequest struct {
+	Payroll  []PayrollRecord  `json:"payroll"`
+	Time     []TimeRecord     `json:"time"`
+	Benefits []BenefitsRecord `json:"benefits"`
+}
+
+// computeResponse is what POST /compute returns.
+type computeResponse struct {
+	Registers []PayRegister `json:"registers"`
+	RowErrors []string      `json:"rowErrors,omitempty"`
+	Warnings  []string      `json:"warnings,omitempty"`
+}
+
+// registerServer computes registers on demand (-serve) with the run's tax config and
+// options, exactly as a file-driven run would.
+type registerServer struct {
+	cfg        TaxConfig
+	readerOpts ReaderOptions
+	opts       ComputeOptions
+}
+
+// ServeHTTP handles POST /compute. The body is either JSON (computeRequest) or
+// multipart/form-data with payroll, time and benefits CSV file parts in the same
+// layout as the input files.
+func (s registerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxComputeRequest)
+
+	var payrollMap map[string]PayrollRecord
+	var timeMap map[string]TimeRecord
+	var benefitsMap map[string]BenefitsRecord
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		payrollMap, timeMap, benefitsMap, err = s.readMultipart(r)
+	} else {
+		payrollMap, timeMap, benefitsMap, err = s.readJSON(r)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := computeRegister(payrollMap, timeMap, benefitsMap, s.cfg, s.opts)
+	resp := computeResponse{Registers: result.Registers}
+	if resp.Registers == nil {
+		resp.Registers = []PayRegister{}
+	}
+	for _, rowErr := range result.RowErrors {
+		resp.RowErrors = append(resp.RowErrors, rowErr.Error())
+	}
+	for _, warning := range result.Warnings {
+		resp.Warnings = append(resp.Warnings, warning.String())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(resp); err != nil {
+		logf("Warning: cannot write /compute response: %v", err)
+	}
+}
+
+// readJSON builds the record maps from a computeRequest, keying and normalizing the
+// records the way the CSV readers do.
+func (s registerServer) readJSON(r *http.Request) (map[string]PayrollRecord, map[string]TimeRecord, map[string]BenefitsRecord, error) {
+	var req computeRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	payrollMap := make(map[string]PayrollRecord)
+	for i, rec := range req.Payroll {
+		if strings.TrimSpace(rec.EmployeeID) == "" || strings.TrimSpace(rec.PayPeriod) == "" {
+			return nil, nil, nil, fmt.Errorf("payroll record %d: employeeID and payPeriod are required", i)
+		}
+		rec.EmployeeID = s.readerOpts.employeeID(rec.EmployeeID)
+		period, err := s.readerOpts.period(rec.PayPeriod)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("payroll record %d: %v", i, err)
+		}
+		rec.PayPeriod = period
+		rec.Currency = strings.ToUpper(strings.TrimSpace(rec.Currency))
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		if prev, ok := payrollMap[key]; ok {
+			rec = prev.addJob(rec)
+		}
+		payrollMap[key] = rec
+	}
+	timeMap := make(map[string]TimeRecord)
+	for i, rec := range req.Time {
+		if strings.TrimSpace(rec.EmployeeID) == "" || strings.TrimSpace(rec.PayPeriod) == "" {
+			return nil, nil, nil, fmt.Errorf("time record %d: employeeID and payPeriod are required", i)
+		}
+		for _, h := range []int{rec.RegularHours, rec.OvertimeHours, rec.DoubleTimeHours} {
+			if _, err := s.readerOpts.checkSign(h); err != nil {
+				return nil, nil, nil, fmt.Errorf("time record %d: %v", i, err)
+			}
+		}
+		rec.EmployeeID = s.readerOpts.employeeID(rec.EmployeeID)
+		period, err := s.readerOpts.period(rec.PayPeriod)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("time record %d: %v", i, err)
+		}
+		rec.PayPeriod = period
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		if prev, ok := timeMap[key]; ok {
+			if rec, err = s.readerOpts.mergeTime(prev, rec); err != nil {
+				return nil, nil, nil, fmt.Errorf("time record %d: %v (see -time-merge)", i, err)
+			}
+		}
+		timeMap[key] = rec
+	}
+	benefitsMap := make(map[string]BenefitsRecord)
+	for i, rec := range req.Benefits {
+		if strings.TrimSpace(rec.EmployeeID) == "" || strings.TrimSpace(rec.PayPeriod) == "" {
+			return nil, nil, nil, fmt.Errorf("benefits record %d: employeeID and payPeriod are required", i)
+		}
+		rec.EmployeeID = s.readerOpts.employeeID(rec.EmployeeID)
+		period, err := s.readerOpts.period(rec.PayPeriod)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("benefits record %d: %v", i, err)
+		}
+		rec.PayPeriod = period
+		key := makeKey(rec.EmployeeID, rec.PayPeriod)
+		if prev, ok := benefitsMap[key]; ok {
+			switch s.readerOpts.BenefitsMerge {
+			case mergeSum:
+				rec = prev.plus(rec)
+			case mergeError:
+				return nil, nil, nil, fmt.Errorf("duplicate benefits record for employee %s period %s (see -benefits-merge)", rec.EmployeeID, rec.PayPeriod)
+			}
+		}
+		benefitsMap[key] = rec
+	}
+	return payrollMap, timeMap, benefitsMap, nil
+}
+
+// readMultipart saves the payroll, time and benefits file parts to a temporary
+// directory and reads them with the normal CSV readers, so uploads parse exactly
+// like files on disk.
+func (s registerServer) readMultipart(r *http.Request) (map[string]PayrollRecord, map[string]TimeRecord, map[string]BenefitsRecord, error) {
+	if err := r.ParseMultipartForm(maxComputeRequest); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid multipart body: %v", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+	dir, err := os.MkdirTemp("", "payroll-compute-")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := make(map[string]string)
+	for _, name := range []string{"payroll", "time", "benefits"} {
+		part, _, err := r.FormFile(name)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("missing %s file part: %v", name, err)
+		}
+		paths[name] = filepath.Join(dir, name+".csv")
+		out, err := os.Create(paths[name])
+		if err == nil {
+			_, err = out.ReadFrom(part)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}
+		part.Close()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cannot save %s file part: %v", name, err)
+		}
+	}
+	payrollMap, err := readPayrollRecords(paths["payroll"], s.readerOpts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	timeMap, err := readTimeRecords(paths["time"], s.readerOpts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	benefitsMap, err := readBenefitsRecords(paths["benefits"], s.readerOpts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payrollMap, timeMap, benefitsMap, nil
+}
+
+// serveRegisters runs the -serve HTTP server until it fails.
+func serveRegisters(addr string, s registerServer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/compute", s)
+	return http.ListenAndServe(addr, mux)
+}
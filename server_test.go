@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONTimeMerge(t *testing.T) {
+	const body = `{"time": [
+		{"employeeID": "001", "payPeriod": "2024-06", "regularHours": 40, "adjustment": "10.00"},
+		{"employeeID": "001", "payPeriod": "2024-06", "regularHours": 8, "overtimeHours": 2}
+	]}`
+	const csv = "Employee ID,Pay Period,Regular Hours,Overtime Hours,Adjustment\n001,2024-06,40,0,10.00\n001,2024-06,8,2,\n"
+	for _, tc := range []struct {
+		merge             string
+		regular, overtime int
+		adjustment        Money
+		wantErr           bool
+	}{
+		{mergeLast, 8, 2, 0, false},
+		{mergeSum, 48, 2, 1000, false},
+		{mergeError, 0, 0, 0, true},
+	} {
+		opts := ReaderOptions{TimeMerge: tc.merge}
+		s := registerServer{cfg: defaultTaxConfig(), readerOpts: opts, opts: defaultComputeOptions()}
+		_, timeMap, _, err := s.readJSON(httptest.NewRequest("POST", "/compute", strings.NewReader(body)))
+		csvMap, csvErr := readTimeRecords(writeInput(t, csv), opts)
+		if (err != nil) != tc.wantErr || (csvErr != nil) != tc.wantErr {
+			t.Errorf("-time-merge %s: got errors %v from JSON and %v from CSV, want error %v", tc.merge, err, csvErr, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		rec := timeMap[makeKey("001", "2024-06")]
+		if rec.RegularHours != tc.regular || rec.OvertimeHours != tc.overtime || rec.Adjustment != tc.adjustment {
+			t.Errorf("-time-merge %s: got %d regular, %d overtime, %s adjustment, want %d, %d and %s",
+				tc.merge, rec.RegularHours, rec.OvertimeHours, rec.Adjustment, tc.regular, tc.overtime, tc.adjustment)
+		}
+		if fromCSV := csvMap[makeKey("001", "2024-06")]; fromCSV.RegularHours != rec.RegularHours || fromCSV.OvertimeHours != rec.OvertimeHours || fromCSV.Adjustment != rec.Adjustment {
+			t.Errorf("-time-merge %s: JSON gave %+v, the CSV reader %+v", tc.merge, rec, fromCSV)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keyedRecord is one employee-period's record from a sorted input.
+type keyedRecord[T any] struct {
+	key string
+	rec T
+}
+
+// sortedStream reads one input in the background for -sorted-input and hands its
+// records over one key at a time. Rows sharing a key must be adjacent, and keys
+// must rise through the file; a row that breaks the order is rejected like any
+// other bad row.
+type sortedStream[T any] struct {
+	records <-chan keyedRecord[T]
+	head    *keyedRecord[T]
+	count   int
+	err     error // the read's outcome, set before records is closed
+}
+
+// streamSorted starts reading filename with the same row parser the map reader
+// uses, applied to a map that never holds more than the key being read.
+func streamSorted[T any](filename, kind string, opts ReaderOptions, rows func(map[string]T, ReaderOptions) func(columnMap, []string, int) error) *sortedStream[T] {
+	ch := make(chan keyedRecord[T], 256)
+	s := &sortedStream[T]{records: ch}
+	go func() {
+		defer close(ch)
+		group := make(map[string]T)
+		parse := rows(group, opts)
+		current := ""
+		s.err = readCSV(filename, kind, opts, func(cols columnMap, row []string, line int) error {
+			if err := parse(cols, row, line); err != nil {
+				return err
+			}
+			for key := range group {
+				if key == current {
+					continue
+				}
+				if current != "" && key < current {
+					delete(group, key)
+					return fmt.Errorf("row %d is out of order for -sorted-input: %s comes after %s", line, key, current)
+				}
+				if current != "" {
+					ch <- keyedRecord[T]{current, group[current]}
+					delete(group, current)
+				}
+				current = key
+			}
+			return nil
+		})
+		if rec, ok := group[current]; ok && s.err == nil {
+			ch <- keyedRecord[T]{current, rec}
+		}
+	}()
+	return s
+}
+
+// peek returns the next record without consuming it, or nil at the end.
+func (s *sortedStream[T]) peek() *keyedRecord[T] {
+	if s.head == nil {
+		if next, ok := <-s.records; ok {
+			s.head = &next
+			s.count++
+		}
+	}
+	return s.head
+}
+
+// takeEmployee moves the stream's records for one employee into m.
+func (s *sortedStream[T]) takeEmployee(employeeKey string, m map[string]T) {
+	for next := s.peek(); next != nil && strings.HasPrefix(next.key, employeeKey); next = s.peek() {
+		m[next.key] = next.rec
+		s.head = nil
+	}
+}
+
+// SortedJoin is the outcome of a -sorted-input run: the register, the records that
+// had nothing to join to, each input's record count, and each input's read error.
+type SortedJoin struct {
+	ComputeResult
+	Unmatched []error
+	Records   map[string]int
+	Errors    map[string]error
+	// EmployeeIDs lists the employees read, for -employees-file's not-found check.
+	EmployeeIDs []string
+}
+
+// computeSortedRegister is computeRegister for inputs sorted by EmployeeID|PayPeriod
+// key: it streams the three files in lockstep and computes one employee at a time,
+// so no more than one employee's records are ever held. Carries between an
+// employee's periods (arrears, net rounding, the match cap) never cross
+// employees, so the result is the one computeRegister gives on the whole maps.
+// The register it returns is still built in memory.
+func computeSortedRegister(payrollFile, timeFile, benefitsFile string, readerOpts ReaderOptions, cfg TaxConfig, opts ComputeOptions) SortedJoin {
+	payroll := streamSorted(payrollFile, "payroll", readerOpts, payrollRows)
+	times := streamSorted(timeFile, "time", readerOpts, timeRows)
+	benefits := streamSorted(benefitsFile, "benefits", readerOpts, benefitsRows)
+
+	join := SortedJoin{ComputeResult: ComputeResult{Rounding: make(map[string]RoundingAdjustment)}}
+	for {
+		// The smallest key left in any input names the next employee.
+		var next string
+		for _, head := range []*string{headKey(payroll), headKey(times), headKey(benefits)} {
+			if head != nil && (next == "" || *head < next) {
+				next = *head
+			}
+		}
+		if next == "" {
+			break
+		}
+		employeeID, _, _ := strings.Cut(next, "|")
+		join.EmployeeIDs = append(join.EmployeeIDs, employeeID)
+		payrollMap, timeMap, benefitsMap := make(map[string]PayrollRecord), make(map[string]TimeRecord), make(map[string]BenefitsRecord)
+		payroll.takeEmployee(employeeID+"|", payrollMap)
+		times.takeEmployee(employeeID+"|", timeMap)
+		benefits.takeEmployee(employeeID+"|", benefitsMap)
+
+		result := computeRegister(payrollMap, timeMap, benefitsMap, cfg, opts)
+		join.Registers = append(join.Registers, result.Registers...)
+		join.RowErrors = append(join.RowErrors, result.RowErrors...)
+		join.Warnings = append(join.Warnings, result.Warnings...)
+		for currency, r := range result.Rounding {
+			total := join.Rounding[currency]
+			total.Add(r)
+			join.Rounding[currency] = total
+		}
+		join.Unmatched = append(join.Unmatched, unmatchedRecords(payrollMap, timeMap, benefitsMap, cfg, opts)...)
+	}
+	join.Records = map[string]int{"payroll": payroll.count, "time": times.count, "benefits": benefits.count}
+	join.Errors = make(map[string]error)
+	for kind, err := range map[string]error{"payroll": payroll.err, "time": times.err, "benefits": benefits.err} {
+		if err != nil {
+			join.Errors[kind] = err
+		}
+	}
+	return join
+}
+
+// headKey is the key of a stream's next record, or nil at its end.
+func headKey[T any](s *sortedStream[T]) *string {
+	if next := s.peek(); next != nil {
+		return &next.key
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkSSN validates a Social Security number written as nine digits, with or
+// without the usual dashes, and returns it in 123-45-6789 form. It rejects the
+// numbers the SSA never issues: area 000, 666, or 900-999, group 00, and serial
+// 0000.
+func checkSSN(ssn string) (string, error) {
+	digits := strings.ReplaceAll(strings.TrimSpace(ssn), "-", "")
+	if len(digits) != 9 || strings.Trim(digits, "0123456789") != "" {
+		return "", fmt.Errorf("want 9 digits, got %q", ssn)
+	}
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	switch {
+	case area == "000" || area == "666" || area[0] == '9':
+		return "", fmt.Errorf("area number %s is never issued", area)
+	case group == "00":
+		return "", fmt.Errorf("group number 00 is never issued")
+	case serial == "0000":
+		return "", fmt.Errorf("serial number 0000 is never issued")
+	}
+	return area + "-" + group + "-" + serial, nil
+}
+
+// maskSSN hides all but the last four digits: ***-**-6789. A blank SSN stays blank.
+func maskSSN(ssn string) string {
+	digits := strings.ReplaceAll(ssn, "-", "")
+	if digits == "" {
+		return ""
+	}
+	if len(digits) < 4 {
+		return "***-**-****"
+	}
+	return "***-**-" + digits[len(digits)-4:]
+}
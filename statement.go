@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// StatementLine is one pay period on an employee statement, with the running
+// totals from the statement's first period through this one.
+type StatementLine struct {
+	PayPeriod  string
+	Gross      Money
+	Taxes      Money
+	Deductions Money
+	Net        Money
+
+	CumulativeGross Money
+	CumulativeTaxes Money
+	CumulativeNet   Money
+}
+
+// Statement consolidates one employee's registers over a range of periods, for
+// earnings-verification requests (-statement).
+type Statement struct {
+	EmployeeID   string
+	EmployeeName string
+	Currency     string
+	From, To     time.Time // zero when the range is open on that side
+	Lines        []StatementLine
+}
+
+// employeeStatement collects the registers of employeeID whose periods start within
+// [from, to] (either may be zero for no bound), in period order, with cumulative
+// totals. Adjustment lines count toward the period they belong to. A statement
+// cannot mix currencies, since its totals would be meaningless.
+func employeeStatement(registers []PayRegister, employeeID string, from, to time.Time) (Statement, error) {
+	st := Statement{EmployeeID: employeeID, From: from, To: to}
+	type dated struct {
+		reg   PayRegister
+		start time.Time
+	}
+	var lines []dated
+	for _, reg := range registers {
+		if reg.EmployeeID != employeeID {
+			continue
+		}
+		start, err := parsePeriod(reg.PayPeriod)
+		if err != nil {
+			return st, fmt.Errorf("cannot order period %q: %v", reg.PayPeriod, err)
+		}
+		if (!from.IsZero() && start.Before(from)) || (!to.IsZero() && start.After(to)) {
+			continue
+		}
+		if len(lines) > 0 && reg.Currency != st.Currency {
+			return st, fmt.Errorf("employee %s is paid in both %q and %q in this range", employeeID, st.Currency, reg.Currency)
+		}
+		st.EmployeeName, st.Currency = reg.EmployeeName, reg.Currency
+		lines = append(lines, dated{reg, start})
+	}
+	if len(lines) == 0 {
+		return st, fmt.Errorf("no registers for employee %s in the requested range", employeeID)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].start.Before(lines[j].start) })
+
+	var cumulative StatementLine
+	for _, l := range lines {
+		reg := l.reg
+		taxes := reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare + contributionTotal(reg.Contributions)
+		cumulative.CumulativeGross += reg.GrossWages
+		cumulative.CumulativeTaxes += taxes
+		cumulative.CumulativeNet += reg.NetPay
+		if n := len(st.Lines); n > 0 && st.Lines[n-1].PayPeriod == reg.PayPeriod {
+			// An adjustment line for a period already listed.
+			last := &st.Lines[n-1]
+			last.Gross += reg.GrossWages
+			last.Taxes += taxes
+			last.Deductions += reg.TotalDeductions
+			last.Net += reg.NetPay
+			last.CumulativeGross, last.CumulativeTaxes, last.CumulativeNet = cumulative.CumulativeGross, cumulative.CumulativeTaxes, cumulative.CumulativeNet
+			continue
+		}
+		st.Lines = append(st.Lines, StatementLine{
+			PayPeriod:       reg.PayPeriod,
+			Gross:           reg.GrossWages,
+			Taxes:           taxes,
+			Deductions:      reg.TotalDeductions,
+			Net:             reg.NetPay,
+			CumulativeGross: cumulative.CumulativeGross,
+			CumulativeTaxes: cumulative.CumulativeTaxes,
+			CumulativeNet:   cumulative.CumulativeNet,
+		})
+	}
+	return st, nil
+}
+
+// title is the statement's heading line, naming the employee and the range covered.
+func (st Statement) title() string {
+	first, last := st.Lines[0].PayPeriod, st.Lines[len(st.Lines)-1].PayPeriod
+	title := fmt.Sprintf("Earnings statement for %s (%s), periods %s to %s", st.EmployeeName, st.EmployeeID, first, last)
+	if st.Currency != "" {
+		title += ", amounts in " + st.Currency
+	}
+	return title
+}
+
+// writeStatement writes st to filename: a PDF when the name ends in .pdf, otherwise
+// an aligned text table. "-" writes the text form to stdout. Amounts are written in
+// the display format.
+func writeStatement(st Statement, filename string, display DisplayFormat) (err error) {
+	if strings.EqualFold(filename[max(0, len(filename)-4):], ".pdf") {
+		if err := os.WriteFile(filename, renderPaystubPDF(statementLines(st, display)), 0644); err != nil {
+			return fmt.Errorf("cannot write statement: %v", err)
+		}
+		return nil
+	}
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create statement file: %v", err)
+	}
+	defer closeOutput(file, &err)
+	if err := writeStatementText(st, file, display); err != nil {
+		return fmt.Errorf("cannot write statement: %v", err)
+	}
+	return nil
+}
+
+// writeStatementText writes st as a heading and one row per period.
+func writeStatementText(st Statement, w io.Writer, display DisplayFormat) error {
+	if _, err := fmt.Fprintf(w, "%s\n\n", st.title()); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Pay Period\tGross\tTaxes\tDeductions\tNet Pay\tGross to Date\tTaxes to Date\tNet to Date\t")
+	for _, l := range st.Lines {
+		money := display.Money
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", l.PayPeriod, money(l.Gross), money(l.Taxes), money(l.Deductions), money(l.Net),
+			money(l.CumulativeGross), money(l.CumulativeTaxes), money(l.CumulativeNet))
+	}
+	return tw.Flush()
+}
+
+// statementLines lays out st for renderPaystubPDF: per period, its net pay and the
+// figures behind it, then the totals for the whole range.
+func statementLines(st Statement, display DisplayFormat) []paystubLine {
+	money := display.Money
+	lines := []paystubLine{
+		{Label: "EARNINGS STATEMENT", Bold: true},
+		{Label: st.title()},
+		{},
+	}
+	for _, l := range st.Lines {
+		lines = append(lines,
+			paystubLine{Label: "Pay Period " + l.PayPeriod, Value: money(l.Net), Bold: true},
+			paystubLine{Label: fmt.Sprintf("Gross %s, taxes %s, deductions %s", money(l.Gross), money(l.Taxes), money(l.Deductions))},
+			paystubLine{Label: fmt.Sprintf("To date: gross %s, taxes %s", money(l.CumulativeGross), money(l.CumulativeTaxes)), Value: money(l.CumulativeNet)},
+		)
+	}
+	last := st.Lines[len(st.Lines)-1]
+	lines = append(lines,
+		paystubLine{},
+		paystubLine{Label: "Total Gross Wages", Value: money(last.CumulativeGross), Bold: true},
+		paystubLine{Label: "Total Taxes", Value: money(last.CumulativeTaxes), Bold: true},
+		paystubLine{Label: "Total Net Pay", Value: money(last.CumulativeNet), Bold: true},
+	)
+	return lines
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TaxOverride replaces the configured federal and/or state withholding for one
+// employee (expats, negotiated or court-ordered withholding). A zero TaxOverride
+// leaves both taxes to the normal computation.
+type TaxOverride struct {
+	Federal TaxOverrideValue
+	State   TaxOverrideValue
+}
+
+// TaxOverrideValue overrides one tax with either a rate applied to the usual taxable
+// base or a fixed amount per register line. At most one of the two is set.
+type TaxOverrideValue struct {
+	Rate   *float64
+	Amount *Money
+}
+
+// tax computes the withholding on base: the fixed amount if there is one, otherwise
+// base at the override rate, or at defaultRate when the tax is not overridden.
+func (v TaxOverrideValue) tax(base Money, defaultRate float64, acc *float64) Money {
+	if v.Amount != nil {
+		return *v.Amount
+	}
+	rate := defaultRate
+	if v.Rate != nil {
+		rate = *v.Rate
+	}
+	return roundedMul(base, rate, acc)
+}
+
+// readTaxOverrides reads the -tax-overrides CSV: Employee ID, then any of Federal
+// Rate, State Rate, Federal Amount, and State Amount located by header. A blank cell
+// means that tax is not overridden; setting both a rate and an amount for the same
+// tax, or listing an employee twice, is an error.
+func readTaxOverrides(filename string, opts ReaderOptions) (map[string]TaxOverride, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a tax overrides file must have a header row")
+	}
+	overrides := make(map[string]TaxOverride)
+	err := readCSV(filename, "tax overrides", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		if _, ok := overrides[id]; ok {
+			return fmt.Errorf("employee %s is listed twice (row %d)", id, line)
+		}
+		var o TaxOverride
+		for _, tax := range []struct {
+			name string
+			dst  *TaxOverrideValue
+		}{
+			{"Federal", &o.Federal},
+			{"State", &o.State},
+		} {
+			if v := strings.TrimSpace(cols.value(row, tax.name+" Rate")); v != "" {
+				rate, err := strconv.ParseFloat(v, 64)
+				if err != nil || rate < 0 || rate > 1 {
+					return fmt.Errorf("error parsing %s Rate in row %d: want a rate between 0 and 1, got %q", tax.name, line, v)
+				}
+				tax.dst.Rate = &rate
+			}
+			if v := strings.TrimSpace(cols.value(row, tax.name+" Amount")); v != "" {
+				amount, err := parseMoney(v)
+				if err != nil {
+					return fmt.Errorf("error parsing %s Amount in row %d: %v", tax.name, line, err)
+				}
+				tax.dst.Amount = &amount
+			}
+			if tax.dst.Rate != nil && tax.dst.Amount != nil {
+				return fmt.Errorf("row %d sets both a %s rate and a %s amount", line, tax.name, tax.name)
+			}
+		}
+		overrides[id] = o
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Tax rounding policies (-tax-rounding). Every register line is computed in
+// these stages, and the policy only changes the last:
+//
+//  1. Gross: hours at the straight rate are exact; each overtime amount is
+//     rounded to the cent, half away from zero, as it is computed (see the
+//     earnings rounding policies below).
+//  2. Taxable bases: derived from that gross (whole units first under
+//     -round-gross-for-tax) and the benefits, all in cents, so exact.
+//  3. Taxes and contributions: each is its base times its rate.
+//
+// Under roundPerTax, the default, each tax in stage 3 is rounded to the cent
+// on its own, half away from zero, and the line's total tax is their sum.
+// Under roundTotal the taxes are kept exact until the end: the line's total tax
+// is the exact sum rounded once, half away from zero, and is then divided among
+// the taxes by the largest-remainder method, each getting its exact amount
+// rounded down plus one cent for the largest fractions until the total is
+// reached (ties go to the tax listed first). The two can differ by a cent or
+// two in total tax and in which column holds it. Fixed override amounts are
+// exact already. A contribution then held to its wage base is recomputed on the
+// capped earnings and rounded on its own.
+const (
+	roundPerTax = "per-tax"
+	roundTotal  = "total"
+)
+
+// Earnings rounding policies (-earnings-rounding), for stage 1. Hours are whole,
+// so only time and a half can leave a fraction of a cent. Under
+// roundEarningsComponents, the default, each overtime amount (per job, and per
+// rate either side of a mid-period change) is rounded on its own and gross is
+// their sum, as registers always have been. Under roundEarningsGross the overtime
+// amounts are added up exact and rounded once, so gross can be a cent or so less
+// (or more) than under the default when a line has several; the overtime premium
+// absorbs the difference. A line with one overtime amount is the same either way.
+const (
+	roundEarningsComponents = "components"
+	roundEarningsGross      = "gross"
+)
+
+// roundTaxesTotal applies roundTotal to a line's tax details, returning each
+// tax's amount under that policy, in the details' order. A detail's exact amount
+// is its base times its rate, or its amount when it has no rate (an override
+// amount, or a tax not levied).
+func roundTaxesTotal(details []TaxDetail) []Money {
+	exact := make([]float64, len(details))
+	var sum float64
+	for i, d := range details {
+		exact[i] = float64(d.Amount)
+		if d.Rate != 0 {
+			// Trimmed to a millionth of a cent, so float noise cannot move a floor.
+			exact[i] = math.Round(float64(d.Base)*d.Rate*1e6) / 1e6
+		}
+		sum += exact[i]
+	}
+	total := Money(math.Round(sum))
+	amounts := make([]Money, len(details))
+	order := make([]int, len(details))
+	var allocated Money
+	for i, e := range exact {
+		amounts[i] = Money(math.Floor(e))
+		allocated += amounts[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		fa, fb := exact[order[a]]-math.Floor(exact[order[a]]), exact[order[b]]-math.Floor(exact[order[b]])
+		return fa > fb
+	})
+	for k := 0; allocated < total && k < len(order); k++ {
+		amounts[order[k]]++
+		allocated++
+	}
+	return amounts
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestTaxRounding(t *testing.T) {
+	// 80 hours at 10.06 is where the policies part: rounding each tax gives 198.39
+	// of tax, rounding the exact total gives 198.38, a cent less federal.
+	for _, tc := range []struct {
+		policy         string
+		federal, total Money
+	}{
+		{roundPerTax, 9658, 19839},
+		{roundTotal, 9657, 19838},
+	} {
+		opts := defaultComputeOptions()
+		opts.TaxRounding = tc.policy
+		reg, _, err := computeRow(PayrollRecord{EmployeeID: "003", PayPeriod: "2024-06", HourlyRate: 1006},
+			TimeRecord{EmployeeID: "003", PayPeriod: "2024-06", RegularHours: 80}, BenefitsRecord{}, defaultTaxConfig(), opts)
+		if err != nil {
+			t.Errorf("-tax-rounding %s: %v", tc.policy, err)
+			continue
+		}
+		taxes := reg.FederalTax + reg.StateTax + reg.LocalTax + reg.SocialSecurity + reg.Medicare
+		if reg.FederalTax != tc.federal || taxes != tc.total {
+			t.Errorf("-tax-rounding %s: got federal %s of %s total tax, want %s of %s", tc.policy, reg.FederalTax, taxes, tc.federal, tc.total)
+		}
+	}
+}
+
+func TestEarningsRounding(t *testing.T) {
+	// Two jobs' hour of overtime at 10.01 is 15.015 each: 15.02 + 15.02 rounded
+	// per job, 30.03 rounded once.
+	for _, tc := range []struct {
+		policy string
+		gross  Money
+	}{
+		{roundEarningsComponents, 3004},
+		{roundEarningsGross, 3003},
+	} {
+		opts := defaultComputeOptions()
+		opts.EarningsRounding = tc.policy
+		jobs := []JobRate{{"Cook", 1001}, {"Server", 1001}}
+		hours := map[string]JobHours{jobKey("Cook"): {OvertimeHours: 1}, jobKey("Server"): {OvertimeHours: 1}}
+		reg, _, err := computeRow(PayrollRecord{EmployeeID: "004", PayPeriod: "2024-06", HourlyRate: 1001, Jobs: jobs},
+			TimeRecord{EmployeeID: "004", PayPeriod: "2024-06", OvertimeHours: 2, Jobs: hours}, BenefitsRecord{}, defaultTaxConfig(), opts)
+		if err != nil {
+			t.Errorf("-earnings-rounding %s: %v", tc.policy, err)
+			continue
+		}
+		if reg.GrossWages != tc.gross || reg.OvertimeStraight+reg.OvertimePremium != reg.GrossWages {
+			t.Errorf("-earnings-rounding %s: got gross %s (overtime %s + %s), want %s", tc.policy, reg.GrossWages, reg.OvertimeStraight, reg.OvertimePremium, tc.gross)
+		}
+	}
+}
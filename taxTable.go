@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TaxBracket is one band of a graduated income tax: Rate applies to the annual
+// taxable wages from From up to To. To is zero on the last bracket, which has no
+// upper limit.
+type TaxBracket struct {
+	From Money   `json:"from"`
+	To   Money   `json:"to,omitempty"`
+	Rate float64 `json:"rate"`
+}
+
+// checkBrackets validates a bracket schedule, passing each problem to add with
+// its field path: the first bracket starts at zero, each one starts where the one
+// before ends (no gaps, no overlaps), and only the last is open-ended, so every
+// wage falls in exactly one bracket.
+func checkBrackets(path string, brackets []TaxBracket, add func(path, format string, args ...any)) {
+	for i, b := range brackets {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		checkConfigRate(p+".rate", b.Rate, add)
+		if i == 0 && b.From != 0 {
+			add(p+".from", "the first bracket must start at 0, got %s", b.From)
+		}
+		if prev := brackets[max(i-1, 0)]; i > 0 && prev.To != 0 {
+			switch {
+			case b.From > prev.To:
+				add(p+".from", "leaves a gap: the bracket before ends at %s, this one starts at %s", prev.To, b.From)
+			case b.From < prev.To:
+				add(p+".from", "overlaps the bracket before, which ends at %s; this one starts at %s", prev.To, b.From)
+			}
+		}
+		last := i == len(brackets)-1
+		switch {
+		case b.To == 0 && !last:
+			add(p+".to", "only the last bracket may be open-ended")
+		case b.To != 0 && last:
+			add(p+".to", "the last bracket must be open-ended (no to), got %s", b.To)
+		case b.To != 0 && b.To <= b.From:
+			add(p+".to", "must be above from (%s), got %s", b.From, b.To)
+		}
+	}
+}
+
+// incomeTaxRate is the rate income tax is withheld at on a line's taxable base:
+// flat, or with brackets their effective rate on the base. For brackets the base
+// is annualized over PeriodsPerYear, taxed band by band, and the tax spread back
+// over the periods. A negative base (a correction) is refunded at the rate the
+// same wages would be taxed at.
+func (cfg TaxConfig) incomeTaxRate(brackets []TaxBracket, flat float64, base Money) float64 {
+	if len(brackets) == 0 {
+		return flat
+	}
+	if base == 0 {
+		return brackets[0].Rate
+	}
+	annual := math.Abs(float64(base)) * float64(max(cfg.PeriodsPerYear, 1))
+	var tax float64
+	for _, b := range brackets {
+		if annual <= float64(b.From) {
+			break
+		}
+		top := annual
+		if b.To != 0 {
+			top = min(annual, float64(b.To))
+		}
+		tax += (top - float64(b.From)) * b.Rate
+	}
+	return tax / annual
+}
+
+// TaxTable is a -tax-table file: for each tax year ("2024") and jurisdiction,
+// the brackets, rates, wage bases and thresholds that year's config takes. The
+// jurisdictions are "federal", "state", "socialSecurity", "medicare",
+// "local:<locality>" and "contribution:<name>":
+//
+//	{
+//	  "2024": {
+//	    "federal": {"brackets": [{"from": 0, "to": 11600, "rate": 0.10}, {"from": 11600, "rate": 0.12}]},
+//	    "socialSecurity": {"rate": 0.062, "employerRate": 0.062, "wageBase": 168600},
+//	    "local:NYC": {"rate": 0.03876}
+//	  }
+//	}
+type TaxTable map[string]map[string]TaxTableEntry
+
+// TaxTableEntry is one jurisdiction's figures for one year. Unset fields leave
+// the config's as they are.
+type TaxTableEntry struct {
+	Brackets     []TaxBracket `json:"brackets,omitempty"`
+	Rate         *float64     `json:"rate,omitempty"`
+	EmployerRate *float64     `json:"employerRate,omitempty"`
+	WageBase     *Money       `json:"wageBase,omitempty"`
+	Threshold    *Money       `json:"threshold,omitempty"`
+}
+
+// taxTableFields lists the fields each kind of jurisdiction takes.
+var taxTableFields = map[string][]string{
+	"federal":        {"brackets", "rate"},
+	"state":          {"brackets", "rate"},
+	"socialSecurity": {"rate", "employerRate", "wageBase"},
+	"medicare":       {"rate", "employerRate"},
+	"local":          {"rate"},
+	"contribution":   {"rate", "employerRate", "threshold", "wageBase"},
+}
+
+// fields lists the fields an entry sets.
+func (e TaxTableEntry) fields() []string {
+	var fields []string
+	if len(e.Brackets) > 0 {
+		fields = append(fields, "brackets")
+	}
+	if e.Rate != nil {
+		fields = append(fields, "rate")
+	}
+	if e.EmployerRate != nil {
+		fields = append(fields, "employerRate")
+	}
+	if e.WageBase != nil {
+		fields = append(fields, "wageBase")
+	}
+	if e.Threshold != nil {
+		fields = append(fields, "threshold")
+	}
+	return fields
+}
+
+// readTaxTable reads a -tax-table file, JSON when its name ends in .json and CSV
+// otherwise, and checks it is complete: see TaxTable.problems.
+//
+// The CSV has Year, Jurisdiction, From, To, Rate, Employer Rate, Wage Base and
+// Threshold columns, located by header. A row with a From is one bracket, in
+// order; any other row sets the jurisdiction's other figures for the year.
+func readTaxTable(filename string, opts ReaderOptions) (TaxTable, error) {
+	var table TaxTable
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		data, err := readInput(filename, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tax table: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&table); err != nil {
+			return nil, fmt.Errorf("cannot parse tax table %s: %v", filename, err)
+		}
+	} else {
+		if opts.NoHeader {
+			return nil, fmt.Errorf("a tax table file must have a header row")
+		}
+		table = make(TaxTable)
+		err := readCSV(filename, "tax table", opts, func(cols columnMap, row []string, line int) error {
+			year := strings.TrimSpace(cols.value(row, "Year"))
+			jurisdiction := strings.TrimSpace(cols.value(row, "Jurisdiction"))
+			if year == "" || jurisdiction == "" {
+				return fmt.Errorf("missing Year or Jurisdiction in row %d", line)
+			}
+			if table[year] == nil {
+				table[year] = make(map[string]TaxTableEntry)
+			}
+			entry := table[year][jurisdiction]
+			rate := func(column string) (*float64, error) {
+				v := strings.TrimSpace(cols.value(row, column))
+				if v == "" {
+					return nil, nil
+				}
+				r, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("error parsing %s in row %d: want a rate, got %q", column, line, v)
+				}
+				return &r, nil
+			}
+			amount := func(column string) (*Money, error) {
+				v := strings.TrimSpace(cols.value(row, column))
+				if v == "" {
+					return nil, nil
+				}
+				m, err := opts.money(v)
+				if err != nil {
+					return nil, fmt.Errorf("error parsing %s in row %d: %v", column, line, err)
+				}
+				return &m, nil
+			}
+			from, err := amount("From")
+			if err != nil {
+				return err
+			}
+			to, err := amount("To")
+			if err != nil {
+				return err
+			}
+			r, err := rate("Rate")
+			if err != nil {
+				return err
+			}
+			if from != nil || to != nil {
+				if from == nil || r == nil {
+					return fmt.Errorf("bracket in row %d needs a From and a Rate", line)
+				}
+				b := TaxBracket{From: *from, Rate: *r}
+				if to != nil {
+					b.To = *to
+				}
+				entry.Brackets = append(entry.Brackets, b)
+				table[year][jurisdiction] = entry
+				return nil
+			}
+			employerRate, err := rate("Employer Rate")
+			if err != nil {
+				return err
+			}
+			wageBase, err := amount("Wage Base")
+			if err != nil {
+				return err
+			}
+			threshold, err := amount("Threshold")
+			if err != nil {
+				return err
+			}
+			for _, f := range []struct {
+				column   string
+				set, was bool
+			}{
+				{"Rate", r != nil, entry.Rate != nil},
+				{"Employer Rate", employerRate != nil, entry.EmployerRate != nil},
+				{"Wage Base", wageBase != nil, entry.WageBase != nil},
+				{"Threshold", threshold != nil, entry.Threshold != nil},
+			} {
+				if f.set && f.was {
+					return fmt.Errorf("%s %s has its %s set twice (row %d)", jurisdiction, year, f.column, line)
+				}
+			}
+			entry.Rate = cmp.Or(r, entry.Rate)
+			entry.EmployerRate = cmp.Or(employerRate, entry.EmployerRate)
+			entry.WageBase = cmp.Or(wageBase, entry.WageBase)
+			entry.Threshold = cmp.Or(threshold, entry.Threshold)
+			table[year][jurisdiction] = entry
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if problems := table.problems(); len(problems) > 0 {
+		return nil, configError{"tax table " + filename, problems}
+	}
+	return table, nil
+}
+
+// problems lists what is wrong with a tax table, each prefixed with its
+// year.jurisdiction path: unknown years and jurisdictions, fields a jurisdiction
+// does not take, both brackets and a flat rate, out-of-range figures, and
+// bracket schedules with gaps or overlaps.
+func (t TaxTable) problems() []string {
+	var problems []string
+	add := func(path, format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	for _, year := range sortedKeys(t) {
+		if _, err := strconv.Atoi(year); err != nil {
+			add(year, "tax year %q is not a year", year)
+			continue
+		}
+		for _, jurisdiction := range sortedKeys(t[year]) {
+			path := year + "." + jurisdiction
+			entry := t[year][jurisdiction]
+			kind, name, qualified := strings.Cut(jurisdiction, ":")
+			allowed, ok := taxTableFields[kind]
+			switch {
+			case !ok:
+				add(path, "unknown jurisdiction (want federal, state, socialSecurity, medicare, local:<locality> or contribution:<name>)")
+				continue
+			case (kind == "local" || kind == "contribution") && strings.TrimSpace(name) == "":
+				add(path, "needs a name after the colon, e.g. %s:NYC", kind)
+				continue
+			case qualified && kind != "local" && kind != "contribution":
+				add(path, "%s takes no name after a colon", kind)
+				continue
+			}
+			fields := entry.fields()
+			if len(fields) == 0 {
+				add(path, "sets nothing")
+			}
+			for _, field := range fields {
+				if !slices.Contains(allowed, field) {
+					add(path+"."+field, "does not apply to %s (it takes %s)", kind, strings.Join(allowed, ", "))
+				}
+			}
+			if len(entry.Brackets) > 0 && entry.Rate != nil {
+				add(path, "sets both brackets and a flat rate")
+			}
+			checkBrackets(path+".brackets", entry.Brackets, add)
+			if entry.Rate != nil {
+				checkConfigRate(path+".rate", *entry.Rate, add)
+			}
+			if entry.EmployerRate != nil {
+				checkConfigRate(path+".employerRate", *entry.EmployerRate, add)
+			}
+			if entry.WageBase != nil && *entry.WageBase < 0 {
+				add(path+".wageBase", "must not be negative (0 means none), got %s", *entry.WageBase)
+			}
+			if entry.Threshold != nil && *entry.Threshold < 0 {
+				add(path+".threshold", "must not be negative, got %s", *entry.Threshold)
+			}
+		}
+	}
+	return problems
+}
+
+// apply layers the table over cfg's per-year tables, starting any year cfg does
+// not have from its base config, as a "years" entry in the config file would.
+// Brackets replace the flat rate and a flat rate drops the brackets. Contributions
+// must already be in the config; the table only updates their figures.
+func (t TaxTable) apply(cfg *TaxConfig) error {
+	if len(t) > 0 && cfg.Years == nil {
+		cfg.Years = make(map[string]TaxConfig)
+	}
+	for _, year := range sortedKeys(t) {
+		yearCfg, ok := cfg.Years[year]
+		if !ok {
+			var err error
+			if yearCfg, err = cfg.clone(); err != nil {
+				return err
+			}
+		}
+		for _, jurisdiction := range sortedKeys(t[year]) {
+			e := t[year][jurisdiction]
+			kind, name, _ := strings.Cut(jurisdiction, ":")
+			switch kind {
+			case "federal":
+				applyIncomeTax(e, &yearCfg.FederalBrackets, &yearCfg.FederalRate)
+			case "state":
+				applyIncomeTax(e, &yearCfg.StateBrackets, &yearCfg.StateRate)
+			case "socialSecurity":
+				setIf(&yearCfg.SocialSecurityRate, e.Rate)
+				setIf(&yearCfg.EmployerSocialSecurityRate, e.EmployerRate)
+				setIf(&yearCfg.SocialSecurityWageBase, e.WageBase)
+			case "medicare":
+				setIf(&yearCfg.MedicareRate, e.Rate)
+				setIf(&yearCfg.EmployerMedicareRate, e.EmployerRate)
+			case "local":
+				if yearCfg.LocalTaxRates == nil {
+					yearCfg.LocalTaxRates = make(map[string]float64)
+				}
+				yearCfg.LocalTaxRates[strings.ToUpper(strings.TrimSpace(name))] = *e.Rate
+			case "contribution":
+				i := indexContribution(yearCfg.Contributions, name)
+				if i < 0 {
+					return fmt.Errorf("tax table %s.%s: the config has no contribution %q", year, jurisdiction, name)
+				}
+				c := &yearCfg.Contributions[i]
+				setIf(&c.Rate, e.Rate)
+				setIf(&c.EmployerRate, e.EmployerRate)
+				setIf(&c.Threshold, e.Threshold)
+				setIf(&c.WageBase, e.WageBase)
+			}
+		}
+		cfg.Years[year] = yearCfg
+	}
+	return nil
+}
+
+// applyIncomeTax sets an income tax to an entry's brackets or flat rate.
+func applyIncomeTax(e TaxTableEntry, brackets *[]TaxBracket, rate *float64) {
+	if len(e.Brackets) > 0 {
+		*brackets = e.Brackets
+	}
+	if e.Rate != nil {
+		*brackets = nil
+		*rate = *e.Rate
+	}
+}
+
+// setIf sets *dst to *v when v is set.
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// indexContribution finds a contribution by name, ignoring case, or returns -1.
+func indexContribution(contributions []Contribution, name string) int {
+	for i, c := range contributions {
+		if strings.EqualFold(strings.TrimSpace(c.Name), strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyTaxTable reads a -tax-table file and layers it over the config.
+func (cfg *TaxConfig) applyTaxTable(filename string, opts ReaderOptions) error {
+	table, err := readTaxTable(filename, opts)
+	if err != nil {
+		return err
+	}
+	return table.apply(cfg)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFederalBrackets(t *testing.T) {
+	// 1000.00 a period is 26000.00 a year, 1160.00 at 10% plus 1728.00 at 12%, so
+	// 2888.00 / 26 = 111.08 withheld.
+	cfg := defaultTaxConfig()
+	cfg.FederalBrackets = []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}, {From: 1160000, Rate: 0.12}}
+	reg, _, err := computeRow(PayrollRecord{EmployeeID: "005", PayPeriod: "2024-06"},
+		TimeRecord{EmployeeID: "005", PayPeriod: "2024-06", Adjustment: 100000}, BenefitsRecord{}, cfg, defaultComputeOptions())
+	if err != nil || reg.FederalTax != 11108 {
+		t.Errorf("federal brackets on 1000.00: got %s (%v), want 111.08", reg.FederalTax, err)
+	}
+	cfg.FederalBrackets[1].From = 1200000
+	if problems := cfg.problems(); len(problems) != 1 || !strings.Contains(problems[0], "gap") {
+		t.Errorf("brackets with a gap: got problems %q, want one gap", problems)
+	}
+}
+
+func TestCheckBrackets(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		brackets []TaxBracket
+		want     string // a problem's text, or empty for none
+	}{
+		{"contiguous", []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}, {From: 1160000, Rate: 0.12}}, ""},
+		{"gap", []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}, {From: 1200000, Rate: 0.12}}, "gap"},
+		{"overlap", []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}, {From: 1000000, Rate: 0.12}}, "overlaps"},
+		{"first above zero", []TaxBracket{{From: 100, Rate: 0.10}}, "start at 0"},
+		{"open-ended early", []TaxBracket{{From: 0, Rate: 0.10}, {From: 1160000, Rate: 0.12}}, "only the last"},
+		{"closed last", []TaxBracket{{From: 0, To: 1160000, Rate: 0.10}}, "must be open-ended"},
+	} {
+		var problems []string
+		checkBrackets("federalBrackets", tc.brackets, func(path, format string, args ...any) {
+			problems = append(problems, path+": "+format)
+		})
+		switch {
+		case tc.want == "" && len(problems) > 0:
+			t.Errorf("%s: got problems %q, want none", tc.name, problems)
+		case tc.want != "" && (len(problems) != 1 || !strings.Contains(problems[0], tc.want)):
+			t.Errorf("%s: got problems %q, want one mentioning %q", tc.name, problems, tc.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Reported tips (the time file's optional Reported Tips column) are tips a
+// tipped employee has already received in cash. They are wages for tax, like
+// imputed income: every tax base includes them unless the config's UntaxedTips
+// exempts them, while GrossWages, the pay that goes through payroll, does not,
+// so the taxes on them come out of the cash wages. Zero tips change nothing.
+
+// tipColumns are the register columns for a line's tips, after any
+// contributions' and before the PTO columns.
+var tipColumns = []string{"Tip Wages", "Tip Credit"}
+
+// untaxedTips reports whether tax's base leaves reported tips out.
+func (cfg TaxConfig) untaxedTips(tax string) bool {
+	return slices.Contains(cfg.UntaxedTips, tax)
+}
+
+// tipCredit is the part of a line's tips that counts toward cfg.TipMinimumWage
+// for its hours: what the cash wages fall short of the minimum by, up to the
+// tips. The part of the shortfall the tips do not cover is returned too, for the
+// employer to make up; both are zero when the config sets no minimum.
+func (cfg TaxConfig) tipCredit(cashWages, tips Money, hours int) (credit, shortfall Money) {
+	if cfg.TipMinimumWage <= 0 || hours <= 0 {
+		return 0, 0
+	}
+	owed := max(cfg.TipMinimumWage.MulHours(hours)-cashWages, 0)
+	credit = min(owed, max(tips, 0))
+	return credit, owed - credit
+}
+
+// checkUntaxedTips validates the config's UntaxedTips, passing each problem to add.
+func checkUntaxedTips(untaxed []string, add func(path, format string, args ...any)) {
+	for i, tax := range untaxed {
+		if !slices.Contains(taxNames, tax) {
+			add(fmt.Sprintf("untaxedTips[%d]", i), "unknown tax %q (valid: federal, state, local, socialSecurity, medicare)", tax)
+		}
+	}
+}
+
+// hasTips reports whether any line has tips, for the register's tip columns.
+func hasTips(registers []PayRegister) bool {
+	for _, reg := range registers {
+		if reg.TipWages != 0 || reg.TipCredit != 0 {
+			return true
+		}
+	}
+	return false
+}
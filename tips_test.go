@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestReportedTips(t *testing.T) {
+	// Tips are wages for Medicare but, here, not for federal tax: 10 hours at 2.13
+	// plus 100.00 tips is federal on 21.30 and Medicare on 121.30 (1.76), and 51.20
+	// of the tips make up the 72.50 a 7.25 minimum wage needs.
+	cfg := defaultTaxConfig()
+	cfg.UntaxedTips = []string{"federal"}
+	cfg.TipMinimumWage = 725
+	reg, _, err := computeRow(PayrollRecord{EmployeeID: "007", PayPeriod: "2024-06", HourlyRate: 213},
+		TimeRecord{EmployeeID: "007", PayPeriod: "2024-06", RegularHours: 10, ReportedTips: 10000}, BenefitsRecord{}, cfg, defaultComputeOptions())
+	if err != nil || reg.TaxableWages != 2130 || reg.Medicare != 176 || reg.TipCredit != 5120 || reg.NetPay != reg.GrossWages-reg.TotalDeductions {
+		t.Errorf("got taxable %s, Medicare %s, credit %s, net %s (%v), want 21.30, 1.76, 51.20 and net of cash gross", reg.TaxableWages, reg.Medicare, reg.TipCredit, reg.NetPay, err)
+	}
+}
+
+func TestTipCredit(t *testing.T) {
+	cfg := defaultTaxConfig()
+	cfg.TipMinimumWage = 725
+	for _, tc := range []struct {
+		name              string
+		cash, tips        Money
+		hours             int
+		credit, shortfall Money
+	}{
+		{"tips cover the shortfall", 2130, 10000, 10, 5120, 0},
+		{"tips fall short", 2130, 2000, 10, 2000, 3120},
+		{"cash meets the minimum", 7250, 10000, 10, 0, 0},
+		{"no hours", 0, 10000, 0, 0, 0},
+	} {
+		credit, shortfall := cfg.tipCredit(tc.cash, tc.tips, tc.hours)
+		if credit != tc.credit || shortfall != tc.shortfall {
+			t.Errorf("%s: got credit %s, shortfall %s, want %s and %s", tc.name, credit, shortfall, tc.credit, tc.shortfall)
+		}
+	}
+}
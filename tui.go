@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// isTerminal reports whether f is an interactive terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// dashboardRedrawEvery throttles redraws while registers stream in.
+const dashboardRedrawEvery = 50 * time.Millisecond
+
+// dashboard is the -tui view: phase progress and running totals, redrawn in place
+// with plain ANSI escapes so no terminal library is needed. All methods are no-ops
+// on a nil *dashboard, so callers need not check whether -tui is active.
+type dashboard struct {
+	out       io.Writer
+	phases    []string
+	state     map[string]string
+	employees int
+	count     map[string]int
+	gross     map[string]Money
+	net       map[string]Money
+	drawn     int
+	lastDraw  time.Time
+}
+
+func newDashboard(out io.Writer, phases ...string) *dashboard {
+	return &dashboard{
+		out:    out,
+		phases: phases,
+		state:  make(map[string]string),
+		count:  make(map[string]int),
+		gross:  make(map[string]Money),
+		net:    make(map[string]Money),
+	}
+}
+
+// startPhase marks a phase as running.
+func (d *dashboard) startPhase(name string) {
+	if d == nil {
+		return
+	}
+	d.state[name] = "running"
+	d.draw()
+}
+
+// endPhase marks a phase as finished in duration.
+func (d *dashboard) endPhase(name string, duration time.Duration) {
+	if d == nil {
+		return
+	}
+	d.state[name] = duration.Round(time.Microsecond).String()
+	d.draw()
+}
+
+// observe adds one computed register to the running totals.
+func (d *dashboard) observe(reg PayRegister) {
+	if d == nil {
+		return
+	}
+	d.employees++
+	d.count[reg.Currency]++
+	d.gross[reg.Currency] += reg.GrossWages
+	d.net[reg.Currency] += reg.NetPay
+	if time.Since(d.lastDraw) >= dashboardRedrawEvery {
+		d.draw()
+	}
+}
+
+// draw repaints the dashboard over its previous frame.
+func (d *dashboard) draw() {
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", d.drawn)
+	}
+	lines := 0
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, "\033[2K"+format+"\n", args...)
+		lines++
+	}
+	line("Pay register")
+	for _, p := range d.phases {
+		mark, state := "[ ]", d.state[p]
+		switch state {
+		case "":
+			state = "pending"
+		case "running":
+			mark = "[>]"
+		default:
+			mark = "[x]"
+		}
+		line("  %s %-8s %s", mark, p, state)
+	}
+	line("  Employees processed: %d", d.employees)
+	for _, c := range sortedKeys(d.gross) {
+		label := ""
+		if c != "" {
+			label = " " + c
+		}
+		line("  Total gross%s: %s   Total net%s: %s", label, d.gross[c], label, d.net[c])
+	}
+	fmt.Fprint(d.out, b.String())
+	d.drawn = lines
+	d.lastDraw = time.Now()
+}
+
+// finish draws the final frame and a summary table of the run.
+func (d *dashboard) finish(output string) {
+	if d == nil {
+		return
+	}
+	d.draw()
+	fmt.Fprintln(d.out)
+	fmt.Fprintf(d.out, "%-10s %10s %16s %16s\n", "Currency", "Employees", "Gross Wages", "Net Pay")
+	for _, c := range sortedKeys(d.gross) {
+		label := c
+		if label == "" {
+			label = "-"
+		}
+		fmt.Fprintf(d.out, "%-10s %10d %16s %16s\n", label, d.count[c], d.gross[c], d.net[c])
+	}
+	fmt.Fprintf(d.out, "%-10s %10d\n", "TOTAL", d.employees)
+	fmt.Fprintf(d.out, "Pay register saved to %s\n", output)
+}
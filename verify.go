@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// checkRegisterConsistency checks that a register line adds up on its own terms,
+// independent of any recomputation: gross against rate and hours, total benefits and
+// total deductions against their parts, and net against gross less deductions. tol
+// is the largest difference accepted. It returns one message per inconsistency.
+// A register paid with overtimeAmounts (-overtime-mode amount) has no overtime
+// rate to check; its overtime pay is taken from Overtime Straight and Overtime
+// Premium instead, and only the straight-time part is checked against the hours.
+func checkRegisterConsistency(reg PayRegister, overtimeAmounts bool, tol Money) []string {
+	var problems []string
+	expect := func(what string, got, want Money) {
+		if !withinTolerance(got-want, tol) {
+			problems = append(problems, fmt.Sprintf("%s is %s but its parts give %s", what, got, want))
+		}
+	}
+	// Overtime is rounded to the cent the way computeRegister rounds it.
+	gross := reg.HourlyRate.MulHours(reg.RegularHours) +
+		reg.HourlyRate.MulRate(1.5*float64(reg.OvertimeHours)) +
+		reg.HourlyRate.MulHours(2*reg.DoubleTimeHours) +
+		reg.Adjustment + reg.CustomEarnings
+	if reg.PieceEarnings != 0 {
+		var rounding float64
+		gross = pieceGross(reg.PieceEarnings, reg.HourlyRate, reg.RegularHours, reg.OvertimeHours, reg.DoubleTimeHours, &rounding) + reg.Adjustment + reg.CustomEarnings
+	}
+	if overtimeAmounts {
+		gross = reg.HourlyRate.MulHours(reg.RegularHours) + reg.PieceEarnings + reg.OvertimeStraight + reg.OvertimePremium + reg.Adjustment + reg.CustomEarnings
+		expect("Overtime Straight", reg.OvertimeStraight, reg.HourlyRate.MulHours(reg.OvertimeHours+reg.DoubleTimeHours))
+	}
+	expect("Gross Wages", reg.GrossWages, gross)
+	if reg.OvertimeStraight != 0 || reg.OvertimePremium != 0 {
+		expect("Overtime Straight plus Overtime Premium", reg.OvertimeStraight+reg.OvertimePremium,
+			reg.GrossWages-reg.Adjustment-reg.CustomEarnings-reg.PieceEarnings-reg.HourlyRate.MulHours(reg.RegularHours))
+	}
+	expect("Total Benefits", reg.TotalBenefits, reg.HealthInsurance+reg.Retirement+reg.OtherBenefits)
+	// Arrears move deductions between lines, so Total Deductions then depends on
+	// the employee's earlier lines and cannot be checked from this one alone.
+	if reg.ArrearsCollected == 0 && reg.ArrearsOutstanding == 0 {
+		expect("Total Deductions", reg.TotalDeductions,
+			reg.FederalTax+reg.StateTax+reg.LocalTax+reg.SocialSecurity+reg.Medicare+contributionTotal(reg.Contributions)+reg.TotalBenefits+reg.CustomDeductions)
+	}
+	expect("Net Pay", reg.NetPay, reg.GrossWages-reg.TotalDeductions)
+	return problems
+}
+
+// checkTaxPlausibility checks that a register line's taxes could have been
+// withheld at all, however consistently the line adds up: no tax negative, taxes
+// together not above gross (plus imputed income), and Social Security and
+// Medicare not above their configured rate of that, nor below half of it unless
+// nothing was withheld (an exempt employee, a run without FICA). The Social
+// Security floor is skipped when the config sets a wage base, since the period
+// crossing it is withheld on only part of its wages. Corrections and other lines
+// with negative gross are left out: their taxes are legitimately negative.
+func checkTaxPlausibility(reg PayRegister, cfg TaxConfig, tol Money) []string {
+	if reg.IsCorrection || reg.GrossWages < 0 {
+		return nil
+	}
+	cfg, err := cfg.forPeriod(reg.PayPeriod)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot check taxes: %v", err)}
+	}
+	var problems []string
+	taxes := []struct {
+		name   string
+		amount Money
+	}{
+		{"Federal Tax", reg.FederalTax},
+		{"State Tax", reg.StateTax},
+		{"Local Tax", reg.LocalTax},
+		{"Social Security", reg.SocialSecurity},
+		{"Medicare", reg.Medicare},
+	}
+	var total Money
+	for _, t := range taxes {
+		if t.amount < 0 {
+			problems = append(problems, fmt.Sprintf("%s is negative (%s)", t.name, t.amount))
+		}
+		total += t.amount
+	}
+	wages := reg.GrossWages + reg.ImputedIncome + reg.TipWages
+	if total > wages+tol {
+		problems = append(problems, fmt.Sprintf("taxes total %s, more than gross wages of %s", total, wages))
+	}
+	fica := func(name string, amount Money, rate float64, floor bool) {
+		if amount <= 0 || wages <= 0 {
+			return
+		}
+		effective := float64(amount) / float64(wages)
+		if amount > wages.MulRate(rate)+tol || floor && amount < wages.MulRate(rate/2)-tol {
+			problems = append(problems, fmt.Sprintf("%s is %s, an effective rate of %.2f%% against the configured %.2f%%",
+				name, amount, 100*effective, 100*rate))
+		}
+	}
+	fica("Social Security", reg.SocialSecurity, cfg.SocialSecurityRate, cfg.SocialSecurityWageBase == 0)
+	fica("Medicare", reg.Medicare, cfg.MedicareRate, true)
+	return problems
+}
+
+// parseRegisterAmount parses an amount as written by writeRegister, including a
+// currency symbol ("-$1,234.50" style symbols but not grouping) or a blank cell.
+func parseRegisterAmount(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "-")
+	s = strings.TrimLeftFunc(s, func(r rune) bool { return !(r >= '0' && r <= '9') && r != '.' })
+	m, err := parseMoney(s)
+	if neg {
+		m = -m
+	}
+	return m, err
+}
+
+// readRegisterFile reads a register CSV (ours or an external one with the same
+// column names) back into PayRegisters. Columns are located by header name; columns
+// after the standard ones are contributions, PTO accruals, and named benefits,
+// which as in memory also count toward Other Benefits. TOTAL rows are skipped.
+func readRegisterFile(filename string, opts ReaderOptions) ([]PayRegister, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a register file must have a header row")
+	}
+	var registers []PayRegister
+	extras := -1 // the first column after the standard ones
+	err := readCSV(filename, "register", opts, func(cols columnMap, row []string, line int) error {
+		if extras < 0 {
+			// Optional standard columns may be left out, so the extras start
+			// after the last standard column the header has.
+			extras = 0
+			for _, name := range registerHeader {
+				if i, ok := cols.lookup(name); ok {
+					extras = max(extras, i+1)
+				}
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(cols.value(row, "Row Type")), "TOTAL") {
+			return nil
+		}
+		reg := PayRegister{
+			EmployeeID:   cols.value(row, "Employee ID"),
+			EmployeeName: cols.value(row, "Employee Name"),
+			JobTitle:     cols.value(row, "Job Title"),
+			PayPeriod:    cols.value(row, "Pay Period"),
+			IsAdjustment: strings.EqualFold(strings.TrimSpace(cols.value(row, "Row Type")), "ADJUSTMENT"),
+			IsCorrection: strings.EqualFold(strings.TrimSpace(cols.value(row, "Row Type")), "CORRECTION"),
+			Currency:     strings.TrimSpace(cols.value(row, "Currency")),
+		}
+		hours := []struct {
+			column string
+			dst    *int
+		}{
+			{"Regular Hours", &reg.RegularHours},
+			{"Overtime Hours", &reg.OvertimeHours},
+			{"Double Time Hours", &reg.DoubleTimeHours},
+			{"Units", &reg.Units},
+		}
+		for _, h := range hours {
+			v := strings.TrimSpace(cols.value(row, h.column))
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", h.column, line, err)
+			}
+			*h.dst = n
+		}
+		amounts := []struct {
+			column string
+			dst    *Money
+		}{
+			{"Hourly Rate", &reg.HourlyRate},
+			{"Piece Earnings", &reg.PieceEarnings},
+			{"Overtime Straight", &reg.OvertimeStraight},
+			{"Overtime Premium", &reg.OvertimePremium},
+			{"Adjustment", &reg.Adjustment},
+			{"Gross Wages", &reg.GrossWages},
+			{"Imputed Income", &reg.ImputedIncome},
+			{"Federal Tax", &reg.FederalTax},
+			{"State Tax", &reg.StateTax},
+			{"Local Tax", &reg.LocalTax},
+			{"Social Security", &reg.SocialSecurity},
+			{"Medicare", &reg.Medicare},
+			{"Health Insurance", &reg.HealthInsurance},
+			{"Retirement", &reg.Retirement},
+			{"Other Benefits", &reg.OtherBenefits},
+			{"Total Benefits", &reg.TotalBenefits},
+			{"Custom Deductions", &reg.CustomDeductions},
+			{"Arrears Collected", &reg.ArrearsCollected},
+			{"Arrears Outstanding", &reg.ArrearsOutstanding},
+			{"Total Deductions", &reg.TotalDeductions},
+			{"Net Pay", &reg.NetPay},
+		}
+		for _, a := range amounts {
+			m, err := parseRegisterAmount(cols.value(row, a.column))
+			if err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", a.column, line, err)
+			}
+			*a.dst = m
+		}
+		for i := extras; i < len(row) && i < len(cols.names); i++ {
+			name := strings.TrimSpace(cols.names[i])
+			if slices.Contains(ptoColumns, name) {
+				if err := readPTOCell(&reg, name, row[i]); err != nil {
+					return fmt.Errorf("error parsing %s in row %d: %v", name, line, err)
+				}
+				continue
+			}
+			m, err := parseRegisterAmount(row[i])
+			if err != nil {
+				return fmt.Errorf("error parsing %s in row %d: %v", name, line, err)
+			}
+			switch name {
+			case "Custom Earnings":
+				reg.CustomEarnings = m
+				continue
+			case "Tip Wages":
+				reg.TipWages = m
+				continue
+			case "Tip Credit":
+				reg.TipCredit = m
+				continue
+			}
+			if contribution, ok := strings.CutSuffix(name, " Contribution"); ok {
+				reg.Contributions = append(reg.Contributions, ContributionAmount{Name: contribution, Employee: m})
+				continue
+			}
+			if reg.NamedBenefits == nil {
+				reg.NamedBenefits = make(map[string]Money)
+			}
+			reg.NamedBenefits[name] = m
+			reg.OtherBenefits += m
+		}
+		registers = append(registers, reg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registers, nil
+}
+
+// verifyRegisterFile reads a register file and checks every line's internal
+// consistency and the plausibility of its taxes under cfg, returning one message
+// per problem.
+func verifyRegisterFile(filename string, opts ReaderOptions, cfg TaxConfig, overtimeAmounts bool, tol Money) ([]string, int, error) {
+	registers, err := readRegisterFile(filename, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var problems []string
+	for _, reg := range registers {
+		for _, p := range append(checkRegisterConsistency(reg, overtimeAmounts, tol), checkTaxPlausibility(reg, cfg, tol)...) {
+			problems = append(problems, fmt.Sprintf("employee %s period %s: %s", reg.EmployeeID, reg.PayPeriod, p))
+		}
+	}
+	return problems, len(registers), nil
+}
+
+// roundTripColumns are the register columns -round-trip-check compares, with how to
+// read each from a PayRegister.
+var roundTripColumns = []struct {
+	name  string
+	value func(PayRegister) Money
+}{
+	{"Hourly Rate", func(r PayRegister) Money { return r.HourlyRate }},
+	{"Regular Hours", func(r PayRegister) Money { return Money(r.RegularHours) }},
+	{"Overtime Hours", func(r PayRegister) Money { return Money(r.OvertimeHours) }},
+	{"Double Time Hours", func(r PayRegister) Money { return Money(r.DoubleTimeHours) }},
+	{"Units", func(r PayRegister) Money { return Money(r.Units) }},
+	{"Piece Earnings", func(r PayRegister) Money { return r.PieceEarnings }},
+	{"Overtime Straight", func(r PayRegister) Money { return r.OvertimeStraight }},
+	{"Overtime Premium", func(r PayRegister) Money { return r.OvertimePremium }},
+	{"Adjustment", func(r PayRegister) Money { return r.Adjustment }},
+	{"Gross Wages", func(r PayRegister) Money { return r.GrossWages }},
+	{"Imputed Income", func(r PayRegister) Money { return r.ImputedIncome }},
+	{"Federal Tax", func(r PayRegister) Money { return r.FederalTax }},
+	{"State Tax", func(r PayRegister) Money { return r.StateTax }},
+	{"Local Tax", func(r PayRegister) Money { return r.LocalTax }},
+	{"Social Security", func(r PayRegister) Money { return r.SocialSecurity }},
+	{"Medicare", func(r PayRegister) Money { return r.Medicare }},
+	{"Health Insurance", func(r PayRegister) Money { return r.HealthInsurance }},
+	{"Retirement", func(r PayRegister) Money { return r.Retirement }},
+	{"Total Benefits", func(r PayRegister) Money { return r.TotalBenefits }},
+	{"Custom Deductions", func(r PayRegister) Money { return r.CustomDeductions }},
+	{"Total Deductions", func(r PayRegister) Money { return r.TotalDeductions }},
+	{"Net Pay", func(r PayRegister) Money { return r.NetPay }},
+}
+
+// readRegisterNDJSON reads a register written by writeRegisterNDJSON.
+func readRegisterNDJSON(filename string) ([]PayRegister, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open register file: %w", err)
+	}
+	defer file.Close()
+	var registers []PayRegister
+	decoder := json.NewDecoder(file)
+	for line := 1; ; line++ {
+		var reg PayRegister
+		if err := decoder.Decode(&reg); err == io.EOF {
+			return registers, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing register line %d: %v", line, err)
+		}
+		registers = append(registers, reg)
+	}
+}
+
+// checkRoundTrip re-reads a register just written to filename in format and checks
+// that it holds the same lines as want, in the same order: identifiers exactly, and
+// every amount in roundTripColumns within tol. Hours are compared
+// exactly. Columns the register was written without (see registerColumns) are
+// not compared.
+func checkRoundTrip(want []PayRegister, filename, format string, opts WriterOptions, tol Money) error {
+	var got []PayRegister
+	var err error
+	written := func(string) bool { return true }
+	switch format {
+	case "ndjson":
+		got, err = readRegisterNDJSON(filename)
+	default:
+		got, err = readRegisterFile(filename, ReaderOptions{Delimiter: ','})
+		if columns, cerr := registerColumns(want, opts); cerr == nil {
+			written = func(name string) bool {
+				return slices.ContainsFunc(columns, func(c int) bool { return registerHeader[c] == name })
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot re-read %s: %v", filename, err)
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("%s holds %d register line(s), %d were written", filename, len(got), len(want))
+	}
+	// Cents are written as whole numbers and read back as currency units.
+	scale := Money(1)
+	if format != "ndjson" && opts.NumberFormat == numberCents {
+		scale = 100
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if written("Employee ID") && w.EmployeeID != g.EmployeeID || written("Pay Period") && w.PayPeriod != g.PayPeriod {
+			return fmt.Errorf("line %d: wrote employee %s period %s but read back employee %s period %s", i+1, w.EmployeeID, w.PayPeriod, g.EmployeeID, g.PayPeriod)
+		}
+		for _, c := range roundTripColumns {
+			if !written(c.name) {
+				continue
+			}
+			wantValue, gotValue := c.value(w), c.value(g)
+			if strings.HasSuffix(c.name, "Hours") {
+				if wantValue != gotValue {
+					return fmt.Errorf("employee %s period %s: %s wrote %d but read back %d", w.EmployeeID, w.PayPeriod, c.name, wantValue, gotValue)
+				}
+				continue
+			}
+			if scale != 1 {
+				gotValue /= scale
+			}
+			if !withinTolerance(gotValue-wantValue, tol) {
+				return fmt.Errorf("employee %s period %s: %s wrote %s but read back %s", w.EmployeeID, w.PayPeriod, c.name, wantValue, gotValue)
+			}
+		}
+	}
+	return nil
+}
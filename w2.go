@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+)
+
+// W2Preview is one employee's simplified W-2 for one calendar year, added up from
+// the year's registers, to sanity-check figures before the official filing. The
+// box numbers are the form's.
+type W2Preview struct {
+	EmployeeID   string
+	EmployeeName string
+	SSN          string // as the register shows it, masked unless -full-ssn
+	Year         int
+	Currency     string
+	Periods      int
+	// WagesTips (box 1) is the federal income-tax base; FederalWithheld (box 2)
+	// the federal tax.
+	WagesTips       Money
+	FederalWithheld Money
+	// SocialSecurityWages (box 3) stops at the year's wage base when the config
+	// sets one; SocialSecurityWithheld (box 4) is what was actually withheld.
+	SocialSecurityWages    Money
+	SocialSecurityWithheld Money
+	MedicareWages          Money // box 5
+	MedicareWithheld       Money // box 6
+	StateWages             Money // box 16
+	StateWithheld          Money // box 17
+}
+
+// w2Previews totals each employee's registers per calendar year (of the period's
+// start) and currency into W-2 boxes, adjustment lines included. Like the
+// -ss-wage-base report it only sees the periods in the run, so a full year's
+// preview needs the whole year's inputs, or the prior system's totals in seeds.
+// Seeds fill boxes 1, 2, 3 and 5; the others count the run's periods only.
+func w2Previews(registers []PayRegister, cfg TaxConfig, seeds map[string]YTDSeed) ([]W2Preview, error) {
+	previews := make(map[string]*W2Preview)
+	for _, reg := range registers {
+		start, err := parsePeriod(reg.PayPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("cannot place period %q in a year: %v", reg.PayPeriod, err)
+		}
+		key := ytdKey(reg.EmployeeID, start.Year(), reg.Currency)
+		w := previews[key]
+		if w == nil {
+			seed := seeds[key]
+			w = &W2Preview{EmployeeID: reg.EmployeeID, EmployeeName: reg.EmployeeName, Year: start.Year(), Currency: reg.Currency,
+				WagesTips: seed.FederalTaxableWages, FederalWithheld: seed.FederalWithheld,
+				SocialSecurityWages: seed.SocialSecurityWages, MedicareWages: seed.MedicareWages}
+			previews[key] = w
+		}
+		if w.SSN == "" {
+			w.SSN = reg.SSN
+		}
+		w.Periods++
+		w.WagesTips += reg.TaxableWages
+		w.FederalWithheld += reg.FederalTax
+		w.SocialSecurityWages += reg.SocialSecurityWages
+		w.SocialSecurityWithheld += reg.SocialSecurity
+		w.MedicareWages += reg.MedicareWages
+		w.MedicareWithheld += reg.Medicare
+		w.StateWages += reg.StateWages
+		w.StateWithheld += reg.StateTax
+	}
+	result := make([]W2Preview, 0, len(previews))
+	for _, key := range sortedKeys(previews) {
+		w := previews[key]
+		yearCfg, err := cfg.forPeriod(strconv.Itoa(w.Year) + "-01")
+		if err != nil {
+			return nil, err
+		}
+		if base := yearCfg.SocialSecurityWageBase; base > 0 {
+			w.SocialSecurityWages = min(w.SocialSecurityWages, base)
+		}
+		result = append(result, *w)
+	}
+	return result, nil
+}
+
+// writeW2Previews writes the -w2-preview report as CSV, one row per employee and year.
+func writeW2Previews(previews []W2Preview, filename string, opts WriterOptions) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create W-2 preview file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+	header := []string{"Employee ID", "Employee Name", "SSN", "Year", "Periods",
+		"Box 1 Wages", "Box 2 Federal Withheld", "Box 3 Social Security Wages", "Box 4 Social Security Withheld",
+		"Box 5 Medicare Wages", "Box 6 Medicare Withheld", "Box 16 State Wages", "Box 17 State Withheld", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write W-2 preview header: %v", err)
+	}
+	for _, w := range previews {
+		money := opts.formatter(w.Currency)
+		row := []string{csvText(w.EmployeeID), csvText(w.EmployeeName), w.SSN, strconv.Itoa(w.Year), strconv.Itoa(w.Periods),
+			money(w.WagesTips), money(w.FederalWithheld), money(w.SocialSecurityWages), money(w.SocialSecurityWithheld),
+			money(w.MedicareWages), money(w.MedicareWithheld), money(w.StateWages), money(w.StateWithheld), w.Currency}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write W-2 preview row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write W-2 preview file: %v", err)
+	}
+	return nil
+}
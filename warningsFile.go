@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+)
+
+// Severities in the -warnings-file sidecar: a warning is a finding on a line
+// that was still written; an error is a row the register left out.
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// WarningsFile collects a run's warnings, failed rows and unmatched records for
+// the -warnings-file sidecar, one triage list in place of the log. Like the run
+// report, its methods are no-ops on a nil *WarningsFile.
+type WarningsFile struct {
+	path    string
+	entries []WarningEntry
+}
+
+// WarningEntry is one row of the -warnings-file sidecar.
+type WarningEntry struct {
+	Severity string
+	Warning
+}
+
+// activeWarnings is the -warnings-file being collected, if any. fatalf writes it
+// before exiting, so a failed run still leaves what it found.
+var activeWarnings *WarningsFile
+
+func newWarningsFile(path string) *WarningsFile {
+	return &WarningsFile{path: path}
+}
+
+// warn adds a warning.
+func (f *WarningsFile) warn(w Warning) {
+	if f == nil {
+		return
+	}
+	f.entries = append(f.entries, WarningEntry{severityWarning, w})
+}
+
+// rowErrors adds the rows that failed to compute.
+func (f *WarningsFile) rowErrors(errs []RowError) {
+	if f == nil {
+		return
+	}
+	for _, e := range errs {
+		f.entries = append(f.entries, WarningEntry{severityError, Warning{Category: "row-error", EmployeeID: e.EmployeeID, PayPeriod: e.PayPeriod, Message: e.Err.Error()}})
+	}
+}
+
+// unmatched adds the records that did not reach the register because another
+// input had no record for their employee-period.
+func (f *WarningsFile) unmatched(errs []error) {
+	if f == nil {
+		return
+	}
+	for _, err := range errs {
+		w := Warning{Category: "unmatched", Message: err.Error()}
+		var rowErr RowError
+		if errors.As(err, &rowErr) {
+			w.EmployeeID, w.PayPeriod, w.Message = rowErr.EmployeeID, rowErr.PayPeriod, rowErr.Err.Error()
+		}
+		f.entries = append(f.entries, WarningEntry{severityWarning, w})
+	}
+}
+
+// finish writes the sidecar. One that cannot be written is logged rather than
+// failing the run.
+func (f *WarningsFile) finish() {
+	if f == nil {
+		return
+	}
+	if err := writeWarningsFile(f.entries, f.path); err != nil {
+		logf("Warning: %v", err)
+	}
+}
+
+// writeWarningsFile writes the -warnings-file CSV, one row per entry in the order
+// they were found.
+func writeWarningsFile(entries []WarningEntry, filename string) (err error) {
+	file, err := createOutput(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create warnings file: %v", err)
+	}
+	defer closeOutput(file, &err)
+
+	writer := csv.NewWriter(file)
+
+	header := []string{"Severity", "Category", "Employee ID", "Pay Period", "Message", "Value"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("cannot write warnings header: %v", err)
+	}
+	for _, e := range entries {
+		row := []string{e.Severity, e.Category, csvText(e.EmployeeID), csvText(e.PayPeriod), csvText(e.Message), e.Value}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("cannot write warnings row: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write warnings file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// fileState is what watchAndRerun compares between polls.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// snapshotFiles stats every file; a missing file is recorded rather than an error,
+// since editors often replace a file by deleting and recreating it.
+func snapshotFiles(files []string) map[string]fileState {
+	states := make(map[string]fileState, len(files))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			states[f] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+		} else {
+			states[f] = fileState{}
+		}
+	}
+	return states
+}
+
+// sameSnapshot reports whether two snapshots of the same files are identical.
+func sameSnapshot(a, b map[string]fileState) bool {
+	for f, s := range a {
+		if b[f] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// watchAndRerun runs the pipeline, then polls files every interval and runs it again
+// after any of them changes. Changes are debounced: the run waits until the files
+// have been still for debounce, so a burst of saves triggers one run. Each run is
+// this binary re-executed with the same flags and -watch=false, so a failing run
+// (which exits through fatalf) reports its error without ending the watch. It
+// returns only if the binary cannot be located.
+func watchAndRerun(files []string, interval, debounce time.Duration, out io.Writer) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate own executable: %v", err)
+	}
+	run := func() {
+		cmd := exec.Command(self, append(os.Args[1:], "-watch=false")...)
+		cmd.Stdout, cmd.Stderr = out, os.Stderr
+		start := time.Now()
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(out, "[watch] run failed (%v) after %v\n", err, time.Since(start).Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(out, "[watch] run finished in %v\n", time.Since(start).Round(time.Millisecond))
+		}
+		fmt.Fprintf(out, "[watch] waiting for changes to %d file(s); Ctrl-C to stop\n", len(files))
+	}
+
+	last := snapshotFiles(files)
+	run()
+	for {
+		time.Sleep(interval)
+		current := snapshotFiles(files)
+		if sameSnapshot(last, current) {
+			continue
+		}
+		// Wait for the writes to settle.
+		for stillSince := time.Now(); time.Since(stillSince) < debounce; {
+			time.Sleep(interval)
+			if next := snapshotFiles(files); !sameSnapshot(current, next) {
+				current, stillSince = next, time.Now()
+			}
+		}
+		last = current
+		fmt.Fprintf(out, "[watch] change detected at %s; rerunning\n", time.Now().Format("15:04:05"))
+		run()
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isXLSX reports whether an input path names an Excel workbook rather than a CSV.
+func isXLSX(filename string) bool {
+	return strings.EqualFold(path.Ext(filename), ".xlsx")
+}
+
+// inputPath returns name, or the workbook of the same name (payroll_data.xlsx for
+// payroll_data.csv) when only that exists.
+func inputPath(name string) string {
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	workbook := strings.TrimSuffix(name, path.Ext(name)) + ".xlsx"
+	if _, err := os.Stat(workbook); err == nil {
+		return workbook
+	}
+	return name
+}
+
+// xlsxRow is one worksheet row: its 1-based row number and its cells as text.
+type xlsxRow struct {
+	line  int
+	cells []string
+}
+
+// readXLSXRows reads the rows of one sheet of an .xlsx workbook, the first sheet
+// unless sheet names another. Cells become the text the CSV path would have seen:
+// shared and inline strings as they are, numbers in their shortest decimal form (so
+// 32.950000000000003 reads as 32.95), booleans as TRUE/FALSE, and numbers in a date
+// format as YYYY-MM-DD, or YYYY-MM when the format shows no day. Missing cells in a row are blank, and rows with no cells at
+// all are skipped.
+func readXLSXRows(r io.Reader, sheet string) ([]xlsxRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	book, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx workbook: %v", err)
+	}
+	sheetPath, err := xlsxSheetPath(book, sheet)
+	if err != nil {
+		return nil, err
+	}
+	var sst struct {
+		Items []*xlsxText `xml:"si"`
+	}
+	if _, err := xlsxDecode(book, "xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		shared[i] = item.String()
+	}
+	dateStyles, err := xlsxDateStyles(book)
+	if err != nil {
+		return nil, err
+	}
+
+	var ws struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string    `xml:"r,attr"`
+				Type   string    `xml:"t,attr"`
+				Style  int       `xml:"s,attr"`
+				Value  string    `xml:"v"`
+				Inline *xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if found, err := xlsxDecode(book, sheetPath, &ws); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("workbook has no worksheet at %s", sheetPath)
+	}
+
+	var rows []xlsxRow
+	for i, row := range ws.Rows {
+		line := row.R
+		if line == 0 {
+			line = i + 1
+		}
+		var cells []string
+		for j, c := range row.Cells {
+			col := j
+			if c.Ref != "" {
+				if col, err = xlsxColumn(c.Ref); err != nil {
+					return nil, fmt.Errorf("row %d: %v", line, err)
+				}
+			}
+			var text string
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("row %d: cell %s refers to missing shared string %q", line, c.Ref, c.Value)
+				}
+				text = shared[n]
+			case "inlineStr":
+				if c.Inline != nil {
+					text = c.Inline.String()
+				}
+			case "b":
+				text = map[string]string{"1": "TRUE", "0": "FALSE"}[c.Value]
+			case "str", "e":
+				text = c.Value
+			default:
+				text = xlsxNumber(c.Value, dateStyles[c.Style])
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = text
+		}
+		if len(cells) > 0 {
+			rows = append(rows, xlsxRow{line: line, cells: cells})
+		}
+	}
+	// Like a CSV, every row is as wide as the widest.
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row.cells))
+	}
+	for i := range rows {
+		for len(rows[i].cells) < width {
+			rows[i].cells = append(rows[i].cells, "")
+		}
+	}
+	return rows, nil
+}
+
+// xlsxText is a string item: plain text, or rich-text runs to be concatenated.
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *xlsxText) String() string {
+	s := t.Text
+	for _, r := range t.Runs {
+		s += r.Text
+	}
+	return s
+}
+
+// xlsxDecode unmarshals one XML part of the workbook into v. A part that does not
+// exist leaves v alone and reports false; optional parts (shared strings, styles)
+// are often left out.
+func xlsxDecode(book *zip.Reader, name string, v any) (bool, error) {
+	f, err := book.Open(name)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return true, fmt.Errorf("cannot parse %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// xlsxSheetPath finds the worksheet part for the named sheet, or the first sheet
+// when sheet is empty, via the workbook and its relationships.
+func xlsxSheetPath(book *zip.Reader, sheet string) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if _, err := xlsxDecode(book, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+	if _, err := xlsxDecode(book, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+	var names []string
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+		if sheet != "" && s.Name != sheet {
+			continue
+		}
+		for _, rel := range rels.Rels {
+			if rel.ID == s.ID {
+				if strings.HasPrefix(rel.Target, "/") {
+					return strings.TrimPrefix(rel.Target, "/"), nil
+				}
+				return path.Join("xl", rel.Target), nil
+			}
+		}
+		return "", fmt.Errorf("sheet %q has no worksheet part", s.Name)
+	}
+	return "", fmt.Errorf("workbook has no sheet %q (sheets: %s)", sheet, strings.Join(names, ", "))
+}
+
+// xlsxDateStyles maps the index of each cell style that displays numbers as dates
+// to the layout such cells are read in: the built-in date formats (14-22, 45-47) and
+// custom formats with day, month or year codes outside quoted text. A custom format
+// without a day code ("mmm yyyy") names a month, as pay periods often do.
+func xlsxDateStyles(book *zip.Reader) (map[int]string, error) {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Xfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if _, err := xlsxDecode(book, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+	dateFormat := func(id int) bool { return (id >= 14 && id <= 22) || (id >= 45 && id <= 47) }
+	custom := make(map[int]string)
+	for _, f := range styles.NumFmts {
+		// Quoted text and bracketed colours or locales ("[Red]", "[$-409]") are not codes.
+		code, quoted, bracketed := strings.Builder{}, false, false
+		for _, r := range f.Code {
+			switch {
+			case r == '"' && !bracketed:
+				quoted = !quoted
+			case r == '[' && !quoted:
+				bracketed = true
+			case r == ']' && !quoted:
+				bracketed = false
+			case !quoted && !bracketed:
+				code.WriteRune(r)
+			}
+		}
+		switch lower := strings.ToLower(code.String()); {
+		case strings.Contains(lower, "d"):
+			custom[f.ID] = "2006-01-02"
+		case strings.ContainsAny(lower, "my"):
+			custom[f.ID] = "2006-01"
+		default:
+			custom[f.ID] = ""
+		}
+	}
+	dates := make(map[int]string)
+	for i, xf := range styles.Xfs {
+		if layout, ok := custom[xf.NumFmtID]; ok {
+			dates[i] = layout
+		} else if dateFormat(xf.NumFmtID) {
+			dates[i] = "2006-01-02"
+		}
+	}
+	return dates, nil
+}
+
+// xlsxEpoch is day zero of Excel's 1900 date system (serial 1 is 1900-01-01, and
+// the nonexistent 1900-02-29 is accounted for by starting on 1899-12-30).
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxNumber formats a numeric cell's stored value as the CSV path would read it,
+// as a date in dateLayout when the cell's style is a date format.
+func xlsxNumber(v string, dateLayout string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if dateLayout != "" {
+		return xlsxEpoch.AddDate(0, 0, int(math.Floor(f))).Format(dateLayout)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// xlsxColumn converts the column letters of a cell reference ("C7") to a 0-based index.
+func xlsxColumn(ref string) (int, error) {
+	col := 0
+	for i, r := range ref {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			col = col*26 + int(r-'A') + 1
+		case r >= '0' && r <= '9' && i > 0:
+			return col - 1, nil
+		default:
+			return 0, fmt.Errorf("invalid cell reference %q", ref)
+		}
+	}
+	return 0, fmt.Errorf("invalid cell reference %q", ref)
+}
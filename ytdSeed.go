@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// YTDSeed is one employee's year-to-date totals carried over from a prior payroll
+// system when migrating mid-year (-ytd-seed). The year-to-date reports, the Social
+// Security wage base and the contributions' wage bases start from them instead of
+// zero.
+type YTDSeed struct {
+	Gross               Money
+	SocialSecurityWages Money
+	MedicareWages       Money
+	FederalWithheld     Money
+	// FederalTaxableWages is the prior system's federal income-tax base, W-2 box 1;
+	// Gross when the file does not give it.
+	FederalTaxableWages Money
+	// Contributions maps a contribution's name to the earnings it has been levied
+	// on so far, toward its wage base.
+	Contributions map[string]Money
+}
+
+// ytdKey keys year-to-date figures by employee, calendar year and currency, the
+// way the wage base and W-2 totals are kept.
+func ytdKey(employeeID string, year int, currency string) string {
+	return fmt.Sprintf("%s|%d|%s", employeeID, year, currency)
+}
+
+// readYTDSeed reads the -ytd-seed CSV: Employee ID, Year, Gross, Social Security
+// Wages, Medicare Wages and Federal Withheld, located by header, and optionally
+// Currency (blank for the run's), Federal Taxable Wages, and "<name> Earnings"
+// for each contribution with a wage base. The result is keyed by ytdKey.
+func readYTDSeed(filename string, opts ReaderOptions) (map[string]YTDSeed, error) {
+	if opts.NoHeader {
+		return nil, fmt.Errorf("a YTD seed file must have a header row")
+	}
+	seeds := make(map[string]YTDSeed)
+	err := readCSV(filename, "ytd seed", opts, func(cols columnMap, row []string, line int) error {
+		id := opts.employeeID(row[0])
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("missing Employee ID in row %d", line)
+		}
+		year, err := strconv.Atoi(strings.TrimSpace(cols.value(row, "Year")))
+		if err != nil || year < 1 {
+			return fmt.Errorf("error parsing Year in row %d: want a calendar year, got %q", line, cols.value(row, "Year"))
+		}
+		key := ytdKey(id, year, strings.ToUpper(strings.TrimSpace(cols.value(row, "Currency"))))
+		if _, ok := seeds[key]; ok {
+			return fmt.Errorf("employee %s is listed twice for %d (row %d)", id, year, line)
+		}
+		seed := YTDSeed{Contributions: make(map[string]Money)}
+		for _, field := range []struct {
+			name string
+			dst  *Money
+		}{
+			{"Gross", &seed.Gross},
+			{"Social Security Wages", &seed.SocialSecurityWages},
+			{"Medicare Wages", &seed.MedicareWages},
+			{"Federal Withheld", &seed.FederalWithheld},
+		} {
+			if _, ok := cols.lookup(field.name); !ok {
+				return fmt.Errorf("YTD seed file has no %s column", field.name)
+			}
+			if *field.dst, err = opts.money(cols.value(row, field.name)); err != nil || *field.dst < 0 {
+				return fmt.Errorf("error parsing %s in row %d: want a non-negative amount, got %q", field.name, line, cols.value(row, field.name))
+			}
+		}
+		seed.FederalTaxableWages = seed.Gross
+		if v := strings.TrimSpace(cols.value(row, "Federal Taxable Wages")); v != "" {
+			if seed.FederalTaxableWages, err = opts.money(v); err != nil || seed.FederalTaxableWages < 0 {
+				return fmt.Errorf("error parsing Federal Taxable Wages in row %d: want a non-negative amount, got %q", line, v)
+			}
+		}
+		for _, column := range cols.names {
+			name, ok := strings.CutSuffix(strings.TrimSpace(column), " Earnings")
+			if !ok || name == "" {
+				continue
+			}
+			v := strings.TrimSpace(cols.value(row, column))
+			if v == "" {
+				continue
+			}
+			earnings, err := opts.money(v)
+			if err != nil || earnings < 0 {
+				return fmt.Errorf("error parsing %s in row %d: want a non-negative amount, got %q", column, line, v)
+			}
+			seed.Contributions[name] = earnings
+		}
+		seeds[key] = seed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// seedLevied starts computeRegister's Social Security wages (keyed
+// employee|year|currency) and contribution earnings (keyed
+// employee|year|currency|contribution) from the seeds.
+func seedLevied(levied map[string]Money, seeds map[string]YTDSeed) {
+	for key, seed := range seeds {
+		levied[key] = seed.SocialSecurityWages
+		for name, earnings := range seed.Contributions {
+			levied[key+"|"+name] = earnings
+		}
+	}
+}